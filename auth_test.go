@@ -0,0 +1,192 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MrAlias/collex"
+	"go.opentelemetry.io/collector/client"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/configauth"
+	"go.opentelemetry.io/collector/config/confighttp"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/extension"
+	"go.opentelemetry.io/collector/extension/auth"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type httpConfig struct {
+	confighttp.ClientConfig `mapstructure:",squash"`
+}
+
+// httpExporter is a collector exporter that, like the otlphttp exporter,
+// posts the spans it receives to an HTTP endpoint using a client created from
+// its confighttp settings.
+func httpExporter() exporter.Factory {
+	return exporter.NewFactory(
+		component.MustNewType("http"),
+		func() component.Config { return &httpConfig{ClientConfig: confighttp.NewDefaultClientConfig()} },
+		exporter.WithTraces(func(_ context.Context, set exporter.Settings, cfg component.Config) (exporter.Traces, error) {
+			hCfg := cfg.(*httpConfig)
+			var client *http.Client
+			start := func(ctx context.Context, host component.Host) (err error) {
+				client, err = hCfg.ToClient(ctx, host, set.TelemetrySettings)
+				return err
+			}
+			marshaler := &ptrace.ProtoMarshaler{}
+			c, err := consumer.NewTraces(func(ctx context.Context, td ptrace.Traces) error {
+				body, err := marshaler.MarshalTraces(td)
+				if err != nil {
+					return err
+				}
+				req, err := http.NewRequestWithContext(ctx, http.MethodPost, hCfg.Endpoint, bytes.NewReader(body))
+				if err != nil {
+					return err
+				}
+				resp, err := client.Do(req)
+				if err != nil {
+					return err
+				}
+				defer resp.Body.Close()
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("export failed: %s", resp.Status)
+				}
+				return nil
+			})
+			return tracesComponent{testComponent: testComponent{StartFunc: start}, Traces: c}, err
+		}, component.StabilityLevelDevelopment),
+	)
+}
+
+// clientAuth is a collector client authenticator extension that, like the
+// oauth2client or headers_setter extensions, calls set with every request
+// before it is sent.
+func clientAuth(set func(*http.Request)) extension.Factory {
+	return extension.NewFactory(
+		component.MustNewType("clientauth"),
+		createEmptyConfig,
+		func(context.Context, extension.Settings, component.Config) (extension.Extension, error) {
+			return auth.NewClient(auth.WithClientRoundTripper(func(base http.RoundTripper) (http.RoundTripper, error) {
+				return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+					req = req.Clone(req.Context())
+					set(req)
+					return base.RoundTrip(req)
+				}), nil
+			})), nil
+		},
+		component.StabilityLevelDevelopment,
+	)
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (fn roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return fn(req)
+}
+
+// authServer returns a server that only accepts requests with the header
+// key set to value.
+func authServer(t *testing.T, key, value string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get(key); got != value {
+			t.Errorf("%s header = %q, want %q", key, got, value)
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestPipelineFromYAMLAuthenticator(t *testing.T) {
+	srv := authServer(t, "Authorization", "Bearer secret")
+	conf := fmt.Sprintf(`
+extensions:
+  clientauth:
+exporters:
+  http:
+    endpoint: %s
+    auth:
+      authenticator: clientauth
+service:
+  extensions: [clientauth]
+  pipelines:
+    traces:
+      exporters: [http]
+`, srv.URL)
+
+	expF := httpExporter()
+	extF := clientAuth(func(req *http.Request) { req.Header.Set("Authorization", "Bearer secret") })
+	factories := collex.Factories{
+		Exporters:  map[component.Type]exporter.Factory{expF.Type(): expF},
+		Extensions: map[component.Type]extension.Factory{extF.Type(): extF},
+	}
+
+	ctx := context.Background()
+	p, err := collex.PipelineFromYAML(ctx, factories, []byte(conf), settings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans := tracetest.SpanStubs{{Name: "a", Resource: resource.Empty()}}.Snapshots()
+	if err := p.SpanExporter().ExportSpans(ctx, spans); err != nil {
+		t.Error(err)
+	}
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFactoryAuthenticatorMetadata(t *testing.T) {
+	srv := authServer(t, "X-Scope-OrgID", "acme")
+
+	// Set the header from the metadata of the export, like headers_setter
+	// configured with from_context.
+	extF := clientAuth(func(req *http.Request) {
+		if v := client.FromContext(req.Context()).Metadata.Get("tenant"); len(v) > 0 {
+			req.Header.Set("X-Scope-OrgID", v[0])
+		}
+	})
+	f, err := collex.NewFactory(httpExporter(), settings(), collex.WithExtensions(
+		collex.Extension{Factory: extF},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &httpConfig{ClientConfig: confighttp.NewDefaultClientConfig()}
+	cfg.Endpoint = srv.URL
+	cfg.Auth = &configauth.Authentication{AuthenticatorID: component.NewID(extF.Type())}
+
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenantCtx := collex.ContextWithMetadata(ctx, map[string][]string{"tenant": {"acme"}})
+	spans := tracetest.SpanStubs{{Name: "a", Resource: resource.Empty()}}.Snapshots()
+	if err := exp.ExportSpans(tenantCtx, spans); err != nil {
+		t.Error(err)
+	}
+	if err := exp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
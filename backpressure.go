@@ -0,0 +1,126 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/exporter/exporterqueue"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+// BackpressureConfig configures what an export does when the sending queue
+// of the wrapped exporter is full. Blocking suits batch jobs that must not
+// lose telemetry, dropping suits latency sensitive request paths.
+type BackpressureConfig struct {
+	// Drop drops the exports refused by a full sending queue, counted by
+	// the collex.exporter.dropped metric, instead of blocking them until
+	// the queue has room. Blocking sets the blocking setting of the
+	// sending_queue of the exporter configuration.
+	Drop bool
+	// LogSampling logs the first of every LogSampling dropped exports. No
+	// dropped exports are logged if it is not positive.
+	LogSampling int
+}
+
+// blockingQueue is the override of the sending queue of an exporter that
+// blocks when it is full.
+var blockingQueue = map[string]any{"sending_queue": map[string]any{"blocking": true}}
+
+// dropper drops the exports refused by the full sending queue of an exporter.
+type dropper struct {
+	cfg     BackpressureConfig
+	log     *zap.Logger
+	rec     exportRecorder
+	dropped atomic.Int64
+}
+
+// newDropper returns the dropper of the exports to the exporter with id
+// recorded with rec, or nil if cfg does not drop them.
+func newDropper(cfg *BackpressureConfig, log *zap.Logger, id component.ID, rec exportRecorder) *dropper {
+	if cfg == nil || !cfg.Drop {
+		return nil
+	}
+	return &dropper{cfg: *cfg, log: log.With(zap.String("exporter", id.String())), rec: rec}
+}
+
+// drop returns err, or nil if err is from a full sending queue and the export
+// of n items is dropped.
+func (d *dropper) drop(ctx context.Context, n int, err error) error {
+	if !errors.Is(err, exporterqueue.ErrQueueIsFull) {
+		return err
+	}
+	d.rec.dropped(ctx, n, "queue_full")
+	total := d.dropped.Add(1)
+	if d.cfg.LogSampling > 0 && (total-1)%int64(d.cfg.LogSampling) == 0 {
+		d.log.Warn("Dropped telemetry, the sending queue is full", zap.Int("items", n), zap.Int64("dropped_exports", total))
+	}
+	return nil
+}
+
+// dropTraces returns next dropping the traces its full sending queue refuses
+// with d. It returns next if d is nil.
+func dropTraces(d *dropper, next consumer.Traces) consumer.Traces {
+	if d == nil {
+		return next
+	}
+	c, err := consumer.NewTraces(func(ctx context.Context, td ptrace.Traces) error {
+		return d.drop(ctx, td.SpanCount(), next.ConsumeTraces(ctx, td))
+	}, consumer.WithCapabilities(next.Capabilities()))
+	if err != nil {
+		// Only returned for a nil function.
+		panic(err)
+	}
+	return c
+}
+
+// dropMetrics returns next dropping the metrics its full sending queue
+// refuses with d. It returns next if d is nil.
+func dropMetrics(d *dropper, next consumer.Metrics) consumer.Metrics {
+	if d == nil {
+		return next
+	}
+	c, err := consumer.NewMetrics(func(ctx context.Context, md pmetric.Metrics) error {
+		return d.drop(ctx, md.DataPointCount(), next.ConsumeMetrics(ctx, md))
+	}, consumer.WithCapabilities(next.Capabilities()))
+	if err != nil {
+		// Only returned for a nil function.
+		panic(err)
+	}
+	return c
+}
+
+// dropLogs returns next dropping the logs its full sending queue refuses with
+// d. It returns next if d is nil.
+func dropLogs(d *dropper, next consumer.Logs) consumer.Logs {
+	if d == nil {
+		return next
+	}
+	c, err := consumer.NewLogs(func(ctx context.Context, ld plog.Logs) error {
+		return d.drop(ctx, ld.LogRecordCount(), next.ConsumeLogs(ctx, ld))
+	}, consumer.WithCapabilities(next.Capabilities()))
+	if err != nil {
+		// Only returned for a nil function.
+		panic(err)
+	}
+	return c
+}
@@ -0,0 +1,143 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/MrAlias/collex"
+	"github.com/MrAlias/collex/collextest"
+	"go.opentelemetry.io/collector/exporter/exporterqueue"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// stuckQueue returns the configuration of a mock exporter with a sending
+// queue of a single export, consumed by a single consumer.
+func stuckQueue() *collex.HelperConfig {
+	cfg := collex.NewHelperConfig()
+	cfg.RetryConfig.Enabled = false
+	cfg.QueueConfig.QueueSize = 1
+	cfg.QueueConfig.NumConsumers = 1
+	return cfg
+}
+
+func TestWithBackpressureDrop(t *testing.T) {
+	release := make(chan struct{})
+	mock := collextest.NewMockExporter(collextest.Block(release), collextest.Block(release))
+	set, reader := meteredSettings()
+	f, err := collex.NewFactory(mock.Factory(), set, collex.WithBackpressure(collex.BackpressureConfig{Drop: true, LogSampling: 2}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, stuckQueue())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = exp.Shutdown(ctx) }()
+	defer close(release)
+
+	// The consumer is stuck on an export and at most one more is queued.
+	const exports = 5
+	for range exports {
+		if err := exp.ExportSpans(ctx, spans(1)); err != nil {
+			t.Fatalf("export error = %v, want it dropped", err)
+		}
+	}
+
+	got := collect(t, reader, collexScope)["collex.exporter.dropped"]
+	sum, ok := got.(metricdata.Sum[int64])
+	if !ok || len(sum.DataPoints) != 1 {
+		t.Fatalf("dropped: %v", got)
+	}
+	want := attribute.NewSet(
+		attribute.String("exporter", "mock"),
+		attribute.String("signal", "traces"),
+		attribute.String("reason", "queue_full"),
+	)
+	if dp := sum.DataPoints[0]; !dp.Attributes.Equals(&want) || dp.Value < exports-2 {
+		t.Errorf("dropped %d spans with %v, want at least %d", dp.Value, dp.Attributes.ToSlice(), exports-2)
+	}
+}
+
+func TestWithBackpressureBlock(t *testing.T) {
+	release := make(chan struct{})
+	mock := collextest.NewMockExporter(collextest.Block(release), collextest.Block(release))
+	f, err := collex.NewFactory(mock.Factory(), settings(), collex.WithBackpressure(collex.BackpressureConfig{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, stuckQueue())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		for range 3 {
+			if err := exp.ExportSpans(ctx, spans(1)); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("exports to a full queue returned: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if err := exp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(mock.Traces()); got != 3 {
+		t.Errorf("exported %d times, want 3", got)
+	}
+}
+
+func TestWithoutBackpressure(t *testing.T) {
+	release := make(chan struct{})
+	mock := collextest.NewMockExporter(collextest.Block(release), collextest.Block(release))
+	f, err := collex.NewFactory(mock.Factory(), settings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, stuckQueue())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = exp.Shutdown(ctx) }()
+	defer close(release)
+
+	for range 5 {
+		if err = exp.ExportSpans(ctx, spans(1)); err != nil {
+			break
+		}
+	}
+	if !errors.Is(err, exporterqueue.ErrQueueIsFull) {
+		t.Errorf("export error = %v, want %v", err, exporterqueue.ErrQueueIsFull)
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+// ErrCircuitOpen is the error of the exports refused by the circuit breaker
+// of an exporter while it is open.
+var ErrCircuitOpen = errors.New("collex: circuit breaker open")
+
+// CircuitBreakerConfig configures the circuit breaker of an exporter. While
+// the backend of the exporter is failing, the breaker fails exports fast
+// instead of having each one wait on the exporter, protecting the latency of
+// the application when the exporter lacks good timeouts.
+//
+// The breaker opens after Failures consecutive failed exports. Exports fail
+// with ErrCircuitOpen while it is open. Once OpenDuration has passed it is
+// half-open: a single export is let through to probe the exporter, closing
+// the breaker if it succeeds and opening it again if it fails.
+//
+// Permanent errors, e.g. telemetry the backend rejected as invalid, show the
+// backend is up and are not counted as failures.
+type CircuitBreakerConfig struct {
+	// Failures is the number of consecutive failed exports that opens the
+	// breaker. The breaker is disabled if it is not positive.
+	Failures int
+	// OpenDuration is the time the breaker stays open before it is
+	// half-open.
+	OpenDuration time.Duration
+}
+
+// NewCircuitBreakerConfig returns a CircuitBreakerConfig that opens after 5
+// consecutive failed exports and probes the exporter every 30 seconds.
+func NewCircuitBreakerConfig() CircuitBreakerConfig {
+	return CircuitBreakerConfig{Failures: 5, OpenDuration: 30 * time.Second}
+}
+
+// breaker is the circuit breaker of the exports of a signal to an exporter.
+type breaker struct {
+	cfg CircuitBreakerConfig
+	log *zap.Logger
+	now func() time.Time
+
+	mu sync.Mutex
+	// failures are the consecutive failed exports. The breaker is open if
+	// there are at least cfg.Failures of them.
+	failures int
+	openedAt time.Time
+	// probing is true while the probe of the half-open breaker is exported.
+	probing bool
+}
+
+// newBreaker returns the breaker of the exports to the exporter with id, or
+// nil if cfg disables it.
+func newBreaker(cfg CircuitBreakerConfig, log *zap.Logger, id component.ID, now func() time.Time) *breaker {
+	if cfg.Failures <= 0 {
+		return nil
+	}
+	return &breaker{cfg: cfg, log: log.With(zap.String("exporter", id.String())), now: now}
+}
+
+// allow returns whether an export is let through, and whether it is the
+// probe of the half-open breaker.
+func (b *breaker) allow() (ok, probe bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.cfg.Failures {
+		return true, false
+	}
+	if b.probing || b.now().Sub(b.openedAt) < b.cfg.OpenDuration {
+		return false, false
+	}
+	b.probing = true
+	return true, true
+}
+
+// done records the result of an export let through by allow.
+func (b *breaker) done(probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if probe {
+		b.probing = false
+	}
+	if err == nil || consumererror.IsPermanent(err) {
+		if b.failures >= b.cfg.Failures {
+			b.log.Info("Circuit breaker closed")
+		}
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.cfg.Failures {
+		if b.failures == b.cfg.Failures {
+			b.log.Warn("Circuit breaker opened", zap.Error(err))
+		}
+		b.openedAt = b.now()
+	}
+}
+
+// export exports with fn if the breaker lets it through.
+func (b *breaker) export(fn func() error) error {
+	ok, probe := b.allow()
+	if !ok {
+		return ErrCircuitOpen
+	}
+	err := fn()
+	b.done(probe, err)
+	return err
+}
+
+// breakTraces returns next guarded by b. It returns next if b is nil.
+func breakTraces(b *breaker, next consumer.Traces) consumer.Traces {
+	if b == nil {
+		return next
+	}
+	c, err := consumer.NewTraces(func(ctx context.Context, td ptrace.Traces) error {
+		return b.export(func() error { return next.ConsumeTraces(ctx, td) })
+	}, consumer.WithCapabilities(next.Capabilities()))
+	if err != nil {
+		// Only returned for a nil function.
+		panic(err)
+	}
+	return c
+}
+
+// breakMetrics returns next guarded by b. It returns next if b is nil.
+func breakMetrics(b *breaker, next consumer.Metrics) consumer.Metrics {
+	if b == nil {
+		return next
+	}
+	c, err := consumer.NewMetrics(func(ctx context.Context, md pmetric.Metrics) error {
+		return b.export(func() error { return next.ConsumeMetrics(ctx, md) })
+	}, consumer.WithCapabilities(next.Capabilities()))
+	if err != nil {
+		// Only returned for a nil function.
+		panic(err)
+	}
+	return c
+}
+
+// breakLogs returns next guarded by b. It returns next if b is nil.
+func breakLogs(b *breaker, next consumer.Logs) consumer.Logs {
+	if b == nil {
+		return next
+	}
+	c, err := consumer.NewLogs(func(ctx context.Context, ld plog.Logs) error {
+		return b.export(func() error { return next.ConsumeLogs(ctx, ld) })
+	}, consumer.WithCapabilities(next.Capabilities()))
+	if err != nil {
+		// Only returned for a nil function.
+		panic(err)
+	}
+	return c
+}
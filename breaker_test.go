@@ -0,0 +1,120 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/MrAlias/collex"
+	"github.com/MrAlias/collex/collextest"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// backend is a collector exporter that fails with err, if not nil.
+type backend struct {
+	err   error
+	calls int
+}
+
+func (b *backend) factory() exporter.Factory {
+	return exporter.NewFactory(
+		component.MustNewType("backend"),
+		createEmptyConfig,
+		exporter.WithTraces(func(context.Context, exporter.Settings, component.Config) (exporter.Traces, error) {
+			c, err := consumer.NewTraces(func(context.Context, ptrace.Traces) error {
+				b.calls++
+				return b.err
+			})
+			return tracesComponent{Traces: c}, err
+		}, component.StabilityLevelDevelopment),
+	)
+}
+
+func TestWithCircuitBreaker(t *testing.T) {
+	b := &backend{err: errBroken}
+	clock := collextest.NewFakeClock(time.Now())
+	cfg := collex.CircuitBreakerConfig{Failures: 2, OpenDuration: time.Minute}
+	f, err := collex.NewFactory(b.factory(), settings(), collex.WithCircuitBreaker(cfg), collex.WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = exp.Shutdown(ctx) }()
+
+	spans := tracetest.SpanStubs{{Name: "span"}}.Snapshots()
+	export := func(want error, calls int) {
+		t.Helper()
+		if err := exp.ExportSpans(ctx, spans); !errors.Is(err, want) {
+			t.Errorf("export error = %v, want %v", err, want)
+		}
+		if b.calls != calls {
+			t.Errorf("exporter called %d times, want %d", b.calls, calls)
+		}
+	}
+
+	export(errBroken, 1)
+	export(errBroken, 2)
+	// Open: exports fail fast.
+	export(collex.ErrCircuitOpen, 2)
+
+	// Half-open: the failed probe opens the breaker again.
+	clock.Advance(time.Minute)
+	export(errBroken, 3)
+	export(collex.ErrCircuitOpen, 3)
+
+	// Half-open: the probe succeeds and closes the breaker.
+	b.err = nil
+	clock.Advance(time.Minute)
+	export(nil, 4)
+	export(nil, 5)
+}
+
+func TestWithCircuitBreakerPermanent(t *testing.T) {
+	b := &backend{err: consumererror.NewPermanent(errBroken)}
+	cfg := collex.CircuitBreakerConfig{Failures: 1, OpenDuration: time.Hour}
+	f, err := collex.NewFactory(b.factory(), settings(), collex.WithCircuitBreaker(cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = exp.Shutdown(ctx) }()
+
+	// The backend is up, it rejects the telemetry.
+	spans := tracetest.SpanStubs{{Name: "span"}}.Snapshots()
+	for range 3 {
+		if err := exp.ExportSpans(ctx, spans); !errors.Is(err, errBroken) {
+			t.Fatalf("export error = %v, want %v", err, errBroken)
+		}
+	}
+	if b.calls != 3 {
+		t.Errorf("exporter called %d times, want 3", b.calls)
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package clickhouse provides OpenTelemetry Go exporters sending telemetry to
+// ClickHouse with the ClickHouse exporter of the collector contrib
+// repository, configured with Go options instead of a collector
+// configuration.
+package clickhouse // import "github.com/MrAlias/collex/clickhouse"
+
+import (
+	"context"
+	"time"
+
+	"github.com/MrAlias/collex"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/clickhouseexporter"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Option configures the exporters.
+type Option interface {
+	apply(config) config
+}
+
+type config struct {
+	// conf is the collector configuration of the ClickHouse exporter.
+	conf map[string]any
+	set  *exporter.Settings
+	opts []collex.Option
+}
+
+type optionFunc func(config) config
+
+func (fn optionFunc) apply(c config) config {
+	return fn(c)
+}
+
+// set returns an Option setting the configuration key to v.
+func set(key string, v any) Option {
+	return optionFunc(func(c config) config {
+		c.conf[key] = v
+		return c
+	})
+}
+
+// WithDSN returns an Option that sets the data source name of the ClickHouse
+// server, e.g. tcp://clickhouse:9000?dial_timeout=10s.
+func WithDSN(dsn string) Option {
+	return set("endpoint", dsn)
+}
+
+// WithCredentials returns an Option that sets the username and password the
+// exporters authenticate with.
+func WithCredentials(username, password string) Option {
+	return optionFunc(func(c config) config {
+		c.conf["username"], c.conf["password"] = username, password
+		return c
+	})
+}
+
+// WithDatabase returns an Option that sets the database the telemetry is
+// written to. The otel database is used by default.
+func WithDatabase(name string) Option {
+	return set("database", name)
+}
+
+// WithTTL returns an Option that sets the time the telemetry is kept for
+// before ClickHouse deletes it. It is kept indefinitely by default.
+func WithTTL(ttl time.Duration) Option {
+	return set("ttl", ttl)
+}
+
+// WithTracesTable returns an Option that sets the name of the table of the
+// spans.
+func WithTracesTable(name string) Option {
+	return set("traces_table_name", name)
+}
+
+// WithLogsTable returns an Option that sets the name of the table of the log
+// records.
+func WithLogsTable(name string) Option {
+	return set("logs_table_name", name)
+}
+
+// WithMetricsTable returns an Option that sets the prefix of the names of the
+// tables of the metrics, one for each metric type: the gauges are written to
+// the prefix_gauge table, the sums to prefix_sum, and likewise for the
+// summary, histogram, and exponential_histogram types.
+func WithMetricsTable(prefix string) Option {
+	tables := make(map[string]any, len(metricTypes))
+	for _, typ := range metricTypes {
+		tables[typ] = map[string]any{"name": prefix + "_" + typ}
+	}
+	return set("metrics_tables", tables)
+}
+
+// metricTypes are the metric types of the tables of the metrics.
+var metricTypes = []string{"gauge", "sum", "summary", "histogram", "exponential_histogram"}
+
+// WithAsyncInsert returns an Option that sets whether ClickHouse inserts the
+// telemetry asynchronously, buffering the inserts of many exports.
+func WithAsyncInsert(enabled bool) Option {
+	return set("async_insert", enabled)
+}
+
+// WithCreateSchema returns an Option that sets whether the exporters create
+// the database and tables when they start.
+func WithCreateSchema(create bool) Option {
+	return set("create_schema", create)
+}
+
+// WithSettings returns an Option that sets the settings the ClickHouse
+// exporter is created with, e.g. its logger. Default settings are used
+// otherwise, like with collex.NewFactory.
+func WithSettings(set *exporter.Settings) Option {
+	return optionFunc(func(c config) config {
+		c.set = set
+		return c
+	})
+}
+
+// WithFactoryOptions returns an Option that configures the collex.Factory
+// wrapping the ClickHouse exporter with opts, e.g. collex.WithRetry.
+func WithFactoryOptions(opts ...collex.Option) Option {
+	return optionFunc(func(c config) config {
+		c.opts = append(c.opts, opts...)
+		return c
+	})
+}
+
+// newFactory returns the Factory of the ClickHouse exporter and its
+// configuration set by opts.
+func newFactory(opts []Option) (*collex.Factory, component.Config, error) {
+	c := config{conf: make(map[string]any)}
+	for _, opt := range opts {
+		c = opt.apply(c)
+	}
+	ch := clickhouseexporter.NewFactory()
+	cfg, err := collex.ConfigFromMap(ch, c.conf)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := collex.NewFactory(ch, c.set, c.opts...)
+	return f, cfg, err
+}
+
+// NewSpanExporter returns a SpanExporter writing spans to ClickHouse.
+func NewSpanExporter(ctx context.Context, opts ...Option) (trace.SpanExporter, error) {
+	f, cfg, err := newFactory(opts)
+	if err != nil {
+		return nil, err
+	}
+	return f.SpanExporter(ctx, cfg)
+}
+
+// NewMetricExporter returns a metric Exporter writing metrics to ClickHouse.
+func NewMetricExporter(ctx context.Context, opts ...Option) (metric.Exporter, error) {
+	f, cfg, err := newFactory(opts)
+	if err != nil {
+		return nil, err
+	}
+	return f.MetricExporter(ctx, cfg)
+}
+
+// NewLogExporter returns a log Exporter writing log records to ClickHouse.
+func NewLogExporter(ctx context.Context, opts ...Option) (log.Exporter, error) {
+	f, cfg, err := newFactory(opts)
+	if err != nil {
+		return nil, err
+	}
+	return f.LogExporter(ctx, cfg)
+}
@@ -0,0 +1,81 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clickhouse
+
+import (
+	"testing"
+	"time"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/clickhouseexporter"
+)
+
+func exporterConfig(t *testing.T, opts ...Option) *clickhouseexporter.Config {
+	t.Helper()
+	_, cfg, err := newFactory(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg.(*clickhouseexporter.Config)
+}
+
+func TestOptions(t *testing.T) {
+	cfg := exporterConfig(t,
+		WithDSN("tcp://clickhouse:9000"),
+		WithCredentials("user", "secret"),
+		WithDatabase("telemetry"),
+		WithTTL(72*time.Hour),
+		WithTracesTable("spans"),
+		WithLogsTable("records"),
+		WithAsyncInsert(true),
+		WithCreateSchema(false),
+	)
+	if cfg.Endpoint != "tcp://clickhouse:9000" {
+		t.Errorf("endpoint = %q, want tcp://clickhouse:9000", cfg.Endpoint)
+	}
+	if cfg.Username != "user" || string(cfg.Password) != "secret" {
+		t.Errorf("credentials = %q, %q, want user, secret", cfg.Username, cfg.Password)
+	}
+	if cfg.Database != "telemetry" {
+		t.Errorf("database = %q, want telemetry", cfg.Database)
+	}
+	if cfg.TTL != 72*time.Hour {
+		t.Errorf("TTL = %v, want 72h", cfg.TTL)
+	}
+	if cfg.TracesTableName != "spans" || cfg.LogsTableName != "records" {
+		t.Errorf("tables = %q, %q, want spans, records", cfg.TracesTableName, cfg.LogsTableName)
+	}
+	if !cfg.AsyncInsert || cfg.CreateSchema {
+		t.Errorf("async insert = %t, create schema = %t, want true, false", cfg.AsyncInsert, cfg.CreateSchema)
+	}
+}
+
+func TestWithMetricsTable(t *testing.T) {
+	cfg := exporterConfig(t, WithDSN("tcp://clickhouse:9000"), WithMetricsTable("app_metrics"))
+	if cfg.MetricsTableName != "" {
+		t.Errorf("deprecated metrics_table_name set to %q", cfg.MetricsTableName)
+	}
+	tables := map[string]string{
+		"gauge":                 cfg.MetricsTables.Gauge.Name,
+		"sum":                   cfg.MetricsTables.Sum.Name,
+		"summary":               cfg.MetricsTables.Summary.Name,
+		"histogram":             cfg.MetricsTables.Histogram.Name,
+		"exponential_histogram": cfg.MetricsTables.ExponentialHistogram.Name,
+	}
+	for typ, name := range tables {
+		if want := "app_metrics_" + typ; name != want {
+			t.Errorf("%s table = %q, want %q", typ, name, want)
+		}
+	}
+}
@@ -0,0 +1,387 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/configcompression"
+	"go.opentelemetry.io/collector/config/configgrpc"
+	"go.opentelemetry.io/collector/config/confighttp"
+	"go.opentelemetry.io/collector/config/configopaque"
+	"go.opentelemetry.io/collector/config/configtls"
+	"go.opentelemetry.io/collector/exporter/exporterhelper"
+)
+
+// ClientSetting is a setting of the HTTP or gRPC client of an exporter.
+type ClientSetting interface {
+	apply(clientConfig) error
+}
+
+// clientConfig holds the client settings found in an exporter configuration.
+type clientConfig struct {
+	http    *confighttp.ClientConfig
+	grpc    *configgrpc.ClientConfig
+	timeout *exporterhelper.TimeoutConfig
+}
+
+// tls returns the TLS settings of the client.
+func (c clientConfig) tls() *configtls.ClientConfig {
+	if c.http != nil {
+		return &c.http.TLSSetting
+	}
+	return &c.grpc.TLSSetting
+}
+
+type clientSettingFunc func(clientConfig) error
+
+func (fn clientSettingFunc) apply(c clientConfig) error {
+	return fn(c)
+}
+
+// ConfigureClient applies settings to the confighttp or configgrpc client
+// settings embedded in cfg, the configuration of an exporter like the otlp
+// and otlphttp exporters. It lets common client tuning be done without
+// knowing where each exporter keeps its client settings.
+//
+//	cfg, err := collex.Configure(otlpexporter.NewFactory(), func(cfg *otlpexporter.Config) error {
+//		return collex.ConfigureClient(cfg,
+//			collex.ClientCompression(configcompression.TypeZstd),
+//			collex.ClientTimeout(10*time.Second),
+//		)
+//	})
+//
+// An error is returned if cfg does not embed client settings or a setting
+// is not supported by the client.
+func ConfigureClient(cfg component.Config, settings ...ClientSetting) error {
+	c, err := findClient(cfg)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, s := range settings {
+		errs = append(errs, s.apply(c))
+	}
+	return errors.Join(errs...)
+}
+
+var (
+	httpClientType = reflect.TypeFor[confighttp.ClientConfig]()
+	grpcClientType = reflect.TypeFor[configgrpc.ClientConfig]()
+	timeoutType    = reflect.TypeFor[exporterhelper.TimeoutConfig]()
+)
+
+// findClient returns the client settings of cfg.
+func findClient(cfg component.Config) (clientConfig, error) {
+	var c clientConfig
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return c, fmt.Errorf("client settings: configuration %T is not a pointer to a struct", cfg)
+	}
+	c.find(v.Elem())
+	if c.http == nil && c.grpc == nil {
+		return c, fmt.Errorf("client settings: no HTTP or gRPC client settings in %T", cfg)
+	}
+	return c, nil
+}
+
+// find sets the first client settings and timeout settings in the fields of
+// the struct v, searched depth-first.
+func (c *clientConfig) find(v reflect.Value) {
+	for i := range v.NumField() {
+		f := v.Field(i)
+		if !v.Type().Field(i).IsExported() {
+			continue
+		}
+		switch f.Type() {
+		case httpClientType:
+			if c.http == nil && c.grpc == nil {
+				c.http = f.Addr().Interface().(*confighttp.ClientConfig)
+			}
+		case grpcClientType:
+			if c.http == nil && c.grpc == nil {
+				c.grpc = f.Addr().Interface().(*configgrpc.ClientConfig)
+			}
+		case timeoutType:
+			if c.timeout == nil {
+				c.timeout = f.Addr().Interface().(*exporterhelper.TimeoutConfig)
+			}
+		default:
+			if f.Kind() == reflect.Struct {
+				c.find(f)
+			}
+		}
+	}
+}
+
+// ClientCompression returns a ClientSetting that compresses the requests of
+// the client with the compression t, e.g. configcompression.TypeGzip.
+func ClientCompression(t configcompression.Type) ClientSetting {
+	return clientSettingFunc(func(c clientConfig) error {
+		if c.http != nil {
+			c.http.Compression = t
+		} else {
+			c.grpc.Compression = t
+		}
+		return nil
+	})
+}
+
+// ClientProxy returns a ClientSetting that sends the requests of an HTTP
+// client through the proxy at url. gRPC clients use the proxy set by the
+// HTTPS_PROXY environment variable instead, and return an error.
+func ClientProxy(url string) ClientSetting {
+	return clientSettingFunc(func(c clientConfig) error {
+		if c.http == nil {
+			return errors.New("client settings: proxy is not supported by gRPC clients, use the HTTPS_PROXY environment variable")
+		}
+		c.http.ProxyURL = url
+		return nil
+	})
+}
+
+// ClientKeepalive returns a ClientSetting that pings the server when a
+// connection has been idle for the duration d, and closes the connection if
+// the ping is not answered within timeout. HTTP clients ping HTTP/2
+// connections.
+func ClientKeepalive(d, timeout time.Duration) ClientSetting {
+	return clientSettingFunc(func(c clientConfig) error {
+		if c.http != nil {
+			c.http.HTTP2ReadIdleTimeout = d
+			c.http.HTTP2PingTimeout = timeout
+			return nil
+		}
+		if c.grpc.Keepalive == nil {
+			c.grpc.Keepalive = configgrpc.NewDefaultKeepaliveClientConfig()
+		}
+		c.grpc.Keepalive.Time = d
+		c.grpc.Keepalive.Timeout = timeout
+		return nil
+	})
+}
+
+// ClientTimeout returns a ClientSetting that bounds each export to the
+// duration d, both the HTTP request of an HTTP client and the export timeout
+// of the exporter, if it has one.
+func ClientTimeout(d time.Duration) ClientSetting {
+	return clientSettingFunc(func(c clientConfig) error {
+		if c.http != nil {
+			c.http.Timeout = d
+		}
+		if c.timeout != nil {
+			c.timeout.Timeout = d
+		} else if c.http == nil {
+			return errors.New("client settings: timeout is not supported by the exporter")
+		}
+		return nil
+	})
+}
+
+// ClientHeaders returns a ClientSetting that adds headers to every request
+// of the client. The headers replace configured headers with the same name.
+func ClientHeaders(headers map[string]string) ClientSetting {
+	return clientSettingFunc(func(c clientConfig) error {
+		var h *map[string]configopaque.String
+		if c.http != nil {
+			h = &c.http.Headers
+		} else {
+			h = &c.grpc.Headers
+		}
+		if *h == nil {
+			*h = make(map[string]configopaque.String, len(headers))
+		}
+		for k, v := range headers {
+			(*h)[k] = configopaque.String(v)
+		}
+		return nil
+	})
+}
+
+// withHeaders returns e with headers set in a copy of its configuration. If
+// the configuration has no client settings, e is returned unchanged.
+func (e Exporter) withHeaders(headers map[string]string) Exporter {
+	if len(headers) == 0 {
+		return e
+	}
+	cfg := deepCopy(reflect.ValueOf(e.config())).Interface().(component.Config)
+	if ConfigureClient(cfg, ClientHeaders(headers)) != nil {
+		return e
+	}
+	e.Config = cfg
+	return e
+}
+
+// ClientTLS returns a ClientSetting that translates cfg, a TLS configuration
+// managed by the application, into the TLS settings of the client. The
+// certificate, TLS versions, cipher suites, curve preferences,
+// InsecureSkipVerify, and ServerName of cfg are used.
+//
+// The certificate pool of RootCAs cannot be read, so an error is returned if
+// it is set. The CA certificates are set with ClientCA instead. An error is
+// also returned if cfg has more than one certificate or a
+// GetClientCertificate function, neither of which are supported by the
+// client.
+func ClientTLS(cfg *tls.Config) ClientSetting {
+	return clientSettingFunc(func(c clientConfig) error {
+		var errs []error
+		if cfg.RootCAs != nil {
+			errs = append(errs, errors.New("client settings: tls: RootCAs cannot be translated, use ClientCA"))
+		}
+		if cfg.GetClientCertificate != nil {
+			errs = append(errs, errors.New("client settings: tls: GetClientCertificate is not supported"))
+		}
+		if len(cfg.Certificates) > 1 {
+			errs = append(errs, fmt.Errorf("client settings: tls: %d certificates, only one is supported", len(cfg.Certificates)))
+		}
+		if len(errs) > 0 {
+			return errors.Join(errs...)
+		}
+
+		t := c.tls()
+		if len(cfg.Certificates) == 1 {
+			if err := setCertificate(t, cfg.Certificates[0]); err != nil {
+				return err
+			}
+		}
+		var err error
+		if t.MinVersion, err = tlsVersion(cfg.MinVersion); err != nil {
+			return err
+		}
+		if t.MaxVersion, err = tlsVersion(cfg.MaxVersion); err != nil {
+			return err
+		}
+		t.CipherSuites = nil
+		for _, id := range cfg.CipherSuites {
+			t.CipherSuites = append(t.CipherSuites, tls.CipherSuiteName(id))
+		}
+		t.CurvePreferences = nil
+		for _, id := range cfg.CurvePreferences {
+			name, ok := curveNames[id]
+			if !ok {
+				return fmt.Errorf("client settings: tls: unsupported curve %s", id)
+			}
+			t.CurvePreferences = append(t.CurvePreferences, name)
+		}
+		t.Insecure = false
+		t.InsecureSkipVerify = cfg.InsecureSkipVerify
+		t.ServerName = cfg.ServerName
+		return nil
+	})
+}
+
+// ClientCertificate returns a ClientSetting that sets the certificate the
+// client presents to the server to cert, e.g. a certificate loaded from a
+// secret store rather than a file.
+func ClientCertificate(cert tls.Certificate) ClientSetting {
+	return clientSettingFunc(func(c clientConfig) error {
+		return setCertificate(c.tls(), cert)
+	})
+}
+
+// ClientCertificateReload returns a ClientSetting that reloads the
+// certificate and key files of the client every duration d, so certificates
+// rotated on disk, e.g. by a service mesh, are used without restarting the
+// exporter.
+func ClientCertificateReload(d time.Duration) ClientSetting {
+	return clientSettingFunc(func(c clientConfig) error {
+		c.tls().ReloadInterval = d
+		return nil
+	})
+}
+
+// RotateCertificate swaps exp, an exporter returned by a Factory, to its
+// configuration with the certificate the client presents to the server set
+// to cert. This rotates a certificate held in memory without restarting the
+// application, see Swap. An error is returned if exp was not returned by a
+// Factory or its configuration has no client settings.
+func RotateCertificate(ctx context.Context, exp any, cert tls.Certificate) error {
+	sup := supervisorOf(exp)
+	if sup == nil {
+		return errNotSwappable
+	}
+	cfg := deepCopy(reflect.ValueOf(sup.config())).Interface().(component.Config)
+	if err := ConfigureClient(cfg, ClientCertificate(cert)); err != nil {
+		return err
+	}
+	return sup.swap(ctx, cfg)
+}
+
+// ClientCA returns a ClientSetting that sets the CA certificates the client
+// verifies the server certificate with to certs.
+func ClientCA(certs ...*x509.Certificate) ClientSetting {
+	return clientSettingFunc(func(c clientConfig) error {
+		var b []byte
+		for _, cert := range certs {
+			b = append(b, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+		}
+		t := c.tls()
+		t.CAFile = ""
+		t.CAPem = configopaque.String(b)
+		t.Insecure = false
+		return nil
+	})
+}
+
+// setCertificate sets the certificate and key of t to the PEM encoding of
+// cert.
+func setCertificate(t *configtls.ClientConfig, cert tls.Certificate) error {
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("client settings: tls: private key: %w", err)
+	}
+	var chain []byte
+	for _, der := range cert.Certificate {
+		chain = append(chain, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	t.CertFile, t.KeyFile = "", ""
+	t.CertPem = configopaque.String(chain)
+	t.KeyPem = configopaque.String(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}))
+	t.Insecure = false
+	return nil
+}
+
+// tlsVersion returns the configtls name of the TLS version v, or "" for the
+// default version.
+func tlsVersion(v uint16) (string, error) {
+	switch v {
+	case 0:
+		return "", nil
+	case tls.VersionTLS10:
+		return "1.0", nil
+	case tls.VersionTLS11:
+		return "1.1", nil
+	case tls.VersionTLS12:
+		return "1.2", nil
+	case tls.VersionTLS13:
+		return "1.3", nil
+	}
+	return "", fmt.Errorf("client settings: tls: unsupported version %#04x", v)
+}
+
+// curveNames are the configtls names of the supported curves.
+var curveNames = map[tls.CurveID]string{
+	tls.CurveP256: "P256",
+	tls.CurveP384: "P384",
+	tls.CurveP521: "P521",
+	tls.X25519:    "X25519",
+}
@@ -0,0 +1,315 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MrAlias/collex"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/configcompression"
+	"go.opentelemetry.io/collector/config/configgrpc"
+	"go.opentelemetry.io/collector/config/confighttp"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/exporter/exporterhelper"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// grpcConfig is laid out like the configuration of the otlp exporter.
+type grpcConfig struct {
+	TimeoutConfig exporterhelper.TimeoutConfig `mapstructure:",squash"`
+	ClientConfig  configgrpc.ClientConfig      `mapstructure:",squash"`
+}
+
+func grpcExporter() exporter.Factory {
+	return exporter.NewFactory(
+		component.MustNewType("grpc"),
+		func() component.Config {
+			return &grpcConfig{
+				TimeoutConfig: exporterhelper.NewDefaultTimeoutConfig(),
+				ClientConfig:  *configgrpc.NewDefaultClientConfig(),
+			}
+		},
+	)
+}
+
+func TestConfigureClientGRPC(t *testing.T) {
+	cfg, err := collex.Configure(grpcExporter(), func(cfg *grpcConfig) error {
+		return collex.ConfigureClient(cfg,
+			collex.ClientCompression(configcompression.TypeZstd),
+			collex.ClientKeepalive(time.Minute, 5*time.Second),
+			collex.ClientTimeout(3*time.Second),
+		)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.ClientConfig.Compression; got != configcompression.TypeZstd {
+		t.Errorf("compression = %q, want zstd", got)
+	}
+	if ka := cfg.ClientConfig.Keepalive; ka == nil || ka.Time != time.Minute || ka.Timeout != 5*time.Second {
+		t.Errorf("keepalive = %+v, want 1m/5s", ka)
+	}
+	if got := cfg.TimeoutConfig.Timeout; got != 3*time.Second {
+		t.Errorf("timeout = %s, want 3s", got)
+	}
+
+	if err := collex.ConfigureClient(cfg, collex.ClientProxy("http://proxy:3128")); err == nil {
+		t.Error("expected an error for a gRPC proxy")
+	}
+}
+
+func TestConfigureClientHTTP(t *testing.T) {
+	cfg, err := collex.Configure(httpExporter(), func(cfg *httpConfig) error {
+		return collex.ConfigureClient(cfg,
+			collex.ClientCompression(configcompression.TypeGzip),
+			collex.ClientProxy("http://proxy:3128"),
+			collex.ClientKeepalive(time.Minute, 5*time.Second),
+			collex.ClientTimeout(3*time.Second),
+		)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := cfg.ClientConfig
+	if c.Compression != configcompression.TypeGzip {
+		t.Errorf("compression = %q, want gzip", c.Compression)
+	}
+	if c.ProxyURL != "http://proxy:3128" {
+		t.Errorf("proxy = %q, want http://proxy:3128", c.ProxyURL)
+	}
+	if c.HTTP2ReadIdleTimeout != time.Minute || c.HTTP2PingTimeout != 5*time.Second {
+		t.Errorf("keepalive = %s/%s, want 1m/5s", c.HTTP2ReadIdleTimeout, c.HTTP2PingTimeout)
+	}
+	if c.Timeout != 3*time.Second {
+		t.Errorf("timeout = %s, want 3s", c.Timeout)
+	}
+
+}
+
+func TestConfigureClientNoClient(t *testing.T) {
+	var e endpoints
+	if err := collex.ConfigureClient(e.factory().CreateDefaultConfig(), collex.ClientTimeout(time.Second)); err == nil {
+		t.Error("expected an error for a configuration without client settings")
+	}
+}
+
+// certificate returns a self-signed client certificate held in memory.
+func certificate(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "collex"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestConfigureClientTLS(t *testing.T) {
+	cert := certificate(t)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if peers := r.TLS.PeerCertificates; len(peers) == 0 || !bytes.Equal(peers[0].Raw, cert.Certificate[0]) {
+			t.Error("client certificate not presented")
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	tlsCfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+		CipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+	}
+	cfg, err := collex.Configure(httpExporter(), func(cfg *httpConfig) error {
+		cfg.Endpoint = srv.URL
+		return collex.ConfigureClient(cfg, collex.ClientTLS(tlsCfg), collex.ClientCA(srv.Certificate()))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.TLSSetting.MinVersion; got != "1.2" {
+		t.Errorf("min version = %q, want 1.2", got)
+	}
+	if got := cfg.TLSSetting.CipherSuites; len(got) != 1 || got[0] != "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256" {
+		t.Errorf("cipher suites = %v", got)
+	}
+
+	f, err := collex.NewFactory(httpExporter(), settings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans := tracetest.SpanStubs{{Name: "a", Resource: resource.Empty()}}.Snapshots()
+	if err := exp.ExportSpans(ctx, spans); err != nil {
+		t.Error(err)
+	}
+	if err := exp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfigureClientTLSRootCAs(t *testing.T) {
+	cfg := &httpConfig{ClientConfig: confighttp.NewDefaultClientConfig()}
+	err := collex.ConfigureClient(cfg, collex.ClientTLS(&tls.Config{RootCAs: x509.NewCertPool()}))
+	if err == nil {
+		t.Error("expected an error for RootCAs")
+	}
+}
+
+func TestFactoryWithHeaders(t *testing.T) {
+	srv := authServer(t, "X-Scope-OrgID", "acme")
+	f, err := collex.NewFactory(httpExporter(), settings(), collex.WithHeaders(map[string]string{"X-Scope-OrgID": "acme"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &httpConfig{ClientConfig: confighttp.NewDefaultClientConfig()}
+	cfg.Endpoint = srv.URL
+
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans := tracetest.SpanStubs{{Name: "a", Resource: resource.Empty()}}.Snapshots()
+	if err := exp.ExportSpans(ctx, spans); err != nil {
+		t.Error(err)
+	}
+	if err := exp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Headers) != 0 {
+		t.Errorf("configuration changed: headers = %v", cfg.Headers)
+	}
+}
+
+func TestPipelineWithHeaders(t *testing.T) {
+	srv := authServer(t, "Authorization", "Bearer secret")
+	cfg := &httpConfig{ClientConfig: confighttp.NewDefaultClientConfig()}
+	cfg.Endpoint = srv.URL
+
+	// Exporters without client settings are unchanged.
+	var s sink
+	ctx := context.Background()
+	p, err := collex.NewPipeline().
+		WithExporter(httpExporter(), cfg).
+		WithExporter(s.factory(), nil).
+		WithHeaders(map[string]string{"Authorization": "Bearer secret"}).
+		Build(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans := tracetest.SpanStubs{{Name: "a", Resource: resource.Empty()}}.Snapshots()
+	if err := p.SpanExporter().ExportSpans(ctx, spans); err != nil {
+		t.Error(err)
+	}
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(s.traces); got != 1 {
+		t.Errorf("sink got %d exports, want 1", got)
+	}
+}
+
+func TestRotateCertificate(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		peers [][]byte
+	)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		peers = append(peers, r.TLS.PeerCertificates[0].Raw)
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	first, second := certificate(t), certificate(t)
+	cfg, err := collex.Configure(httpExporter(), func(cfg *httpConfig) error {
+		cfg.Endpoint = srv.URL
+		return collex.ConfigureClient(cfg,
+			collex.ClientCertificate(first),
+			collex.ClientCA(srv.Certificate()),
+			collex.ClientCertificateReload(time.Hour),
+		)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.TLSSetting.ReloadInterval; got != time.Hour {
+		t.Errorf("reload interval = %s, want 1h", got)
+	}
+
+	f, err := collex.NewFactory(httpExporter(), settings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans := tracetest.SpanStubs{{Name: "a", Resource: resource.Empty()}}.Snapshots()
+	if err := exp.ExportSpans(ctx, spans); err != nil {
+		t.Fatal(err)
+	}
+	if err := collex.RotateCertificate(ctx, exp, second); err != nil {
+		t.Fatal(err)
+	}
+	if err := exp.ExportSpans(ctx, spans); err != nil {
+		t.Fatal(err)
+	}
+	if err := exp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(peers) != 2 || !bytes.Equal(peers[0], first.Certificate[0]) || !bytes.Equal(peers[1], second.Certificate[0]) {
+		t.Error("rotated certificate not presented")
+	}
+	if err := collex.RotateCertificate(ctx, struct{}{}, second); err == nil {
+		t.Error("expected an error for an exporter not returned by a Factory")
+	}
+}
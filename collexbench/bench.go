@@ -0,0 +1,174 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package collexbench measures the throughput, allocations, and export
+// latency of OpenTelemetry Go exporters, so the overhead of an exporter
+// bridged by collex can be compared with a native one under identical load.
+package collexbench
+
+import (
+	"context"
+	"math/rand/v2"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/MrAlias/collex/collextest"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Load is the load exporters are measured under.
+type Load struct {
+	// BatchSize is the number of spans of each export. If zero, 100 is used.
+	BatchSize int
+	// Concurrency is the number of goroutines exporting concurrently. If
+	// zero, 1 is used.
+	Concurrency int
+	// Seed seeds the generation of the exported spans. The same seed
+	// exports the same spans, so exporters are measured with the same data.
+	Seed uint64
+}
+
+func (l Load) batchSize() int {
+	if l.BatchSize <= 0 {
+		return 100
+	}
+	return l.BatchSize
+}
+
+func (l Load) concurrency() int {
+	if l.Concurrency <= 0 {
+		return 1
+	}
+	return l.Concurrency
+}
+
+// Result is the measurement of an exporter.
+type Result struct {
+	// Exports is the number of exports made.
+	Exports int
+	// Spans is the number of spans exported.
+	Spans int
+	// Duration is the time all exports took.
+	Duration time.Duration
+	// SpansPerSecond is the throughput of the exporter.
+	SpansPerSecond float64
+	// AllocsPerSpan is the number of heap allocations made per exported
+	// span. It counts the allocations of the whole process, e.g. those of
+	// a backend running in it.
+	AllocsPerSpan float64
+	// P99 is the 99th percentile of the latency of an export.
+	P99 time.Duration
+}
+
+// Run measures exports calls of exp.ExportSpans under load. It returns the
+// first export error, if any, with the measurement of the exports made.
+func Run(ctx context.Context, exp trace.SpanExporter, load Load, exports int) (Result, error) {
+	spans := collextest.RandomSpans(rand.New(rand.NewPCG(load.Seed, 0)), load.batchSize())
+
+	var (
+		next      atomic.Int64
+		mu        sync.Mutex
+		latencies = make([]time.Duration, 0, exports)
+		firstErr  error
+		wg        sync.WaitGroup
+	)
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	for range load.concurrency() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var local []time.Duration
+			for next.Add(1) <= int64(exports) {
+				s := time.Now()
+				err := exp.ExportSpans(ctx, spans)
+				local = append(local, time.Since(s))
+				if err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+					break
+				}
+			}
+			mu.Lock()
+			latencies = append(latencies, local...)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	r := Result{
+		Exports:  len(latencies),
+		Spans:    len(latencies) * len(spans),
+		Duration: elapsed,
+		P99:      percentile(latencies, 0.99),
+	}
+	if r.Spans > 0 {
+		r.SpansPerSecond = float64(r.Spans) / elapsed.Seconds()
+		r.AllocsPerSpan = float64(after.Mallocs-before.Mallocs) / float64(r.Spans)
+	}
+	return r, firstErr
+}
+
+// percentile returns the p percentile of d. It sorts d.
+func percentile(d []time.Duration, p float64) time.Duration {
+	if len(d) == 0 {
+		return 0
+	}
+	slices.Sort(d)
+	i := int(float64(len(d))*p+0.5) - 1
+	return d[min(max(i, 0), len(d)-1)]
+}
+
+// Benchmark measures b.N exports of exp under load. Along with the time and
+// allocations per export, it reports the spans/s, allocs/span, and
+// p99-ns/export metrics.
+func Benchmark(b *testing.B, exp trace.SpanExporter, load Load) {
+	b.Helper()
+	b.ReportAllocs()
+	b.ResetTimer()
+	r, err := Run(context.Background(), exp, load, b.N)
+	b.StopTimer()
+	if err != nil {
+		b.Fatalf("export failed: %v", err)
+	}
+	b.ReportMetric(r.SpansPerSecond, "spans/s")
+	b.ReportMetric(r.AllocsPerSpan, "allocs/span")
+	b.ReportMetric(float64(r.P99.Nanoseconds()), "p99-ns/export")
+}
+
+// Compare runs a sub-benchmark of Benchmark, named after the key, for each
+// of the exporters under the same load. Compare an exporter bridged by collex
+// with the native exporter of the same backend, e.g. with benchstat, to
+// quantify the overhead of the bridge for a workload.
+func Compare(b *testing.B, load Load, exporters map[string]trace.SpanExporter) {
+	b.Helper()
+	names := make([]string, 0, len(exporters))
+	for name := range exporters {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		b.Run(name, func(b *testing.B) { Benchmark(b, exporters[name], load) })
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collexbench_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/MrAlias/collex"
+	"github.com/MrAlias/collex/collexbench"
+	"github.com/MrAlias/collex/collextest"
+	"github.com/MrAlias/collex/transmute"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestRun(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	load := collexbench.Load{BatchSize: 10, Concurrency: 4}
+	r, err := collexbench.Run(context.Background(), exp, load, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Exports != 20 || r.Spans != 200 {
+		t.Errorf("measured %d exports of %d spans, want 20 of 200", r.Exports, r.Spans)
+	}
+	if got := len(exp.GetSpans()); got != 200 {
+		t.Errorf("exported %d spans, want 200", got)
+	}
+	if r.SpansPerSecond <= 0 || r.AllocsPerSpan <= 0 || r.P99 <= 0 {
+		t.Errorf("incomplete result: %+v", r)
+	}
+}
+
+// failing is a SpanExporter whose exports fail.
+type failing struct{ trace.SpanExporter }
+
+var errExport = errors.New("export failed")
+
+func (failing) ExportSpans(context.Context, []trace.ReadOnlySpan) error { return errExport }
+
+func TestRunError(t *testing.T) {
+	r, err := collexbench.Run(context.Background(), failing{}, collexbench.Load{}, 10)
+	if !errors.Is(err, errExport) {
+		t.Errorf("Run returned %v, want %v", err, errExport)
+	}
+	if r.Exports != 1 {
+		t.Errorf("measured %d exports, want 1", r.Exports)
+	}
+}
+
+// otlpClient returns the OTLP gRPC trace client of the collector connected to
+// endpoint.
+func otlpClient(b *testing.B, endpoint string) ptraceotlp.GRPCClient {
+	conn, err := grpc.NewClient(endpoint, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { _ = conn.Close() })
+	return ptraceotlp.NewGRPCClient(conn)
+}
+
+// bridged returns a SpanExporter bridged by collex that sends spans to
+// endpoint with the OTLP gRPC client of the collector, as its OTLP exporter
+// does.
+func bridged(b *testing.B, endpoint string) trace.SpanExporter {
+	client := otlpClient(b, endpoint)
+	c, err := consumer.NewTraces(func(ctx context.Context, td ptrace.Traces) error {
+		_, err := client.Export(ctx, ptraceotlp.NewExportRequestFromTraces(td))
+		return err
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	f := collex.NewConsumerFactory(component.MustNewType("otlp"), collex.Consumers{Traces: c})
+	cfg := f.CreateDefaultConfig().(*collex.HelperConfig)
+	cfg.QueueConfig.Enabled = false
+	factory, err := collex.NewFactory(f, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	exp, err := factory.SpanExporter(context.Background(), cfg)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { _ = exp.Shutdown(context.Background()) })
+	return exp
+}
+
+// direct is a SpanExporter that sends spans with the OTLP gRPC client of the
+// collector without a collector pipeline, as a native OTLP exporter does.
+type direct struct {
+	trace.SpanExporter
+	client ptraceotlp.GRPCClient
+}
+
+func (e direct) ExportSpans(ctx context.Context, spans []trace.ReadOnlySpan) error {
+	_, err := e.client.Export(ctx, ptraceotlp.NewExportRequestFromTraces(transmute.Spans(spans)))
+	return err
+}
+
+func BenchmarkOTLP(b *testing.B) {
+	srv := collextest.NewOTLPServer(b)
+	collexbench.Compare(b, collexbench.Load{Concurrency: 4}, map[string]trace.SpanExporter{
+		"collex": bridged(b, srv.GRPCEndpoint),
+		"direct": direct{client: otlpClient(b, srv.GRPCEndpoint)},
+	})
+}
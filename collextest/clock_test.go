@@ -0,0 +1,71 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collextest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MrAlias/collex/collextest"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)
+	c := collextest.NewFakeClock(start)
+
+	late := c.NewTimer(2 * time.Minute)
+	early := c.NewTimer(time.Minute)
+	stopped := c.NewTimer(time.Minute)
+	c.BlockUntil(3)
+	if !stopped.Stop() {
+		t.Error("pending timer not stopped")
+	}
+
+	c.Advance(time.Minute)
+	if got := c.Now(); !got.Equal(start.Add(time.Minute)) {
+		t.Errorf("Now: got %v, want %v", got, start.Add(time.Minute))
+	}
+	select {
+	case got := <-early.C():
+		if !got.Equal(start.Add(time.Minute)) {
+			t.Errorf("early timer fired at %v", got)
+		}
+	default:
+		t.Error("early timer did not fire")
+	}
+	select {
+	case <-late.C():
+		t.Error("late timer fired early")
+	case <-stopped.C():
+		t.Error("stopped timer fired")
+	default:
+	}
+	if early.Stop() {
+		t.Error("fired timer stopped")
+	}
+
+	c.Advance(time.Hour)
+	select {
+	case <-late.C():
+	default:
+		t.Error("late timer did not fire")
+	}
+
+	select {
+	case <-c.NewTimer(0).C():
+	default:
+		t.Error("expired timer did not fire")
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collextest
+
+import (
+	"testing"
+
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// AssertMetricsEqual reports, with t, whether got differs from want. It
+// returns whether they are equal.
+//
+// The metrics are compared in their canonical form, see CanonicalMetrics, so
+// by default the order of resources, scopes, metrics, data points, and
+// attributes, as well as timestamps, are ignored. The options keep them
+// instead. This is the equality of the OpenTelemetry data model, one
+// reflect.DeepEqual of pdata does not provide.
+func AssertMetricsEqual(t testing.TB, want, got pmetric.Metrics, opts ...GoldenOption) bool {
+	t.Helper()
+	w, err := CanonicalMetrics(want, opts...)
+	if err != nil {
+		t.Errorf("want metrics: %v", err)
+		return false
+	}
+	g, err := CanonicalMetrics(got, opts...)
+	if err != nil {
+		t.Errorf("got metrics: %v", err)
+		return false
+	}
+	return assertCanonicalEqual(t, "metrics", w, g)
+}
+
+// AssertLogsEqual reports, with t, whether got differs from want. It returns
+// whether they are equal.
+//
+// The logs are compared in their canonical form, see CanonicalLogs, so by
+// default the order of resources, scopes, log records, and attributes, as
+// well as timestamps, are ignored. The options keep them instead.
+func AssertLogsEqual(t testing.TB, want, got plog.Logs, opts ...GoldenOption) bool {
+	t.Helper()
+	w, err := CanonicalLogs(want, opts...)
+	if err != nil {
+		t.Errorf("want logs: %v", err)
+		return false
+	}
+	g, err := CanonicalLogs(got, opts...)
+	if err != nil {
+		t.Errorf("got logs: %v", err)
+		return false
+	}
+	return assertCanonicalEqual(t, "logs", w, g)
+}
+
+func assertCanonicalEqual(t testing.TB, name string, want, got []byte) bool {
+	t.Helper()
+	if line, g, w, ok := firstDiff(got, want); ok {
+		t.Errorf("%s differ at line %d of their canonical form: got %q, want %q", name, line, g, w)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,82 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collextest_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/MrAlias/collex/collextest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// gauge returns metrics with a gauge of the values, recorded at ts, in order.
+func gauge(ts pcommon.Timestamp, values ...int64) pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	g := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	g.SetName("queue.size")
+	dps := g.SetEmptyGauge().DataPoints()
+	for _, v := range values {
+		dp := dps.AppendEmpty()
+		dp.SetIntValue(v)
+		dp.SetTimestamp(ts)
+		dp.Attributes().PutInt("v", v)
+	}
+	return md
+}
+
+func TestAssertMetricsEqual(t *testing.T) {
+	// Order and timestamps are ignored.
+	collextest.AssertMetricsEqual(t, gauge(1, 1, 2, 3), gauge(2, 3, 1, 2))
+
+	r := &recorder{TB: t}
+	if collextest.AssertMetricsEqual(r, gauge(1, 1, 2), gauge(1, 1, 4)) {
+		t.Error("metrics with different values are equal")
+	}
+	if len(r.errs) != 1 || !strings.Contains(r.errs[0], "metrics differ") {
+		t.Errorf("errors: %q", r.errs)
+	}
+
+	r = &recorder{TB: t}
+	if collextest.AssertMetricsEqual(r, gauge(1, 1), gauge(2, 1), collextest.KeepTimestamps()) {
+		t.Error("metrics with different kept timestamps are equal")
+	}
+}
+
+// logs returns logs with a record of each body, observed at ts, in order.
+func logs(ts pcommon.Timestamp, bodies ...string) plog.Logs {
+	ld := plog.NewLogs()
+	lrs := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords()
+	for _, b := range bodies {
+		lr := lrs.AppendEmpty()
+		lr.Body().SetStr(b)
+		lr.SetObservedTimestamp(ts)
+	}
+	return ld
+}
+
+func TestAssertLogsEqual(t *testing.T) {
+	collextest.AssertLogsEqual(t, logs(1, "a", "b"), logs(2, "b", "a"))
+
+	r := &recorder{TB: t}
+	if collextest.AssertLogsEqual(r, logs(1, "a", "b"), logs(1, "a", "c")) {
+		t.Error("logs with different bodies are equal")
+	}
+	if len(r.errs) != 1 || !strings.Contains(r.errs[0], "logs differ") {
+		t.Errorf("errors: %q", r.errs)
+	}
+}
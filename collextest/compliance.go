@@ -0,0 +1,274 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collextest
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MrAlias/collex"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/exporter"
+)
+
+// complianceTimeout is the time an exporter operation is allowed to take in
+// RunExporterCompliance.
+const complianceTimeout = 10 * time.Second
+
+// signalExporter is the signal agnostic view of a collex exporter used by
+// RunExporterCompliance.
+type signalExporter struct {
+	// export exports a batch of n random items.
+	export func(ctx context.Context, r *rand.Rand, n int) error
+	// flush is nil for signals whose exporter cannot be flushed.
+	flush    func(ctx context.Context) error
+	shutdown func(ctx context.Context) error
+}
+
+// signal is a signal a factory can export.
+type signal struct {
+	name      string
+	stability func(exporter.Factory) component.StabilityLevel
+	create    func(context.Context, *collex.Factory, component.Config) (signalExporter, error)
+}
+
+var signals = []signal{
+	{
+		name:      "Traces",
+		stability: exporter.Factory.TracesStability,
+		create: func(ctx context.Context, f *collex.Factory, cfg component.Config) (signalExporter, error) {
+			exp, err := f.SpanExporter(ctx, cfg)
+			if err != nil {
+				return signalExporter{}, err
+			}
+			return signalExporter{
+				export: func(ctx context.Context, r *rand.Rand, n int) error {
+					return exp.ExportSpans(ctx, RandomSpans(r, n))
+				},
+				shutdown: exp.Shutdown,
+			}, nil
+		},
+	},
+	{
+		name:      "Metrics",
+		stability: exporter.Factory.MetricsStability,
+		create: func(ctx context.Context, f *collex.Factory, cfg component.Config) (signalExporter, error) {
+			exp, err := f.MetricExporter(ctx, cfg)
+			if err != nil {
+				return signalExporter{}, err
+			}
+			return signalExporter{
+				export: func(ctx context.Context, r *rand.Rand, n int) error {
+					return exp.Export(ctx, RandomMetrics(r, n))
+				},
+				flush:    exp.ForceFlush,
+				shutdown: exp.Shutdown,
+			}, nil
+		},
+	},
+	{
+		name:      "Logs",
+		stability: exporter.Factory.LogsStability,
+		create: func(ctx context.Context, f *collex.Factory, cfg component.Config) (signalExporter, error) {
+			exp, err := f.LogExporter(ctx, cfg)
+			if err != nil {
+				return signalExporter{}, err
+			}
+			return signalExporter{
+				export: func(ctx context.Context, r *rand.Rand, n int) error {
+					return exp.Export(ctx, RandomLogs(r, n))
+				},
+				flush:    exp.ForceFlush,
+				shutdown: exp.Shutdown,
+			}, nil
+		},
+	},
+}
+
+// RunExporterCompliance runs a suite of subtests verifying that the exporters
+// of factory, configured with cfg, behave as OpenTelemetry Go exporters when
+// bridged by collex. If cfg is nil the default configuration of factory is
+// used. Each signal factory supports is tested:
+//
+//   - Lifecycle: exports succeed once the exporter is created, flushing and
+//     shutting down succeed, shutting down again is a no-op, and exports
+//     after the shut down fail.
+//   - Concurrency: concurrent exports succeed, run it with -race.
+//   - Cancellation: exports, flushes, and shut downs with a canceled context
+//     return promptly.
+//   - Errors: errors of the exporter are returned by exports, keeping whether
+//     they are permanent.
+//
+// The exports must succeed, so cfg needs to send to a reachable backend, e.g.
+// an OTLPServer. This validates exporters collex does not test itself, e.g.
+// less common contrib exporters, before they are used.
+func RunExporterCompliance(t *testing.T, factory exporter.Factory, cfg component.Config) {
+	t.Helper()
+	var n int
+	for _, s := range signals {
+		if s.stability(factory) == component.StabilityLevelUndefined {
+			continue
+		}
+		n++
+		t.Run(s.name, func(t *testing.T) {
+			t.Run("Lifecycle", func(t *testing.T) { complianceLifecycle(t, s, factory, cfg) })
+			t.Run("Concurrency", func(t *testing.T) { complianceConcurrency(t, s, factory, cfg) })
+			t.Run("Cancellation", func(t *testing.T) { complianceCancellation(t, s, factory, cfg) })
+			t.Run("Errors", func(t *testing.T) { complianceErrors(t, s, factory, cfg) })
+		})
+	}
+	if n == 0 {
+		t.Errorf("factory %s supports no signal", factory.Type())
+	}
+}
+
+// newSignalExporter returns an exporter of s created by a collex Factory of f
+// that is shut down when the test completes.
+func newSignalExporter(t *testing.T, s signal, f exporter.Factory, cfg component.Config) signalExporter {
+	t.Helper()
+	factory, err := collex.NewFactory(f, nil)
+	if err != nil {
+		t.Fatalf("failed to create collex factory: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), complianceTimeout)
+	defer cancel()
+	exp, err := s.create(ctx, factory, cfg)
+	if err != nil {
+		t.Fatalf("failed to create exporter: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), complianceTimeout)
+		defer cancel()
+		_ = exp.shutdown(ctx)
+	})
+	return exp
+}
+
+func complianceLifecycle(t *testing.T, s signal, f exporter.Factory, cfg component.Config) {
+	exp := newSignalExporter(t, s, f, cfg)
+	r := rand.New(rand.NewPCG(1, 2))
+
+	ctx, cancel := context.WithTimeout(context.Background(), complianceTimeout)
+	defer cancel()
+	for i := range 3 {
+		if err := exp.export(ctx, r, 10); err != nil {
+			t.Errorf("export %d failed: %v", i, err)
+		}
+	}
+	if exp.flush != nil {
+		if err := exp.flush(ctx); err != nil {
+			t.Errorf("flush failed: %v", err)
+		}
+	}
+	if err := exp.shutdown(ctx); err != nil {
+		t.Errorf("shutdown failed: %v", err)
+	}
+	if err := exp.shutdown(ctx); err != nil {
+		t.Errorf("second shutdown failed: %v", err)
+	}
+	if err := exp.export(ctx, r, 1); err == nil {
+		t.Error("export after shutdown succeeded")
+	}
+}
+
+func complianceConcurrency(t *testing.T, s signal, f exporter.Factory, cfg component.Config) {
+	exp := newSignalExporter(t, s, f, cfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), complianceTimeout)
+	defer cancel()
+	const goroutines, exports = 8, 10
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines*exports+1)
+	for g := range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := rand.New(rand.NewPCG(uint64(g), 0))
+			for range exports {
+				errs <- exp.export(ctx, r, 5)
+			}
+		}()
+	}
+	if exp.flush != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- exp.flush(ctx)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("concurrent export failed: %v", err)
+		}
+	}
+	if err := exp.shutdown(ctx); err != nil {
+		t.Errorf("shutdown failed: %v", err)
+	}
+}
+
+func complianceCancellation(t *testing.T, s signal, f exporter.Factory, cfg component.Config) {
+	exp := newSignalExporter(t, s, f, cfg)
+	r := rand.New(rand.NewPCG(1, 2))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	prompt(t, "export", func() { _ = exp.export(ctx, r, 10) })
+	if exp.flush != nil {
+		prompt(t, "flush", func() { _ = exp.flush(ctx) })
+	}
+	prompt(t, "shutdown", func() { _ = exp.shutdown(ctx) })
+}
+
+// prompt fails t if fn does not return within the complianceTimeout.
+func prompt(t *testing.T, name string, fn func()) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+	select {
+	case <-done:
+	case <-time.After(complianceTimeout):
+		t.Errorf("%s with a canceled context did not return within %s", name, complianceTimeout)
+	}
+}
+
+func complianceErrors(t *testing.T, s signal, f exporter.Factory, cfg component.Config) {
+	r := rand.New(rand.NewPCG(1, 2))
+	ctx, cancel := context.WithTimeout(context.Background(), complianceTimeout)
+	defer cancel()
+
+	exp := newSignalExporter(t, s, FaultFactory(f, Faults{TransientErrorRate: 1}), cfg)
+	if err := exp.export(ctx, r, 1); !errors.Is(err, ErrTransient) {
+		t.Errorf("export returned %v, want %v", err, ErrTransient)
+	}
+
+	exp = newSignalExporter(t, s, FaultFactory(f, Faults{PermanentErrorRate: 1}), cfg)
+	err := exp.export(ctx, r, 1)
+	if !errors.Is(err, ErrPermanent) {
+		t.Errorf("export returned %v, want %v", err, ErrPermanent)
+	}
+	if !consumererror.IsPermanent(err) {
+		t.Errorf("export returned %v, want a permanent error", err)
+	}
+}
@@ -0,0 +1,34 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collextest_test
+
+import (
+	"testing"
+
+	"github.com/MrAlias/collex/collextest"
+	"go.opentelemetry.io/collector/exporter/debugexporter"
+)
+
+func TestRunExporterCompliance(t *testing.T) {
+	collextest.RunExporterCompliance(t, debugexporter.NewFactory(), nil)
+}
+
+func TestRunExporterComplianceSink(t *testing.T) {
+	var s spanSink
+	collextest.RunExporterCompliance(t, s.factory(), nil)
+	if s.exported() == 0 {
+		t.Error("no spans exported")
+	}
+}
@@ -0,0 +1,275 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collextest
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/MrAlias/collex"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+var (
+	// ErrTransient is the error of the exports failed by a transient fault.
+	ErrTransient = errors.New("collextest: injected transient error")
+	// ErrPermanent is the error, wrapped with consumererror.NewPermanent, of
+	// the exports failed by a permanent fault.
+	ErrPermanent = errors.New("collextest: injected permanent error")
+	// ErrRejected is the error of the telemetry rejected by a partial
+	// rejection.
+	ErrRejected = errors.New("collextest: injected partial rejection")
+)
+
+// Faults are the faults injected into exports by the exporters of a
+// FaultFactory. The rates are the fractions, from 0 to 1, of the exports a
+// fault is injected into.
+type Faults struct {
+	// Latency is added to each export, unless its context is done first.
+	Latency time.Duration
+	// TransientErrorRate is the rate of exports failed with ErrTransient,
+	// an error exporter helpers retry.
+	TransientErrorRate float64
+	// PermanentErrorRate is the rate of exports failed with ErrPermanent,
+	// wrapped as a permanent error exporter helpers do not retry.
+	PermanentErrorRate float64
+	// PartialRejectionRate is the rate of exports of which every other span,
+	// metric, or log record is rejected. The rest is exported and the
+	// rejected telemetry is returned in a consumererror signal error, so
+	// only it is retried.
+	PartialRejectionRate float64
+	// Rand, if not nil, is the source of randomness of the faults, e.g. a
+	// seeded one for reproducible tests.
+	Rand *rand.Rand
+	// Clock, if not nil, is the Clock the latency is measured with instead
+	// of the system clock.
+	Clock collex.Clock
+}
+
+// FaultFactory returns a collector exporter factory with the type and
+// configuration of f whose exporters inject faults into the exports of the
+// exporters of f. Passing it to collex.NewFactory verifies how an
+// application, and the collex options it uses, e.g. restarts, handle an
+// exporter that is slow or fails.
+func FaultFactory(f exporter.Factory, faults Faults) exporter.Factory {
+	inj := &injector{faults: faults, rand: faults.Rand, clock: faults.Clock}
+	if inj.rand == nil {
+		inj.rand = rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+	}
+	if inj.clock == nil {
+		inj.clock = systemClock{}
+	}
+
+	var opts []exporter.FactoryOption
+	if s := f.TracesStability(); s != component.StabilityLevelUndefined {
+		opts = append(opts, exporter.WithTraces(func(ctx context.Context, set exporter.Settings, cfg component.Config) (exporter.Traces, error) {
+			exp, err := f.CreateTraces(ctx, set, cfg)
+			if err != nil {
+				return nil, err
+			}
+			return faultyTraces{Traces: exp, inj: inj}, nil
+		}, s))
+	}
+	if s := f.MetricsStability(); s != component.StabilityLevelUndefined {
+		opts = append(opts, exporter.WithMetrics(func(ctx context.Context, set exporter.Settings, cfg component.Config) (exporter.Metrics, error) {
+			exp, err := f.CreateMetrics(ctx, set, cfg)
+			if err != nil {
+				return nil, err
+			}
+			return faultyMetrics{Metrics: exp, inj: inj}, nil
+		}, s))
+	}
+	if s := f.LogsStability(); s != component.StabilityLevelUndefined {
+		opts = append(opts, exporter.WithLogs(func(ctx context.Context, set exporter.Settings, cfg component.Config) (exporter.Logs, error) {
+			exp, err := f.CreateLogs(ctx, set, cfg)
+			if err != nil {
+				return nil, err
+			}
+			return faultyLogs{Logs: exp, inj: inj}, nil
+		}, s))
+	}
+	return exporter.NewFactory(f.Type(), f.CreateDefaultConfig, opts...)
+}
+
+// injector injects faults into exports.
+type injector struct {
+	faults Faults
+	clock  collex.Clock
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// chance returns whether a fault of rate is injected.
+func (inj *injector) chance(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	inj.mu.Lock()
+	defer inj.mu.Unlock()
+	return inj.rand.Float64() < rate
+}
+
+// inject waits for the latency and returns the error of a failed export. It
+// returns whether the export is partially rejected.
+func (inj *injector) inject(ctx context.Context) (bool, error) {
+	if inj.faults.Latency > 0 {
+		t := inj.clock.NewTimer(inj.faults.Latency)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return false, ctx.Err()
+		case <-t.C():
+		}
+	}
+	switch {
+	case inj.chance(inj.faults.PermanentErrorRate):
+		return false, consumererror.NewPermanent(ErrPermanent)
+	case inj.chance(inj.faults.TransientErrorRate):
+		return false, ErrTransient
+	}
+	return inj.chance(inj.faults.PartialRejectionRate), nil
+}
+
+type faultyTraces struct {
+	exporter.Traces
+	inj *injector
+}
+
+func (e faultyTraces) ConsumeTraces(ctx context.Context, td ptrace.Traces) error {
+	partial, err := e.inj.inject(ctx)
+	if err != nil {
+		return err
+	}
+	if !partial {
+		return e.Traces.ConsumeTraces(ctx, td)
+	}
+
+	accepted, rejected := ptrace.NewTraces(), ptrace.NewTraces()
+	td.CopyTo(accepted)
+	td.CopyTo(rejected)
+	removeSpans(accepted, 1)
+	removeSpans(rejected, 0)
+	if rejected.SpanCount() == 0 {
+		return e.Traces.ConsumeTraces(ctx, td)
+	}
+	return errors.Join(e.Traces.ConsumeTraces(ctx, accepted), consumererror.NewTraces(ErrRejected, rejected))
+}
+
+// removeSpans removes every other span of td, starting with the first if
+// parity is 0 and with the second if it is 1.
+func removeSpans(td ptrace.Traces, parity int) {
+	var i int
+	td.ResourceSpans().RemoveIf(func(rs ptrace.ResourceSpans) bool {
+		rs.ScopeSpans().RemoveIf(func(ss ptrace.ScopeSpans) bool {
+			ss.Spans().RemoveIf(func(ptrace.Span) bool {
+				i++
+				return i%2 != parity
+			})
+			return ss.Spans().Len() == 0
+		})
+		return rs.ScopeSpans().Len() == 0
+	})
+}
+
+type faultyMetrics struct {
+	exporter.Metrics
+	inj *injector
+}
+
+func (e faultyMetrics) ConsumeMetrics(ctx context.Context, md pmetric.Metrics) error {
+	partial, err := e.inj.inject(ctx)
+	if err != nil {
+		return err
+	}
+	if !partial {
+		return e.Metrics.ConsumeMetrics(ctx, md)
+	}
+
+	accepted, rejected := pmetric.NewMetrics(), pmetric.NewMetrics()
+	md.CopyTo(accepted)
+	md.CopyTo(rejected)
+	removeMetrics(accepted, 1)
+	removeMetrics(rejected, 0)
+	if rejected.MetricCount() == 0 {
+		return e.Metrics.ConsumeMetrics(ctx, md)
+	}
+	return errors.Join(e.Metrics.ConsumeMetrics(ctx, accepted), consumererror.NewMetrics(ErrRejected, rejected))
+}
+
+// removeMetrics removes every other metric of md, starting with the first if
+// parity is 0 and with the second if it is 1.
+func removeMetrics(md pmetric.Metrics, parity int) {
+	var i int
+	md.ResourceMetrics().RemoveIf(func(rm pmetric.ResourceMetrics) bool {
+		rm.ScopeMetrics().RemoveIf(func(sm pmetric.ScopeMetrics) bool {
+			sm.Metrics().RemoveIf(func(pmetric.Metric) bool {
+				i++
+				return i%2 != parity
+			})
+			return sm.Metrics().Len() == 0
+		})
+		return rm.ScopeMetrics().Len() == 0
+	})
+}
+
+type faultyLogs struct {
+	exporter.Logs
+	inj *injector
+}
+
+func (e faultyLogs) ConsumeLogs(ctx context.Context, ld plog.Logs) error {
+	partial, err := e.inj.inject(ctx)
+	if err != nil {
+		return err
+	}
+	if !partial {
+		return e.Logs.ConsumeLogs(ctx, ld)
+	}
+
+	accepted, rejected := plog.NewLogs(), plog.NewLogs()
+	ld.CopyTo(accepted)
+	ld.CopyTo(rejected)
+	removeLogs(accepted, 1)
+	removeLogs(rejected, 0)
+	if rejected.LogRecordCount() == 0 {
+		return e.Logs.ConsumeLogs(ctx, ld)
+	}
+	return errors.Join(e.Logs.ConsumeLogs(ctx, accepted), consumererror.NewLogs(ErrRejected, rejected))
+}
+
+// removeLogs removes every other log record of ld, starting with the first if
+// parity is 0 and with the second if it is 1.
+func removeLogs(ld plog.Logs, parity int) {
+	var i int
+	ld.ResourceLogs().RemoveIf(func(rl plog.ResourceLogs) bool {
+		rl.ScopeLogs().RemoveIf(func(sl plog.ScopeLogs) bool {
+			sl.LogRecords().RemoveIf(func(plog.LogRecord) bool {
+				i++
+				return i%2 != parity
+			})
+			return sl.LogRecords().Len() == 0
+		})
+		return rl.ScopeLogs().Len() == 0
+	})
+}
@@ -0,0 +1,161 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collextest_test
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MrAlias/collex/collextest"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// spanSink is a collector exporter that counts the spans it exports.
+type spanSink struct {
+	mu    sync.Mutex
+	spans int
+}
+
+func (s *spanSink) factory() exporter.Factory {
+	return exporter.NewFactory(
+		component.MustNewType("sink"),
+		func() component.Config { return &struct{}{} },
+		exporter.WithTraces(func(context.Context, exporter.Settings, component.Config) (exporter.Traces, error) {
+			c, err := consumer.NewTraces(func(_ context.Context, td ptrace.Traces) error {
+				s.mu.Lock()
+				defer s.mu.Unlock()
+				s.spans += td.SpanCount()
+				return nil
+			})
+			return struct {
+				component.StartFunc
+				component.ShutdownFunc
+				consumer.Traces
+			}{Traces: c}, err
+		}, component.StabilityLevelDevelopment),
+	)
+}
+
+func (s *spanSink) exported() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.spans
+}
+
+func faultyTraces(t *testing.T, s *spanSink, faults collextest.Faults) exporter.Traces {
+	t.Helper()
+	f := collextest.FaultFactory(s.factory(), faults)
+	set := exporter.Settings{
+		ID:                component.NewID(f.Type()),
+		TelemetrySettings: componenttest.NewNopTelemetrySettings(),
+	}
+	exp, err := f.CreateTraces(context.Background(), set, f.CreateDefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return exp
+}
+
+func traces(n int) ptrace.Traces {
+	td := ptrace.NewTraces()
+	spans := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+	for range n {
+		spans.AppendEmpty()
+	}
+	return td
+}
+
+func TestFaultFactoryErrors(t *testing.T) {
+	ctx := context.Background()
+	var s spanSink
+
+	exp := faultyTraces(t, &s, collextest.Faults{PermanentErrorRate: 1})
+	if err := exp.ConsumeTraces(ctx, traces(1)); !errors.Is(err, collextest.ErrPermanent) || !consumererror.IsPermanent(err) {
+		t.Errorf("permanent fault error = %v", err)
+	}
+
+	exp = faultyTraces(t, &s, collextest.Faults{TransientErrorRate: 1})
+	if err := exp.ConsumeTraces(ctx, traces(1)); !errors.Is(err, collextest.ErrTransient) || consumererror.IsPermanent(err) {
+		t.Errorf("transient fault error = %v", err)
+	}
+
+	exp = faultyTraces(t, &s, collextest.Faults{TransientErrorRate: 0.5, Rand: rand.New(rand.NewPCG(1, 1))})
+	var failed int
+	for range 100 {
+		if exp.ConsumeTraces(ctx, traces(1)) != nil {
+			failed++
+		}
+	}
+	if failed < 30 || failed > 70 {
+		t.Errorf("%d of 100 exports failed at a rate of 0.5", failed)
+	}
+	if got := s.exported(); got != 100-failed {
+		t.Errorf("exported %d spans, want %d", got, 100-failed)
+	}
+}
+
+func TestFaultFactoryPartialRejection(t *testing.T) {
+	var s spanSink
+	exp := faultyTraces(t, &s, collextest.Faults{PartialRejectionRate: 1})
+
+	err := exp.ConsumeTraces(context.Background(), traces(5))
+	var rejected consumererror.Traces
+	if !errors.As(err, &rejected) || !errors.Is(err, collextest.ErrRejected) {
+		t.Fatalf("partial rejection error = %v", err)
+	}
+	if got := rejected.Data().SpanCount(); got != 2 {
+		t.Errorf("rejected %d spans, want 2", got)
+	}
+	if got := s.exported(); got != 3 {
+		t.Errorf("exported %d spans, want 3", got)
+	}
+}
+
+func TestFaultFactoryLatency(t *testing.T) {
+	var s spanSink
+	clock := collextest.NewFakeClock(time.Now())
+	exp := faultyTraces(t, &s, collextest.Faults{Latency: time.Second, Clock: clock})
+
+	done := make(chan error)
+	go func() { done <- exp.ConsumeTraces(context.Background(), traces(1)) }()
+	clock.BlockUntil(1)
+	if got := s.exported(); got != 0 {
+		t.Errorf("exported %d spans before the latency passed", got)
+	}
+	clock.Advance(time.Second)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if got := s.exported(); got != 1 {
+		t.Errorf("exported %d spans, want 1", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() { done <- exp.ConsumeTraces(ctx, traces(1)) }()
+	clock.BlockUntil(1)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled export error = %v, want %v", err, context.Canceled)
+	}
+}
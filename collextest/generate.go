@@ -0,0 +1,161 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collextest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MrAlias/collex"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SpanGenerator generates traces for load and soak tests, e.g. of an exporter
+// configuration before it is used in production.
+type SpanGenerator struct {
+	// Depth is the number of levels of spans of each trace. A trace is a
+	// single span if it is not positive.
+	Depth int
+	// Breadth is the number of children of each span above the last level.
+	// It is 1 if not positive.
+	Breadth int
+	// Attributes is the number of attributes of each span.
+	Attributes int
+	// Cardinality is the number of distinct values each attribute takes
+	// across the spans generated. It is 1 if not positive.
+	Cardinality int
+}
+
+// Generate generates n traces with tracer and returns the number of spans
+// generated.
+func (g SpanGenerator) Generate(ctx context.Context, tracer trace.Tracer, n int) int {
+	var spans int
+	var span func(ctx context.Context, level int)
+	span = func(ctx context.Context, level int) {
+		attrs := make([]attribute.KeyValue, g.Attributes)
+		for i := range attrs {
+			attrs[i] = attribute.String(fmt.Sprintf("attr.%d", i), fmt.Sprintf("value.%d", spans%max(g.Cardinality, 1)))
+		}
+		ctx, s := tracer.Start(ctx, fmt.Sprintf("span.%d", level), trace.WithAttributes(attrs...))
+		spans++
+		if level+1 < g.Depth {
+			for range max(g.Breadth, 1) {
+				span(ctx, level+1)
+			}
+		}
+		s.End()
+	}
+	for range n {
+		span(ctx, 0)
+	}
+	return spans
+}
+
+// MetricGenerator generates metric series for load and soak tests.
+type MetricGenerator struct {
+	// Name is the name of the counter the series are recorded with. It is
+	// "collextest.generated" if empty.
+	Name string
+	// Series is the number of series, distinct attribute sets, recorded.
+	Series int
+}
+
+// Generate adds one to each series of a counter created with meter.
+func (g MetricGenerator) Generate(ctx context.Context, meter metric.Meter) error {
+	name := g.Name
+	if name == "" {
+		name = "collextest.generated"
+	}
+	counter, err := meter.Int64Counter(name)
+	if err != nil {
+		return err
+	}
+	for i := range g.Series {
+		counter.Add(ctx, 1, metric.WithAttributes(attribute.Int("series", i)))
+	}
+	return nil
+}
+
+// LogGenerator emits log records at a target rate for load and soak tests.
+type LogGenerator struct {
+	// Rate is the number of records emitted per second.
+	Rate float64
+	// Attributes is the number of attributes of each record.
+	Attributes int
+	// BodySize is the length of the body of each record.
+	BodySize int
+	// Clock, if not nil, is the Clock the rate is measured with instead of
+	// the system clock.
+	Clock collex.Clock
+}
+
+// Run emits records with logger at the rate of g until ctx is done. It
+// returns the number of records emitted.
+//
+// Records are emitted in bursts, every millisecond at most, of the records
+// due since Run was called, so the rate is kept even if it exceeds what a
+// timer can tick at.
+func (g LogGenerator) Run(ctx context.Context, logger log.Logger) int {
+	if g.Rate <= 0 {
+		<-ctx.Done()
+		return 0
+	}
+	clock := g.Clock
+	if clock == nil {
+		clock = systemClock{}
+	}
+	tick := max(time.Duration(float64(time.Second)/g.Rate), time.Millisecond)
+
+	body := log.StringValue(strings.Repeat("x", g.BodySize))
+	start := clock.Now()
+	var emitted int
+	for {
+		due := int(clock.Now().Sub(start).Seconds() * g.Rate)
+		for ; emitted < due; emitted++ {
+			var r log.Record
+			r.SetTimestamp(clock.Now())
+			r.SetSeverity(log.SeverityInfo)
+			r.SetBody(body)
+			for i := range g.Attributes {
+				r.AddAttributes(log.Int(fmt.Sprintf("attr.%d", i), emitted))
+			}
+			logger.Emit(ctx, r)
+		}
+
+		t := clock.NewTimer(tick)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return emitted
+		case <-t.C():
+		}
+	}
+}
+
+// systemClock is the collex.Clock of the time package.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) NewTimer(d time.Duration) collex.Timer { return systemTimer{time.NewTimer(d)} }
+
+type systemTimer struct{ *time.Timer }
+
+func (t systemTimer) C() <-chan time.Time { return t.Timer.C }
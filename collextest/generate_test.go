@@ -0,0 +1,122 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collextest_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MrAlias/collex/collextest"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSpanGenerator(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	g := collextest.SpanGenerator{Depth: 3, Breadth: 2, Attributes: 2, Cardinality: 3}
+
+	// Each trace is 1 + 2 + 4 spans.
+	if got := g.Generate(context.Background(), tp.Tracer("load"), 2); got != 14 {
+		t.Errorf("generated %d spans, want 14", got)
+	}
+	spans := sr.Ended()
+	if len(spans) != 14 {
+		t.Fatalf("recorded %d spans, want 14", len(spans))
+	}
+
+	traces := make(map[string]bool)
+	values := make(map[string]bool)
+	for _, s := range spans {
+		traces[s.SpanContext().TraceID().String()] = true
+		if len(s.Attributes()) != 2 {
+			t.Errorf("span %s has %d attributes, want 2", s.Name(), len(s.Attributes()))
+		}
+		for _, a := range s.Attributes() {
+			values[a.Value.AsString()] = true
+		}
+	}
+	if len(traces) != 2 {
+		t.Errorf("generated %d traces, want 2", len(traces))
+	}
+	if len(values) != 3 {
+		t.Errorf("attributes took %d values, want 3", len(values))
+	}
+}
+
+func TestMetricGenerator(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	ctx := context.Background()
+	if err := (collextest.MetricGenerator{Series: 5}).Generate(ctx, mp.Meter("load")); err != nil {
+		t.Fatal(err)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	sum := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Sum[int64])
+	if got := len(sum.DataPoints); got != 5 {
+		t.Errorf("recorded %d series, want 5", got)
+	}
+}
+
+// countExporter counts the log records exported to it.
+type countExporter struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (e *countExporter) Export(_ context.Context, records []sdklog.Record) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.n += len(records)
+	return nil
+}
+
+func (*countExporter) Shutdown(context.Context) error { return nil }
+
+func (*countExporter) ForceFlush(context.Context) error { return nil }
+
+func TestLogGenerator(t *testing.T) {
+	exp := new(countExporter)
+	lp := sdklog.NewLoggerProvider(sdklog.WithProcessor(sdklog.NewSimpleProcessor(exp)))
+	clock := collextest.NewFakeClock(time.Now())
+	g := collextest.LogGenerator{Rate: 5000, Attributes: 1, BodySize: 64, Clock: clock}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan int)
+	go func() { done <- g.Run(ctx, lp.Logger("load")) }()
+
+	// Ticks are a millisecond apart at most, so a second takes many.
+	for range 1000 {
+		clock.BlockUntil(1)
+		clock.Advance(time.Millisecond)
+	}
+	clock.BlockUntil(1)
+	cancel()
+	if got := <-done; got != 5000 {
+		t.Errorf("emitted %d records in a second, want 5000", got)
+	}
+	if exp.n != 5000 {
+		t.Errorf("exported %d records, want 5000", exp.n)
+	}
+}
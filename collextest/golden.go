@@ -0,0 +1,359 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collextest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// UpdateGoldenEnv is the environment variable that, when set to a non-empty
+// value, makes the AssertGolden functions write their golden files instead of
+// comparing against them.
+const UpdateGoldenEnv = "COLLEXTEST_UPDATE_GOLDEN"
+
+// GoldenOption configures the canonical form of telemetry.
+type GoldenOption interface {
+	apply(goldenConfig) goldenConfig
+}
+
+type goldenConfig struct {
+	keepTimestamps bool
+	keepIDs        bool
+}
+
+type goldenOptionFunc func(goldenConfig) goldenConfig
+
+func (fn goldenOptionFunc) apply(c goldenConfig) goldenConfig {
+	return fn(c)
+}
+
+// KeepTimestamps returns a GoldenOption that keeps timestamps in the
+// canonical form instead of removing them.
+func KeepTimestamps() GoldenOption {
+	return goldenOptionFunc(func(c goldenConfig) goldenConfig {
+		c.keepTimestamps = true
+		return c
+	})
+}
+
+// KeepIDs returns a GoldenOption that keeps trace and span IDs in the
+// canonical form instead of replacing them with sequential ones.
+func KeepIDs() GoldenOption {
+	return goldenOptionFunc(func(c goldenConfig) goldenConfig {
+		c.keepIDs = true
+		return c
+	})
+}
+
+// CanonicalTraces returns td as canonical OTLP JSON.
+//
+// The canonical form is indented, and resources, scopes, spans, and
+// attributes are sorted so the order pdata happens to hold them in does not
+// matter. Unless the options keep them, timestamps are removed and trace and
+// span IDs are replaced with sequential ones, in order of appearance, so
+// telemetry recorded at different times and with different IDs is equal while
+// the relationships between spans are kept.
+func CanonicalTraces(td ptrace.Traces, opts ...GoldenOption) ([]byte, error) {
+	data, err := (&ptrace.JSONMarshaler{}).MarshalTraces(td)
+	if err != nil {
+		return nil, err
+	}
+	return canonical(data, opts)
+}
+
+// CanonicalMetrics returns md as canonical OTLP JSON. See CanonicalTraces for
+// the canonical form. Data points are also sorted.
+func CanonicalMetrics(md pmetric.Metrics, opts ...GoldenOption) ([]byte, error) {
+	data, err := (&pmetric.JSONMarshaler{}).MarshalMetrics(md)
+	if err != nil {
+		return nil, err
+	}
+	return canonical(data, opts)
+}
+
+// CanonicalLogs returns ld as canonical OTLP JSON. See CanonicalTraces for the
+// canonical form.
+func CanonicalLogs(ld plog.Logs, opts ...GoldenOption) ([]byte, error) {
+	data, err := (&plog.JSONMarshaler{}).MarshalLogs(ld)
+	if err != nil {
+		return nil, err
+	}
+	return canonical(data, opts)
+}
+
+// AssertGoldenTraces reports, with t, whether the canonical form of td
+// differs from the golden file at path. It returns whether they are equal.
+//
+// If the UpdateGoldenEnv environment variable is set, the golden file is
+// written instead.
+func AssertGoldenTraces(t testing.TB, path string, td ptrace.Traces, opts ...GoldenOption) bool {
+	t.Helper()
+	data, err := CanonicalTraces(td, opts...)
+	return assertGolden(t, path, data, err)
+}
+
+// AssertGoldenMetrics reports, with t, whether the canonical form of md
+// differs from the golden file at path. It returns whether they are equal.
+//
+// If the UpdateGoldenEnv environment variable is set, the golden file is
+// written instead.
+func AssertGoldenMetrics(t testing.TB, path string, md pmetric.Metrics, opts ...GoldenOption) bool {
+	t.Helper()
+	data, err := CanonicalMetrics(md, opts...)
+	return assertGolden(t, path, data, err)
+}
+
+// AssertGoldenLogs reports, with t, whether the canonical form of ld differs
+// from the golden file at path. It returns whether they are equal.
+//
+// If the UpdateGoldenEnv environment variable is set, the golden file is
+// written instead.
+func AssertGoldenLogs(t testing.TB, path string, ld plog.Logs, opts ...GoldenOption) bool {
+	t.Helper()
+	data, err := CanonicalLogs(ld, opts...)
+	return assertGolden(t, path, data, err)
+}
+
+func assertGolden(t testing.TB, path string, got []byte, err error) bool {
+	t.Helper()
+	if err != nil {
+		t.Errorf("golden %s: %v", path, err)
+		return false
+	}
+
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		err = os.MkdirAll(filepath.Dir(path), 0o755)
+		if err == nil {
+			err = os.WriteFile(path, got, 0o600)
+		}
+		if err != nil {
+			t.Errorf("golden %s: %v", path, err)
+			return false
+		}
+		return true
+	}
+
+	want, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		t.Errorf("golden %s: missing, set %s=1 to write it", path, UpdateGoldenEnv)
+		return false
+	} else if err != nil {
+		t.Errorf("golden %s: %v", path, err)
+		return false
+	}
+	if line, g, w, ok := firstDiff(got, want); ok {
+		t.Errorf("golden %s:%d: got %q, want %q", path, line, g, w)
+		return false
+	}
+	return true
+}
+
+// firstDiff returns the first line, and its trimmed content, where got and
+// want differ. It returns false if they are equal.
+func firstDiff(got, want []byte) (line int, g, w string, ok bool) {
+	if bytes.Equal(got, want) {
+		return 0, "", "", false
+	}
+	gotLines, wantLines := strings.Split(string(got), "\n"), strings.Split(string(want), "\n")
+	for i := range max(len(gotLines), len(wantLines)) {
+		g, w = "", ""
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if g != w {
+			return i + 1, strings.TrimSpace(g), strings.TrimSpace(w), true
+		}
+	}
+	return 0, "", "", false
+}
+
+// sorted are the arrays of OTLP JSON whose order is not meaningful.
+var sorted = map[string]bool{
+	"resourceSpans":   true,
+	"scopeSpans":      true,
+	"spans":           true,
+	"resourceMetrics": true,
+	"scopeMetrics":    true,
+	"metrics":         true,
+	"dataPoints":      true,
+	"resourceLogs":    true,
+	"scopeLogs":       true,
+	"logRecords":      true,
+	"attributes":      true,
+}
+
+// idLen are the hex lengths of the IDs in OTLP JSON.
+var idLen = map[string]int{
+	"traceId":      32,
+	"spanId":       16,
+	"parentSpanId": 16,
+}
+
+func canonical(data []byte, opts []GoldenOption) ([]byte, error) {
+	var c goldenConfig
+	for _, o := range opts {
+		c = o.apply(c)
+	}
+
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	if !c.keepTimestamps {
+		removeTimestamps(v)
+	}
+	if err := sortArrays(v, c.keepIDs); err != nil {
+		return nil, err
+	}
+	if !c.keepIDs {
+		renameIDs(v, make(map[string]string), make(map[int]int))
+	}
+
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+func removeTimestamps(v any) {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			if k == "timeUnixNano" || strings.HasSuffix(k, "TimeUnixNano") {
+				delete(v, k)
+				continue
+			}
+			removeTimestamps(e)
+		}
+	case []any:
+		for _, e := range v {
+			removeTimestamps(e)
+		}
+	}
+}
+
+// sortArrays sorts, bottom up, the arrays of v whose order is not meaningful
+// by their JSON encoding. The IDs are ignored unless keepIDs is true, so the
+// order does not depend on IDs that are later replaced.
+func sortArrays(v any, keepIDs bool) error {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			if err := sortArrays(e, keepIDs); err != nil {
+				return err
+			}
+			a, ok := e.([]any)
+			if !ok || !sorted[k] {
+				continue
+			}
+			keys := make(map[int]string, len(a))
+			for i, elem := range a {
+				if !keepIDs {
+					elem = withoutIDs(elem)
+				}
+				b, err := json.Marshal(elem)
+				if err != nil {
+					return err
+				}
+				keys[i] = string(b)
+			}
+			idx := make([]int, len(a))
+			for i := range idx {
+				idx[i] = i
+			}
+			slices.SortStableFunc(idx, func(i, j int) int { return strings.Compare(keys[i], keys[j]) })
+			s := make([]any, len(a))
+			for i, j := range idx {
+				s[i] = a[j]
+			}
+			v[k] = s
+		}
+	case []any:
+		for _, e := range v {
+			if err := sortArrays(e, keepIDs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// withoutIDs returns a copy of v without IDs.
+func withoutIDs(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			if _, ok := idLen[k]; !ok {
+				out[k] = withoutIDs(e)
+			}
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			out[i] = withoutIDs(e)
+		}
+		return out
+	}
+	return v
+}
+
+// renameIDs replaces the IDs in v with sequential ones in order of
+// appearance. Object keys are visited in sorted order. The same ID is always
+// replaced by the same sequential ID.
+func renameIDs(v any, ids map[string]string, next map[int]int) {
+	switch v := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			n, isID := idLen[k]
+			id, isStr := v[k].(string)
+			if !isID || !isStr || id == "" {
+				renameIDs(v[k], ids, next)
+				continue
+			}
+			if _, ok := ids[id]; !ok {
+				next[n]++
+				ids[id] = fmt.Sprintf("%0*x", n, next[n])
+			}
+			v[k] = ids[id]
+		}
+	case []any:
+		for _, e := range v {
+			renameIDs(e, ids, next)
+		}
+	}
+}
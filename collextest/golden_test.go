@@ -0,0 +1,93 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collextest_test
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/MrAlias/collex/collextest"
+	"github.com/MrAlias/collex/transmute"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// goldenSpans returns the converted spans of recordSpans without the resource
+// attributes that depend on the SDK version and test binary.
+func goldenSpans() ptrace.Traces {
+	td := transmute.Spans(recordSpans())
+	td.ResourceSpans().At(0).Resource().Attributes().Clear()
+	return td
+}
+
+func TestAssertGoldenTraces(t *testing.T) {
+	// Spans recorded at different times with different IDs are equal.
+	path := filepath.Join("testdata", "checkout.json")
+	collextest.AssertGoldenTraces(t, path, goldenSpans())
+	collextest.AssertGoldenTraces(t, path, goldenSpans())
+}
+
+func TestAssertGoldenTracesDiff(t *testing.T) {
+	td := goldenSpans()
+	td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).SetName("changed")
+
+	r := &recorder{TB: t}
+	if collextest.AssertGoldenTraces(r, filepath.Join("testdata", "checkout.json"), td) {
+		t.Fatal("changed spans match the golden file")
+	}
+	if len(r.errs) != 1 || !strings.Contains(r.errs[0], `got "\"name\": \"changed\","`) {
+		t.Errorf("errors: %q", r.errs)
+	}
+
+	r = &recorder{TB: t}
+	collextest.AssertGoldenTraces(r, filepath.Join(t.TempDir(), "missing.json"), td)
+	if len(r.errs) != 1 || !strings.Contains(r.errs[0], "missing") {
+		t.Errorf("errors: %q", r.errs)
+	}
+}
+
+func TestGoldenUpdate(t *testing.T) {
+	t.Setenv(collextest.UpdateGoldenEnv, "1")
+	path := filepath.Join(t.TempDir(), "golden", "spans.json")
+	if !collextest.AssertGoldenTraces(t, path, transmute.Spans(recordSpans())) {
+		t.Fatal("golden file not written")
+	}
+	t.Setenv(collextest.UpdateGoldenEnv, "")
+	collextest.AssertGoldenTraces(t, path, transmute.Spans(recordSpans()))
+}
+
+func TestCanonicalTracesKeepIDs(t *testing.T) {
+	spans := recordSpans()
+	a, err := collextest.CanonicalTraces(transmute.Spans(spans), collextest.KeepIDs(), collextest.KeepTimestamps())
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := spans[0].SpanContext().SpanID().String()
+	if !bytes.Contains(a, []byte(id)) {
+		t.Errorf("span ID %s not kept", id)
+	}
+	if !bytes.Contains(a, []byte("startTimeUnixNano")) {
+		t.Error("timestamps not kept")
+	}
+
+	b, err := collextest.CanonicalTraces(transmute.Spans(recordSpans()), collextest.KeepIDs())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(a, b) {
+		t.Error("spans with different IDs are equal")
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collextest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Eventually calls check until it returns nil, e.g. once the rows or messages
+// exported to a backend can be queried from it. It reports the last error
+// with t if check does not succeed within timeout. Backends for end-to-end
+// tests are started with the github.com/MrAlias/collex/collextest/integration
+// module.
+func Eventually(t testing.TB, timeout time.Duration, check func(context.Context) error) bool {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := poll(ctx, check); err != nil {
+		t.Errorf("eventually: %v", err)
+		return false
+	}
+	return true
+}
+
+// poll calls f until it returns nil or ctx is done. It returns the last error
+// of f if ctx is done first.
+func poll(ctx context.Context, f func(context.Context) error) error {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		err := f(ctx)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package integration_test
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"github.com/MrAlias/collex/clickhouse"
+	"github.com/MrAlias/collex/collextest"
+	"github.com/MrAlias/collex/collextest/integration"
+)
+
+func TestClickHouseSpans(t *testing.T) {
+	ch := integration.StartClickHouse(t)
+
+	ctx := context.Background()
+	exp, err := clickhouse.NewSpanExporter(ctx, clickhouse.WithDSN(ch.DSN), clickhouse.WithDatabase("otel"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = exp.Shutdown(ctx) })
+
+	spans := collextest.RandomSpans(rand.New(rand.NewPCG(1, 2)), 10)
+	if err := exp.ExportSpans(ctx, spans); err != nil {
+		t.Fatal(err)
+	}
+
+	collextest.Eventually(t, time.Minute, func(ctx context.Context) error {
+		n, err := ch.Count(ctx, "otel.otel_traces")
+		if err != nil {
+			return err
+		}
+		if n != uint64(len(spans)) {
+			return fmt.Errorf("%d rows, want %d", n, len(spans))
+		}
+		return nil
+	})
+
+	rows, err := ch.Query(ctx, "SELECT SpanName FROM otel.otel_traces WHERE SpanId = ?", spans[0].SpanContext().SpanID().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		t.Fatalf("span %s: no row", spans[0].SpanContext().SpanID())
+	}
+	var name string
+	if err := rows.Scan(&name); err != nil {
+		t.Fatal(err)
+	}
+	if name != spans[0].Name() {
+		t.Errorf("span name: got %q, want %q", name, spans[0].Name())
+	}
+}
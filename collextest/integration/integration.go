@@ -0,0 +1,185 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package integration provides an opt-in harness to test exporters against
+// real backends, e.g. a ClickHouse server or a Kafka broker, started in
+// containers with testcontainers. Tests export telemetry through a bridged
+// exporter and then verify the rows or messages the backend received, which
+// applications can reuse for their own end-to-end verification.
+//
+// Integration tests are opt-in: they are skipped unless the Env environment
+// variable is set.
+package integration // import "github.com/MrAlias/collex/collextest/integration"
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/IBM/sarama"
+	"github.com/testcontainers/testcontainers-go"
+	tcclickhouse "github.com/testcontainers/testcontainers-go/modules/clickhouse"
+	tckafka "github.com/testcontainers/testcontainers-go/modules/kafka"
+)
+
+// Env is the environment variable that, when set to a non-empty value, opts
+// in to integration tests.
+const Env = "COLLEXTEST_INTEGRATION"
+
+// The images the backends are started from.
+const (
+	ClickHouseImage = "clickhouse/clickhouse-server:24.8-alpine"
+	KafkaImage      = "confluentinc/confluent-local:7.5.0"
+)
+
+// OptIn skips t unless integration tests are opted in to with Env. It is
+// called by the Start functions, and by tests starting their own containers
+// with testcontainers.
+func OptIn(t testing.TB) {
+	t.Helper()
+	if os.Getenv(Env) == "" {
+		t.Skipf("integration test: set %s=1 to run", Env)
+	}
+}
+
+// ClickHouse is a ClickHouse server running in a container.
+type ClickHouse struct {
+	// DSN is the data source name of the server, e.g. for the endpoint of a
+	// clickhouse exporter.
+	DSN string
+
+	conn driver.Conn
+}
+
+// StartClickHouse starts a ClickHouse server customized with opts, e.g.
+// tcclickhouse.WithDatabase. The server is stopped when t and its subtests
+// complete.
+func StartClickHouse(t testing.TB, opts ...testcontainers.ContainerCustomizer) *ClickHouse {
+	t.Helper()
+	OptIn(t)
+
+	ctx := context.Background()
+	c, err := tcclickhouse.Run(ctx, ClickHouseImage, opts...)
+	testcontainers.CleanupContainer(t, c)
+	if err != nil {
+		t.Fatalf("clickhouse: %v", err)
+	}
+	dsn, err := c.ConnectionString(ctx)
+	if err != nil {
+		t.Fatalf("clickhouse: %v", err)
+	}
+	o, err := clickhouse.ParseDSN(dsn)
+	if err != nil {
+		t.Fatalf("clickhouse: %v", err)
+	}
+	conn, err := clickhouse.Open(o)
+	if err != nil {
+		t.Fatalf("clickhouse: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return &ClickHouse{DSN: dsn, conn: conn}
+}
+
+// Query runs query on the server and returns its rows.
+func (c *ClickHouse) Query(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+	return c.conn.Query(ctx, query, args...)
+}
+
+// Count returns the number of rows in table, e.g. otel_traces.
+func (c *ClickHouse) Count(ctx context.Context, table string) (uint64, error) {
+	var n uint64
+	err := c.conn.QueryRow(ctx, fmt.Sprintf("SELECT count() FROM %s", table)).Scan(&n)
+	return n, err
+}
+
+// Kafka is a Kafka broker running in a container.
+type Kafka struct {
+	// Brokers are the addresses of the broker, e.g. for the brokers of a
+	// kafka exporter.
+	Brokers []string
+}
+
+// StartKafka starts a Kafka broker customized with opts. The broker is stopped
+// when t and its subtests complete.
+func StartKafka(t testing.TB, opts ...testcontainers.ContainerCustomizer) *Kafka {
+	t.Helper()
+	OptIn(t)
+
+	ctx := context.Background()
+	c, err := tckafka.Run(ctx, KafkaImage, opts...)
+	testcontainers.CleanupContainer(t, c)
+	if err != nil {
+		t.Fatalf("kafka: %v", err)
+	}
+	brokers, err := c.Brokers(ctx)
+	if err != nil {
+		t.Fatalf("kafka: %v", err)
+	}
+	return &Kafka{Brokers: brokers}
+}
+
+// Messages returns the values of the messages published to topic so far. An
+// error is returned if the topic does not exist yet.
+func (k *Kafka) Messages(ctx context.Context, topic string) ([][]byte, error) {
+	client, err := sarama.NewClient(k.Brokers, sarama.NewConfig())
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		return nil, err
+	}
+	defer consumer.Close()
+
+	partitions, err := client.Partitions(topic)
+	if err != nil {
+		return nil, err
+	}
+	var msgs [][]byte
+	for _, p := range partitions {
+		oldest, err := client.GetOffset(topic, p, sarama.OffsetOldest)
+		if err != nil {
+			return nil, err
+		}
+		newest, err := client.GetOffset(topic, p, sarama.OffsetNewest)
+		if err != nil {
+			return nil, err
+		}
+		if oldest >= newest {
+			continue
+		}
+		pc, err := consumer.ConsumePartition(topic, p, oldest)
+		if err != nil {
+			return nil, err
+		}
+		for off := oldest; off < newest; {
+			select {
+			case m := <-pc.Messages():
+				msgs = append(msgs, m.Value)
+				off = m.Offset + 1
+			case <-ctx.Done():
+				_ = pc.Close()
+				return nil, ctx.Err()
+			}
+		}
+		if err := pc.Close(); err != nil {
+			return nil, err
+		}
+	}
+	return msgs, nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package integration_test
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"github.com/MrAlias/collex/collextest"
+	"github.com/MrAlias/collex/collextest/integration"
+	"github.com/MrAlias/collex/kafka"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestKafkaSpans(t *testing.T) {
+	k := integration.StartKafka(t)
+
+	ctx := context.Background()
+	const topic = "collex_spans"
+	exp, err := kafka.NewSpanExporter(ctx, kafka.WithBrokers(k.Brokers...), kafka.WithTracesTopic(topic))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = exp.Shutdown(ctx) })
+
+	spans := collextest.RandomSpans(rand.New(rand.NewPCG(1, 2)), 10)
+	if err := exp.ExportSpans(ctx, spans); err != nil {
+		t.Fatal(err)
+	}
+
+	got := ptrace.NewTraces()
+	collextest.Eventually(t, time.Minute, func(ctx context.Context) error {
+		msgs, err := k.Messages(ctx, topic)
+		if err != nil {
+			return err
+		}
+		if len(msgs) == 0 {
+			return errors.New("no messages")
+		}
+		got = ptrace.NewTraces()
+		var u ptrace.ProtoUnmarshaler
+		for _, m := range msgs {
+			td, err := u.UnmarshalTraces(m)
+			if err != nil {
+				return err
+			}
+			td.ResourceSpans().MoveAndAppendTo(got.ResourceSpans())
+		}
+		if got.SpanCount() < len(spans) {
+			return errors.New("spans missing")
+		}
+		return nil
+	})
+	collextest.AssertSpansEqual(t, spans, got)
+}
@@ -0,0 +1,53 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collextest_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MrAlias/collex/collextest"
+)
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEventually(t *testing.T) {
+	var n int
+	if !collextest.Eventually(t, time.Second, func(context.Context) error {
+		if n++; n < 3 {
+			return errors.New("no rows")
+		}
+		return nil
+	}) {
+		t.Error("check did not succeed")
+	}
+
+	r := &recorder{TB: t}
+	collextest.Eventually(r, 200*time.Millisecond, func(context.Context) error {
+		return errors.New("no rows")
+	})
+	if len(r.errs) != 1 || !strings.Contains(r.errs[0], "no rows") {
+		t.Errorf("errors: %q", r.errs)
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collextest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/MrAlias/collex"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/exporter/exporterhelper"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// Step is the scripted behavior of a call to the exporters of a
+// MockExporter.
+type Step struct {
+	err     error
+	release <-chan struct{}
+}
+
+// Succeed returns a Step that exports the telemetry.
+func Succeed() Step { return Step{} }
+
+// FailPermanent returns a Step that fails with err wrapped as a permanent
+// error, one exporter helpers do not retry.
+func FailPermanent(err error) Step {
+	return Step{err: consumererror.NewPermanent(err)}
+}
+
+// FailTransient returns a Step that fails with err, an error exporter helpers
+// retry. If retryAfter is positive, the retry is throttled to wait at least
+// retryAfter, as a backend responding with a Retry-After would.
+func FailTransient(err error, retryAfter time.Duration) Step {
+	if retryAfter > 0 {
+		err = exporterhelper.NewThrottleRetry(err, retryAfter)
+	}
+	return Step{err: err}
+}
+
+// Block returns a Step that blocks until release is closed and then exports
+// the telemetry. If the context of the export is done first, its error is
+// returned.
+func Block(release <-chan struct{}) Step {
+	return Step{release: release}
+}
+
+// run runs the step for an export with ctx.
+func (s Step) run(ctx context.Context) error {
+	if s.release != nil {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.release:
+		}
+	}
+	return s.err
+}
+
+// MockExporter is a collector exporter whose exports follow a script. It
+// tests how an application handles the errors and stalls of an exporter
+// bridged by collex deterministically.
+//
+// Each call of an exporter, of any signal, runs the next Step of the script.
+// Once the script is done, calls succeed. The telemetry of the successful
+// calls is recorded.
+type MockExporter struct {
+	mu      sync.Mutex
+	script  []Step
+	calls   int
+	traces  []ptrace.Traces
+	metrics []pmetric.Metrics
+	logs    []plog.Logs
+}
+
+// NewMockExporter returns a MockExporter with the script steps.
+func NewMockExporter(steps ...Step) *MockExporter {
+	return &MockExporter{script: steps}
+}
+
+// Script appends steps to the script of m.
+func (m *MockExporter) Script(steps ...Step) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.script = append(m.script, steps...)
+}
+
+// Factory returns a collector exporter factory, of type "mock", whose
+// exporters of all signals follow the script of m. It is configured with a
+// *collex.HelperConfig, so the exports go through the timeout, queue, and
+// retry helpers of the collector as those of a collector exporter do. Disable
+// the queue and retries for the scripted errors to be returned by exports.
+func (m *MockExporter) Factory() exporter.Factory {
+	traces, _ := consumer.NewTraces(func(ctx context.Context, td ptrace.Traces) error {
+		if err := m.next(ctx); err != nil {
+			return err
+		}
+		cp := ptrace.NewTraces()
+		td.CopyTo(cp)
+		m.mu.Lock()
+		m.traces = append(m.traces, cp)
+		m.mu.Unlock()
+		return nil
+	})
+	metrics, _ := consumer.NewMetrics(func(ctx context.Context, md pmetric.Metrics) error {
+		if err := m.next(ctx); err != nil {
+			return err
+		}
+		cp := pmetric.NewMetrics()
+		md.CopyTo(cp)
+		m.mu.Lock()
+		m.metrics = append(m.metrics, cp)
+		m.mu.Unlock()
+		return nil
+	})
+	logs, _ := consumer.NewLogs(func(ctx context.Context, ld plog.Logs) error {
+		if err := m.next(ctx); err != nil {
+			return err
+		}
+		cp := plog.NewLogs()
+		ld.CopyTo(cp)
+		m.mu.Lock()
+		m.logs = append(m.logs, cp)
+		m.mu.Unlock()
+		return nil
+	})
+	return collex.NewConsumerFactory(component.MustNewType("mock"), collex.Consumers{
+		Traces:  traces,
+		Metrics: metrics,
+		Logs:    logs,
+	})
+}
+
+// next runs the next step of the script.
+func (m *MockExporter) next(ctx context.Context) error {
+	m.mu.Lock()
+	m.calls++
+	var s Step
+	if len(m.script) > 0 {
+		s, m.script = m.script[0], m.script[1:]
+	}
+	m.mu.Unlock()
+	return s.run(ctx)
+}
+
+// Calls returns the number of calls made to the exporters of m.
+func (m *MockExporter) Calls() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.calls
+}
+
+// Traces returns the traces of the successful calls.
+func (m *MockExporter) Traces() []ptrace.Traces {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]ptrace.Traces(nil), m.traces...)
+}
+
+// Metrics returns the metrics of the successful calls.
+func (m *MockExporter) Metrics() []pmetric.Metrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]pmetric.Metrics(nil), m.metrics...)
+}
+
+// Logs returns the logs of the successful calls.
+func (m *MockExporter) Logs() []plog.Logs {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]plog.Logs(nil), m.logs...)
+}
@@ -0,0 +1,123 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collextest_test
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"github.com/MrAlias/collex"
+	"github.com/MrAlias/collex/collextest"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+func mockSpanExporter(t *testing.T, m *collextest.MockExporter, retry bool) trace.SpanExporter {
+	t.Helper()
+	f := m.Factory()
+	cfg := f.CreateDefaultConfig().(*collex.HelperConfig)
+	cfg.QueueConfig.Enabled = false
+	cfg.RetryConfig.Enabled = retry
+	cfg.RetryConfig.InitialInterval = time.Millisecond
+
+	factory, err := collex.NewFactory(f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp, err := factory.SpanExporter(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = exp.Shutdown(context.Background()) })
+	return exp
+}
+
+func TestMockExporterScript(t *testing.T) {
+	errDown := errors.New("down")
+	release := make(chan struct{})
+	m := collextest.NewMockExporter(
+		collextest.FailPermanent(errDown),
+		collextest.FailTransient(errDown, 0),
+		collextest.Block(release),
+	)
+	exp := mockSpanExporter(t, m, false)
+	spans := collextest.RandomSpans(rand.New(rand.NewPCG(1, 2)), 2)
+	ctx := context.Background()
+
+	err := exp.ExportSpans(ctx, spans)
+	if !errors.Is(err, errDown) || !consumererror.IsPermanent(err) {
+		t.Errorf("first export returned %v, want permanent %v", err, errDown)
+	}
+	err = exp.ExportSpans(ctx, spans)
+	if !errors.Is(err, errDown) || consumererror.IsPermanent(err) {
+		t.Errorf("second export returned %v, want transient %v", err, errDown)
+	}
+
+	done := make(chan error)
+	go func() { done <- exp.ExportSpans(ctx, spans) }()
+	select {
+	case err := <-done:
+		t.Fatalf("blocked export returned %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("released export returned %v", err)
+	}
+	if err := exp.ExportSpans(ctx, spans); err != nil {
+		t.Errorf("export after the script returned %v", err)
+	}
+
+	if got := m.Calls(); got != 4 {
+		t.Errorf("%d calls, want 4", got)
+	}
+	if got := len(m.Traces()); got != 2 {
+		t.Fatalf("%d traces recorded, want 2", got)
+	}
+	collextest.AssertSpansEqual(t, spans, m.Traces()[0])
+}
+
+func TestMockExporterBlockCanceled(t *testing.T) {
+	m := collextest.NewMockExporter(collextest.Block(make(chan struct{})))
+	exp := mockSpanExporter(t, m, false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := exp.ExportSpans(ctx, collextest.RandomSpans(rand.New(rand.NewPCG(1, 2)), 1))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled export returned %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestMockExporterRetryAfter(t *testing.T) {
+	const retryAfter = 50 * time.Millisecond
+	m := collextest.NewMockExporter()
+	m.Script(collextest.FailTransient(errors.New("throttled"), retryAfter))
+	exp := mockSpanExporter(t, m, true)
+
+	start := time.Now()
+	if err := exp.ExportSpans(context.Background(), collextest.RandomSpans(rand.New(rand.NewPCG(1, 2)), 1)); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < retryAfter {
+		t.Errorf("export retried after %s, want at least %s", elapsed, retryAfter)
+	}
+	if got := m.Calls(); got != 2 {
+		t.Errorf("%d calls, want 2", got)
+	}
+}
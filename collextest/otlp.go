@@ -0,0 +1,255 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collextest
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	"google.golang.org/grpc"
+	_ "google.golang.org/grpc/encoding/gzip" // Accept gzip compressed requests.
+	"google.golang.org/grpc/metadata"
+)
+
+// OTLPServer is an in-process OTLP backend that records the requests it
+// receives over gRPC and HTTP.
+type OTLPServer struct {
+	// GRPCEndpoint is the host:port address of the OTLP/gRPC endpoint,
+	// e.g. the endpoint of an otlp exporter.
+	GRPCEndpoint string
+	// HTTPEndpoint is the URL of the OTLP/HTTP endpoint, e.g. the endpoint
+	// of an otlphttp exporter. Signals are received on their default paths,
+	// e.g. /v1/traces.
+	HTTPEndpoint string
+
+	mu       sync.Mutex
+	requests []Request
+}
+
+// Request is an OTLP request received by an OTLPServer. Only the telemetry of
+// the signal it was sent for is set.
+type Request struct {
+	// Protocol is the protocol the request was sent with: "grpc",
+	// "http/protobuf", or "http/json".
+	Protocol string
+	// Header holds the HTTP headers, or gRPC metadata, of the request.
+	Header http.Header
+
+	Traces  ptrace.Traces
+	Metrics pmetric.Metrics
+	Logs    plog.Logs
+}
+
+// NewOTLPServer returns an OTLPServer listening on local ports. It is
+// stopped when t and its subtests complete.
+func NewOTLPServer(t testing.TB) *OTLPServer {
+	t.Helper()
+	s := new(OTLPServer)
+
+	gl, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("OTLP gRPC listener: %v", err)
+	}
+	gs := grpc.NewServer()
+	ptraceotlp.RegisterGRPCServer(gs, &traceServer{srv: s})
+	pmetricotlp.RegisterGRPCServer(gs, &metricServer{srv: s})
+	plogotlp.RegisterGRPCServer(gs, &logServer{srv: s})
+	go func() { _ = gs.Serve(gl) }()
+	t.Cleanup(gs.Stop)
+	s.GRPCEndpoint = gl.Addr().String()
+
+	hl, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("OTLP HTTP listener: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/traces", func(w http.ResponseWriter, r *http.Request) {
+		req := ptraceotlp.NewExportRequest()
+		s.serveHTTP(w, r, req, ptraceotlp.NewExportResponse(), func(rq *Request) { rq.Traces = req.Traces() })
+	})
+	mux.HandleFunc("POST /v1/metrics", func(w http.ResponseWriter, r *http.Request) {
+		req := pmetricotlp.NewExportRequest()
+		s.serveHTTP(w, r, req, pmetricotlp.NewExportResponse(), func(rq *Request) { rq.Metrics = req.Metrics() })
+	})
+	mux.HandleFunc("POST /v1/logs", func(w http.ResponseWriter, r *http.Request) {
+		req := plogotlp.NewExportRequest()
+		s.serveHTTP(w, r, req, plogotlp.NewExportResponse(), func(rq *Request) { rq.Logs = req.Logs() })
+	})
+	hs := &http.Server{Handler: mux}
+	go func() { _ = hs.Serve(hl) }()
+	t.Cleanup(func() { _ = hs.Close() })
+	s.HTTPEndpoint = "http://" + hl.Addr().String()
+
+	return s
+}
+
+// Requests returns the requests received, in the order they were received.
+func (s *OTLPServer) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Request, len(s.requests))
+	copy(out, s.requests)
+	return out
+}
+
+// Traces returns the traces received, in the order they were received.
+func (s *OTLPServer) Traces() []ptrace.Traces {
+	var out []ptrace.Traces
+	for _, r := range s.Requests() {
+		if r.Traces != (ptrace.Traces{}) {
+			out = append(out, r.Traces)
+		}
+	}
+	return out
+}
+
+// Metrics returns the metrics received, in the order they were received.
+func (s *OTLPServer) Metrics() []pmetric.Metrics {
+	var out []pmetric.Metrics
+	for _, r := range s.Requests() {
+		if r.Metrics != (pmetric.Metrics{}) {
+			out = append(out, r.Metrics)
+		}
+	}
+	return out
+}
+
+// Logs returns the logs received, in the order they were received.
+func (s *OTLPServer) Logs() []plog.Logs {
+	var out []plog.Logs
+	for _, r := range s.Requests() {
+		if r.Logs != (plog.Logs{}) {
+			out = append(out, r.Logs)
+		}
+	}
+	return out
+}
+
+func (s *OTLPServer) record(r Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r)
+}
+
+// recordGRPC records a request received over gRPC with the metadata of ctx.
+func (s *OTLPServer) recordGRPC(ctx context.Context, set func(*Request)) {
+	r := Request{Protocol: "grpc", Header: make(http.Header)}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for k, v := range md {
+		r.Header[http.CanonicalHeaderKey(k)] = v
+	}
+	set(&r)
+	s.record(r)
+}
+
+// otlpRequest is the export request of a signal.
+type otlpRequest interface {
+	UnmarshalProto([]byte) error
+	UnmarshalJSON([]byte) error
+}
+
+// otlpResponse is the export response of a signal.
+type otlpResponse interface {
+	MarshalProto() ([]byte, error)
+	MarshalJSON() ([]byte, error)
+}
+
+// serveHTTP decodes the body of r into req, records it with set, and replies
+// with resp encoded like the request.
+func (s *OTLPServer) serveHTTP(w http.ResponseWriter, r *http.Request, req otlpRequest, resp otlpResponse, set func(*Request)) {
+	body := r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body = zr
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rq := Request{Header: r.Header.Clone()}
+	var out []byte
+	switch ct := r.Header.Get("Content-Type"); {
+	case strings.HasPrefix(ct, "application/x-protobuf"):
+		rq.Protocol = "http/protobuf"
+		if err = req.UnmarshalProto(data); err == nil {
+			out, err = resp.MarshalProto()
+		}
+	case strings.HasPrefix(ct, "application/json"):
+		rq.Protocol = "http/json"
+		if err = req.UnmarshalJSON(data); err == nil {
+			out, err = resp.MarshalJSON()
+		}
+	default:
+		http.Error(w, "unsupported content type: "+ct, http.StatusUnsupportedMediaType)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	set(&rq)
+	s.record(rq)
+	w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+	_, _ = w.Write(out)
+}
+
+type traceServer struct {
+	ptraceotlp.UnimplementedGRPCServer
+	srv *OTLPServer
+}
+
+func (s *traceServer) Export(ctx context.Context, req ptraceotlp.ExportRequest) (ptraceotlp.ExportResponse, error) {
+	s.srv.recordGRPC(ctx, func(r *Request) { r.Traces = req.Traces() })
+	return ptraceotlp.NewExportResponse(), nil
+}
+
+type metricServer struct {
+	pmetricotlp.UnimplementedGRPCServer
+	srv *OTLPServer
+}
+
+func (s *metricServer) Export(ctx context.Context, req pmetricotlp.ExportRequest) (pmetricotlp.ExportResponse, error) {
+	s.srv.recordGRPC(ctx, func(r *Request) { r.Metrics = req.Metrics() })
+	return pmetricotlp.NewExportResponse(), nil
+}
+
+type logServer struct {
+	plogotlp.UnimplementedGRPCServer
+	srv *OTLPServer
+}
+
+func (s *logServer) Export(ctx context.Context, req plogotlp.ExportRequest) (plogotlp.ExportResponse, error) {
+	s.srv.recordGRPC(ctx, func(r *Request) { r.Logs = req.Logs() })
+	return plogotlp.NewExportResponse(), nil
+}
@@ -0,0 +1,133 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collextest_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/MrAlias/collex/collextest"
+	"github.com/MrAlias/collex/transmute"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestOTLPServerGRPC(t *testing.T) {
+	srv := collextest.NewOTLPServer(t)
+	conn, err := grpc.NewClient(srv.GRPCEndpoint, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	spans := recordSpans()
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-tenant", "acme")
+	_, err = ptraceotlp.NewGRPCClient(conn).Export(ctx, ptraceotlp.NewExportRequestFromTraces(transmute.Spans(spans)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	md := pmetric.NewMetrics()
+	md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty().SetName("requests")
+	if _, err = pmetricotlp.NewGRPCClient(conn).Export(ctx, pmetricotlp.NewExportRequestFromMetrics(md)); err != nil {
+		t.Fatal(err)
+	}
+
+	reqs := srv.Requests()
+	if len(reqs) != 2 {
+		t.Fatalf("got %d requests, want 2", len(reqs))
+	}
+	if reqs[0].Protocol != "grpc" {
+		t.Errorf("protocol: got %q, want grpc", reqs[0].Protocol)
+	}
+	if got := reqs[0].Header.Get("X-Tenant"); got != "acme" {
+		t.Errorf("x-tenant metadata: got %q, want acme", got)
+	}
+	if got := srv.Traces(); len(got) != 1 {
+		t.Fatalf("got %d traces, want 1", len(got))
+	}
+	collextest.AssertSpansEqual(t, spans, srv.Traces()[0])
+	if got := srv.Metrics(); len(got) != 1 || got[0].MetricCount() != 1 {
+		t.Errorf("metrics: got %d", len(got))
+	}
+	if got := srv.Logs(); len(got) != 0 {
+		t.Errorf("got %d logs, want none", len(got))
+	}
+}
+
+func TestOTLPServerHTTP(t *testing.T) {
+	srv := collextest.NewOTLPServer(t)
+
+	ld := plog.NewLogs()
+	ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().Body().SetStr("hello")
+	req := plogotlp.NewExportRequestFromLogs(ld)
+
+	proto, err := req.MarshalProto()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, _ = zw.Write(proto)
+	_ = zw.Close()
+	post(t, srv.HTTPEndpoint+"/v1/logs", "application/x-protobuf", "gzip", buf.Bytes())
+
+	js, err := req.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	post(t, srv.HTTPEndpoint+"/v1/logs", "application/json", "", js)
+
+	reqs := srv.Requests()
+	if len(reqs) != 2 {
+		t.Fatalf("got %d requests, want 2", len(reqs))
+	}
+	for i, want := range []string{"http/protobuf", "http/json"} {
+		if reqs[i].Protocol != want {
+			t.Errorf("request %d protocol: got %q, want %q", i, reqs[i].Protocol, want)
+		}
+		if got := reqs[i].Logs.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).Body().Str(); got != "hello" {
+			t.Errorf("request %d body: got %q, want hello", i, got)
+		}
+	}
+}
+
+func post(t *testing.T, url, contentType, encoding string, body []byte) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST %s: %s", url, resp.Status)
+	}
+}
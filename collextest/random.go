@@ -0,0 +1,419 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collextest
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	api "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/log/logtest"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// epoch is the earliest time of generated telemetry.
+var epoch = time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// RandomSpans returns n spans with random values drawn from r. The spans are
+// spread across a few resources and instrumentation scopes, and only hold
+// values pdata can represent.
+func RandomSpans(r *rand.Rand, n int) []trace.ReadOnlySpan {
+	resources := []*resource.Resource{randomResource(r), randomResource(r)}
+	scopes := []instrumentation.Scope{randomScope(r), randomScope(r)}
+
+	spans := make([]trace.ReadOnlySpan, n)
+	for i := range spans {
+		sc := randomSpanContext(r)
+		start := randomTime(r)
+		stub := tracetest.SpanStub{
+			Name:                 randomString(r),
+			SpanContext:          sc,
+			SpanKind:             oteltrace.SpanKind(r.IntN(6)),
+			StartTime:            start,
+			EndTime:              start.Add(time.Duration(r.Int64N(int64(time.Minute)))),
+			Attributes:           randomAttributes(r),
+			Status:               trace.Status{Code: codes.Code(r.IntN(3)), Description: randomString(r)},
+			DroppedAttributes:    r.IntN(3),
+			DroppedEvents:        r.IntN(3),
+			DroppedLinks:         r.IntN(3),
+			Resource:             resources[r.IntN(len(resources))],
+			InstrumentationScope: scopes[r.IntN(len(scopes))],
+		}
+		if r.IntN(2) == 0 {
+			stub.Parent = oteltrace.NewSpanContext(oteltrace.SpanContextConfig{
+				TraceID: sc.TraceID(),
+				SpanID:  randomSpanContext(r).SpanID(),
+			})
+		}
+		for range r.IntN(3) {
+			stub.Events = append(stub.Events, trace.Event{
+				Name:                  randomString(r),
+				Attributes:            randomAttributes(r),
+				DroppedAttributeCount: r.IntN(3),
+				Time:                  randomTime(r),
+			})
+		}
+		for range r.IntN(3) {
+			stub.Links = append(stub.Links, trace.Link{
+				SpanContext:           randomSpanContext(r),
+				Attributes:            randomAttributes(r),
+				DroppedAttributeCount: r.IntN(3),
+			})
+		}
+		spans[i] = stub.Snapshot()
+	}
+	return spans
+}
+
+// RandomMetrics returns n metrics with random values drawn from r. The
+// metrics are of all aggregations, spread across a few instrumentation
+// scopes, and only hold values pdata can represent, e.g. histograms are of
+// float64 values.
+func RandomMetrics(r *rand.Rand, n int) *metricdata.ResourceMetrics {
+	rm := &metricdata.ResourceMetrics{Resource: randomResource(r)}
+	if n == 0 {
+		return rm
+	}
+	rm.ScopeMetrics = make([]metricdata.ScopeMetrics, 1+r.IntN(min(n, 2)))
+	for i := range rm.ScopeMetrics {
+		rm.ScopeMetrics[i].Scope = randomScope(r)
+	}
+	for range n {
+		sm := &rm.ScopeMetrics[r.IntN(len(rm.ScopeMetrics))]
+		sm.Metrics = append(sm.Metrics, metricdata.Metrics{
+			Name:        randomString(r),
+			Description: randomString(r),
+			Unit:        randomString(r),
+			Data:        randomAggregation(r),
+		})
+	}
+	return rm
+}
+
+// RandomLogs returns n log records with random values drawn from r. The
+// records are spread across a few resources and instrumentation scopes, and
+// only hold values pdata can represent.
+func RandomLogs(r *rand.Rand, n int) []log.Record {
+	resources := []*resource.Resource{randomResource(r), randomResource(r)}
+	scopes := []instrumentation.Scope{randomScope(r), randomScope(r)}
+
+	records := make([]log.Record, n)
+	for i := range records {
+		f := logtest.RecordFactory{
+			Timestamp:            randomTime(r),
+			ObservedTimestamp:    randomTime(r),
+			Severity:             api.Severity(r.IntN(25)),
+			SeverityText:         randomString(r),
+			Body:                 randomValue(r, 2),
+			Resource:             resources[r.IntN(len(resources))],
+			InstrumentationScope: &scopes[r.IntN(len(scopes))],
+			DroppedAttributes:    r.IntN(3),
+		}
+		for j := range r.IntN(4) {
+			f.Attributes = append(f.Attributes, api.KeyValue{
+				Key:   fmt.Sprintf("key.%d", j),
+				Value: randomValue(r, 1),
+			})
+		}
+		if r.IntN(2) == 0 {
+			sc := randomSpanContext(r)
+			f.TraceID, f.SpanID, f.TraceFlags = sc.TraceID(), sc.SpanID(), sc.TraceFlags()
+		}
+		records[i] = f.NewRecord()
+	}
+	return records
+}
+
+func randomTime(r *rand.Rand) time.Time {
+	return epoch.Add(time.Duration(r.Int64N(int64(24 * time.Hour))))
+}
+
+func randomString(r *rand.Rand) string {
+	const chars = "abcdefghijklmnopqrstuvwxyz0123456789_ ."
+	b := make([]byte, r.IntN(12))
+	for i := range b {
+		b[i] = chars[r.IntN(len(chars))]
+	}
+	return string(b)
+}
+
+func randomResource(r *rand.Rand) *resource.Resource {
+	var schemaURL string
+	if r.IntN(2) == 0 {
+		schemaURL = "https://opentelemetry.io/schemas/1.26.0"
+	}
+	return resource.NewWithAttributes(schemaURL, randomAttributes(r)...)
+}
+
+func randomScope(r *rand.Rand) instrumentation.Scope {
+	s := instrumentation.Scope{
+		Name:       "scope." + randomString(r),
+		Version:    randomString(r),
+		Attributes: attribute.NewSet(randomAttributes(r)...),
+	}
+	if r.IntN(2) == 0 {
+		s.SchemaURL = "https://opentelemetry.io/schemas/1.26.0"
+	}
+	return s
+}
+
+func randomSpanContext(r *rand.Rand) oteltrace.SpanContext {
+	var tid oteltrace.TraceID
+	var sid oteltrace.SpanID
+	for i := range tid {
+		tid[i] = byte(r.Uint32())
+	}
+	for i := range sid {
+		sid[i] = byte(r.Uint32())
+	}
+	// Ensure the IDs are valid.
+	tid[0], sid[0] = 1+byte(r.IntN(255)), 1+byte(r.IntN(255))
+
+	cfg := oteltrace.SpanContextConfig{
+		TraceID:    tid,
+		SpanID:     sid,
+		TraceFlags: oteltrace.TraceFlags(r.IntN(2)),
+	}
+	if r.IntN(2) == 0 {
+		cfg.TraceState, _ = oteltrace.ParseTraceState("vendor=" + fmt.Sprint(r.Uint32()))
+	}
+	return oteltrace.NewSpanContext(cfg)
+}
+
+// randomAttributes returns up to 4 attributes of random types with distinct
+// keys.
+func randomAttributes(r *rand.Rand) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, r.IntN(5))
+	for i := range attrs {
+		attrs[i] = randomAttribute(r, fmt.Sprintf("key.%d", i))
+	}
+	return attrs
+}
+
+func randomAttribute(r *rand.Rand, k string) attribute.KeyValue {
+	switch r.IntN(8) {
+	case 0:
+		return attribute.Bool(k, r.IntN(2) == 0)
+	case 1:
+		return attribute.Int64(k, r.Int64())
+	case 2:
+		return attribute.Float64(k, r.NormFloat64())
+	case 3:
+		return attribute.BoolSlice(k, randomSlice(r, func(r *rand.Rand) bool { return r.IntN(2) == 0 }))
+	case 4:
+		return attribute.Int64Slice(k, randomSlice(r, (*rand.Rand).Int64))
+	case 5:
+		return attribute.Float64Slice(k, randomSlice(r, (*rand.Rand).NormFloat64))
+	case 6:
+		return attribute.StringSlice(k, randomSlice(r, randomString))
+	}
+	return attribute.String(k, randomString(r))
+}
+
+// randomSlice returns 1 to 3 values returned by v.
+func randomSlice[T any](r *rand.Rand, v func(*rand.Rand) T) []T {
+	s := make([]T, 1+r.IntN(3))
+	for i := range s {
+		s[i] = v(r)
+	}
+	return s
+}
+
+// randomValue returns a log value of a random kind. Slices and maps are
+// nested up to depth levels.
+func randomValue(r *rand.Rand, depth int) api.Value {
+	kinds := 6
+	if depth > 0 {
+		kinds = 8
+	}
+	switch r.IntN(kinds) {
+	case 0:
+		return api.Value{}
+	case 1:
+		return api.BoolValue(r.IntN(2) == 0)
+	case 2:
+		return api.Int64Value(r.Int64())
+	case 3:
+		return api.Float64Value(r.NormFloat64())
+	case 4:
+		return api.BytesValue([]byte(randomString(r)))
+	case 6:
+		vals := make([]api.Value, r.IntN(3))
+		for i := range vals {
+			vals[i] = randomValue(r, depth-1)
+		}
+		return api.SliceValue(vals...)
+	case 7:
+		kvs := make([]api.KeyValue, r.IntN(3))
+		for i := range kvs {
+			kvs[i] = api.KeyValue{Key: fmt.Sprintf("key.%d", i), Value: randomValue(r, depth-1)}
+		}
+		return api.MapValue(kvs...)
+	}
+	return api.StringValue(randomString(r))
+}
+
+func randomAggregation(r *rand.Rand) metricdata.Aggregation {
+	temporality := metricdata.Temporality(1 + r.IntN(2))
+	switch r.IntN(7) {
+	case 0:
+		return metricdata.Gauge[int64]{DataPoints: randomDataPoints(r, (*rand.Rand).Int64)}
+	case 1:
+		return metricdata.Gauge[float64]{DataPoints: randomDataPoints(r, (*rand.Rand).NormFloat64)}
+	case 2:
+		return metricdata.Sum[int64]{
+			DataPoints:  randomDataPoints(r, (*rand.Rand).Int64),
+			Temporality: temporality,
+			IsMonotonic: r.IntN(2) == 0,
+		}
+	case 3:
+		return metricdata.Sum[float64]{
+			DataPoints:  randomDataPoints(r, (*rand.Rand).NormFloat64),
+			Temporality: temporality,
+			IsMonotonic: r.IntN(2) == 0,
+		}
+	case 4:
+		return metricdata.Histogram[float64]{
+			DataPoints:  randomHistogramDataPoints(r),
+			Temporality: temporality,
+		}
+	case 5:
+		return metricdata.ExponentialHistogram[float64]{
+			DataPoints:  randomExponentialHistogramDataPoints(r),
+			Temporality: temporality,
+		}
+	}
+	return metricdata.Summary{DataPoints: randomSummaryDataPoints(r)}
+}
+
+func randomDataPoints[N int64 | float64](r *rand.Rand, v func(*rand.Rand) N) []metricdata.DataPoint[N] {
+	dps := make([]metricdata.DataPoint[N], 1+r.IntN(3))
+	for i := range dps {
+		start := randomTime(r)
+		dps[i] = metricdata.DataPoint[N]{
+			Attributes: attribute.NewSet(randomAttributes(r)...),
+			StartTime:  start,
+			Time:       start.Add(time.Second),
+			Value:      v(r),
+			Exemplars:  randomExemplars(r, v),
+		}
+	}
+	return dps
+}
+
+func randomHistogramDataPoints(r *rand.Rand) []metricdata.HistogramDataPoint[float64] {
+	dps := make([]metricdata.HistogramDataPoint[float64], 1+r.IntN(3))
+	for i := range dps {
+		start := randomTime(r)
+		bounds := randomSlice(r, (*rand.Rand).NormFloat64)
+		dps[i] = metricdata.HistogramDataPoint[float64]{
+			Attributes:   attribute.NewSet(randomAttributes(r)...),
+			StartTime:    start,
+			Time:         start.Add(time.Second),
+			Count:        r.Uint64N(100),
+			Bounds:       bounds,
+			BucketCounts: randomCounts(r, len(bounds)+1),
+			Min:          randomExtrema(r),
+			Max:          randomExtrema(r),
+			Sum:          r.NormFloat64(),
+			Exemplars:    randomExemplars(r, (*rand.Rand).NormFloat64),
+		}
+	}
+	return dps
+}
+
+func randomExponentialHistogramDataPoints(r *rand.Rand) []metricdata.ExponentialHistogramDataPoint[float64] {
+	dps := make([]metricdata.ExponentialHistogramDataPoint[float64], 1+r.IntN(3))
+	for i := range dps {
+		start := randomTime(r)
+		dps[i] = metricdata.ExponentialHistogramDataPoint[float64]{
+			Attributes:     attribute.NewSet(randomAttributes(r)...),
+			StartTime:      start,
+			Time:           start.Add(time.Second),
+			Count:          r.Uint64N(100),
+			Min:            randomExtrema(r),
+			Max:            randomExtrema(r),
+			Sum:            r.NormFloat64(),
+			Scale:          r.Int32N(21) - 10,
+			ZeroCount:      r.Uint64N(10),
+			PositiveBucket: metricdata.ExponentialBucket{Offset: r.Int32N(10), Counts: randomCounts(r, 1+r.IntN(4))},
+			NegativeBucket: metricdata.ExponentialBucket{Offset: r.Int32N(10), Counts: randomCounts(r, 1+r.IntN(4))},
+			ZeroThreshold:  r.Float64(),
+			Exemplars:      randomExemplars(r, (*rand.Rand).NormFloat64),
+		}
+	}
+	return dps
+}
+
+func randomSummaryDataPoints(r *rand.Rand) []metricdata.SummaryDataPoint {
+	dps := make([]metricdata.SummaryDataPoint, 1+r.IntN(3))
+	for i := range dps {
+		start := randomTime(r)
+		dps[i] = metricdata.SummaryDataPoint{
+			Attributes: attribute.NewSet(randomAttributes(r)...),
+			StartTime:  start,
+			Time:       start.Add(time.Second),
+			Count:      r.Uint64N(100),
+			Sum:        r.NormFloat64(),
+			QuantileValues: []metricdata.QuantileValue{
+				{Quantile: 0.5, Value: r.NormFloat64()},
+				{Quantile: 0.99, Value: r.NormFloat64()},
+			},
+		}
+	}
+	return dps
+}
+
+func randomCounts(r *rand.Rand, n int) []uint64 {
+	counts := make([]uint64, n)
+	for i := range counts {
+		counts[i] = r.Uint64N(10)
+	}
+	return counts
+}
+
+func randomExtrema(r *rand.Rand) metricdata.Extrema[float64] {
+	if r.IntN(2) == 0 {
+		return metricdata.Extrema[float64]{}
+	}
+	return metricdata.NewExtrema(r.NormFloat64())
+}
+
+func randomExemplars[N int64 | float64](r *rand.Rand, v func(*rand.Rand) N) []metricdata.Exemplar[N] {
+	var exemplars []metricdata.Exemplar[N]
+	for range r.IntN(3) {
+		e := metricdata.Exemplar[N]{
+			FilteredAttributes: randomAttributes(r),
+			Time:               randomTime(r),
+			Value:              v(r),
+		}
+		if r.IntN(2) == 0 {
+			sc := randomSpanContext(r)
+			tid, sid := sc.TraceID(), sc.SpanID()
+			e.TraceID, e.SpanID = tid[:], sid[:]
+		}
+		exemplars = append(exemplars, e)
+	}
+	return exemplars
+}
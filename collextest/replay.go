@@ -0,0 +1,301 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collextest
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/MrAlias/collex"
+	"github.com/MrAlias/collex/transmute"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// LoadTraces returns the traces of the OTLP capture file at path, one per
+// recorded export request.
+//
+// Files with a .json or .jsonl extension hold OTLP JSON requests, one after
+// the other, e.g. one per line. Other files hold OTLP protobuf requests each
+// prefixed by its length as a big-endian uint32, the format of the proto
+// files of the collector file exporter. In both formats, requests without
+// telemetry of the signal are skipped, so a capture of all signals can be
+// loaded.
+func LoadTraces(path string) ([]ptrace.Traces, error) {
+	return load(path, (&ptrace.JSONUnmarshaler{}).UnmarshalTraces, (&ptrace.ProtoUnmarshaler{}).UnmarshalTraces, ptrace.Traces.SpanCount)
+}
+
+// LoadMetrics returns the metrics of the OTLP capture file at path, one per
+// recorded export request. See LoadTraces for the file formats.
+func LoadMetrics(path string) ([]pmetric.Metrics, error) {
+	return load(path, (&pmetric.JSONUnmarshaler{}).UnmarshalMetrics, (&pmetric.ProtoUnmarshaler{}).UnmarshalMetrics, pmetric.Metrics.DataPointCount)
+}
+
+// LoadLogs returns the logs of the OTLP capture file at path, one per
+// recorded export request. See LoadTraces for the file formats.
+func LoadLogs(path string) ([]plog.Logs, error) {
+	return load(path, (&plog.JSONUnmarshaler{}).UnmarshalLogs, (&plog.ProtoUnmarshaler{}).UnmarshalLogs, plog.Logs.LogRecordCount)
+}
+
+// maxCaptureRequest is the size of the largest protobuf request loaded from a
+// capture file. Larger length prefixes are considered corrupt instead of being
+// allocated.
+const maxCaptureRequest = 64 << 20
+
+func load[T any](path string, fromJSON, fromProto func([]byte) (T, error), count func(T) int) ([]T, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var out []T
+	switch filepath.Ext(path) {
+	case ".json", ".jsonl":
+		dec := json.NewDecoder(f)
+		for {
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); errors.Is(err, io.EOF) {
+				return out, nil
+			} else if err != nil {
+				return nil, fmt.Errorf("capture %s: %w", path, err)
+			}
+			v, err := fromJSON(raw)
+			if err != nil {
+				return nil, fmt.Errorf("capture %s: %w", path, err)
+			}
+			if count(v) > 0 {
+				out = append(out, v)
+			}
+		}
+	default:
+		r := bufio.NewReader(f)
+		for {
+			var n uint32
+			if err := binary.Read(r, binary.BigEndian, &n); errors.Is(err, io.EOF) {
+				return out, nil
+			} else if err != nil {
+				return nil, fmt.Errorf("capture %s: %w", path, err)
+			}
+			if n > maxCaptureRequest {
+				return nil, fmt.Errorf("capture %s: request of %d bytes exceeds %d bytes", path, n, maxCaptureRequest)
+			}
+			buf := make([]byte, n)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return nil, fmt.Errorf("capture %s: %w", path, err)
+			}
+			v, err := fromProto(buf)
+			if err != nil {
+				return nil, fmt.Errorf("capture %s: %w", path, err)
+			}
+			if count(v) > 0 {
+				out = append(out, v)
+			}
+		}
+	}
+}
+
+// Replayer replays recorded telemetry through the exporters of collex, or
+// any other OpenTelemetry Go exporter, for regression and capacity tests
+// with realistic traffic.
+//
+// The telemetry is exported at its original timing, the time between the
+// earliest timestamps of consecutive batches, divided by the Speed.
+type Replayer struct {
+	// Speed is the factor the original timing is accelerated by, e.g. 10
+	// replays ten times faster. If zero or less, the batches are exported
+	// one after the other without waiting.
+	Speed float64
+	// Clock, if not nil, is the Clock the timing is measured with instead of
+	// the system clock.
+	Clock collex.Clock
+}
+
+// ReplayTraces exports each batch of traces with exp. It returns the first
+// export error, or the error of ctx if it is done before the replay is.
+func (r Replayer) ReplayTraces(ctx context.Context, exp trace.SpanExporter, batches []ptrace.Traces) error {
+	return r.replay(ctx, len(batches), func(i int) time.Time {
+		return earliestSpan(batches[i])
+	}, func(ctx context.Context, i int) error {
+		return exp.ExportSpans(ctx, transmute.ReadOnlySpans(batches[i]))
+	})
+}
+
+// ReplayMetrics exports each batch of metrics with exp. It returns the first
+// export error, or the error of ctx if it is done before the replay is.
+func (r Replayer) ReplayMetrics(ctx context.Context, exp metric.Exporter, batches []pmetric.Metrics) error {
+	return r.replay(ctx, len(batches), func(i int) time.Time {
+		return earliestDataPoint(batches[i])
+	}, func(ctx context.Context, i int) error {
+		var err error
+		for _, rm := range transmute.ResourceMetrics(batches[i]) {
+			err = errors.Join(err, exp.Export(ctx, rm))
+		}
+		return err
+	})
+}
+
+// ReplayLogs exports each batch of logs with exp. It returns the first export
+// error, or the error of ctx if it is done before the replay is.
+func (r Replayer) ReplayLogs(ctx context.Context, exp log.Exporter, batches []plog.Logs) error {
+	return r.replay(ctx, len(batches), func(i int) time.Time {
+		return earliestLogRecord(batches[i])
+	}, func(ctx context.Context, i int) error {
+		return exp.Export(ctx, transmute.Records(batches[i]))
+	})
+}
+
+// replay calls export for each of the n batches at the time, relative to the
+// first, returned by at.
+func (r Replayer) replay(ctx context.Context, n int, at func(int) time.Time, export func(context.Context, int) error) error {
+	clock := r.Clock
+	if clock == nil {
+		clock = systemClock{}
+	}
+
+	start := clock.Now()
+	var first time.Time
+	for i := range n {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if ts := at(i); r.Speed > 0 && !ts.IsZero() {
+			if first.IsZero() {
+				first = ts
+			}
+			due := start.Add(time.Duration(float64(ts.Sub(first)) / r.Speed))
+			if d := due.Sub(clock.Now()); d > 0 {
+				t := clock.NewTimer(d)
+				select {
+				case <-ctx.Done():
+					t.Stop()
+					return ctx.Err()
+				case <-t.C():
+				}
+			}
+		}
+		if err := export(ctx, i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// earliest returns the earliest non-zero timestamp of ts as a time, or the
+// zero time if there is none.
+func earliest(ts ...pcommon.Timestamp) time.Time {
+	var first pcommon.Timestamp
+	for _, t := range ts {
+		if t != 0 && (first == 0 || t < first) {
+			first = t
+		}
+	}
+	if first == 0 {
+		return time.Time{}
+	}
+	return first.AsTime()
+}
+
+func earliestSpan(td ptrace.Traces) time.Time {
+	var ts []pcommon.Timestamp
+	for i := range td.ResourceSpans().Len() {
+		sss := td.ResourceSpans().At(i).ScopeSpans()
+		for j := range sss.Len() {
+			spans := sss.At(j).Spans()
+			for k := range spans.Len() {
+				ts = append(ts, spans.At(k).StartTimestamp())
+			}
+		}
+	}
+	return earliest(ts...)
+}
+
+func earliestDataPoint(md pmetric.Metrics) time.Time {
+	var ts []pcommon.Timestamp
+	for i := range md.ResourceMetrics().Len() {
+		sms := md.ResourceMetrics().At(i).ScopeMetrics()
+		for j := range sms.Len() {
+			ms := sms.At(j).Metrics()
+			for k := range ms.Len() {
+				ts = append(ts, dataPointTimestamps(ms.At(k))...)
+			}
+		}
+	}
+	return earliest(ts...)
+}
+
+func dataPointTimestamps(m pmetric.Metric) []pcommon.Timestamp {
+	var ts []pcommon.Timestamp
+	switch m.Type() {
+	case pmetric.MetricTypeGauge:
+		dps := m.Gauge().DataPoints()
+		for i := range dps.Len() {
+			ts = append(ts, dps.At(i).Timestamp())
+		}
+	case pmetric.MetricTypeSum:
+		dps := m.Sum().DataPoints()
+		for i := range dps.Len() {
+			ts = append(ts, dps.At(i).Timestamp())
+		}
+	case pmetric.MetricTypeHistogram:
+		dps := m.Histogram().DataPoints()
+		for i := range dps.Len() {
+			ts = append(ts, dps.At(i).Timestamp())
+		}
+	case pmetric.MetricTypeExponentialHistogram:
+		dps := m.ExponentialHistogram().DataPoints()
+		for i := range dps.Len() {
+			ts = append(ts, dps.At(i).Timestamp())
+		}
+	case pmetric.MetricTypeSummary:
+		dps := m.Summary().DataPoints()
+		for i := range dps.Len() {
+			ts = append(ts, dps.At(i).Timestamp())
+		}
+	}
+	return ts
+}
+
+func earliestLogRecord(ld plog.Logs) time.Time {
+	var ts []pcommon.Timestamp
+	for i := range ld.ResourceLogs().Len() {
+		sls := ld.ResourceLogs().At(i).ScopeLogs()
+		for j := range sls.Len() {
+			lrs := sls.At(j).LogRecords()
+			for k := range lrs.Len() {
+				lr := lrs.At(k)
+				if lr.Timestamp() != 0 {
+					ts = append(ts, lr.Timestamp())
+				} else {
+					ts = append(ts, lr.ObservedTimestamp())
+				}
+			}
+		}
+	}
+	return earliest(ts...)
+}
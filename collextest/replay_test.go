@@ -0,0 +1,171 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collextest_test
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/MrAlias/collex/collextest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// capturedTraces returns traces of a span started at start.
+func capturedTraces(name string, start time.Time) ptrace.Traces {
+	td := ptrace.NewTraces()
+	s := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	s.SetName(name)
+	s.SetTraceID(pcommon.TraceID{1})
+	s.SetSpanID(pcommon.SpanID{byte(len(name))})
+	s.SetStartTimestamp(pcommon.NewTimestampFromTime(start))
+	s.SetEndTimestamp(pcommon.NewTimestampFromTime(start.Add(time.Millisecond)))
+	return td
+}
+
+func TestLoadTracesJSON(t *testing.T) {
+	var data []byte
+	for _, name := range []string{"a", "b"} {
+		b, err := (&ptrace.JSONMarshaler{}).MarshalTraces(capturedTraces(name, time.Unix(1, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(append(data, b...), '\n')
+	}
+	// Requests of other signals are skipped.
+	b, err := (&pmetric.JSONMarshaler{}).MarshalMetrics(gauge(1, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = append(data, b...)
+
+	path := filepath.Join(t.TempDir(), "capture.jsonl")
+	writeFile(t, path, string(data))
+	got, err := collextest.LoadTraces(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("loaded %d traces, want 2", len(got))
+	}
+	if name := got[1].ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Name(); name != "b" {
+		t.Errorf("second span name %q, want %q", name, "b")
+	}
+}
+
+func TestLoadMetricsProto(t *testing.T) {
+	want := []pmetric.Metrics{gauge(1, 1, 2), gauge(2, 3)}
+	var data []byte
+	for _, md := range want {
+		b, err := (&pmetric.ProtoMarshaler{}).MarshalMetrics(md)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = binary.BigEndian.AppendUint32(data, uint32(len(b)))
+		data = append(data, b...)
+	}
+	// Empty requests are skipped, like in JSON captures.
+	data = binary.BigEndian.AppendUint32(data, 0)
+
+	path := filepath.Join(t.TempDir(), "capture.pb")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	got, err := collextest.LoadMetrics(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("loaded %d metrics, want %d", len(got), len(want))
+	}
+	for i := range want {
+		collextest.AssertMetricsEqual(t, want[i], got[i], collextest.KeepTimestamps())
+	}
+
+	if err := os.WriteFile(path, data[:len(data)-5], 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := collextest.LoadMetrics(path); err == nil {
+		t.Error("truncated capture loaded")
+	}
+
+	corrupt := binary.BigEndian.AppendUint32(nil, 1<<32-1)
+	if err := os.WriteFile(path, corrupt, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := collextest.LoadMetrics(path); err == nil {
+		t.Error("capture with a corrupt length loaded")
+	}
+}
+
+func TestReplayerTiming(t *testing.T) {
+	start := time.Unix(1000, 0)
+	batches := []ptrace.Traces{
+		capturedTraces("a", start),
+		capturedTraces("b", start.Add(10*time.Second)),
+		capturedTraces("c", start.Add(30*time.Second)),
+	}
+	clock := collextest.NewFakeClock(time.Now())
+	exp := tracetest.NewInMemoryExporter()
+	r := collextest.Replayer{Speed: 10, Clock: clock}
+
+	done := make(chan error)
+	go func() { done <- r.ReplayTraces(context.Background(), exp, batches) }()
+
+	clock.BlockUntil(1)
+	if got := len(exp.GetSpans()); got != 1 {
+		t.Fatalf("%d spans replayed before the first delay, want 1", got)
+	}
+	clock.Advance(time.Second)
+	clock.BlockUntil(1)
+	if got := len(exp.GetSpans()); got != 2 {
+		t.Fatalf("%d spans replayed after 1s, want 2", got)
+	}
+	clock.Advance(2 * time.Second)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	spans := exp.GetSpans()
+	if len(spans) != 3 || spans[2].Name != "c" {
+		t.Errorf("replayed spans: %v", spans)
+	}
+}
+
+func TestReplayerCanceled(t *testing.T) {
+	batches := []ptrace.Traces{
+		capturedTraces("a", time.Unix(0, 1)),
+		capturedTraces("b", time.Unix(60, 0)),
+	}
+	clock := collextest.NewFakeClock(time.Now())
+	exp := tracetest.NewInMemoryExporter()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error)
+	go func() { done <- collextest.Replayer{Speed: 1, Clock: clock}.ReplayTraces(ctx, exp, batches) }()
+	clock.BlockUntil(1)
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("ReplayTraces returned %v, want %v", err, context.Canceled)
+	}
+	if got := len(exp.GetSpans()); got != 1 {
+		t.Errorf("%d spans replayed, want 1", got)
+	}
+}
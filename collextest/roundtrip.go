@@ -0,0 +1,249 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collextest
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/MrAlias/collex/transmute"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	api "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata/metricdatatest"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Converters convert telemetry from the OpenTelemetry Go SDK to pdata and
+// back. Nil fields use the converters of the transmute package, so a custom
+// converter, or one wrapping a hook that modifies the pdata, is verified by
+// setting only the fields it replaces.
+type Converters struct {
+	Spans           func([]trace.ReadOnlySpan) ptrace.Traces
+	ReadOnlySpans   func(ptrace.Traces) []trace.ReadOnlySpan
+	Metrics         func(*metricdata.ResourceMetrics) pmetric.Metrics
+	ResourceMetrics func(pmetric.Metrics) []*metricdata.ResourceMetrics
+	Logs            func([]log.Record) plog.Logs
+	Records         func(plog.Logs) []log.Record
+}
+
+// RoundTripSpans converts spans to pdata and back, and reports, with t, each
+// difference from spans the round-trip introduced. It returns whether the
+// round-trip is lossless.
+func (c Converters) RoundTripSpans(t testing.TB, spans []trace.ReadOnlySpan) bool {
+	t.Helper()
+	to, from := c.Spans, c.ReadOnlySpans
+	if to == nil {
+		to = transmute.Spans
+	}
+	if from == nil {
+		from = transmute.ReadOnlySpans
+	}
+
+	got := make(map[string]span)
+	for _, s := range sdkSpans(from(to(spans))) {
+		got[s.TraceID+"/"+s.SpanID] = s
+	}
+	return compareSpans(t, sdkSpans(spans), got)
+}
+
+// RoundTripMetrics converts rm to pdata and back, and reports, with t, each
+// difference from rm the round-trip introduced. It returns whether the
+// round-trip is lossless.
+func (c Converters) RoundTripMetrics(t testing.TB, rm *metricdata.ResourceMetrics) bool {
+	t.Helper()
+	to, from := c.Metrics, c.ResourceMetrics
+	if to == nil {
+		to = transmute.Metrics
+	}
+	if from == nil {
+		from = transmute.ResourceMetrics
+	}
+
+	got := from(to(rm))
+	if len(rm.ScopeMetrics) == 0 {
+		if len(got) != 0 {
+			t.Errorf("metrics: got %d resources, want none", len(got))
+			return false
+		}
+		return true
+	}
+	if len(got) != 1 {
+		t.Errorf("metrics: got %d resources, want 1", len(got))
+		return false
+	}
+	return metricdatatest.AssertEqual(t, *rm, *got[0])
+}
+
+// RoundTripLogs converts records to pdata and back, and reports, with t, each
+// difference from records the round-trip introduced. It returns whether the
+// round-trip is lossless.
+//
+// Records are compared in any order.
+func (c Converters) RoundTripLogs(t testing.TB, records []log.Record) bool {
+	t.Helper()
+	to, from := c.Logs, c.Records
+	if to == nil {
+		to = transmute.Logs
+	}
+	if from == nil {
+		from = transmute.Records
+	}
+
+	got := make(map[string]int)
+	for _, r := range from(to(records)) {
+		got[sdkRecord(r)]++
+	}
+	ok := true
+	for _, r := range records {
+		key := sdkRecord(r)
+		if got[key] == 0 {
+			t.Errorf("log record %s: missing", key)
+			ok = false
+			continue
+		}
+		got[key]--
+	}
+	for key, n := range got {
+		for range n {
+			t.Errorf("log record %s: unexpected", key)
+			ok = false
+		}
+	}
+	return ok
+}
+
+// FuzzSpans fuzzes the round-trip of spans generated by RandomSpans with
+// RoundTripSpans.
+func (c Converters) FuzzSpans(f *testing.F) {
+	f.Helper()
+	fuzz(f, func(t *testing.T, r *rand.Rand, n int) {
+		c.RoundTripSpans(t, RandomSpans(r, n))
+	})
+}
+
+// FuzzMetrics fuzzes the round-trip of metrics generated by RandomMetrics
+// with RoundTripMetrics.
+func (c Converters) FuzzMetrics(f *testing.F) {
+	f.Helper()
+	fuzz(f, func(t *testing.T, r *rand.Rand, n int) {
+		c.RoundTripMetrics(t, RandomMetrics(r, n))
+	})
+}
+
+// FuzzLogs fuzzes the round-trip of log records generated by RandomLogs with
+// RoundTripLogs.
+func (c Converters) FuzzLogs(f *testing.F) {
+	f.Helper()
+	fuzz(f, func(t *testing.T, r *rand.Rand, n int) {
+		c.RoundTripLogs(t, RandomLogs(r, n))
+	})
+}
+
+// fuzz runs check with telemetry generated from a seed and a count chosen by
+// the fuzzer.
+func fuzz(f *testing.F, check func(t *testing.T, r *rand.Rand, n int)) {
+	for seed := range uint64(8) {
+		f.Add(seed, uint8(seed))
+	}
+	f.Fuzz(func(t *testing.T, seed uint64, n uint8) {
+		check(t, rand.New(rand.NewPCG(seed, seed)), int(n%32))
+	})
+}
+
+// record is the representation of a log record compared by RoundTripLogs.
+type record struct {
+	ResourceSchemaURL string
+	Resource          map[string]any
+	Scope             string
+	ScopeSchemaURL    string
+	ScopeAttributes   map[string]any
+	Timestamp         pcommon.Timestamp
+	ObservedTimestamp pcommon.Timestamp
+	Severity          api.Severity
+	SeverityText      string
+	Body              any
+	Attributes        map[string]any
+	DroppedAttributes int
+	TraceID           string
+	SpanID            string
+	Sampled           bool
+}
+
+// sdkRecord returns a description of r that is equal for equal records.
+func sdkRecord(r log.Record) string {
+	res := r.Resource()
+	scope := r.InstrumentationScope()
+	out := record{
+		ResourceSchemaURL: res.SchemaURL(),
+		Resource:          sdkAttrs(res.Attributes()),
+		Scope:             scopeName(scope.Name, scope.Version),
+		ScopeSchemaURL:    scope.SchemaURL,
+		ScopeAttributes:   sdkAttrs(scope.Attributes.ToSlice()),
+		Timestamp:         pcommon.NewTimestampFromTime(r.Timestamp()),
+		ObservedTimestamp: pcommon.NewTimestampFromTime(r.ObservedTimestamp()),
+		Severity:          r.Severity(),
+		SeverityText:      r.SeverityText(),
+		Body:              logValue(r.Body()),
+		DroppedAttributes: r.DroppedAttributes(),
+		TraceID:           traceID(r.TraceID()),
+		SpanID:            spanID(r.SpanID()),
+		Sampled:           r.TraceFlags().IsSampled(),
+	}
+	r.WalkAttributes(func(kv api.KeyValue) bool {
+		if out.Attributes == nil {
+			out.Attributes = make(map[string]any)
+		}
+		out.Attributes[kv.Key] = logValue(kv.Value)
+		return true
+	})
+	// Maps are printed sorted by key, so equal records print the same.
+	return fmt.Sprintf("%+v", out)
+}
+
+// logValue returns v as a value that prints the same for equal values.
+func logValue(v api.Value) any {
+	switch v.Kind() {
+	case api.KindBool:
+		return v.AsBool()
+	case api.KindFloat64:
+		return v.AsFloat64()
+	case api.KindInt64:
+		return v.AsInt64()
+	case api.KindString:
+		return v.AsString()
+	case api.KindBytes:
+		return hex.EncodeToString(v.AsBytes())
+	case api.KindSlice:
+		out := make([]any, 0, len(v.AsSlice()))
+		for _, e := range v.AsSlice() {
+			out = append(out, logValue(e))
+		}
+		return out
+	case api.KindMap:
+		out := make(map[string]any, len(v.AsMap()))
+		for _, kv := range v.AsMap() {
+			out[kv.Key] = logValue(kv.Value)
+		}
+		return out
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collextest_test
+
+import (
+	"math/rand/v2"
+	"strings"
+	"testing"
+
+	"github.com/MrAlias/collex/collextest"
+	"github.com/MrAlias/collex/transmute"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func FuzzRoundTripSpans(f *testing.F) {
+	collextest.Converters{}.FuzzSpans(f)
+}
+
+func FuzzRoundTripMetrics(f *testing.F) {
+	collextest.Converters{}.FuzzMetrics(f)
+}
+
+func FuzzRoundTripLogs(f *testing.F) {
+	collextest.Converters{}.FuzzLogs(f)
+}
+
+// lossy returns converters that drop the name of the first span, metric, or
+// log record.
+func lossy() collextest.Converters {
+	return collextest.Converters{
+		Spans: func(spans []sdktrace.ReadOnlySpan) ptrace.Traces {
+			td := transmute.Spans(spans)
+			td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).SetName("")
+			return td
+		},
+		Metrics: func(rm *metricdata.ResourceMetrics) pmetric.Metrics {
+			md := transmute.Metrics(rm)
+			md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).SetName("")
+			return md
+		},
+		Logs: func(records []log.Record) plog.Logs {
+			ld := transmute.Logs(records)
+			ld.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).SetSeverityText("lost")
+			return ld
+		},
+	}
+}
+
+func TestRoundTripLossy(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+
+	rec := &recorder{TB: t}
+	if lossy().RoundTripSpans(rec, collextest.RandomSpans(r, 3)) {
+		t.Error("lossy span round-trip passed")
+	}
+	if len(rec.errs) != 1 || !strings.Contains(rec.errs[0], "name: got , want") {
+		t.Errorf("span errors: %q", rec.errs)
+	}
+
+	rec = &recorder{TB: t}
+	if lossy().RoundTripMetrics(rec, collextest.RandomMetrics(r, 3)) {
+		t.Error("lossy metric round-trip passed")
+	}
+	if len(rec.errs) != 1 {
+		t.Errorf("metric errors: %q", rec.errs)
+	}
+
+	rec = &recorder{TB: t}
+	if lossy().RoundTripLogs(rec, collextest.RandomLogs(r, 3)) {
+		t.Error("lossy log round-trip passed")
+	}
+	if len(rec.errs) != 2 {
+		t.Errorf("log errors: %q", rec.errs)
+	}
+}
+
+func TestRandomDeterministic(t *testing.T) {
+	a := collextest.RandomSpans(rand.New(rand.NewPCG(3, 4)), 5)
+	b := collextest.RandomSpans(rand.New(rand.NewPCG(3, 4)), 5)
+	if !collextest.AssertSpansEqual(t, a, transmute.Spans(b)) {
+		t.Error("spans generated from the same seed differ")
+	}
+}
@@ -0,0 +1,317 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collextest
+
+import (
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// AssertSpansEqual reports, with t, each difference between the spans of the
+// OpenTelemetry Go SDK in want and the pdata spans in got. It returns whether
+// they are equal.
+//
+// Spans are matched by their trace and span IDs, in any order. Their
+// resource, instrumentation scope, IDs, name, kind, timestamps, attributes,
+// events, links, status, and dropped counts are compared field by field, so
+// a span converted wrongly is pinned down to the fields that differ, e.g.
+//
+//	span "checkout" (4bf92f3577b34da6a3ce929d0e0e4736/00f067aa0ba902b7): attributes: got map[http.status_code:500], want map[http.status_code:200]
+func AssertSpansEqual(t testing.TB, want []trace.ReadOnlySpan, got ptrace.Traces) bool {
+	t.Helper()
+	return compareSpans(t, sdkSpans(want), pdataSpans(got))
+}
+
+// compareSpans reports, with t, each difference between the spans in want and
+// those in got, keyed by their trace and span IDs.
+func compareSpans(t testing.TB, want []span, got map[string]span) bool {
+	t.Helper()
+	ok := true
+	seen := make(map[string]bool, len(want))
+	for _, w := range want {
+		key := w.TraceID + "/" + w.SpanID
+		seen[key] = true
+		g, found := got[key]
+		if !found {
+			t.Errorf("span %q (%s): missing", w.Name, key)
+			ok = false
+			continue
+		}
+		for _, d := range diffSpan(w, g) {
+			t.Errorf("span %q (%s): %s", w.Name, key, d)
+			ok = false
+		}
+	}
+	for key, g := range got {
+		if !seen[key] {
+			t.Errorf("span %q (%s): unexpected", g.Name, key)
+			ok = false
+		}
+	}
+	return ok
+}
+
+// span is the representation of a span, from the SDK or pdata, compared by
+// AssertSpansEqual.
+type span struct {
+	ResourceSchemaURL string
+	Resource          map[string]any
+	Scope             string
+	ScopeSchemaURL    string
+	ScopeAttributes   map[string]any
+	TraceID           string
+	SpanID            string
+	ParentSpanID      string
+	TraceState        string
+	Name              string
+	Kind              string
+	Start             pcommon.Timestamp
+	End               pcommon.Timestamp
+	Attributes        map[string]any
+	DroppedAttributes int
+	Events            []event
+	DroppedEvents     int
+	Links             []link
+	DroppedLinks      int
+	StatusCode        string
+	StatusMessage     string
+}
+
+type event struct {
+	Name              string
+	Time              pcommon.Timestamp
+	Attributes        map[string]any
+	DroppedAttributes int
+}
+
+type link struct {
+	TraceID           string
+	SpanID            string
+	TraceState        string
+	Attributes        map[string]any
+	DroppedAttributes int
+}
+
+// diffSpan returns the fields of got that differ from want.
+func diffSpan(want, got span) []string {
+	var diffs []string
+	w, g := reflect.ValueOf(want), reflect.ValueOf(got)
+	for i := range w.NumField() {
+		wf, gf := w.Field(i).Interface(), g.Field(i).Interface()
+		if !reflect.DeepEqual(wf, gf) {
+			diffs = append(diffs, fmt.Sprintf("%s: got %v, want %v", fieldName(w.Type().Field(i).Name), gf, wf))
+		}
+	}
+	return diffs
+}
+
+// fieldName returns the lower case words of the Go field name n.
+func fieldName(n string) string {
+	var b strings.Builder
+	for i, r := range n {
+		if i > 0 && r >= 'A' && r <= 'Z' && !(n[i-1] >= 'A' && n[i-1] <= 'Z') {
+			b.WriteByte(' ')
+		}
+		b.WriteRune(r)
+	}
+	return strings.ToLower(b.String())
+}
+
+func sdkSpans(spans []trace.ReadOnlySpan) []span {
+	out := make([]span, len(spans))
+	for i, s := range spans {
+		out[i] = sdkSpan(s)
+	}
+	return out
+}
+
+func sdkSpan(s trace.ReadOnlySpan) span {
+	sc := s.SpanContext()
+	scope := s.InstrumentationScope()
+	out := span{
+		ResourceSchemaURL: s.Resource().SchemaURL(),
+		Resource:          sdkAttrs(s.Resource().Attributes()),
+		Scope:             scopeName(scope.Name, scope.Version),
+		ScopeSchemaURL:    scope.SchemaURL,
+		ScopeAttributes:   sdkAttrs(scope.Attributes.ToSlice()),
+		TraceID:           traceID(sc.TraceID()),
+		SpanID:            spanID(sc.SpanID()),
+		TraceState:        sc.TraceState().String(),
+		Name:              s.Name(),
+		Kind:              strings.ToLower(s.SpanKind().String()),
+		Start:             pcommon.NewTimestampFromTime(s.StartTime()),
+		End:               pcommon.NewTimestampFromTime(s.EndTime()),
+		Attributes:        sdkAttrs(s.Attributes()),
+		DroppedAttributes: s.DroppedAttributes(),
+		DroppedEvents:     s.DroppedEvents(),
+		DroppedLinks:      s.DroppedLinks(),
+		StatusCode:        s.Status().Code.String(),
+		StatusMessage:     s.Status().Description,
+	}
+	if s.Parent().HasSpanID() {
+		out.ParentSpanID = spanID(s.Parent().SpanID())
+	}
+	for _, e := range s.Events() {
+		out.Events = append(out.Events, event{
+			Name:              e.Name,
+			Time:              pcommon.NewTimestampFromTime(e.Time),
+			Attributes:        sdkAttrs(e.Attributes),
+			DroppedAttributes: e.DroppedAttributeCount,
+		})
+	}
+	for _, l := range s.Links() {
+		out.Links = append(out.Links, link{
+			TraceID:           traceID(l.SpanContext.TraceID()),
+			SpanID:            spanID(l.SpanContext.SpanID()),
+			TraceState:        l.SpanContext.TraceState().String(),
+			Attributes:        sdkAttrs(l.Attributes),
+			DroppedAttributes: l.DroppedAttributeCount,
+		})
+	}
+	return out
+}
+
+// pdataSpans returns the spans of td keyed by their trace and span IDs.
+func pdataSpans(td ptrace.Traces) map[string]span {
+	spans := make(map[string]span)
+	for i := range td.ResourceSpans().Len() {
+		rs := td.ResourceSpans().At(i)
+		res := pdataAttrs(rs.Resource().Attributes())
+		for j := range rs.ScopeSpans().Len() {
+			ss := rs.ScopeSpans().At(j)
+			scope := scopeName(ss.Scope().Name(), ss.Scope().Version())
+			scopeAttrs := pdataAttrs(ss.Scope().Attributes())
+			for k := range ss.Spans().Len() {
+				s := ss.Spans().At(k)
+				out := span{
+					ResourceSchemaURL: rs.SchemaUrl(),
+					Resource:          res,
+					Scope:             scope,
+					ScopeSchemaURL:    ss.SchemaUrl(),
+					ScopeAttributes:   scopeAttrs,
+					TraceID:           traceID(s.TraceID()),
+					SpanID:            spanID(s.SpanID()),
+					ParentSpanID:      spanID(s.ParentSpanID()),
+					TraceState:        s.TraceState().AsRaw(),
+					Name:              s.Name(),
+					Kind:              strings.ToLower(s.Kind().String()),
+					Start:             s.StartTimestamp(),
+					End:               s.EndTimestamp(),
+					Attributes:        pdataAttrs(s.Attributes()),
+					DroppedAttributes: int(s.DroppedAttributesCount()),
+					DroppedEvents:     int(s.DroppedEventsCount()),
+					DroppedLinks:      int(s.DroppedLinksCount()),
+					StatusCode:        s.Status().Code().String(),
+					StatusMessage:     s.Status().Message(),
+				}
+				for l := range s.Events().Len() {
+					e := s.Events().At(l)
+					out.Events = append(out.Events, event{
+						Name:              e.Name(),
+						Time:              e.Timestamp(),
+						Attributes:        pdataAttrs(e.Attributes()),
+						DroppedAttributes: int(e.DroppedAttributesCount()),
+					})
+				}
+				for m := range s.Links().Len() {
+					l := s.Links().At(m)
+					out.Links = append(out.Links, link{
+						TraceID:           traceID(l.TraceID()),
+						SpanID:            spanID(l.SpanID()),
+						TraceState:        l.TraceState().AsRaw(),
+						Attributes:        pdataAttrs(l.Attributes()),
+						DroppedAttributes: int(l.DroppedAttributesCount()),
+					})
+				}
+				spans[out.TraceID+"/"+out.SpanID] = out
+			}
+		}
+	}
+	return spans
+}
+
+func scopeName(name, version string) string {
+	if version == "" {
+		return name
+	}
+	return name + "@" + version
+}
+
+// traceID returns the hex encoding of b, or "" if it is empty.
+func traceID(b [16]byte) string {
+	if b == [16]byte{} {
+		return ""
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// spanID returns the hex encoding of b, or "" if it is empty.
+func spanID(b [8]byte) string {
+	if b == [8]byte{} {
+		return ""
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// sdkAttrs returns attrs as a map of their values, nil if there are none.
+func sdkAttrs(attrs []attribute.KeyValue) map[string]any {
+	if len(attrs) == 0 {
+		return nil
+	}
+	m := make(map[string]any, len(attrs))
+	for _, a := range attrs {
+		m[string(a.Key)] = sdkValue(a.Value)
+	}
+	return m
+}
+
+// sdkValue returns v as the value pdata returns with AsRaw.
+func sdkValue(v attribute.Value) any {
+	switch v.Type() {
+	case attribute.BOOLSLICE:
+		return anySlice(v.AsBoolSlice())
+	case attribute.INT64SLICE:
+		return anySlice(v.AsInt64Slice())
+	case attribute.FLOAT64SLICE:
+		return anySlice(v.AsFloat64Slice())
+	case attribute.STRINGSLICE:
+		return anySlice(v.AsStringSlice())
+	}
+	return v.AsInterface()
+}
+
+func anySlice[T any](s []T) []any {
+	out := make([]any, len(s))
+	for i, v := range s {
+		out[i] = v
+	}
+	return out
+}
+
+// pdataAttrs returns attrs as a map of their values, nil if there are none.
+func pdataAttrs(attrs pcommon.Map) map[string]any {
+	if attrs.Len() == 0 {
+		return nil
+	}
+	return attrs.AsRaw()
+}
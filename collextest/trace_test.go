@@ -0,0 +1,126 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collextest_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/MrAlias/collex/collextest"
+	"github.com/MrAlias/collex/transmute"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recorder records the errors reported to it.
+type recorder struct {
+	testing.TB
+	errs []string
+}
+
+func (*recorder) Helper() {}
+
+func (r *recorder) Error(args ...any) {
+	r.errs = append(r.errs, fmt.Sprint(args...))
+}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errs = append(r.errs, fmt.Sprintf(format, args...))
+}
+
+func recordSpans() []sdktrace.ReadOnlySpan {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	tracer := tp.Tracer("shop", trace.WithInstrumentationVersion("v1"))
+
+	ctx, parent := tracer.Start(context.Background(), "checkout", trace.WithSpanKind(trace.SpanKindServer))
+	_, child := tracer.Start(ctx, "charge",
+		trace.WithAttributes(attribute.Int("amount", 42), attribute.StringSlice("cards", []string{"visa"})),
+		trace.WithLinks(trace.Link{SpanContext: parent.SpanContext(), Attributes: []attribute.KeyValue{attribute.Bool("retry", true)}}),
+	)
+	child.AddEvent("declined", trace.WithAttributes(attribute.String("reason", "funds")))
+	child.SetStatus(codes.Error, "declined")
+	child.End()
+	parent.End()
+	return sr.Ended()
+}
+
+func TestAssertSpansEqual(t *testing.T) {
+	spans := recordSpans()
+	if !collextest.AssertSpansEqual(t, spans, transmute.Spans(spans)) {
+		t.Error("converted spans are not equal")
+	}
+}
+
+func TestAssertSpansEqualDiff(t *testing.T) {
+	spans := recordSpans()
+	td := transmute.Spans(spans)
+	ss := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans()
+	for i := range ss.Len() {
+		if s := ss.At(i); s.Name() == "charge" {
+			s.Attributes().PutInt("amount", 7)
+			s.Events().At(0).SetName("approved")
+		}
+	}
+
+	r := &recorder{TB: t}
+	if collextest.AssertSpansEqual(r, spans, td) {
+		t.Error("spans with changed attributes are equal")
+	}
+	if len(r.errs) != 2 {
+		t.Fatalf("got %d errors, want 2: %q", len(r.errs), r.errs)
+	}
+	for i, want := range []string{`span "charge" (`, `attributes: got map[amount:7`} {
+		if !strings.Contains(r.errs[0], want) {
+			t.Errorf("error %d = %q, want it to contain %q", i, r.errs[0], want)
+		}
+	}
+	if !strings.Contains(r.errs[1], "events: got") || !strings.Contains(r.errs[1], "approved") {
+		t.Errorf("error = %q, want an events difference", r.errs[1])
+	}
+
+	r = &recorder{TB: t}
+	collextest.AssertSpansEqual(r, spans[:1], transmute.Spans(spans))
+	if len(r.errs) != 1 || !strings.Contains(r.errs[0], "unexpected") {
+		t.Errorf("errors = %q, want an unexpected span", r.errs)
+	}
+}
+
+func TestSpanStubs(t *testing.T) {
+	recorded := tracetest.SpanStubsFromReadOnlySpans(recordSpans())
+	// A fixture written by hand, without a resource.
+	fixture := tracetest.SpanStub{
+		Name: "fixture",
+		SpanContext: trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID: trace.TraceID{1},
+			SpanID:  trace.SpanID{1},
+		}),
+		Attributes: []attribute.KeyValue{attribute.String("key", "value")},
+	}
+	stubs := append(recorded, fixture)
+
+	td := transmute.SpanStubs(stubs)
+	if !collextest.AssertSpansEqual(t, stubs.Snapshots(), td) {
+		t.Error("converted span stubs are not equal")
+	}
+	if got := td.SpanCount(); got != 3 {
+		t.Errorf("converted %d spans, want 3", got)
+	}
+}
@@ -0,0 +1,249 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/collector/pipeline"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// CompareConfig configures a comparing exporter.
+type CompareConfig struct {
+	// MeterProvider records the divergence of the exporters. If nil, the
+	// global MeterProvider is used.
+	MeterProvider metric.MeterProvider
+	// Name is the value of the comparison attribute of the metrics, telling
+	// several comparisons apart.
+	Name string
+}
+
+// comparison records the divergence of the exports of a primary and a
+// candidate exporter.
+type comparison struct {
+	exports, divergent, mismatch metric.Int64Counter
+	delta                        metric.Float64Histogram
+
+	// attrs are the attributes of the metrics of each signal.
+	attrs map[pipeline.Signal][]attribute.KeyValue
+}
+
+func newComparison(cfg CompareConfig) *comparison {
+	mp := cfg.MeterProvider
+	if mp == nil {
+		mp = otel.GetMeterProvider()
+	}
+	m := mp.Meter(scopeName)
+
+	c := &comparison{attrs: make(map[pipeline.Signal][]attribute.KeyValue)}
+	var err, iErr error
+	c.exports, iErr = m.Int64Counter(
+		"collex.compare.exports",
+		metric.WithUnit("{export}"),
+		metric.WithDescription("Number of exports of the compared exporters."),
+	)
+	err = errors.Join(err, iErr)
+	c.divergent, iErr = m.Int64Counter(
+		"collex.compare.divergent_exports",
+		metric.WithUnit("{export}"),
+		metric.WithDescription("Number of exports only one of the compared exporters failed."),
+	)
+	err = errors.Join(err, iErr)
+	c.mismatch, iErr = m.Int64Counter(
+		"collex.compare.accepted_mismatch",
+		metric.WithUnit("{item}"),
+		metric.WithDescription("Difference of the number of items the compared exporters accepted."),
+	)
+	err = errors.Join(err, iErr)
+	c.delta, iErr = m.Float64Histogram(
+		"collex.compare.duration_delta",
+		metric.WithUnit("s"),
+		metric.WithDescription("Difference of the durations of an export of the compared exporters."),
+	)
+	err = errors.Join(err, iErr)
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	name := attribute.String("comparison", cfg.Name)
+	for _, signal := range []pipeline.Signal{pipeline.SignalTraces, pipeline.SignalMetrics, pipeline.SignalLogs} {
+		c.attrs[signal] = []attribute.KeyValue{name, attribute.String("signal", signal.String())}
+	}
+	return c
+}
+
+// compare exports n items of signal with primary and candidate concurrently,
+// recording their divergence. Only the error of primary is returned.
+func (c *comparison) compare(ctx context.Context, signal pipeline.Signal, n int, primary, candidate func() error) error {
+	var (
+		cErr error
+		cDur time.Duration
+		done = make(chan struct{})
+	)
+	go func() {
+		defer close(done)
+		start := time.Now()
+		cErr = candidate()
+		cDur = time.Since(start)
+	}()
+	start := time.Now()
+	pErr := primary()
+	pDur := time.Since(start)
+	<-done
+
+	with := func(kvs ...attribute.KeyValue) metric.MeasurementOption {
+		return metric.WithAttributes(append(kvs, c.attrs[signal]...)...)
+	}
+	outcome := func(err error) attribute.KeyValue {
+		if err != nil {
+			return attribute.String("outcome", "failure")
+		}
+		return attribute.String("outcome", "success")
+	}
+	c.exports.Add(ctx, 1, with(attribute.String("exporter", "primary"), outcome(pErr)))
+	c.exports.Add(ctx, 1, with(attribute.String("exporter", "candidate"), outcome(cErr)))
+	switch {
+	case pErr != nil && cErr == nil:
+		c.divergent.Add(ctx, 1, with(attribute.String("failed", "primary")))
+	case pErr == nil && cErr != nil:
+		c.divergent.Add(ctx, 1, with(attribute.String("failed", "candidate")))
+	}
+
+	slower, delta := "candidate", cDur-pDur
+	if delta < 0 {
+		slower, delta = "primary", -delta
+	}
+	c.delta.Record(ctx, delta.Seconds(), with(attribute.String("slower", slower)))
+
+	if diff := accepted(pErr, n) - accepted(cErr, n); diff != 0 {
+		c.mismatch.Add(ctx, int64(max(diff, -diff)), with())
+	}
+	return pErr
+}
+
+// accepted returns the number of items of an export of n items that returned
+// err accepted.
+func accepted(err error, n int) int {
+	if err == nil {
+		return n
+	}
+	return n - failedItems(err, n)
+}
+
+// NewCompareSpanExporter returns a SpanExporter writing each export to both
+// primary and candidate, recording how they diverge: their error rates, the
+// difference of their export durations, and of the spans they accepted. This
+// builds confidence in candidate before a backend cutover.
+//
+// Only the errors of primary fail the exports. Shutting down the returned
+// exporter shuts down both exporters.
+func NewCompareSpanExporter(primary, candidate trace.SpanExporter, cfg CompareConfig) trace.SpanExporter {
+	return &compareSpanExporter{primary: primary, candidate: candidate, c: newComparison(cfg)}
+}
+
+type compareSpanExporter struct {
+	primary, candidate trace.SpanExporter
+	c                  *comparison
+}
+
+func (e *compareSpanExporter) ExportSpans(ctx context.Context, spans []trace.ReadOnlySpan) error {
+	return e.c.compare(
+		ctx, pipeline.SignalTraces, len(spans),
+		func() error { return e.primary.ExportSpans(ctx, spans) },
+		func() error { return e.candidate.ExportSpans(ctx, spans) },
+	)
+}
+
+func (e *compareSpanExporter) Shutdown(ctx context.Context) error {
+	return errors.Join(e.primary.Shutdown(ctx), e.candidate.Shutdown(ctx))
+}
+
+// NewCompareMetricExporter returns a metric Exporter writing each export to
+// both primary and candidate, recording how they diverge. The temporality and
+// aggregation of primary are used.
+//
+// Only the errors of primary fail the exports. Shutting down the returned
+// exporter shuts down both exporters.
+func NewCompareMetricExporter(primary, candidate sdkmetric.Exporter, cfg CompareConfig) sdkmetric.Exporter {
+	return &compareMetricExporter{primary: primary, candidate: candidate, c: newComparison(cfg)}
+}
+
+type compareMetricExporter struct {
+	primary, candidate sdkmetric.Exporter
+	c                  *comparison
+}
+
+func (e *compareMetricExporter) Temporality(k sdkmetric.InstrumentKind) metricdata.Temporality {
+	return e.primary.Temporality(k)
+}
+
+func (e *compareMetricExporter) Aggregation(k sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	return e.primary.Aggregation(k)
+}
+
+func (e *compareMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	return e.c.compare(
+		ctx, pipeline.SignalMetrics, dataPoints(rm),
+		func() error { return e.primary.Export(ctx, rm) },
+		func() error { return e.candidate.Export(ctx, rm) },
+	)
+}
+
+func (e *compareMetricExporter) ForceFlush(ctx context.Context) error {
+	return errors.Join(e.primary.ForceFlush(ctx), e.candidate.ForceFlush(ctx))
+}
+
+func (e *compareMetricExporter) Shutdown(ctx context.Context) error {
+	return errors.Join(e.primary.Shutdown(ctx), e.candidate.Shutdown(ctx))
+}
+
+// NewCompareLogExporter returns a log Exporter writing each export to both
+// primary and candidate, recording how they diverge.
+//
+// Only the errors of primary fail the exports. Shutting down the returned
+// exporter shuts down both exporters.
+func NewCompareLogExporter(primary, candidate sdklog.Exporter, cfg CompareConfig) sdklog.Exporter {
+	return &compareLogExporter{primary: primary, candidate: candidate, c: newComparison(cfg)}
+}
+
+type compareLogExporter struct {
+	primary, candidate sdklog.Exporter
+	c                  *comparison
+}
+
+func (e *compareLogExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	return e.c.compare(
+		ctx, pipeline.SignalLogs, len(records),
+		func() error { return e.primary.Export(ctx, records) },
+		func() error { return e.candidate.Export(ctx, records) },
+	)
+}
+
+func (e *compareLogExporter) ForceFlush(ctx context.Context) error {
+	return errors.Join(e.primary.ForceFlush(ctx), e.candidate.ForceFlush(ctx))
+}
+
+func (e *compareLogExporter) Shutdown(ctx context.Context) error {
+	return errors.Join(e.primary.Shutdown(ctx), e.candidate.Shutdown(ctx))
+}
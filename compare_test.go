@@ -0,0 +1,68 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/MrAlias/collex"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestCompareSpanExporter(t *testing.T) {
+	var primary sink
+	candidate := &backend{err: errBroken}
+	reader := metric.NewManualReader()
+	exp := collex.NewCompareSpanExporter(sinkSpanExporter(t, &primary), backendExporter(t, candidate), collex.CompareConfig{
+		MeterProvider: metric.NewMeterProvider(metric.WithReader(reader)),
+		Name:          "migration",
+	})
+	ctx := context.Background()
+	defer func() { _ = exp.Shutdown(ctx) }()
+
+	if err := exp.ExportSpans(ctx, spans(3)); err != nil {
+		t.Fatalf("export failed with the error of the candidate: %v", err)
+	}
+	if len(primary.traces) != 1 || candidate.calls != 1 {
+		t.Fatalf("primary exported %d times and candidate %d times, want both once", len(primary.traces), candidate.calls)
+	}
+
+	got := collect(t, reader, collexScope)
+	comparison := attribute.String("comparison", "migration")
+	signal := attribute.String("signal", "traces")
+	if n := intValue(t, "divergent_exports", got["collex.compare.divergent_exports"], comparison, signal, attribute.String("failed", "candidate")); n != 1 {
+		t.Errorf("divergent exports = %d, want 1", n)
+	}
+	if n := intValue(t, "accepted_mismatch", got["collex.compare.accepted_mismatch"], comparison, signal); n != 3 {
+		t.Errorf("accepted mismatch = %d, want 3", n)
+	}
+
+	exports := got["collex.compare.exports"].(metricdata.Sum[int64])
+	outcomes := make(map[string]string)
+	for _, dp := range exports.DataPoints {
+		exporter, _ := dp.Attributes.Value("exporter")
+		outcome, _ := dp.Attributes.Value("outcome")
+		outcomes[exporter.AsString()] = outcome.AsString()
+	}
+	if outcomes["primary"] != "success" || outcomes["candidate"] != "failure" {
+		t.Errorf("got outcomes %v, want the primary to succeed and the candidate to fail", outcomes)
+	}
+	if _, ok := got["collex.compare.duration_delta"]; !ok {
+		t.Error("duration delta not recorded")
+	}
+}
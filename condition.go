@@ -0,0 +1,274 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"context"
+
+	"github.com/MrAlias/collex/transmute"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// SpanCondition is a condition on the collector data of a span guarding a
+// route, like the OTTL conditions of the routing connector of the collector.
+// The NewSpanCondition function of the github.com/MrAlias/collex/ottl module
+// returns the SpanCondition of OTTL conditions of the span context.
+type SpanCondition func(ctx context.Context, span ptrace.Span, scope pcommon.InstrumentationScope, res pcommon.Resource) bool
+
+// DataPointCondition is a condition on the collector data of a metric data
+// point guarding a route, like the OTTL conditions of the datapoint context.
+// The data point dp is a pmetric.NumberDataPoint, HistogramDataPoint,
+// ExponentialHistogramDataPoint, or SummaryDataPoint of m. The
+// NewDataPointCondition function of the ottl module returns those conditions.
+type DataPointCondition func(ctx context.Context, dp any, m pmetric.Metric, scope pcommon.InstrumentationScope, res pcommon.Resource) bool
+
+// LogCondition is a condition on the collector data of a log record guarding
+// a route, like the OTTL conditions of the log context. The NewLogCondition
+// function of the ottl module returns those conditions.
+type LogCondition func(ctx context.Context, lr plog.LogRecord, scope pcommon.InstrumentationScope, res pcommon.Resource) bool
+
+// batchKey is the resource and instrumentation scope of telemetry, the
+// telemetry of a key is converted to collector data together.
+type batchKey struct {
+	res   resource.Resource
+	scope instrumentation.Scope
+}
+
+// groupBatch returns the keys of the items of a batch in the order they are
+// first seen, and the indexes of the items of each key.
+func groupBatch(n int, key func(int) batchKey) ([]batchKey, map[batchKey][]int) {
+	var keys []batchKey
+	groups := make(map[batchKey][]int)
+	for i := range n {
+		k := key(i)
+		if _, ok := groups[k]; !ok {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], i)
+	}
+	return keys, groups
+}
+
+// spanData is the collector data of a span.
+type spanData struct {
+	span  ptrace.Span
+	scope pcommon.InstrumentationScope
+	res   pcommon.Resource
+}
+
+// spansData returns the collector data of spans, in their order. Each span is
+// converted once, with the other spans of its resource and scope.
+func spansData(spans []trace.ReadOnlySpan) []spanData {
+	keys, groups := groupBatch(len(spans), func(i int) batchKey {
+		k := batchKey{scope: spans[i].InstrumentationScope()}
+		if res := spans[i].Resource(); res != nil {
+			k.res = *res
+		}
+		return k
+	})
+	data := make([]spanData, len(spans))
+	for _, k := range keys {
+		group := make([]trace.ReadOnlySpan, 0, len(groups[k]))
+		for _, i := range groups[k] {
+			group = append(group, spans[i])
+		}
+		rs := transmute.Spans(group).ResourceSpans().At(0)
+		ss := rs.ScopeSpans().At(0)
+		for j, i := range groups[k] {
+			data[i] = spanData{span: ss.Spans().At(j), scope: ss.Scope(), res: rs.Resource()}
+		}
+	}
+	return data
+}
+
+// eval returns whether the collector data of the span i satisfies c, or true
+// if c is nil. The data are those of the batch of the span, returned by
+// spansData.
+func (c SpanCondition) eval(ctx context.Context, data []spanData, i int) bool {
+	if c == nil {
+		return true
+	}
+	return c(ctx, data[i].span, data[i].scope, data[i].res)
+}
+
+// logData is the collector data of a log record.
+type logData struct {
+	record plog.LogRecord
+	scope  pcommon.InstrumentationScope
+	res    pcommon.Resource
+}
+
+// logsData returns the collector data of records, in their order. Each record
+// is converted once, with the other records of its resource and scope.
+func logsData(records []log.Record) []logData {
+	keys, groups := groupBatch(len(records), func(i int) batchKey {
+		return batchKey{res: records[i].Resource(), scope: records[i].InstrumentationScope()}
+	})
+	data := make([]logData, len(records))
+	for _, k := range keys {
+		group := make([]log.Record, 0, len(groups[k]))
+		for _, i := range groups[k] {
+			group = append(group, records[i])
+		}
+		rl := transmute.Logs(group).ResourceLogs().At(0)
+		sl := rl.ScopeLogs().At(0)
+		for j, i := range groups[k] {
+			data[i] = logData{record: sl.LogRecords().At(j), scope: sl.Scope(), res: rl.Resource()}
+		}
+	}
+	return data
+}
+
+// eval returns whether the collector data of the log record i satisfies c, or
+// true if c is nil. The data are those of the batch of the record, returned
+// by logsData.
+func (c LogCondition) eval(ctx context.Context, data []logData, i int) bool {
+	if c == nil {
+		return true
+	}
+	return c(ctx, data[i].record, data[i].scope, data[i].res)
+}
+
+// dataPoint returns the data point i of m, or nil if m has no data points of
+// a known type.
+func dataPoint(m pmetric.Metric, i int) any {
+	switch m.Type() {
+	case pmetric.MetricTypeGauge:
+		return m.Gauge().DataPoints().At(i)
+	case pmetric.MetricTypeSum:
+		return m.Sum().DataPoints().At(i)
+	case pmetric.MetricTypeHistogram:
+		return m.Histogram().DataPoints().At(i)
+	case pmetric.MetricTypeExponentialHistogram:
+		return m.ExponentialHistogram().DataPoints().At(i)
+	case pmetric.MetricTypeSummary:
+		return m.Summary().DataPoints().At(i)
+	}
+	return nil
+}
+
+// pointCount returns the number of data points of m.
+func pointCount(m pmetric.Metric) int {
+	switch m.Type() {
+	case pmetric.MetricTypeGauge:
+		return m.Gauge().DataPoints().Len()
+	case pmetric.MetricTypeSum:
+		return m.Sum().DataPoints().Len()
+	case pmetric.MetricTypeHistogram:
+		return m.Histogram().DataPoints().Len()
+	case pmetric.MetricTypeExponentialHistogram:
+		return m.ExponentialHistogram().DataPoints().Len()
+	case pmetric.MetricTypeSummary:
+		return m.Summary().DataPoints().Len()
+	}
+	return 0
+}
+
+// keepPoints returns the data points of dps for whose index keep returns
+// true.
+func keepPoints[T any](dps []T, keep func(int) bool) []T {
+	var kept []T
+	for i, dp := range dps {
+		if keep(i) {
+			kept = append(kept, dp)
+		}
+	}
+	return kept
+}
+
+// keepData returns data with only the data points for whose index keep
+// returns true, and their number.
+func keepData(data metricdata.Aggregation, keep func(int) bool) (metricdata.Aggregation, int) {
+	switch a := data.(type) {
+	case metricdata.Gauge[int64]:
+		a.DataPoints = keepPoints(a.DataPoints, keep)
+		return a, len(a.DataPoints)
+	case metricdata.Gauge[float64]:
+		a.DataPoints = keepPoints(a.DataPoints, keep)
+		return a, len(a.DataPoints)
+	case metricdata.Sum[int64]:
+		a.DataPoints = keepPoints(a.DataPoints, keep)
+		return a, len(a.DataPoints)
+	case metricdata.Sum[float64]:
+		a.DataPoints = keepPoints(a.DataPoints, keep)
+		return a, len(a.DataPoints)
+	case metricdata.Histogram[int64]:
+		a.DataPoints = keepPoints(a.DataPoints, keep)
+		return a, len(a.DataPoints)
+	case metricdata.Histogram[float64]:
+		a.DataPoints = keepPoints(a.DataPoints, keep)
+		return a, len(a.DataPoints)
+	case metricdata.ExponentialHistogram[int64]:
+		a.DataPoints = keepPoints(a.DataPoints, keep)
+		return a, len(a.DataPoints)
+	case metricdata.ExponentialHistogram[float64]:
+		a.DataPoints = keepPoints(a.DataPoints, keep)
+		return a, len(a.DataPoints)
+	case metricdata.Summary:
+		a.DataPoints = keepPoints(a.DataPoints, keep)
+		return a, len(a.DataPoints)
+	}
+	return data, 0
+}
+
+// splitMetrics splits rm by the index of the destination dest returns for
+// each data point, evaluated with the collector data of the data point, into
+// n parts. Parts without data points are nil.
+func splitMetrics(rm *metricdata.ResourceMetrics, n int, dest func(dp any, m pmetric.Metric, scope pcommon.InstrumentationScope, res pcommon.Resource) int) []*metricdata.ResourceMetrics {
+	parts := make([]*metricdata.ResourceMetrics, n)
+	md := transmute.Metrics(rm)
+	if md.ResourceMetrics().Len() == 0 {
+		return parts
+	}
+	prm := md.ResourceMetrics().At(0)
+	for i, sm := range rm.ScopeMetrics {
+		psm := prm.ScopeMetrics().At(i)
+		// scoped are the parts that have sm.
+		scoped := make([]bool, n)
+		for j, m := range sm.Metrics {
+			pm := psm.Metrics().At(j)
+			var dests []int
+			for k := range pointCount(pm) {
+				dests = append(dests, dest(dataPoint(pm, k), pm, psm.Scope(), prm.Resource()))
+			}
+			for d := range n {
+				data, kept := keepData(m.Data, func(k int) bool { return dests[k] == d })
+				if kept == 0 {
+					continue
+				}
+				if parts[d] == nil {
+					parts[d] = &metricdata.ResourceMetrics{Resource: rm.Resource}
+				}
+				if !scoped[d] {
+					parts[d].ScopeMetrics = append(parts[d].ScopeMetrics, metricdata.ScopeMetrics{Scope: sm.Scope})
+					scoped[d] = true
+				}
+				part := m
+				part.Data = data
+				last := &parts[d].ScopeMetrics[len(parts[d].ScopeMetrics)-1]
+				last.Metrics = append(last.Metrics, part)
+			}
+		}
+	}
+	return parts
+}
@@ -0,0 +1,775 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/confmap"
+	"go.opentelemetry.io/collector/confmap/provider/envprovider"
+	"go.opentelemetry.io/collector/confmap/provider/fileprovider"
+	"go.opentelemetry.io/collector/confmap/provider/httpprovider"
+	"go.opentelemetry.io/collector/confmap/provider/httpsprovider"
+	"go.opentelemetry.io/collector/confmap/provider/yamlprovider"
+	"go.opentelemetry.io/collector/confmap/xconfmap"
+	"go.opentelemetry.io/collector/connector"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/extension"
+	"go.opentelemetry.io/collector/pipeline"
+	"go.opentelemetry.io/collector/processor"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// Factories are the collector component factories used to build a Pipeline
+// from a collector configuration. Each map can be created with the
+// MakeFactoryMap function of the component package.
+type Factories struct {
+	Processors map[component.Type]processor.Factory
+	Exporters  map[component.Type]exporter.Factory
+	Connectors map[component.Type]connector.Factory
+	Extensions map[component.Type]extension.Factory
+
+	// Providers are additional confmap providers used to expand references
+	// in the configuration, e.g. ${secretsmanager:db-password}. They replace
+	// the default providers with the same scheme.
+	Providers []confmap.ProviderFactory
+}
+
+type serviceConfig struct {
+	Extensions []component.ID                 `mapstructure:"extensions"`
+	Pipelines  map[pipeline.ID]pipelineConfig `mapstructure:"pipelines"`
+}
+
+type pipelineConfig struct {
+	Receivers  []component.ID `mapstructure:"receivers"`
+	Processors []component.ID `mapstructure:"processors"`
+	Exporters  []component.ID `mapstructure:"exporters"`
+}
+
+// PipelineFromYAML builds and starts a Pipeline from a collector
+// configuration. The extensions, processors, exporters, connectors, and
+// service sections of the configuration are used to create the components of
+// the Pipeline using factories. If set is nil, the same default Settings as
+// NewFactory are used.
+//
+// The OpenTelemetry Go exporters and processors of the returned Pipeline take
+// the place of the receivers. Telemetry they export is sent to all pipelines
+// of the same signal that have a receiver other than a connector, or no
+// receivers at all. Receiver configuration is ignored.
+//
+// Environment variables are expanded in the configuration like in a collector
+// configuration, e.g. ${env:ENDPOINT}.
+func PipelineFromYAML(ctx context.Context, factories Factories, data []byte, set *exporter.Settings) (*Pipeline, error) {
+	conf, err := confFromYAML(ctx, data, factories.Providers)
+	if err != nil {
+		return nil, err
+	}
+	return pipelineFromConf(ctx, factories, conf, set)
+}
+
+func pipelineFromConf(ctx context.Context, factories Factories, conf *confmap.Conf, set *exporter.Settings) (*Pipeline, error) {
+	exts, roots, err := parseGraph(factories, conf)
+	if err != nil {
+		return nil, err
+	}
+	if set == nil {
+		set, err = defaultSettings()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return newPipeline(ctx, *set, newExtensionSet(*set, nil, nil, exts), roots)
+}
+
+// PipelineFromURI builds and starts a Pipeline, like PipelineFromYAML, from
+// the collector configuration at uri. The configuration is retrieved with the
+// same confmap providers as the collector: "file:", "env:", "yaml:", "http:",
+// and "https:". A uri without a scheme is a file path.
+//
+// For example, a configuration is loaded from a file or from the
+// OTEL_COLLEX_CONFIG environment variable with the following.
+//
+//	p, err := collex.PipelineFromURI(ctx, factories, "file:/etc/otel/pipeline.yaml", nil)
+//	p, err := collex.PipelineFromURI(ctx, factories, "env:OTEL_COLLEX_CONFIG", nil)
+func PipelineFromURI(ctx context.Context, factories Factories, uri string, set *exporter.Settings) (*Pipeline, error) {
+	conf, err := resolve(ctx, uri, factories.Providers)
+	if err != nil {
+		return nil, err
+	}
+	return pipelineFromConf(ctx, factories, conf, set)
+}
+
+// ExportersFromYAML builds and starts a Pipeline for each exporter of the
+// exporters section of a collector configuration, keyed by the ID of the
+// exporter. Each Pipeline only holds its exporter and handles all the signals
+// the exporter supports. The other sections of the configuration are ignored.
+// If set is nil, the same default Settings as NewFactory are used.
+//
+// For example, a primary and a backup exporter are created with the following.
+//
+//	exps, err := collex.ExportersFromYAML(ctx, factories, []byte(`
+//	exporters:
+//	  otlp/primary:
+//	    endpoint: primary:4317
+//	  otlphttp/backup:
+//	    endpoint: https://backup:4318
+//	`), nil)
+//	primary := exps[component.MustNewIDWithName("otlp", "primary")].SpanExporter()
+func ExportersFromYAML(ctx context.Context, factories Factories, data []byte, set *exporter.Settings) (map[component.ID]*Pipeline, error) {
+	conf, err := confFromYAML(ctx, data, factories.Providers)
+	if err != nil {
+		return nil, err
+	}
+	confs, err := sectionConfs(conf, "exporters")
+	if err != nil {
+		return nil, err
+	}
+	if set == nil {
+		set, err = defaultSettings()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ids := make([]component.ID, 0, len(confs))
+	for id := range confs {
+		ids = append(ids, id)
+	}
+	// Sort the exporters so they are always created in the same order.
+	sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+
+	pipes := make(map[component.ID]*Pipeline, len(ids))
+	shutdown := func() error {
+		var err error
+		for _, p := range pipes {
+			err = errors.Join(err, p.Shutdown(ctx))
+		}
+		return err
+	}
+	for _, id := range ids {
+		f, ok := factories.Exporters[id.Type()]
+		if !ok {
+			return nil, errors.Join(fmt.Errorf("exporter %s: unknown type %q", id, id.Type()), shutdown())
+		}
+		cfg := f.CreateDefaultConfig()
+		if err := confs[id].Unmarshal(cfg); err != nil {
+			return nil, errors.Join(fmt.Errorf("exporters::%s: %w", id, err), shutdown())
+		}
+		if err := xconfmap.Validate(cfg); err != nil {
+			return nil, errors.Join(fmt.Errorf("exporters::%s: %w", id, err), shutdown())
+		}
+
+		n := &pipeNode{exps: []*expNode{{id: id, Exporter: Exporter{Factory: f, Config: cfg}}}}
+		roots := signalRoots(n)
+		if len(roots) == 0 {
+			return nil, errors.Join(fmt.Errorf("exporter %s: %w", id, errNoSignals), shutdown())
+		}
+		p, err := newPipeline(ctx, *set, newExtensionSet(*set, nil, nil, nil), roots)
+		if err != nil {
+			return nil, errors.Join(err, shutdown())
+		}
+		pipes[id] = p
+	}
+	return pipes, nil
+}
+
+// ConfigFromYAML returns the configuration of a component created by f, e.g.
+// an exporter Factory passed to NewFactory, from a collector configuration
+// block of the component. The block is unmarshaled over the default
+// configuration of f the same way a collector does it, environment variables
+// like ${env:API_KEY} are expanded.
+//
+// For example, the configuration of an OTLP exporter is loaded with the
+// following.
+//
+//	cfg, err := collex.ConfigFromYAML(otlpexporter.NewFactory(), []byte(`
+//	endpoint: collector:4317
+//	compression: zstd
+//	`))
+//
+// References to other confmap providers are expanded with providers, e.g. a
+// secret store provider for ${secretsmanager:api-key}. They replace the
+// default providers with the same scheme.
+func ConfigFromYAML(f component.Factory, data []byte, providers ...confmap.ProviderFactory) (component.Config, error) {
+	conf, err := confFromYAML(context.Background(), data, providers)
+	if err != nil {
+		return nil, err
+	}
+	return configFromConf(f, conf)
+}
+
+// ConfigFromMap returns the configuration of a component created by f, like
+// ConfigFromYAML, from a collector configuration block held in a map, e.g.
+// one built from typed options by a package wrapping an exporter. Nested
+// blocks are maps and the keys are those of the collector configuration.
+//
+//	cfg, err := collex.ConfigFromMap(otlpexporter.NewFactory(), map[string]any{
+//		"endpoint":    "collector:4317",
+//		"compression": "zstd",
+//	})
+func ConfigFromMap(f component.Factory, m map[string]any) (component.Config, error) {
+	return configFromConf(f, confmap.NewFromStringMap(m))
+}
+
+// ConfigFromURI returns the configuration of a component created by f, like
+// ConfigFromYAML, from the collector configuration block at uri. The block is
+// retrieved with the same confmap providers as PipelineFromURI, and
+// providers.
+//
+// For example, an exporter configuration is loaded from a file with the
+// following.
+//
+//	cfg, err := collex.ConfigFromURI(ctx, otlpexporter.NewFactory(), "file:/etc/otel/exporter.yaml")
+func ConfigFromURI(ctx context.Context, f component.Factory, uri string, providers ...confmap.ProviderFactory) (component.Config, error) {
+	conf, err := resolve(ctx, uri, providers)
+	if err != nil {
+		return nil, err
+	}
+	return configFromConf(f, conf)
+}
+
+// Configure returns a copy of the default configuration of a component created
+// by f, e.g. an exporter Factory passed to NewFactory, changed by fn. The
+// returned configuration is validated like the configuration of a collector.
+//
+// The pointers, slices, and maps of the default configuration are copied,
+// so configurations returned by Configure never share state, even if f
+// returns the same default configuration every time. An error is returned if
+// the default configuration of f is not a *T.
+//
+//	cfg, err := collex.Configure(otlpexporter.NewFactory(), func(cfg *otlpexporter.Config) error {
+//		cfg.ClientConfig.Endpoint = "collector:4317"
+//		return nil
+//	})
+func Configure[T any](f component.Factory, fn func(*T) error) (*T, error) {
+	def, ok := f.CreateDefaultConfig().(*T)
+	if !ok || def == nil {
+		return nil, fmt.Errorf("%s: default configuration is %T, not %T", f.Type(), f.CreateDefaultConfig(), def)
+	}
+	cfg := deepCopy(reflect.ValueOf(def)).Interface().(*T)
+	if err := fn(cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Type(), err)
+	}
+	if err := xconfmap.Validate(cfg); err != nil {
+		return nil, fmt.Errorf("%s: invalid configuration: %w", f.Type(), err)
+	}
+	return cfg, nil
+}
+
+// deepCopy returns a copy of v that does not share the pointers, slices, and
+// maps reachable from the exported fields of v. Unexported fields are copied
+// as is.
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(deepCopy(v.Elem()))
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(deepCopy(v.Elem()))
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := range c.NumField() {
+			if f := c.Field(i); f.CanSet() {
+				f.Set(deepCopy(f))
+			}
+		}
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := range v.Len() {
+			c.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), deepCopy(iter.Value()))
+		}
+		return c
+	default:
+		return v
+	}
+}
+
+func configFromConf(f component.Factory, conf *confmap.Conf) (component.Config, error) {
+	cfg := f.CreateDefaultConfig()
+	if err := conf.Unmarshal(cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Type(), err)
+	}
+	if err := xconfmap.Validate(cfg); err != nil {
+		return nil, fmt.Errorf("%s: invalid configuration: %w", f.Type(), err)
+	}
+	return cfg, nil
+}
+
+// ConfigToYAML returns cfg, the configuration of a component, as a collector
+// configuration block. It is the reverse of ConfigFromYAML and is meant for
+// logging the configuration in use or adding it to a support bundle. Secrets,
+// fields of type configopaque.String, are redacted.
+func ConfigToYAML(cfg component.Config) ([]byte, error) {
+	m, err := configMap(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return marshalYAML(m)
+}
+
+// ConfigYAML returns the configuration of the components of p as a collector
+// configuration, with the secrets redacted like ConfigToYAML. Components
+// created without a configuration have their default configuration.
+//
+// Pipelines and components of p that have the same ID, but are different
+// instances, are given unique names. The pipelines that telemetry is
+// exported to from p have no receivers.
+func (p *Pipeline) ConfigYAML() ([]byte, error) {
+	m, err := p.g.config()
+	if err != nil {
+		return nil, err
+	}
+	return marshalYAML(m)
+}
+
+// marshalYAML returns m as YAML indented like a collector configuration.
+func marshalYAML(m map[string]any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(m); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), enc.Close()
+}
+
+func configMap(cfg component.Config) (map[string]any, error) {
+	conf := confmap.New()
+	if cfg != nil {
+		if err := conf.Marshal(cfg); err != nil {
+			return nil, err
+		}
+	}
+	return conf.ToStringMap(), nil
+}
+
+// config returns the collector configuration of g.
+func (g *graph) config() (map[string]any, error) {
+	var (
+		exts  = make(map[string]any)
+		procs = make(map[string]any)
+		exps  = make(map[string]any)
+		conns = make(map[string]any)
+
+		// Exporters and connectors are both referenced in the exporters of
+		// a pipeline so they need to have different names.
+		expUsed   = make(map[string]bool)
+		extNames  = newNames[confKey](make(map[string]bool))
+		procNames = newNames[confKey](make(map[string]bool))
+		expNames  = newNames[*expNode](expUsed)
+		connNames = newNames[*connNode](expUsed)
+		pipeNames = newNames[*pipeNode](make(map[string]bool))
+
+		pipes     = make(map[*pipeNode]map[string]any)
+		receivers = make(map[*pipeNode][]string)
+	)
+
+	var svcExts []string
+	if g.exts != nil {
+		for _, n := range g.exts.nodes {
+			cfg, key, err := nodeConfig(n.id, n.Factory, n.Config)
+			if err != nil {
+				return nil, fmt.Errorf("extension %s: %w", n.id, err)
+			}
+			name := extNames.get(key, n.id)
+			exts[name] = cfg
+			svcExts = append(svcExts, name)
+		}
+	}
+
+	for _, p := range g.pipeList {
+		var procIDs, expIDs []string
+		for _, n := range p.procs {
+			cfg, key, err := nodeConfig(n.id, n.Factory, n.Config)
+			if err != nil {
+				return nil, fmt.Errorf("processor %s: %w", n.id, err)
+			}
+			name := procNames.get(key, n.id)
+			procs[name] = cfg
+			procIDs = append(procIDs, name)
+		}
+		for _, n := range p.exps {
+			cfg, _, err := nodeConfig(n.id, n.Factory, n.Config)
+			if err != nil {
+				return nil, fmt.Errorf("exporter %s: %w", n.id, err)
+			}
+			name := expNames.get(n, n.id)
+			exps[name] = cfg
+			expIDs = append(expIDs, name)
+		}
+		for _, n := range p.conns {
+			cfg, _, err := nodeConfig(n.id, n.factory, n.config)
+			if err != nil {
+				return nil, fmt.Errorf("connector %s: %w", n.id, err)
+			}
+			name := connNames.get(n, n.id)
+			conns[name] = cfg
+			expIDs = append(expIDs, name)
+			for _, out := range n.pipes {
+				receivers[out] = append(receivers[out], name)
+			}
+		}
+		pipes[p] = map[string]any{"exporters": expIDs}
+		if len(procIDs) > 0 {
+			pipes[p]["processors"] = procIDs
+		}
+	}
+
+	svcPipes := make(map[string]any, len(pipes))
+	for _, p := range g.pipeList {
+		if r := receivers[p]; len(r) > 0 {
+			pipes[p]["receivers"] = r
+		}
+		svcPipes[pipeNames.get(p, p.id)] = pipes[p]
+	}
+
+	svc := map[string]any{"pipelines": svcPipes}
+	if len(svcExts) > 0 {
+		svc["extensions"] = svcExts
+	}
+	conf := map[string]any{"service": svc}
+	for section, m := range map[string]map[string]any{
+		"extensions": exts,
+		"processors": procs,
+		"exporters":  exps,
+		"connectors": conns,
+	} {
+		if len(m) > 0 {
+			conf[section] = m
+		}
+	}
+	return conf, nil
+}
+
+// confKey identifies a component by its ID and configuration. It is used for
+// the components that are created for each pipeline, or graph, they are in.
+type confKey struct {
+	id, conf string
+}
+
+// nodeConfig returns the configuration of the component with id as a map, and
+// a confKey of it. If cfg is nil, the default configuration of f is used.
+func nodeConfig(id component.ID, f component.Factory, cfg component.Config) (map[string]any, confKey, error) {
+	if cfg == nil {
+		cfg = f.CreateDefaultConfig()
+	}
+	m, err := configMap(cfg)
+	if err != nil {
+		return nil, confKey{}, err
+	}
+	// Maps are printed in key order so the same configuration has the same
+	// key.
+	return m, confKey{id: id.String(), conf: fmt.Sprint(m)}, nil
+}
+
+// names gives unique names to components, or pipelines, keyed by K. The first
+// key with an ID is named after it, the others are suffixed with a number.
+type names[K comparable] struct {
+	names map[K]string
+	used  map[string]bool
+}
+
+func newNames[K comparable](used map[string]bool) *names[K] {
+	return &names[K]{names: make(map[K]string), used: used}
+}
+
+func (n *names[K]) get(key K, id fmt.Stringer) string {
+	if name, ok := n.names[key]; ok {
+		return name
+	}
+	base := id.String()
+	sep := "/"
+	if strings.Contains(base, "/") {
+		sep = "_"
+	}
+	name := base
+	for i := 2; n.used[name]; i++ {
+		name = fmt.Sprintf("%s%s%d", base, sep, i)
+	}
+	n.used[name] = true
+	n.names[key] = name
+	return name
+}
+
+// resolve retrieves the configuration at uri with the confmap providers of
+// the collector and providers. A provider of providers replaces the collector
+// provider with the same scheme.
+func resolve(ctx context.Context, uri string, providers []confmap.ProviderFactory) (*confmap.Conf, error) {
+	set := confmap.ProviderSettings{Logger: zap.NewNop()}
+	replaced := make(map[string]bool, len(providers))
+	for _, pf := range providers {
+		replaced[scheme(ctx, pf, set)] = true
+	}
+	pfs := make([]confmap.ProviderFactory, 0, 5+len(providers))
+	for _, pf := range []confmap.ProviderFactory{
+		fileprovider.NewFactory(),
+		envprovider.NewFactory(),
+		yamlprovider.NewFactory(),
+		httpprovider.NewFactory(),
+		httpsprovider.NewFactory(),
+	} {
+		if !replaced[scheme(ctx, pf, set)] {
+			pfs = append(pfs, pf)
+		}
+	}
+
+	r, err := confmap.NewResolver(confmap.ResolverSettings{
+		URIs:              []string{uri},
+		ProviderFactories: append(pfs, providers...),
+		DefaultScheme:     "env",
+		ProviderSettings:  set,
+	})
+	if err != nil {
+		return nil, err
+	}
+	conf, err := r.Resolve(ctx)
+	return conf, errors.Join(err, r.Shutdown(ctx))
+}
+
+// scheme returns the scheme of the providers created by pf.
+func scheme(ctx context.Context, pf confmap.ProviderFactory, set confmap.ProviderSettings) string {
+	p := pf.Create(set)
+	defer func() { _ = p.Shutdown(ctx) }()
+	return p.Scheme()
+}
+
+// confFromYAML returns the configuration in data. References to environment
+// variables, and other providers, in data are expanded like the collector
+// does, e.g. ${env:ENDPOINT} or ${ENDPOINT}. A $$ is an escaped $.
+func confFromYAML(ctx context.Context, data []byte, providers []confmap.ProviderFactory) (*confmap.Conf, error) {
+	return resolve(ctx, "yaml:"+string(data), providers)
+}
+
+// parseGraph returns the extensions and root pipelines of the graph described
+// by conf.
+func parseGraph(factories Factories, conf *confmap.Conf) ([]extNode, []*pipeNode, error) {
+	extConfs, err := sectionConfs(conf, "extensions")
+	if err != nil {
+		return nil, nil, err
+	}
+	procConfs, err := sectionConfs(conf, "processors")
+	if err != nil {
+		return nil, nil, err
+	}
+	expConfs, err := sectionConfs(conf, "exporters")
+	if err != nil {
+		return nil, nil, err
+	}
+	connConfs, err := sectionConfs(conf, "connectors")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	svcConf, err := conf.Sub("service")
+	if err != nil {
+		return nil, nil, err
+	}
+	var svc serviceConfig
+	if err := svcConf.Unmarshal(&svc, confmap.WithIgnoreUnused()); err != nil {
+		return nil, nil, fmt.Errorf("service: %w", err)
+	}
+	if len(svc.Pipelines) == 0 {
+		return nil, nil, fmt.Errorf("service::pipelines: no pipelines")
+	}
+
+	ids := make([]pipeline.ID, 0, len(svc.Pipelines))
+	pipes := make(map[pipeline.ID]*pipeNode, len(svc.Pipelines))
+	for id := range svc.Pipelines {
+		ids = append(ids, id)
+		pipes[id] = &pipeNode{id: id}
+	}
+	// Sort the pipelines so the components are always created in the same
+	// order.
+	sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+
+	exps := make(map[component.ID]*expNode)
+	conns := make(map[component.ID]*connNode)
+	connNodeFor := func(id component.ID) (*connNode, error) {
+		if n, ok := conns[id]; ok {
+			return n, nil
+		}
+		f, ok := factories.Connectors[id.Type()]
+		if !ok {
+			return nil, fmt.Errorf("connector %s: unknown type %q", id, id.Type())
+		}
+		cfg := f.CreateDefaultConfig()
+		if err := connConfs[id].Unmarshal(cfg); err != nil {
+			return nil, fmt.Errorf("connectors::%s: %w", id, err)
+		}
+		if err := xconfmap.Validate(cfg); err != nil {
+			return nil, fmt.Errorf("connectors::%s: %w", id, err)
+		}
+		n := &connNode{id: id, factory: f, config: cfg}
+		conns[id] = n
+		return n, nil
+	}
+
+	var roots []*pipeNode
+	for _, id := range ids {
+		pCfg, p := svc.Pipelines[id], pipes[id]
+
+		for _, procID := range pCfg.Processors {
+			c, ok := procConfs[procID]
+			if !ok {
+				return nil, nil, fmt.Errorf("pipeline %s: processor %s is not configured", id, procID)
+			}
+			f, ok := factories.Processors[procID.Type()]
+			if !ok {
+				return nil, nil, fmt.Errorf("processor %s: unknown type %q", procID, procID.Type())
+			}
+			cfg := f.CreateDefaultConfig()
+			if err := c.Unmarshal(cfg); err != nil {
+				return nil, nil, fmt.Errorf("processors::%s: %w", procID, err)
+			}
+			if err := xconfmap.Validate(cfg); err != nil {
+				return nil, nil, fmt.Errorf("processors::%s: %w", procID, err)
+			}
+			// Like in a collector, each pipeline has its own instance of a
+			// processor.
+			p.procs = append(p.procs, procNode{
+				id:        procID,
+				Processor: Processor{Factory: f, Config: cfg},
+			})
+		}
+
+		for _, expID := range pCfg.Exporters {
+			if _, ok := connConfs[expID]; ok {
+				n, err := connNodeFor(expID)
+				if err != nil {
+					return nil, nil, err
+				}
+				p.conns = append(p.conns, n)
+				continue
+			}
+
+			n, ok := exps[expID]
+			if !ok {
+				c, ok := expConfs[expID]
+				if !ok {
+					return nil, nil, fmt.Errorf("pipeline %s: exporter %s is not configured", id, expID)
+				}
+				f, ok := factories.Exporters[expID.Type()]
+				if !ok {
+					return nil, nil, fmt.Errorf("exporter %s: unknown type %q", expID, expID.Type())
+				}
+				cfg := f.CreateDefaultConfig()
+				if err := c.Unmarshal(cfg); err != nil {
+					return nil, nil, fmt.Errorf("exporters::%s: %w", expID, err)
+				}
+				if err := xconfmap.Validate(cfg); err != nil {
+					return nil, nil, fmt.Errorf("exporters::%s: %w", expID, err)
+				}
+				n = &expNode{id: expID, Exporter: Exporter{Factory: f, Config: cfg}}
+				exps[expID] = n
+			}
+			p.exps = append(p.exps, n)
+		}
+
+		root := len(pCfg.Receivers) == 0
+		for _, recvID := range pCfg.Receivers {
+			if _, ok := connConfs[recvID]; !ok {
+				root = true
+				continue
+			}
+			n, err := connNodeFor(recvID)
+			if err != nil {
+				return nil, nil, err
+			}
+			n.pipes = append(n.pipes, p)
+		}
+		if root {
+			roots = append(roots, p)
+		}
+	}
+	if len(roots) == 0 {
+		return nil, nil, fmt.Errorf("service::pipelines: all pipelines only receive from connectors")
+	}
+
+	exts := make([]extNode, 0, len(svc.Extensions))
+	for _, extID := range svc.Extensions {
+		c, ok := extConfs[extID]
+		if !ok {
+			return nil, nil, fmt.Errorf("service::extensions: extension %s is not configured", extID)
+		}
+		f, ok := factories.Extensions[extID.Type()]
+		if !ok {
+			return nil, nil, fmt.Errorf("extension %s: unknown type %q", extID, extID.Type())
+		}
+		cfg := f.CreateDefaultConfig()
+		if err := c.Unmarshal(cfg); err != nil {
+			return nil, nil, fmt.Errorf("extensions::%s: %w", extID, err)
+		}
+		if err := xconfmap.Validate(cfg); err != nil {
+			return nil, nil, fmt.Errorf("extensions::%s: %w", extID, err)
+		}
+		exts = append(exts, extNode{id: extID, Extension: Extension{Factory: f, Config: cfg}})
+	}
+	return exts, roots, nil
+}
+
+// sectionConfs returns the configuration of each component in the section of
+// conf keyed by component ID.
+func sectionConfs(conf *confmap.Conf, section string) (map[component.ID]*confmap.Conf, error) {
+	sub, err := conf.Sub(section)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", section, err)
+	}
+
+	confs := make(map[component.ID]*confmap.Conf)
+	for key := range sub.ToStringMap() {
+		var id component.ID
+		if err := id.UnmarshalText([]byte(key)); err != nil {
+			return nil, fmt.Errorf("%s::%s: %w", section, key, err)
+		}
+		c, err := sub.Sub(key)
+		if err != nil {
+			return nil, fmt.Errorf("%s::%s: %w", section, key, err)
+		}
+		confs[id] = c
+	}
+	return confs, nil
+}
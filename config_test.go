@@ -0,0 +1,637 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MrAlias/collex"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/configopaque"
+	"go.opentelemetry.io/collector/config/configretry"
+	"go.opentelemetry.io/collector/confmap"
+	"go.opentelemetry.io/collector/connector"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/processor"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+const pipelineYAML = `
+receivers:
+  otlp:
+    protocols:
+      grpc:
+exporters:
+  sink:
+  sink/metrics:
+connectors:
+  spancount:
+service:
+  pipelines:
+    traces:
+      receivers: [otlp]
+      exporters: [sink, spancount]
+    metrics:
+      receivers: [spancount]
+      exporters: [sink/metrics]
+`
+
+func TestPipelineFromYAML(t *testing.T) {
+	var s sink
+	factories := collex.Factories{
+		Exporters:  map[component.Type]exporter.Factory{},
+		Connectors: map[component.Type]connector.Factory{},
+	}
+	expF, connF := s.factory(), spanCount()
+	factories.Exporters[expF.Type()] = expF
+	factories.Connectors[connF.Type()] = connF
+
+	ctx := context.Background()
+	p, err := collex.PipelineFromYAML(ctx, factories, []byte(pipelineYAML), settings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans := tracetest.SpanStubs{{Name: "a", Resource: resource.Empty()}}.Snapshots()
+	if err := p.SpanExporter().ExportSpans(ctx, spans); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := len(s.traces); got != 1 {
+		t.Errorf("traces pipeline exported %d times, want 1", got)
+	}
+	if got := len(s.metrics); got != 1 {
+		t.Errorf("metrics pipeline exported %d times, want 1", got)
+	}
+}
+
+func TestPipelineFromYAMLCycle(t *testing.T) {
+	const cycle = `
+connectors:
+  forward:
+service:
+  pipelines:
+    traces/in:
+      exporters: [forward]
+    traces:
+      receivers: [forward]
+      exporters: [forward]
+`
+	f := connector.NewFactory(
+		component.MustNewType("forward"),
+		createEmptyConfig,
+		connector.WithTracesToTraces(nil, component.StabilityLevelDevelopment),
+	)
+	factories := collex.Factories{
+		Connectors: map[component.Type]connector.Factory{f.Type(): f},
+	}
+	if _, err := collex.PipelineFromYAML(context.Background(), factories, []byte(cycle), settings()); err == nil {
+		t.Error("expected an error for a pipeline cycle")
+	}
+}
+
+type maskConfig struct {
+	BlockedKeys []string `mapstructure:"blocked_keys"`
+}
+
+// mask is a collector processor that masks the values of the span attributes
+// with a blocked key.
+func mask() processor.Factory {
+	return processor.NewFactory(
+		component.MustNewType("mask"),
+		func() component.Config { return &maskConfig{} },
+		processor.WithTraces(func(_ context.Context, _ processor.Settings, cfg component.Config, next consumer.Traces) (processor.Traces, error) {
+			blocked := cfg.(*maskConfig).BlockedKeys
+			c, err := consumer.NewTraces(func(ctx context.Context, td ptrace.Traces) error {
+				rss := td.ResourceSpans()
+				for i := 0; i < rss.Len(); i++ {
+					sss := rss.At(i).ScopeSpans()
+					for j := 0; j < sss.Len(); j++ {
+						spans := sss.At(j).Spans()
+						for k := 0; k < spans.Len(); k++ {
+							attrs := spans.At(k).Attributes()
+							for _, key := range blocked {
+								if v, ok := attrs.Get(key); ok {
+									v.SetStr("****")
+								}
+							}
+						}
+					}
+				}
+				return next.ConsumeTraces(ctx, td)
+			}, consumer.WithCapabilities(consumer.Capabilities{MutatesData: true}))
+			return tracesComponent{Traces: c}, err
+		}, component.StabilityLevelDevelopment),
+	)
+}
+
+func TestPipelineFromYAMLProcessorConfig(t *testing.T) {
+	const conf = `
+processors:
+  mask:
+    blocked_keys: [password]
+exporters:
+  sink:
+service:
+  pipelines:
+    traces:
+      processors: [mask]
+      exporters: [sink]
+`
+	var s sink
+	expF, procF := s.factory(), mask()
+	factories := collex.Factories{
+		Processors: map[component.Type]processor.Factory{procF.Type(): procF},
+		Exporters:  map[component.Type]exporter.Factory{expF.Type(): expF},
+	}
+
+	ctx := context.Background()
+	p, err := collex.PipelineFromYAML(ctx, factories, []byte(conf), settings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans := tracetest.SpanStubs{{
+		Name:       "login",
+		Attributes: []attribute.KeyValue{attribute.String("password", "hunter2")},
+		Resource:   resource.Empty(),
+	}}.Snapshots()
+	if err := p.SpanExporter().ExportSpans(ctx, spans); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.traces) != 1 {
+		t.Fatalf("exporter received %d exports, want 1", len(s.traces))
+	}
+	attrs := s.traces[0].ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Attributes()
+	if v, _ := attrs.Get("password"); v.Str() != "****" {
+		t.Errorf("password = %q, want it masked", v.Str())
+	}
+}
+
+func TestConfigFromYAML(t *testing.T) {
+	traces, err := consumer.NewTraces(func(context.Context, ptrace.Traces) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := collex.NewConsumerFactory(component.MustNewType("consumer"), collex.Consumers{Traces: traces})
+
+	cfg, err := collex.ConfigFromYAML(f, []byte(`
+timeout: 3s
+sending_queue:
+  queue_size: 10
+retry_on_failure:
+  enabled: false
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := cfg.(*collex.HelperConfig)
+	if got.Timeout != 3*time.Second {
+		t.Errorf("timeout = %v, want 3s", got.Timeout)
+	}
+	if got.QueueConfig.QueueSize != 10 {
+		t.Errorf("queue size = %d, want 10", got.QueueConfig.QueueSize)
+	}
+	if !got.QueueConfig.Enabled {
+		t.Error("sending queue disabled, want the default to be kept")
+	}
+	if got.RetryConfig.Enabled {
+		t.Error("retry enabled, want it disabled")
+	}
+
+	if _, err := collex.ConfigFromYAML(f, []byte("unknown: true")); err == nil {
+		t.Error("expected an error loading an unknown setting")
+	}
+}
+
+func TestConfigFromMap(t *testing.T) {
+	traces, err := consumer.NewTraces(func(context.Context, ptrace.Traces) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := collex.NewConsumerFactory(component.MustNewType("consumer"), collex.Consumers{Traces: traces})
+
+	cfg, err := collex.ConfigFromMap(f, map[string]any{
+		"timeout":       3 * time.Second,
+		"sending_queue": map[string]any{"queue_size": 10},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := cfg.(*collex.HelperConfig)
+	if got.Timeout != 3*time.Second {
+		t.Errorf("timeout = %v, want 3s", got.Timeout)
+	}
+	if got.QueueConfig.QueueSize != 10 || !got.QueueConfig.Enabled {
+		t.Errorf("sending queue = %+v, want an enabled queue of 10", got.QueueConfig)
+	}
+
+	if _, err := collex.ConfigFromMap(f, map[string]any{"unknown": true}); err == nil {
+		t.Error("expected an error loading an unknown setting")
+	}
+}
+
+func TestConfigFromURI(t *testing.T) {
+	traces, err := consumer.NewTraces(func(context.Context, ptrace.Traces) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := collex.NewConsumerFactory(component.MustNewType("consumer"), collex.Consumers{Traces: traces})
+
+	path := filepath.Join(t.TempDir(), "exporter.yaml")
+	if err := os.WriteFile(path, []byte("timeout: 3s"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("COLLEX_TEST_CONFIG", "timeout: 3s")
+
+	ctx := context.Background()
+	for _, uri := range []string{"file:" + path, path, "env:COLLEX_TEST_CONFIG", "yaml:timeout: 3s"} {
+		cfg, err := collex.ConfigFromURI(ctx, f, uri)
+		if err != nil {
+			t.Errorf("%s: %v", uri, err)
+			continue
+		}
+		if got := cfg.(*collex.HelperConfig).Timeout; got != 3*time.Second {
+			t.Errorf("%s: timeout = %v, want 3s", uri, got)
+		}
+	}
+
+	if _, err := collex.ConfigFromURI(ctx, f, "unknown:config"); err == nil {
+		t.Error("expected an error for an unsupported scheme")
+	}
+}
+
+func TestPipelineFromURI(t *testing.T) {
+	var s sink
+	expF, connF := s.factory(), spanCount()
+	factories := collex.Factories{
+		Exporters:  map[component.Type]exporter.Factory{expF.Type(): expF},
+		Connectors: map[component.Type]connector.Factory{connF.Type(): connF},
+	}
+
+	path := filepath.Join(t.TempDir(), "pipeline.yaml")
+	if err := os.WriteFile(path, []byte(pipelineYAML), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	p, err := collex.PipelineFromURI(ctx, factories, "file:"+path, settings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans := tracetest.SpanStubs{{Name: "a", Resource: resource.Empty()}}.Snapshots()
+	if err := p.SpanExporter().ExportSpans(ctx, spans); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(s.traces); got != 1 {
+		t.Errorf("traces pipeline exported %d times, want 1", got)
+	}
+}
+
+func TestConfigFromYAMLEnv(t *testing.T) {
+	traces, err := consumer.NewTraces(func(context.Context, ptrace.Traces) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := collex.NewConsumerFactory(component.MustNewType("consumer"), collex.Consumers{Traces: traces})
+	t.Setenv("COLLEX_TEST_TIMEOUT", "3s")
+
+	for _, conf := range []string{"timeout: ${env:COLLEX_TEST_TIMEOUT}", "timeout: ${COLLEX_TEST_TIMEOUT}"} {
+		cfg, err := collex.ConfigFromYAML(f, []byte(conf))
+		if err != nil {
+			t.Errorf("%s: %v", conf, err)
+			continue
+		}
+		if got := cfg.(*collex.HelperConfig).Timeout; got != 3*time.Second {
+			t.Errorf("%s: timeout = %v, want 3s", conf, got)
+		}
+	}
+}
+
+func TestConfigValidation(t *testing.T) {
+	traces, err := consumer.NewTraces(func(context.Context, ptrace.Traces) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := collex.NewConsumerFactory(component.MustNewType("consumer"), collex.Consumers{Traces: traces})
+
+	const invalid = `
+sending_queue:
+  queue_size: 0
+`
+	_, err = collex.ConfigFromYAML(f, []byte(invalid))
+	if err == nil || !strings.Contains(err.Error(), "sending_queue") {
+		t.Errorf("ConfigFromYAML error = %v, want it to name sending_queue", err)
+	}
+
+	cfg := collex.NewHelperConfig()
+	cfg.QueueConfig.QueueSize = 0
+	factory, err := collex.NewFactory(f, settings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = factory.SpanExporter(context.Background(), cfg)
+	const want = "exporter consumer: invalid configuration: sending_queue"
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("SpanExporter error = %v, want it to contain %q", err, want)
+	}
+
+	const pipe = `
+exporters:
+  consumer:
+    sending_queue:
+      queue_size: 0
+service:
+  pipelines:
+    traces:
+      exporters: [consumer]
+`
+	factories := collex.Factories{Exporters: map[component.Type]exporter.Factory{f.Type(): f}}
+	_, err = collex.PipelineFromYAML(context.Background(), factories, []byte(pipe), settings())
+	if err == nil || !strings.Contains(err.Error(), "exporters::consumer: sending_queue") {
+		t.Errorf("PipelineFromYAML error = %v, want it to name exporters::consumer: sending_queue", err)
+	}
+}
+
+type secretConfig struct {
+	Endpoint string              `mapstructure:"endpoint"`
+	APIKey   configopaque.String `mapstructure:"api_key"`
+}
+
+func TestConfigToYAML(t *testing.T) {
+	out, err := collex.ConfigToYAML(&secretConfig{Endpoint: "localhost:4317", APIKey: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(out)
+	if strings.Contains(got, "secret") {
+		t.Errorf("api_key not redacted:\n%s", got)
+	}
+	for _, want := range []string{"endpoint: localhost:4317", "api_key: '[REDACTED]'"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("config does not contain %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestPipelineConfigYAML(t *testing.T) {
+	var s sink
+	expF, connF := s.factory(), spanCount()
+	factories := collex.Factories{
+		Exporters:  map[component.Type]exporter.Factory{expF.Type(): expF},
+		Connectors: map[component.Type]connector.Factory{connF.Type(): connF},
+	}
+
+	ctx := context.Background()
+	p, err := collex.PipelineFromYAML(ctx, factories, []byte(pipelineYAML), settings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := p.ConfigYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// The configuration builds the same pipeline.
+	s = sink{}
+	p, err = collex.PipelineFromYAML(ctx, factories, out, settings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans := tracetest.SpanStubs{{Name: "a", Resource: resource.Empty()}}.Snapshots()
+	if err := p.SpanExporter().ExportSpans(ctx, spans); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(s.traces); got != 1 {
+		t.Errorf("traces pipeline exported %d times, want 1", got)
+	}
+	if got := len(s.metrics); got != 1 {
+		t.Errorf("metrics pipeline exported %d times, want 1", got)
+	}
+}
+
+type sharedConfig struct {
+	Headers map[string]configopaque.String `mapstructure:"headers"`
+	Retry   *configretry.BackOffConfig     `mapstructure:"retry"`
+}
+
+func TestConfigure(t *testing.T) {
+	// A factory returning the same default configuration every time.
+	def := &sharedConfig{
+		Headers: map[string]configopaque.String{},
+		Retry:   &configretry.BackOffConfig{Enabled: true},
+	}
+	f := exporter.NewFactory(component.MustNewType("shared"), func() component.Config { return def })
+
+	a, err := collex.Configure(f, func(cfg *sharedConfig) error {
+		cfg.Headers["tenant"] = "a"
+		cfg.Retry.Enabled = false
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := collex.Configure(f, func(cfg *sharedConfig) error {
+		cfg.Headers["tenant"] = "b"
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := a.Headers["tenant"]; got != "a" {
+		t.Errorf("a tenant = %q, want a", got)
+	}
+	if got := b.Headers["tenant"]; got != "b" {
+		t.Errorf("b tenant = %q, want b", got)
+	}
+	if a.Retry.Enabled || !b.Retry.Enabled {
+		t.Error("retry configuration shared between configurations")
+	}
+	if len(def.Headers) != 0 || !def.Retry.Enabled {
+		t.Error("default configuration changed")
+	}
+
+	if _, err := collex.Configure(f, func(*collex.HelperConfig) error { return nil }); err == nil {
+		t.Error("expected an error for the wrong configuration type")
+	}
+}
+
+func TestConfigureValidation(t *testing.T) {
+	traces, err := consumer.NewTraces(func(context.Context, ptrace.Traces) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := collex.NewConsumerFactory(component.MustNewType("consumer"), collex.Consumers{Traces: traces})
+
+	_, err = collex.Configure(f, func(cfg *collex.HelperConfig) error {
+		cfg.QueueConfig.QueueSize = 0
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "invalid configuration") {
+		t.Errorf("Configure error = %v, want an invalid configuration", err)
+	}
+
+	errBad := errors.New("bad")
+	_, err = collex.Configure(f, func(*collex.HelperConfig) error { return errBad })
+	if !errors.Is(err, errBad) {
+		t.Errorf("Configure error = %v, want %v", err, errBad)
+	}
+}
+
+func TestExportersFromYAML(t *testing.T) {
+	const conf = `
+exporters:
+  endpoints/primary:
+    endpoint: primary
+  endpoints/backup:
+    endpoint: backup
+`
+	var e endpoints
+	expF := e.factory()
+	factories := collex.Factories{Exporters: map[component.Type]exporter.Factory{expF.Type(): expF}}
+
+	ctx := context.Background()
+	exps, err := collex.ExportersFromYAML(ctx, factories, []byte(conf), settings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exps) != 2 {
+		t.Fatalf("got %d exporters, want 2", len(exps))
+	}
+	primary := exps[component.MustNewIDWithName("endpoints", "primary")]
+	if primary == nil {
+		t.Fatal("no endpoints/primary exporter")
+	}
+	spans := tracetest.SpanStubs{{Name: "a", Resource: resource.Empty()}}.Snapshots()
+	if err := primary.SpanExporter().ExportSpans(ctx, spans); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range exps {
+		if err := p.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := map[string]int{"primary": 1}; !reflect.DeepEqual(e.spans, want) {
+		t.Errorf("spans = %v, want %v", e.spans, want)
+	}
+
+	// Exporters already created are shut down if one fails.
+	e = endpoints{}
+	const down = `
+exporters:
+  endpoints/a:
+    endpoint: a
+  endpoints/b:
+    endpoint: down
+`
+	if _, err := collex.ExportersFromYAML(ctx, factories, []byte(down), settings()); !errors.Is(err, errBroken) {
+		t.Errorf("ExportersFromYAML error = %v, want %v", err, errBroken)
+	}
+	if want := []string{"down", "a"}; !reflect.DeepEqual(e.stopped, want) {
+		t.Errorf("stopped = %v, want %v", e.stopped, want)
+	}
+}
+
+// secretProvider is a confmap provider that, like the provider of a secret
+// store, resolves ${secret:<name>} references to the value of the secret.
+type secretProvider map[string]string
+
+func (p secretProvider) factory() confmap.ProviderFactory {
+	return confmap.NewProviderFactory(func(confmap.ProviderSettings) confmap.Provider { return p })
+}
+
+func (p secretProvider) Retrieve(_ context.Context, uri string, _ confmap.WatcherFunc) (*confmap.Retrieved, error) {
+	v, ok := p[strings.TrimPrefix(uri, "secret:")]
+	if !ok {
+		return nil, fmt.Errorf("secret %q not found", uri)
+	}
+	return confmap.NewRetrieved(v)
+}
+
+func (secretProvider) Scheme() string { return "secret" }
+
+func (secretProvider) Shutdown(context.Context) error { return nil }
+
+func TestConfigFromYAMLProviders(t *testing.T) {
+	secrets := secretProvider{"endpoint": "vault"}
+	var e endpoints
+	cfg, err := collex.ConfigFromYAML(e.factory(), []byte("endpoint: ${secret:endpoint}"), secrets.factory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.(*endpointConfig).Endpoint; got != "vault" {
+		t.Errorf("endpoint = %q, want vault", got)
+	}
+
+	expF := e.factory()
+	factories := collex.Factories{
+		Exporters: map[component.Type]exporter.Factory{expF.Type(): expF},
+		Providers: []confmap.ProviderFactory{secrets.factory()},
+	}
+	const conf = `
+exporters:
+  endpoints:
+    endpoint: ${secret:endpoint}
+service:
+  pipelines:
+    traces:
+      exporters: [endpoints]
+`
+	ctx := context.Background()
+	p, err := collex.PipelineFromYAML(ctx, factories, []byte(conf), settings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans := tracetest.SpanStubs{{Name: "a", Resource: resource.Empty()}}.Snapshots()
+	if err := p.SpanExporter().ExportSpans(ctx, spans); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := e.spans["vault"]; got != 1 {
+		t.Errorf("vault endpoint exported %d spans, want 1", got)
+	}
+
+	if _, err := collex.ConfigFromYAML(e.factory(), []byte("endpoint: ${secret:unknown}"), secrets.factory()); err == nil {
+		t.Error("expected an error for an unknown secret")
+	}
+}
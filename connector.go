@@ -0,0 +1,61 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/connector"
+	"go.opentelemetry.io/collector/pipeline"
+)
+
+// Connector is an OpenTelemetry collector connector. It receives the same
+// telemetry as the wrapped exporter and emits the telemetry it generates to
+// the exporters of its pipelines.
+//
+// For example, the spanmetrics connector generates request, error, and
+// duration metrics from the exported spans and sends them to the metrics
+// exporters of its pipelines. Or, the routing connector sends the exported
+// spans to the exporters of the pipelines its routing table selects.
+type Connector struct {
+	// Factory creates the connector.
+	Factory connector.Factory
+	// Config is the connector configuration. If nil, the Factory default
+	// configuration is used.
+	Config component.Config
+	// Name is the name of the component ID, e.g. primary for routing/primary.
+	// Components of the same type are told apart by their names, like in
+	// a collector configuration.
+	Name string
+	// Pipelines are the exporters the connector output is sent to keyed by
+	// the ID of the pipeline they belong to. The signal of the pipeline ID
+	// determines the type of telemetry the connector emits to the pipeline.
+	Pipelines map[pipeline.ID][]Exporter
+}
+
+func (c Connector) node() *connNode {
+	n := &connNode{
+		id:      component.NewIDWithName(c.Factory.Type(), c.Name),
+		factory: c.Factory,
+		config:  c.Config,
+	}
+	for id, exps := range c.Pipelines {
+		p := &pipeNode{id: id}
+		for _, e := range exps {
+			p.exps = append(p.exps, e.node())
+		}
+		n.pipes = append(n.pipes, p)
+	}
+	return n
+}
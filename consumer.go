@@ -0,0 +1,93 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"context"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/configretry"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/exporter/exporterhelper"
+)
+
+// Consumers are the collector consumers of each signal a consumer exporter
+// sends telemetry to. Only the signals with a non-nil consumer are supported.
+type Consumers struct {
+	Traces  consumer.Traces
+	Metrics consumer.Metrics
+	Logs    consumer.Logs
+}
+
+// HelperConfig is the configuration of the exporterhelper used to wrap
+// consumers. It uses the same format as the timeout, sending_queue, and
+// retry_on_failure settings of collector exporters.
+type HelperConfig struct {
+	exporterhelper.TimeoutConfig `mapstructure:",squash"`
+	QueueConfig                  exporterhelper.QueueConfig `mapstructure:"sending_queue"`
+	RetryConfig                  configretry.BackOffConfig  `mapstructure:"retry_on_failure"`
+}
+
+// NewHelperConfig returns a HelperConfig with the same defaults collector
+// exporters use.
+func NewHelperConfig() *HelperConfig {
+	return &HelperConfig{
+		TimeoutConfig: exporterhelper.NewDefaultTimeoutConfig(),
+		QueueConfig:   exporterhelper.NewDefaultQueueConfig(),
+		RetryConfig:   configretry.NewDefaultBackOffConfig(),
+	}
+}
+
+// NewConsumerFactory returns a collector exporter Factory of type typ that
+// creates exporters sending telemetry to the consumers of c. The consumers
+// are wrapped with the timeout, queue, and retry helpers of the collector
+// configured with a *HelperConfig, the default being NewHelperConfig.
+//
+// This gives consumers that were not built with exporterhelper the same
+// resilience as collector exporters. The returned Factory is used like any
+// other, e.g. with NewFactory or PipelineBuilder.WithExporter.
+func NewConsumerFactory(typ component.Type, c Consumers) exporter.Factory {
+	var opts []exporter.FactoryOption
+	if c.Traces != nil {
+		opts = append(opts, exporter.WithTraces(func(ctx context.Context, set exporter.Settings, cfg component.Config) (exporter.Traces, error) {
+			hCfg := cfg.(*HelperConfig)
+			return exporterhelper.NewTraces(ctx, set, cfg, c.Traces.ConsumeTraces, hCfg.options(c.Traces.Capabilities())...)
+		}, component.StabilityLevelDevelopment))
+	}
+	if c.Metrics != nil {
+		opts = append(opts, exporter.WithMetrics(func(ctx context.Context, set exporter.Settings, cfg component.Config) (exporter.Metrics, error) {
+			hCfg := cfg.(*HelperConfig)
+			return exporterhelper.NewMetrics(ctx, set, cfg, c.Metrics.ConsumeMetrics, hCfg.options(c.Metrics.Capabilities())...)
+		}, component.StabilityLevelDevelopment))
+	}
+	if c.Logs != nil {
+		opts = append(opts, exporter.WithLogs(func(ctx context.Context, set exporter.Settings, cfg component.Config) (exporter.Logs, error) {
+			hCfg := cfg.(*HelperConfig)
+			return exporterhelper.NewLogs(ctx, set, cfg, c.Logs.ConsumeLogs, hCfg.options(c.Logs.Capabilities())...)
+		}, component.StabilityLevelDevelopment))
+	}
+	createDefault := func() component.Config { return NewHelperConfig() }
+	return exporter.NewFactory(typ, createDefault, opts...)
+}
+
+func (c *HelperConfig) options(caps consumer.Capabilities) []exporterhelper.Option {
+	return []exporterhelper.Option{
+		exporterhelper.WithCapabilities(caps),
+		exporterhelper.WithTimeout(c.TimeoutConfig),
+		exporterhelper.WithQueue(c.QueueConfig),
+		exporterhelper.WithRetry(c.RetryConfig),
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MrAlias/collex"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/extension"
+	"go.opentelemetry.io/collector/extension/xextension/storage"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestConsumerFactoryRetry(t *testing.T) {
+	var (
+		attempts int
+		received []ptrace.Traces
+	)
+	c, err := consumer.NewTraces(func(_ context.Context, td ptrace.Traces) error {
+		attempts++
+		if attempts == 1 {
+			return errors.New("temporarily unavailable")
+		}
+		received = append(received, td)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := collex.NewConsumerFactory(component.MustNewType("raw"), collex.Consumers{Traces: c})
+	cfg := f.CreateDefaultConfig().(*collex.HelperConfig)
+	cfg.QueueConfig.Enabled = false
+	cfg.RetryConfig.InitialInterval = time.Millisecond
+
+	ctx := context.Background()
+	p, err := collex.NewPipeline().
+		WithSettings(*settings()).
+		WithExporter(f, cfg).
+		Build(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans := tracetest.SpanStubs{{Name: "a", Resource: resource.Empty()}}.Snapshots()
+	if err := p.SpanExporter().ExportSpans(ctx, spans); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if attempts != 2 {
+		t.Errorf("consumer called %d times, want 2", attempts)
+	}
+	if len(received) != 1 {
+		t.Errorf("consumer received %d exports, want 1", len(received))
+	}
+}
+
+// memStorage is a collector storage extension that keeps the data of its
+// clients in memory.
+type memStorage struct {
+	testComponent
+
+	mu      sync.Mutex
+	clients []component.ID
+	data    map[string][]byte
+}
+
+func (s *memStorage) factory() extension.Factory {
+	return extension.NewFactory(
+		component.MustNewType("memory_storage"),
+		createEmptyConfig,
+		func(context.Context, extension.Settings, component.Config) (extension.Extension, error) {
+			return s, nil
+		},
+		component.StabilityLevelDevelopment,
+	)
+}
+
+func (s *memStorage) GetClient(_ context.Context, _ component.Kind, id component.ID, _ string) (storage.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clients = append(s.clients, id)
+	if s.data == nil {
+		s.data = make(map[string][]byte)
+	}
+	return s, nil
+}
+
+func (s *memStorage) Get(ctx context.Context, key string) ([]byte, error) {
+	op := storage.GetOperation(key)
+	err := s.Batch(ctx, op)
+	return op.Value, err
+}
+
+func (s *memStorage) Set(ctx context.Context, key string, value []byte) error {
+	return s.Batch(ctx, storage.SetOperation(key, value))
+}
+
+func (s *memStorage) Delete(ctx context.Context, key string) error {
+	return s.Batch(ctx, storage.DeleteOperation(key))
+}
+
+func (s *memStorage) Batch(_ context.Context, ops ...*storage.Operation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, op := range ops {
+		switch op.Type {
+		case storage.Get:
+			op.Value = s.data[op.Key]
+		case storage.Set:
+			s.data[op.Key] = op.Value
+		case storage.Delete:
+			delete(s.data, op.Key)
+		}
+	}
+	return nil
+}
+
+func (s *memStorage) Close(context.Context) error { return nil }
+
+func TestConsumerFactoryPersistentQueue(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received int
+	)
+	c, err := consumer.NewTraces(func(_ context.Context, td ptrace.Traces) error {
+		mu.Lock()
+		defer mu.Unlock()
+		received += td.SpanCount()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := new(memStorage)
+	storageF := store.factory()
+	storageID := component.NewID(storageF.Type())
+
+	f := collex.NewConsumerFactory(component.MustNewType("raw"), collex.Consumers{Traces: c})
+	cfg := f.CreateDefaultConfig().(*collex.HelperConfig)
+	cfg.QueueConfig.StorageID = &storageID
+
+	ctx := context.Background()
+	p, err := collex.NewPipeline().
+		WithSettings(*settings()).
+		WithExtensions(collex.Extension{Factory: storageF}).
+		WithExporter(f, cfg).
+		Build(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans := tracetest.SpanStubs{{Name: "a", Resource: resource.Empty()}}.Snapshots()
+	if err := p.SpanExporter().ExportSpans(ctx, spans); err != nil {
+		t.Fatal(err)
+	}
+	// The persistent queue keeps what it has not sent on shutdown for the
+	// next run, wait for the span to be sent instead.
+	delivered := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return received == 1
+	}
+	for deadline := time.Now().Add(5 * time.Second); !delivered() && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(store.clients) != 1 || store.clients[0] != component.MustNewID("raw") {
+		t.Errorf("storage clients = %v, want one for the raw exporter", store.clients)
+	}
+	if !delivered() {
+		t.Error("span was not sent from the persistent queue")
+	}
+}
@@ -0,0 +1,279 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pipeline"
+	"go.uber.org/zap"
+)
+
+// DeadLetterConfig configures the dead-letter directory of an exporter. The
+// telemetry of the exports that failed, permanently or once the retries of
+// the Retry configuration of the exporter were exhausted, is written there
+// instead of being lost. Only the telemetry rejected by an exporter that
+// rejected part of an export is. The exports refused by an open circuit
+// breaker or failed with a transient error are not written, their caller can
+// still retry them.
+//
+// The failures are only seen for the exports that fail synchronously. An
+// exporter with the sending queue of the exporterhelper of the collector
+// accepts the exports it queues, the telemetry it fails to send later is not
+// written.
+//
+// The files are in the formats of the collector file exporter: OTLP JSON
+// requests, one per line, or OTLP protobuf requests each prefixed with its
+// length as a big-endian uint32. They are replayed with the LoadTraces,
+// LoadMetrics, and LoadLogs functions of the collextest package, or with the
+// otlpjsonfile receiver of a collector.
+type DeadLetterConfig struct {
+	// Dir is the directory the files are written to. It is created if
+	// needed. Nothing is written if it is empty.
+	Dir string
+	// Protobuf writes OTLP protobuf files, with the .binpb extension,
+	// instead of OTLP JSON files, with the .jsonl extension.
+	Protobuf bool
+	// MaxFileSize is the size in bytes a file is rotated at. If it is not
+	// positive, 100 MiB is used.
+	MaxFileSize int64
+	// MaxFiles is the number of files kept for each exporter and signal,
+	// the oldest are removed. All files are kept if it is not positive.
+	MaxFiles int
+}
+
+const defaultDeadLetterFileSize = 100 << 20
+
+// deadLetter writes the undeliverable telemetry of a signal of an exporter to
+// rotated files. The files of the exporter and signal are named with prefix,
+// followed by the time they were created and ext.
+type deadLetter struct {
+	cfg    DeadLetterConfig
+	log    *zap.Logger
+	now    func() time.Time
+	prefix string
+	ext    string
+
+	mu   sync.Mutex
+	file string
+	size int64
+}
+
+// newDeadLetter returns the deadLetter of the exports of signal to the
+// exporter with id, or nil if cfg has no directory.
+func newDeadLetter(cfg DeadLetterConfig, log *zap.Logger, id component.ID, signal pipeline.Signal, now func() time.Time) *deadLetter {
+	if cfg.Dir == "" {
+		return nil
+	}
+	if cfg.MaxFileSize <= 0 {
+		cfg.MaxFileSize = defaultDeadLetterFileSize
+	}
+	ext := ".jsonl"
+	if cfg.Protobuf {
+		ext = ".binpb"
+	}
+	return &deadLetter{
+		cfg:    cfg,
+		log:    log.With(zap.String("exporter", id.String())),
+		now:    now,
+		prefix: strings.ReplaceAll(id.String(), "/", "_") + "-" + signal.String() + "-",
+		ext:    ext,
+	}
+}
+
+// write writes a request, encoded as JSON or protobuf per the configuration
+// of d. Errors are logged, the telemetry is lost then.
+func (d *deadLetter) write(json, proto func() ([]byte, error)) {
+	var (
+		b   []byte
+		err error
+	)
+	if d.cfg.Protobuf {
+		var msg []byte
+		if msg, err = proto(); err == nil {
+			b = binary.BigEndian.AppendUint32(make([]byte, 0, 4+len(msg)), uint32(len(msg)))
+			b = append(b, msg...)
+		}
+	} else if b, err = json(); err == nil {
+		b = append(b, '\n')
+	}
+	if err == nil {
+		err = d.append(b)
+	}
+	if err != nil {
+		d.log.Error("Failed to write undeliverable telemetry to the dead-letter directory", zap.Error(err))
+	}
+}
+
+// append appends b to the current file, rotating it first if b would make it
+// exceed the maximum file size.
+func (d *deadLetter) append(b []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.file == "" || (d.size > 0 && d.size+int64(len(b)) > d.cfg.MaxFileSize) {
+		if err := d.rotate(); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(d.file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	n, err := f.Write(b)
+	d.size += int64(n)
+	return errors.Join(err, f.Close())
+}
+
+// rotate starts a new file and removes the oldest files over the maximum.
+func (d *deadLetter) rotate() error {
+	if err := os.MkdirAll(d.cfg.Dir, 0o700); err != nil {
+		return err
+	}
+	name := d.prefix + d.now().UTC().Format("20060102T150405.000000000Z") + d.ext
+	d.file, d.size = filepath.Join(d.cfg.Dir, name), 0
+	if d.cfg.MaxFiles <= 0 {
+		return nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(d.cfg.Dir, d.prefix+"*"+d.ext))
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(files, d.file) {
+		files = append(files, d.file)
+	}
+	// The names sort by the time the files were created.
+	slices.Sort(files)
+	for len(files) > d.cfg.MaxFiles {
+		if err := os.Remove(files[0]); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		files = files[1:]
+	}
+	return nil
+}
+
+// undeliverable returns true if the telemetry of an export failed with err is
+// lost: it failed permanently or the retries of the export were exhausted.
+func undeliverable(err error) bool {
+	return consumererror.IsPermanent(err) || errors.Is(err, errRetriesExhausted)
+}
+
+// deadLetterTraces returns next writing the traces it fails to deliver to d.
+// It returns next if d is nil.
+func deadLetterTraces(d *deadLetter, next consumer.Traces) consumer.Traces {
+	if d == nil {
+		return next
+	}
+	var (
+		j ptrace.JSONMarshaler
+		p ptrace.ProtoMarshaler
+	)
+	c, err := consumer.NewTraces(func(ctx context.Context, td ptrace.Traces) error {
+		err := next.ConsumeTraces(ctx, td)
+		if undeliverable(err) {
+			var rejected consumererror.Traces
+			if errors.As(err, &rejected) {
+				td = rejected.Data()
+			}
+			d.write(
+				func() ([]byte, error) { return j.MarshalTraces(td) },
+				func() ([]byte, error) { return p.MarshalTraces(td) },
+			)
+		}
+		return err
+	}, consumer.WithCapabilities(next.Capabilities()))
+	if err != nil {
+		// Only returned for a nil function.
+		panic(err)
+	}
+	return c
+}
+
+// deadLetterMetrics returns next writing the metrics it fails to deliver to d.
+// It returns next if d is nil.
+func deadLetterMetrics(d *deadLetter, next consumer.Metrics) consumer.Metrics {
+	if d == nil {
+		return next
+	}
+	var (
+		j pmetric.JSONMarshaler
+		p pmetric.ProtoMarshaler
+	)
+	c, err := consumer.NewMetrics(func(ctx context.Context, md pmetric.Metrics) error {
+		err := next.ConsumeMetrics(ctx, md)
+		if undeliverable(err) {
+			var rejected consumererror.Metrics
+			if errors.As(err, &rejected) {
+				md = rejected.Data()
+			}
+			d.write(
+				func() ([]byte, error) { return j.MarshalMetrics(md) },
+				func() ([]byte, error) { return p.MarshalMetrics(md) },
+			)
+		}
+		return err
+	}, consumer.WithCapabilities(next.Capabilities()))
+	if err != nil {
+		// Only returned for a nil function.
+		panic(err)
+	}
+	return c
+}
+
+// deadLetterLogs returns next writing the logs it fails to deliver to d. It
+// returns next if d is nil.
+func deadLetterLogs(d *deadLetter, next consumer.Logs) consumer.Logs {
+	if d == nil {
+		return next
+	}
+	var (
+		j plog.JSONMarshaler
+		p plog.ProtoMarshaler
+	)
+	c, err := consumer.NewLogs(func(ctx context.Context, ld plog.Logs) error {
+		err := next.ConsumeLogs(ctx, ld)
+		if undeliverable(err) {
+			var rejected consumererror.Logs
+			if errors.As(err, &rejected) {
+				ld = rejected.Data()
+			}
+			d.write(
+				func() ([]byte, error) { return j.MarshalLogs(ld) },
+				func() ([]byte, error) { return p.MarshalLogs(ld) },
+			)
+		}
+		return err
+	}, consumer.WithCapabilities(next.Capabilities()))
+	if err != nil {
+		// Only returned for a nil function.
+		panic(err)
+	}
+	return c
+}
@@ -0,0 +1,146 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/MrAlias/collex"
+	"github.com/MrAlias/collex/collextest"
+	"go.opentelemetry.io/collector/config/configretry"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+)
+
+func TestWithDeadLetter(t *testing.T) {
+	var s sink
+	faulty := collextest.FaultFactory(s.factory(), collextest.Faults{PartialRejectionRate: 1})
+	dir := t.TempDir()
+	// The rejections are transient, they are written once the retries are
+	// exhausted.
+	retry := configretry.BackOffConfig{Enabled: true, InitialInterval: time.Second, MaxElapsedTime: time.Nanosecond}
+	f, err := collex.NewFactory(faulty, settings(), collex.WithDeadLetter(collex.DeadLetterConfig{Dir: dir}), collex.WithRetry(retry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = exp.Shutdown(ctx) }()
+
+	for range 2 {
+		if err := exp.ExportSpans(ctx, spans(4)); !errors.Is(err, collextest.ErrRejected) {
+			t.Fatalf("export error = %v, want %v", err, collextest.ErrRejected)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "sink-traces-*.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("dead-letter files %q, want 1", files)
+	}
+	batches, err := collextest.LoadTraces(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the rejected spans are undeliverable.
+	if len(batches) != 2 || batches[0].SpanCount() != 2 || batches[1].SpanCount() != 2 {
+		t.Errorf("dead-lettered %d batches, want 2 of 2 spans", len(batches))
+	}
+}
+
+func TestWithDeadLetterRotation(t *testing.T) {
+	b := &backend{err: consumererror.NewPermanent(errBroken)}
+	clock := collextest.NewFakeClock(time.Now())
+	dir := t.TempDir()
+	cfg := collex.DeadLetterConfig{Dir: dir, Protobuf: true, MaxFileSize: 1, MaxFiles: 2}
+	f, err := collex.NewFactory(b.factory(), settings(), collex.WithDeadLetter(cfg), collex.WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = exp.Shutdown(ctx) }()
+
+	for i := range 3 {
+		if err := exp.ExportSpans(ctx, spans(i+1)); !errors.Is(err, errBroken) {
+			t.Fatalf("export error = %v, want %v", err, errBroken)
+		}
+		clock.Advance(time.Second)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("dead-letter files %q, want the 2 newest", files)
+	}
+	for i, file := range files {
+		if filepath.Ext(file) != ".binpb" {
+			t.Errorf("dead-letter file %q is not protobuf", file)
+		}
+		batches, err := collextest.LoadTraces(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(batches) != 1 || batches[0].SpanCount() != i+2 {
+			t.Errorf("file %d: %d batches, want 1 of %d spans", i, len(batches), i+2)
+		}
+	}
+}
+
+func TestWithDeadLetterRetriable(t *testing.T) {
+	b := &backend{err: errBroken}
+	dir := t.TempDir()
+	cb := collex.CircuitBreakerConfig{Failures: 1, OpenDuration: time.Hour}
+	f, err := collex.NewFactory(b.factory(), settings(), collex.WithDeadLetter(collex.DeadLetterConfig{Dir: dir}), collex.WithCircuitBreaker(cb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = exp.Shutdown(ctx) }()
+
+	if err := exp.ExportSpans(ctx, spans(1)); !errors.Is(err, errBroken) {
+		t.Fatalf("export error = %v, want %v", err, errBroken)
+	}
+	if err := exp.ExportSpans(ctx, spans(1)); !errors.Is(err, collex.ErrCircuitOpen) {
+		t.Fatalf("export error = %v, want %v", err, collex.ErrCircuitOpen)
+	}
+
+	// Neither the transient failure nor the refused export is lost, their
+	// caller can retry them.
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Errorf("dead-letter files %q, want none", files)
+	}
+}
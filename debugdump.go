@@ -0,0 +1,71 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"context"
+	"encoding/json"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pipeline"
+	"go.uber.org/zap"
+)
+
+// DebugDumpEnv is the environment variable that enables WithDebugDump for
+// all Factories when it is set to a non-empty value, without changing the
+// code of an application.
+const DebugDumpEnv = "COLLEX_DEBUG_DUMP"
+
+// debugDump returns the Middleware logging each export, as OTLP JSON, to log
+// at debug level. The exports of the wrapped exporter of type typ are only
+// marshaled when debug logging is enabled.
+func debugDump(log *zap.Logger, typ component.Type) Middleware {
+	log = log.With(zap.String("exporter", typ.String()))
+	return func(next Export) Export {
+		return func(ctx context.Context, b Batch) error {
+			if ce := log.Check(zap.DebugLevel, "Exporting batch"); ce != nil {
+				ce.Write(
+					zap.String("signal", b.Signal.String()),
+					zap.Int("items", b.Items()),
+					otlpJSON(b),
+				)
+			}
+			return next(ctx, b)
+		}
+	}
+}
+
+// otlpJSON returns the field of b marshaled as OTLP JSON.
+func otlpJSON(b Batch) zap.Field {
+	var (
+		buf []byte
+		err error
+	)
+	switch b.Signal {
+	case pipeline.SignalTraces:
+		buf, err = (&ptrace.JSONMarshaler{}).MarshalTraces(b.Traces)
+	case pipeline.SignalMetrics:
+		buf, err = (&pmetric.JSONMarshaler{}).MarshalMetrics(b.Metrics)
+	case pipeline.SignalLogs:
+		buf, err = (&plog.JSONMarshaler{}).MarshalLogs(b.Logs)
+	}
+	if err != nil {
+		return zap.NamedError("otlp_error", err)
+	}
+	return zap.Reflect("otlp", json.RawMessage(buf))
+}
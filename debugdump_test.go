@@ -0,0 +1,85 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/MrAlias/collex"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func dumpedSpans(t *testing.T, level zapcore.Level, opts ...collex.Option) []observer.LoggedEntry {
+	t.Helper()
+	core, logs := observer.New(level)
+	set := settings()
+	set.Logger = zap.New(core)
+	var s sink
+	f, err := collex.NewFactory(s.factory(), set, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = exp.Shutdown(ctx) }()
+	spans := tracetest.SpanStubs{{Name: "dumped", Resource: resource.Empty()}}.Snapshots()
+	if err := exp.ExportSpans(ctx, spans); err != nil {
+		t.Fatal(err)
+	}
+	return logs.FilterMessage("Exporting batch").All()
+}
+
+func TestWithDebugDump(t *testing.T) {
+	entries := dumpedSpans(t, zapcore.DebugLevel, collex.WithDebugDump())
+	if len(entries) != 1 {
+		t.Fatalf("logged %d batches, want 1", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if fields["signal"] != "traces" || fields["items"] != int64(1) {
+		t.Errorf("logged %v, want 1 item of traces", fields)
+	}
+	otlp, err := zapcore.NewJSONEncoder(zapcore.EncoderConfig{}).EncodeEntry(entries[0].Entry, entries[0].Context)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(otlp.String(), `"otlp":{"resourceSpans":`) || !strings.Contains(otlp.String(), `"name":"dumped"`) {
+		t.Errorf("logged %s, want the spans as OTLP JSON", otlp)
+	}
+}
+
+func TestDebugDumpLevel(t *testing.T) {
+	if entries := dumpedSpans(t, zapcore.InfoLevel, collex.WithDebugDump()); len(entries) != 0 {
+		t.Errorf("logged %d batches without debug level, want none", len(entries))
+	}
+}
+
+func TestDebugDumpEnv(t *testing.T) {
+	if entries := dumpedSpans(t, zapcore.DebugLevel); len(entries) != 0 {
+		t.Errorf("logged %d batches without debug dump, want none", len(entries))
+	}
+	t.Setenv(collex.DebugDumpEnv, "1")
+	if entries := dumpedSpans(t, zapcore.DebugLevel); len(entries) != 1 {
+		t.Errorf("logged %d batches with %s set, want 1", len(entries), collex.DebugDumpEnv)
+	}
+}
@@ -17,11 +17,13 @@ package collex_test
 import (
 	"context"
 	"log"
+	"time"
 
 	"github.com/MrAlias/collex"
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/exporter"
 	"go.opentelemetry.io/collector/exporter/debugexporter"
+	"go.opentelemetry.io/collector/processor/memorylimiterprocessor"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/sdk/trace"
 	"go.uber.org/zap"
@@ -56,3 +58,69 @@ func Example() {
 
 	// Output: {"level":"info","msg":"Traces","resource spans":1,"spans":1}
 }
+
+func ExampleWithProcessors() {
+	limiter := memorylimiterprocessor.NewFactory()
+	limiterCfg := limiter.CreateDefaultConfig().(*memorylimiterprocessor.Config)
+	limiterCfg.CheckInterval = time.Second
+	limiterCfg.MemoryLimitMiB = 512
+	limiterCfg.MemorySpikeLimitMiB = 128
+
+	factory, err := collex.NewFactory(
+		debugexporter.NewFactory(),
+		nil,
+		collex.WithProcessors(collex.Processor{
+			Factory: limiter,
+			Config:  limiterCfg,
+		}),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+	exp, err := factory.SpanExporter(ctx, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Spans are refused by the memory limiter, instead of being sent to the
+	// debug exporter, while the process is above its memory limit.
+	provider := trace.NewTracerProvider(trace.WithBatcher(exp))
+	defer func() {
+		if err := provider.Shutdown(ctx); err != nil {
+			log.Fatal(err)
+		}
+	}()
+	// Output:
+}
+
+func ExampleNewPipeline() {
+	ctx := context.Background()
+	pipeline, err := collex.NewPipeline().
+		WithSettings(exporter.Settings{
+			TelemetrySettings: component.TelemetrySettings{
+				Logger:         zap.NewExample(), // Log to STDOUT for example.
+				TracerProvider: otel.GetTracerProvider(),
+				MeterProvider:  otel.GetMeterProvider(),
+			},
+		}).
+		WithExporter(debugexporter.NewFactory(), nil).
+		Build(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	provider := trace.NewTracerProvider(trace.WithSpanProcessor(pipeline.SpanProcessor()))
+	tracer := provider.Tracer("github.com/MrAlias/collex")
+	_, s := tracer.Start(ctx, "ExamplePipeline")
+	s.End()
+	if err := provider.Shutdown(ctx); err != nil {
+		log.Fatal(err)
+	}
+	if err := pipeline.Shutdown(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	// Output: {"level":"info","msg":"Traces","resource spans":1,"spans":1}
+}
@@ -0,0 +1,116 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"fmt"
+	"reflect"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/configretry"
+	"go.opentelemetry.io/collector/confmap"
+	"go.opentelemetry.io/collector/confmap/xconfmap"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/pipeline"
+)
+
+// Exporter is an OpenTelemetry collector exporter that receives the output of
+// a Pipeline or Connector.
+type Exporter struct {
+	// Factory creates the exporter.
+	Factory exporter.Factory
+	// Config is the exporter configuration. If nil, the Factory default
+	// configuration is used.
+	Config component.Config
+	// Name is the name of the component ID, e.g. primary for otlp/primary.
+	// Components of the same type are told apart by their names, like in
+	// a collector configuration.
+	Name string
+	// Overrides are settings, keyed like in a collector configuration, that
+	// override Config for the exporter of a signal, e.g. a different table
+	// or endpoint for logs. The settings of other signals are not changed.
+	Overrides map[pipeline.Signal]map[string]any
+	// CircuitBreaker configures the circuit breaker of the exporter. The
+	// zero value disables it. See NewCircuitBreakerConfig for defaults.
+	CircuitBreaker CircuitBreakerConfig
+	// RateLimit configures the rate limit of the exports to the exporter.
+	// The zero value does not limit them.
+	RateLimit RateLimitConfig
+	// DeadLetter configures the directory the telemetry the exporter fails
+	// to export, permanently or once the retries of Retry were exhausted, is
+	// written to. The zero value writes none.
+	DeadLetter DeadLetterConfig
+	// Retry configures the retries of the exports the exporter fails, for
+	// exporters without retries of their own, e.g. consumers not built with
+	// the exporterhelper of the collector. Exports are not retried if it is
+	// not enabled.
+	Retry configretry.BackOffConfig
+	// Backpressure configures what the exports do when the sending queue of
+	// the exporter is full. If nil, the exporter configuration decides.
+	Backpressure *BackpressureConfig
+	// MaxBytes limits the size of the telemetry sent to the exporter in one
+	// call. The zero value does not limit it.
+	MaxBytes MaxBytesConfig
+}
+
+func (e Exporter) config() component.Config {
+	if e.Config == nil {
+		return e.Factory.CreateDefaultConfig()
+	}
+	return e.Config
+}
+
+// signalConfig returns the configuration of the exporter of signal, a copy
+// of the configuration with the overrides of signal applied.
+func (e Exporter) signalConfig(signal pipeline.Signal) (component.Config, error) {
+	override, ok := e.Overrides[signal]
+	if e.blocks() {
+		conf := confmap.NewFromStringMap(override)
+		if err := conf.Merge(confmap.NewFromStringMap(blockingQueue)); err != nil {
+			return nil, err
+		}
+		override, ok = conf.ToStringMap(), true
+	}
+	if !ok {
+		return e.config(), nil
+	}
+	cfg := deepCopy(reflect.ValueOf(e.config())).Interface().(component.Config)
+	if err := confmap.NewFromStringMap(override).Unmarshal(cfg); err != nil {
+		return nil, fmt.Errorf("%s overrides: %w", signal, err)
+	}
+	if err := xconfmap.Validate(cfg); err != nil {
+		return nil, fmt.Errorf("invalid %s configuration: %w", signal, err)
+	}
+	return cfg, nil
+}
+
+// blocks returns whether the sending queue of the exporter is set to block
+// when it is full, i.e. its Backpressure does not drop and it has a sending
+// queue.
+func (e Exporter) blocks() bool {
+	if e.Backpressure == nil || e.Backpressure.Drop {
+		return false
+	}
+	m, err := configMap(e.config())
+	if err != nil {
+		return false
+	}
+	_, ok := m["sending_queue"]
+	return ok
+}
+
+func (e Exporter) node() *expNode {
+	return &expNode{id: component.NewIDWithName(e.Factory.Type(), e.Name), Exporter: e}
+}
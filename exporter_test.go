@@ -0,0 +1,119 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/MrAlias/collex"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pipeline"
+)
+
+type tableConfig struct {
+	Endpoint string `mapstructure:"endpoint"`
+	Table    string `mapstructure:"table"`
+}
+
+// tables is a collector exporter that, like the clickhouse exporter, writes
+// each signal to a table. It records the table and endpoint the exporter of
+// each signal is created with.
+type tables struct {
+	mu      sync.Mutex
+	created map[pipeline.Signal]tableConfig
+}
+
+func (tb *tables) factory() exporter.Factory {
+	record := func(signal pipeline.Signal, cfg component.Config) {
+		tb.mu.Lock()
+		defer tb.mu.Unlock()
+		if tb.created == nil {
+			tb.created = make(map[pipeline.Signal]tableConfig)
+		}
+		tb.created[signal] = *cfg.(*tableConfig)
+	}
+	return exporter.NewFactory(
+		component.MustNewType("tables"),
+		func() component.Config { return &tableConfig{Table: "otel"} },
+		exporter.WithTraces(func(_ context.Context, _ exporter.Settings, cfg component.Config) (exporter.Traces, error) {
+			record(pipeline.SignalTraces, cfg)
+			c, err := consumer.NewTraces(func(context.Context, ptrace.Traces) error { return nil })
+			return tracesComponent{Traces: c}, err
+		}, component.StabilityLevelDevelopment),
+		exporter.WithLogs(func(_ context.Context, _ exporter.Settings, cfg component.Config) (exporter.Logs, error) {
+			record(pipeline.SignalLogs, cfg)
+			c, err := consumer.NewLogs(func(context.Context, plog.Logs) error { return nil })
+			return logsComponent{Logs: c}, err
+		}, component.StabilityLevelDevelopment),
+	)
+}
+
+func TestFactoryWithSignalOverrides(t *testing.T) {
+	var tb tables
+	f, err := collex.NewFactory(tb.factory(), settings(), collex.WithSignalOverrides(map[pipeline.Signal]map[string]any{
+		pipeline.SignalLogs: {"table": "otel_logs"},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := &tableConfig{Endpoint: "clickhouse:9000", Table: "otel_traces"}
+
+	ctx := context.Background()
+	spanExp, err := f.SpanExporter(ctx, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logExp, err := f.LogExporter(ctx, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := spanExp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := logExp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := tb.created[pipeline.SignalTraces], *base; got != want {
+		t.Errorf("traces configuration = %+v, want %+v", got, want)
+	}
+	if got, want := tb.created[pipeline.SignalLogs], (tableConfig{Endpoint: "clickhouse:9000", Table: "otel_logs"}); got != want {
+		t.Errorf("logs configuration = %+v, want %+v", got, want)
+	}
+	if base.Table != "otel_traces" {
+		t.Errorf("base configuration changed: %+v", base)
+	}
+}
+
+func TestPipelineExporterOverridesInvalid(t *testing.T) {
+	var tb tables
+	_, err := collex.NewPipeline().
+		WithExporters(collex.Exporter{
+			Factory:   tb.factory(),
+			Overrides: map[pipeline.Signal]map[string]any{pipeline.SignalLogs: {"tabel": "otel_logs"}},
+		}).
+		WithSettings(*settings()).
+		Build(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "exporter tables: logs overrides") {
+		t.Errorf("error = %v, want a logs overrides error", err)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/pipeline"
+)
+
+// ExportErrorFunc is called with the error of each failed export of an
+// exporter returned by a Factory, the signal exported, the number of items
+// (spans, metric data points, or log records) exported, and the number of
+// those that failed.
+//
+// The items that failed are fewer than those exported if the wrapped exporter
+// rejected only part of them, returning a consumererror signal error with the
+// rejected telemetry. Otherwise, all the items exported failed.
+//
+// The function is called synchronously with the export, it should not block.
+type ExportErrorFunc func(err error, signal pipeline.Signal, items, failed int)
+
+// exportErrors reports the failed exports of an exporter of a Factory. A nil
+// exportErrors reports nothing.
+type exportErrors struct {
+	fn     ExportErrorFunc
+	signal pipeline.Signal
+}
+
+// newExportErrors returns an exportErrors reporting the failed exports of
+// signal to fn. It returns nil if fn is nil.
+func newExportErrors(fn ExportErrorFunc, signal pipeline.Signal) *exportErrors {
+	if fn == nil {
+		return nil
+	}
+	return &exportErrors{fn: fn, signal: signal}
+}
+
+// report reports err, if not nil, as the error of an export of items.
+func (r *exportErrors) report(err error, items int) {
+	if r == nil || err == nil {
+		return
+	}
+	r.fn(err, r.signal, items, failedItems(err, items))
+}
+
+// failedItems returns the number of items of an export of items that failed
+// with err.
+func failedItems(err error, items int) int {
+	var (
+		traces  consumererror.Traces
+		metrics consumererror.Metrics
+		logs    consumererror.Logs
+	)
+	switch {
+	case errors.As(err, &traces):
+		return traces.Data().SpanCount()
+	case errors.As(err, &metrics):
+		return metrics.Data().DataPointCount()
+	case errors.As(err, &logs):
+		return logs.Data().LogRecordCount()
+	}
+	return items
+}
@@ -0,0 +1,99 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/MrAlias/collex"
+	"github.com/MrAlias/collex/collextest"
+	"go.opentelemetry.io/collector/pipeline"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type exportFailure struct {
+	err           error
+	signal        pipeline.Signal
+	items, failed int
+}
+
+func onExportErr(failures *[]exportFailure) collex.Option {
+	return collex.WithExportErrorFunc(func(err error, signal pipeline.Signal, items, failed int) {
+		*failures = append(*failures, exportFailure{err, signal, items, failed})
+	})
+}
+
+func TestWithExportErrorFunc(t *testing.T) {
+	var failures []exportFailure
+	f, err := collex.NewFactory(flaky(), settings(), onExportErr(&failures))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = exp.Shutdown(ctx) }()
+
+	spans := tracetest.SpanStubs{{Name: "a"}, {Name: "b"}}.Snapshots()
+	if err := exp.ExportSpans(ctx, spans); err == nil {
+		t.Fatal("first export succeeded")
+	}
+	if err := exp.ExportSpans(ctx, spans); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(failures) != 1 {
+		t.Fatalf("ExportErrorFunc called %d times, want 1", len(failures))
+	}
+	got := failures[0]
+	if !errors.Is(got.err, errBroken) {
+		t.Errorf("error = %v, want %v", got.err, errBroken)
+	}
+	if got.signal != pipeline.SignalTraces || got.items != 2 || got.failed != 2 {
+		t.Errorf("called with %v, %d items, %d failed", got.signal, got.items, got.failed)
+	}
+}
+
+func TestWithExportErrorFuncPartial(t *testing.T) {
+	var s sink
+	faulty := collextest.FaultFactory(s.factory(), collextest.Faults{PartialRejectionRate: 1})
+	var failures []exportFailure
+	f, err := collex.NewFactory(faulty, settings(), onExportErr(&failures))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = exp.Shutdown(ctx) }()
+
+	spans := tracetest.SpanStubs{{Name: "a"}, {Name: "b"}, {Name: "c"}, {Name: "d"}}.Snapshots()
+	if err := exp.ExportSpans(ctx, spans); !errors.Is(err, collextest.ErrRejected) {
+		t.Fatalf("export error = %v, want %v", err, collextest.ErrRejected)
+	}
+
+	if len(failures) != 1 {
+		t.Fatalf("ExportErrorFunc called %d times, want 1", len(failures))
+	}
+	if got := failures[0]; got.items != 4 || got.failed != 2 {
+		t.Errorf("called with %d items, %d failed, want 4 and 2", got.items, got.failed)
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/extension"
+)
+
+// Extension is an OpenTelemetry collector extension. Extensions are started
+// before and shut down after all other components, which find them by ID in
+// their host. For example, an exporter configured with an authenticator finds
+// the extension it refers to this way.
+type Extension struct {
+	// Factory creates the extension.
+	Factory extension.Factory
+	// Config is the extension configuration. If nil, the Factory default
+	// configuration is used.
+	Config component.Config
+	// Name is the name of the component ID, e.g. primary for oauth2client/primary.
+	// Components of the same type are told apart by their names, like in
+	// a collector configuration.
+	Name string
+}
+
+func (e Extension) config() component.Config {
+	if e.Config == nil {
+		return e.Factory.CreateDefaultConfig()
+	}
+	return e.Config
+}
+
+func (e Extension) node() extNode {
+	return extNode{id: component.NewIDWithName(e.Factory.Type(), e.Name), Extension: e}
+}
@@ -0,0 +1,536 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/MrAlias/collex"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/extension"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// lifecycle records the start and shutdown of components.
+type lifecycle struct {
+	events []string
+}
+
+func (l *lifecycle) component(name string) testComponent {
+	return testComponent{
+		StartFunc: func(context.Context, component.Host) error {
+			l.events = append(l.events, "start "+name)
+			return nil
+		},
+		ShutdownFunc: func(context.Context) error {
+			l.events = append(l.events, "shutdown "+name)
+			return nil
+		},
+	}
+}
+
+func (l *lifecycle) extension() extension.Factory {
+	return extension.NewFactory(
+		component.MustNewType("token"),
+		createEmptyConfig,
+		func(context.Context, extension.Settings, component.Config) (extension.Extension, error) {
+			return l.component("token"), nil
+		},
+		component.StabilityLevelDevelopment,
+	)
+}
+
+// exporter returns the factory of an exporter that, like exporters configured
+// with an authenticator, fails to start if the token extension is not found.
+func (l *lifecycle) exporter() exporter.Factory {
+	tokenID := component.MustNewID("token")
+	return exporter.NewFactory(
+		component.MustNewType("auth"),
+		createEmptyConfig,
+		exporter.WithTraces(func(context.Context, exporter.Settings, component.Config) (exporter.Traces, error) {
+			c, err := consumer.NewTraces(func(context.Context, ptrace.Traces) error { return nil })
+			comp := l.component("exporter")
+			start := comp.StartFunc
+			comp.StartFunc = func(ctx context.Context, host component.Host) error {
+				if _, ok := host.GetExtensions()[tokenID]; !ok {
+					return errors.New("token extension not found")
+				}
+				return start(ctx, host)
+			}
+			return tracesComponent{testComponent: comp, Traces: c}, err
+		}, component.StabilityLevelDevelopment),
+	)
+}
+
+func TestFactoryExtensions(t *testing.T) {
+	var l lifecycle
+	f, err := collex.NewFactory(l.exporter(), settings(), collex.WithExtensions(
+		collex.Extension{Factory: l.extension()},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := exp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"start token", "start exporter", "shutdown exporter", "shutdown token"}
+	if !reflect.DeepEqual(l.events, want) {
+		t.Errorf("events = %v, want %v", l.events, want)
+	}
+}
+
+func TestFactoryMissingExtension(t *testing.T) {
+	var l lifecycle
+	f, err := collex.NewFactory(l.exporter(), settings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.SpanExporter(context.Background(), nil); err == nil {
+		t.Error("expected an error starting an exporter without its extension")
+	}
+}
+
+func TestPipelineFromYAMLExtensions(t *testing.T) {
+	const conf = `
+extensions:
+  token:
+exporters:
+  auth:
+service:
+  extensions: [token]
+  pipelines:
+    traces:
+      exporters: [auth]
+`
+	var l lifecycle
+	expF, extF := l.exporter(), l.extension()
+	factories := collex.Factories{
+		Exporters:  map[component.Type]exporter.Factory{expF.Type(): expF},
+		Extensions: map[component.Type]extension.Factory{extF.Type(): extF},
+	}
+
+	ctx := context.Background()
+	p, err := collex.PipelineFromYAML(ctx, factories, []byte(conf), settings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"start token", "start exporter", "shutdown exporter", "shutdown token"}
+	if !reflect.DeepEqual(l.events, want) {
+		t.Errorf("events = %v, want %v", l.events, want)
+	}
+}
+
+// zpages returns the factory of an extension that, like the zpages
+// extension, registers the pages of the host with mux when it is started.
+func zpages(mux *http.ServeMux) extension.Factory {
+	return extension.NewFactory(
+		component.MustNewType("zpages"),
+		createEmptyConfig,
+		func(context.Context, extension.Settings, component.Config) (extension.Extension, error) {
+			return testComponent{StartFunc: func(_ context.Context, host component.Host) error {
+				h, ok := host.(interface {
+					RegisterZPages(mux *http.ServeMux, pathPrefix string)
+				})
+				if !ok {
+					return errors.New("host does not provide zPages")
+				}
+				h.RegisterZPages(mux, "/debug")
+				return nil
+			}}, nil
+		},
+		component.StabilityLevelDevelopment,
+	)
+}
+
+func TestPipelineZPages(t *testing.T) {
+	mux := http.NewServeMux()
+	s := new(sink)
+	ctx := context.Background()
+	p, err := collex.NewPipeline().
+		WithSettings(*settings()).
+		WithExtensions(collex.Extension{Factory: zpages(mux)}).
+		WithProcessors(collex.Processor{Factory: holdSpans()}).
+		WithExporter(s.factory(), nil).
+		Build(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := p.Shutdown(ctx); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	get := func(path string) string {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s: %d", path, rec.Code)
+		}
+		return rec.Body.String()
+	}
+	if got, want := get("/debug/pipelinez"), "traces\n  processors: [hold]\n  exporters: [sink]\n"; !strings.Contains(got, want) {
+		t.Errorf("pipelinez = %q, want it to contain %q", got, want)
+	}
+	if got := get("/debug/extensionz"); got != "zpages\n" {
+		t.Errorf("extensionz = %q, want %q", got, "zpages\n")
+	}
+}
+
+func TestFactoryZPagesConcurrent(t *testing.T) {
+	mux := http.NewServeMux()
+	f, err := collex.NewFactory(new(sink).factory(), settings(), collex.WithExtensions(collex.Extension{Factory: zpages(mux)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Hold the extensions so they stay started while exporters come and go.
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	var started, wg sync.WaitGroup
+	for _, path := range []string{"/debug/pipelinez", "/debug/extensionz"} {
+		started.Add(1)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				if i == 1 {
+					started.Done()
+				}
+				select {
+				case <-done:
+					return
+				default:
+				}
+				mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+			}
+		}()
+	}
+	started.Wait()
+	for range 100 {
+		e, err := f.SpanExporter(ctx, nil)
+		if err != nil {
+			t.Error(err)
+			break
+		}
+		if err := e.Shutdown(ctx); err != nil {
+			t.Error(err)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	if err := exp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type healthCheck struct {
+	testComponent
+	l *lifecycle
+}
+
+func (h healthCheck) Ready() error {
+	h.l.events = append(h.l.events, "ready")
+	return nil
+}
+
+func (h healthCheck) NotReady() error {
+	h.l.events = append(h.l.events, "not ready")
+	return nil
+}
+
+// healthCheck returns the factory of an extension that, like the health_check
+// extension, watches if the pipelines are ready.
+func (l *lifecycle) healthCheck() extension.Factory {
+	return extension.NewFactory(
+		component.MustNewType("health_check"),
+		createEmptyConfig,
+		func(context.Context, extension.Settings, component.Config) (extension.Extension, error) {
+			return healthCheck{testComponent: l.component("health_check"), l: l}, nil
+		},
+		component.StabilityLevelDevelopment,
+	)
+}
+
+func TestPipelineHealthCheck(t *testing.T) {
+	var l lifecycle
+	ctx := context.Background()
+	p, err := collex.NewPipeline().
+		WithSettings(*settings()).
+		WithExtensions(
+			collex.Extension{Factory: l.extension()},
+			collex.Extension{Factory: l.healthCheck()},
+		).
+		WithExporter(l.exporter(), nil).
+		Build(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"start token", "start health_check", "start exporter", "ready",
+		"not ready", "shutdown exporter", "shutdown health_check", "shutdown token",
+	}
+	if !reflect.DeepEqual(l.events, want) {
+		t.Errorf("events = %v, want %v", l.events, want)
+	}
+}
+
+// readiness is a pipeline watching extension that records if it is notified
+// out of order.
+type readiness struct {
+	testComponent
+
+	mu         sync.Mutex
+	ready      bool
+	outOfOrder bool
+}
+
+func (r *readiness) Ready() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.outOfOrder = r.outOfOrder || r.ready
+	r.ready = true
+	return nil
+}
+
+func (r *readiness) NotReady() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.outOfOrder = r.outOfOrder || !r.ready
+	r.ready = false
+	return nil
+}
+
+func TestFactoryHealthCheckConcurrent(t *testing.T) {
+	r := new(readiness)
+	ext := extension.NewFactory(
+		component.MustNewType("health_check"),
+		createEmptyConfig,
+		func(context.Context, extension.Settings, component.Config) (extension.Extension, error) {
+			return r, nil
+		},
+		component.StabilityLevelDevelopment,
+	)
+	f, err := collex.NewFactory(new(sink).factory(), settings(), collex.WithExtensions(collex.Extension{Factory: ext}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				exp, err := f.SpanExporter(ctx, nil)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if err := exp.Shutdown(ctx); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if r.outOfOrder {
+		t.Error("Ready and NotReady called out of order")
+	}
+	if r.ready {
+		t.Error("extension ready after all exporters are shut down")
+	}
+}
+
+type customHost struct {
+	exts map[component.ID]component.Component
+}
+
+func (h customHost) GetExtensions() map[component.ID]component.Component {
+	return h.exts
+}
+
+func TestFactoryWithHost(t *testing.T) {
+	var l lifecycle
+	host := customHost{exts: map[component.ID]component.Component{
+		component.MustNewID("token"): testComponent{},
+	}}
+	f, err := collex.NewFactory(l.exporter(), settings(), collex.WithHost(host))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := exp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"start exporter", "shutdown exporter"}
+	if !reflect.DeepEqual(l.events, want) {
+		t.Errorf("events = %v, want %v", l.events, want)
+	}
+}
+
+func TestPipelineWithHostAndExtensions(t *testing.T) {
+	var l lifecycle
+	var got map[component.ID]component.Component
+	probe := extension.NewFactory(
+		component.MustNewType("probe"),
+		createEmptyConfig,
+		func(context.Context, extension.Settings, component.Config) (extension.Extension, error) {
+			return testComponent{StartFunc: func(_ context.Context, host component.Host) error {
+				got = host.GetExtensions()
+				return nil
+			}}, nil
+		},
+		component.StabilityLevelDevelopment,
+	)
+	host := customHost{exts: map[component.ID]component.Component{
+		component.MustNewID("user"): testComponent{},
+	}}
+
+	ctx := context.Background()
+	p, err := collex.NewPipeline().
+		WithSettings(*settings()).
+		WithHost(host).
+		WithExtensions(collex.Extension{Factory: l.extension()}, collex.Extension{Factory: probe}).
+		WithExporter(l.exporter(), nil).
+		Build(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"user", "token", "probe"} {
+		if _, ok := got[component.MustNewID(id)]; !ok {
+			t.Errorf("extension %s not found in the host", id)
+		}
+	}
+}
+
+func TestFactorySharedExtensions(t *testing.T) {
+	var l lifecycle
+	f, err := collex.NewFactory(l.exporter(), settings(), collex.WithExtensions(
+		collex.Extension{Factory: l.extension()},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	exp0, err := f.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp1, err := f.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := exp0.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := exp1.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"start token", "start exporter", "start exporter",
+		"shutdown exporter", "shutdown exporter", "shutdown token",
+	}
+	if !reflect.DeepEqual(l.events, want) {
+		t.Errorf("events = %v, want %v", l.events, want)
+	}
+}
+
+type dependent struct {
+	testComponent
+	deps []component.ID
+}
+
+func (d dependent) Dependencies() []component.ID { return d.deps }
+
+func TestPipelineExtensionDependencies(t *testing.T) {
+	var l lifecycle
+	client := extension.NewFactory(
+		component.MustNewType("client"),
+		createEmptyConfig,
+		func(context.Context, extension.Settings, component.Config) (extension.Extension, error) {
+			return dependent{
+				testComponent: l.component("client"),
+				deps:          []component.ID{component.MustNewID("token")},
+			}, nil
+		},
+		component.StabilityLevelDevelopment,
+	)
+
+	ctx := context.Background()
+	p, err := collex.NewPipeline().
+		WithSettings(*settings()).
+		WithExtensions(collex.Extension{Factory: client}, collex.Extension{Factory: l.extension()}).
+		WithExporter(l.exporter(), nil).
+		Build(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"start token", "start client", "start exporter",
+		"shutdown exporter", "shutdown client", "shutdown token",
+	}
+	if !reflect.DeepEqual(l.events, want) {
+		t.Errorf("events = %v, want %v", l.events, want)
+	}
+}
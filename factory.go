@@ -16,63 +16,277 @@ package collex
 
 import (
 	"context"
+	"errors"
+	"os"
+	"time"
 
 	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/configretry"
+	"go.opentelemetry.io/collector/consumer"
 	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/pipeline"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/trace"
+	api "go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
+var errNotSwappable = errors.New("collex: exporter not returned by a Factory")
+
 // Factory wraps an OpenTelemetry collector ExporterFactory and initializes new
 // OpenTelemetry Go exporters from it.
 type Factory struct {
-	createCfg   exporter.Settings
-	collFactory exporter.Factory
+	createCfg    exporter.Settings
+	collFactory  exporter.Factory
+	processors   []Processor
+	connectors   []Connector
+	exts         *extensionSet
+	temporality  metric.TemporalitySelector
+	timeout      time.Duration
+	restart      RestartConfig
+	metadata     map[string][]string
+	headers      map[string]string
+	overrides    map[pipeline.Signal]map[string]any
+	clock        Clock
+	tracing      api.TracerProvider
+	onExportErr  ExportErrorFunc
+	middleware   []Middleware
+	breaker      CircuitBreakerConfig
+	rateLimit    RateLimitConfig
+	deadLetter   DeadLetterConfig
+	retry        configretry.BackOffConfig
+	backpressure *BackpressureConfig
+	shed         *shedder
+	maxBytes     MaxBytesConfig
 }
 
 // NewFactory returns a new configured *Factory. If set is nil, a default
-// Settings will be used. These settings use a production ready Zap logger and
-// a global OpenTelemetry Go TracerProvider.
-func NewFactory(f exporter.Factory, set *exporter.Settings) (*Factory, error) {
+// Settings will be used. These settings use a production ready Zap logger, at
+// the level set with SetLogLevel, and a global OpenTelemetry Go
+// TracerProvider.
+func NewFactory(f exporter.Factory, set *exporter.Settings, opts ...Option) (*Factory, error) {
 	if set == nil {
-		logger, err := zap.NewProduction()
+		var err error
+		set, err = defaultSettings()
 		if err != nil {
 			return nil, err
 		}
+	}
+	c := newConfig(opts)
+	if err := setFeatureGates(c.gates); err != nil {
+		return nil, err
+	}
+	if c.logDedup > 0 {
+		s := *set
+		s.Logger = dedupLogger(s.Logger, c.logDedup, clockOrSystem(c.clock))
+		set = &s
+	}
+	mw := c.middleware
+	if c.expTimeout > 0 {
+		mw = append([]Middleware{exportTimeout(c.expTimeout)}, mw...)
+	}
+	if c.debugDump || os.Getenv(DebugDumpEnv) != "" {
+		mw = append(mw[:len(mw):len(mw)], debugDump(set.Logger, f.Type()))
+	}
+	return &Factory{
+		createCfg:    *set,
+		collFactory:  f,
+		processors:   c.processors,
+		connectors:   c.connectors,
+		exts:         newExtensionSet(*set, c.host, statusWithErrors(c.status, c.onErr), extNodes(c.extensions)),
+		temporality:  c.temporality,
+		timeout:      c.timeout,
+		restart:      c.restart,
+		metadata:     c.metadata,
+		headers:      c.headers,
+		overrides:    c.overrides,
+		clock:        clockOrSystem(c.clock),
+		tracing:      c.tracing,
+		onExportErr:  c.onExportErr,
+		middleware:   mw,
+		shed:         newShedder(c.shedding, *set, f.Type()),
+		breaker:      c.breaker,
+		rateLimit:    c.rateLimit,
+		deadLetter:   c.deadLetter,
+		retry:        c.retry,
+		backpressure: c.backpressure,
+		maxBytes:     c.maxBytes,
+	}, nil
+}
 
-		set = &exporter.Settings{
-			TelemetrySettings: component.TelemetrySettings{
-				Logger:         logger,
-				TracerProvider: otel.GetTracerProvider(),
-				MeterProvider:  otel.GetMeterProvider(),
-			},
-			BuildInfo: component.BuildInfo{
-				Command:     "collex",
-				Description: "OpenTelemetry Collector to OpenTelemetry Go translator",
-				Version:     "latest",
-			},
-		}
+func defaultSettings() (*exporter.Settings, error) {
+	cfg := zap.NewProductionConfig()
+	cfg.Level = logLevel
+	logger, err := cfg.Build()
+	if err != nil {
+		return nil, err
 	}
-	return &Factory{*set, f}, nil
+
+	return &exporter.Settings{
+		TelemetrySettings: component.TelemetrySettings{
+			Logger:         logger,
+			TracerProvider: otel.GetTracerProvider(),
+			MeterProvider:  otel.GetMeterProvider(),
+		},
+		BuildInfo: component.BuildInfo{
+			Command:     "collex",
+			Description: "OpenTelemetry Collector to OpenTelemetry Go translator",
+			Version:     "latest",
+		},
+	}, nil
 }
 
 // SpanExporter returns an OpenTelemetry Go SpanExporter that can be registered
 // with a TracerProvider. If cfg is nil the factory default configuration for
 // the ExporterFactory is used.
+//
+// Spans are passed through any processors the Factory was configured with
+// before being sent to the wrapped exporter and any connectors.
 func (f *Factory) SpanExporter(ctx context.Context, cfg component.Config) (trace.SpanExporter, error) {
-	if cfg == nil {
-		cfg = f.collFactory.CreateDefaultConfig()
+	sup, err := f.supervise(ctx, pipeline.SignalTraces, cfg)
+	if sup == nil {
+		return nil, err
 	}
-	collExp, err := f.collFactory.CreateTraces(ctx, f.createCfg, cfg)
-	if err != nil {
+	exp := &spanExporter{
+		next:     sup.consumer(pipeline.SignalTraces).(consumer.Traces),
+		sup:      sup,
+		metadata: f.metadata,
+		tracer:   f.exportTracer(pipeline.SignalTraces),
+		errs:     newExportErrors(f.onExportErr, pipeline.SignalTraces),
+		shed:     f.shed,
+		panics:   f.panicGuard(pipeline.SignalTraces),
+	}
+	exp.export = f.chain(exp.consume)
+	return exp, err
+}
+
+// MetricExporter returns an OpenTelemetry Go metric Exporter that can be
+// registered with a MeterProvider using a Reader. If cfg is nil the factory
+// default configuration for the ExporterFactory is used.
+//
+// Metrics are passed through any processors the Factory was configured with
+// before being sent to the wrapped exporter and any connectors.
+func (f *Factory) MetricExporter(ctx context.Context, cfg component.Config) (metric.Exporter, error) {
+	sup, err := f.supervise(ctx, pipeline.SignalMetrics, cfg)
+	if sup == nil {
+		return nil, err
+	}
+	exp := &metricExporter{
+		next:        sup.consumer(pipeline.SignalMetrics).(consumer.Metrics),
+		sup:         sup,
+		metadata:    f.metadata,
+		temporality: f.temporality,
+		tracer:      f.exportTracer(pipeline.SignalMetrics),
+		errs:        newExportErrors(f.onExportErr, pipeline.SignalMetrics),
+		shed:        f.shed,
+		panics:      f.panicGuard(pipeline.SignalMetrics),
+	}
+	exp.export = f.chain(exp.consume)
+	return exp, err
+}
+
+// LogExporter returns an OpenTelemetry Go log Exporter that can be registered
+// with a LoggerProvider using a Processor. If cfg is nil the factory default
+// configuration for the ExporterFactory is used.
+//
+// Log records are passed through any processors the Factory was configured
+// with before being sent to the wrapped exporter and any connectors.
+func (f *Factory) LogExporter(ctx context.Context, cfg component.Config) (log.Exporter, error) {
+	sup, err := f.supervise(ctx, pipeline.SignalLogs, cfg)
+	if sup == nil {
 		return nil, err
 	}
-	return &spanExporter{cexp: collExp}, collExp.Start(ctx, emptyHost{})
+	exp := &logExporter{
+		next:     sup.consumer(pipeline.SignalLogs).(consumer.Logs),
+		sup:      sup,
+		metadata: f.metadata,
+		tracer:   f.exportTracer(pipeline.SignalLogs),
+		errs:     newExportErrors(f.onExportErr, pipeline.SignalLogs),
+		shed:     f.shed,
+		panics:   f.panicGuard(pipeline.SignalLogs),
+	}
+	exp.export = f.chain(exp.consume)
+	return exp, err
 }
 
-type emptyHost struct{}
+// Swap replaces the wrapped exporter of exp, an exporter returned by a
+// Factory, with one configured with cfg. If cfg is nil the factory default
+// configuration for the ExporterFactory is used. The exp handle stays valid, it
+// does not need to be registered again.
+//
+// All the components of exp are recreated and started before exports are
+// switched to them. The previous components are then shut down once the
+// exports in progress are done, draining their sending queues, so no
+// telemetry is lost. If the new components fail to start, an error is
+// returned and exp keeps using the previous ones.
+//
+// This allows credentials to be rotated, or an endpoint migrated, without
+// restarting the application.
+func Swap(ctx context.Context, exp any, cfg component.Config) error {
+	sup := supervisorOf(exp)
+	if sup == nil {
+		return errNotSwappable
+	}
+	return sup.swap(ctx, cfg)
+}
 
-func (emptyHost) GetExtensions() map[component.ID]component.Component {
+// supervisorOf returns the supervisor of exp, or nil if exp was not returned
+// by a Factory.
+func supervisorOf(exp any) *supervisor {
+	switch e := exp.(type) {
+	case *spanExporter:
+		return e.sup
+	case *metricExporter:
+		return e.sup
+	case *logExporter:
+		return e.sup
+	}
 	return nil
 }
+
+// exportTracer returns the exportTracer of the exporters of signal of f.
+func (f *Factory) exportTracer(signal pipeline.Signal) *exportTracer {
+	return newExportTracer(f.tracing, component.NewID(f.collFactory.Type()), signal)
+}
+
+// panicGuard returns the panicGuard of the exporters of signal of f.
+func (f *Factory) panicGuard(signal pipeline.Signal) *panicGuard {
+	id := component.NewID(f.collFactory.Type())
+	m := newSelfMetrics(f.createCfg.MeterProvider, f.createCfg.Logger)
+	return newPanicGuard(f.createCfg.Logger, m, signal, attribute.String("exporter", id.String()))
+}
+
+// supervise returns the supervisor of the components of an exporter of signal
+// that sends to the wrapped exporter configured with cfg. If the supervisor is
+// nil, the components could not be built. Otherwise, any error returned is
+// from starting the components.
+func (f *Factory) supervise(ctx context.Context, signal pipeline.Signal, cfg component.Config) (*supervisor, error) {
+	build := func(ctx context.Context, cfg component.Config) (*graph, any, error) {
+		exp := Exporter{Factory: f.collFactory, Config: cfg, Overrides: f.overrides, CircuitBreaker: f.breaker, RateLimit: f.rateLimit, DeadLetter: f.deadLetter, Retry: f.retry, Backpressure: f.backpressure, MaxBytes: f.maxBytes}
+		exps := []Exporter{exp.withHeaders(f.headers)}
+		root := newPipeNode(pipeline.NewID(signal), f.processors, exps, f.connectors)
+		g, heads, err := newGraph(ctx, f.createCfg, f.exts, []*pipeNode{root})
+		if err != nil {
+			return nil, nil, err
+		}
+		g.timeout, g.clock = f.timeout, f.clock
+		return g, heads[signal], nil
+	}
+	sup, err := newSupervisor(ctx, f.createCfg.Logger, f.restart, f.clock, f.collFactory.CreateDefaultConfig, cfg, build)
+	if sup != nil && f.restart.Enabled {
+		// Exports buffered while the wrapped exporter is recreated are
+		// reported as queued.
+		stop, mErr := observeBuffer(f.createCfg.MeterProvider, component.NewID(f.collFactory.Type()), signal, sup)
+		if mErr != nil {
+			f.createCfg.Logger.Warn("failed to record collex queue metrics", zap.Error(mErr))
+		} else {
+			sup.mu.Lock()
+			sup.stopMetrics = stop
+			sup.mu.Unlock()
+		}
+	}
+	return sup, err
+}
@@ -0,0 +1,233 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/MrAlias/collex"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/connector"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pipeline"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type testComponent struct {
+	component.StartFunc
+	component.ShutdownFunc
+}
+
+type tracesComponent struct {
+	testComponent
+	consumer.Traces
+}
+
+type metricsComponent struct {
+	testComponent
+	consumer.Metrics
+}
+
+type logsComponent struct {
+	testComponent
+	consumer.Logs
+}
+
+type emptyConfig struct{}
+
+func createEmptyConfig() component.Config { return &emptyConfig{} }
+
+var errSinkStopped = errors.New("sink: shut down")
+
+// sink is a collector exporter that records the telemetry it receives.
+type sink struct {
+	stopped bool
+	traces  []ptrace.Traces
+	metrics []pmetric.Metrics
+	logs    []plog.Logs
+}
+
+func (s *sink) factory() exporter.Factory {
+	return exporter.NewFactory(
+		component.MustNewType("sink"),
+		createEmptyConfig,
+		exporter.WithTraces(func(context.Context, exporter.Settings, component.Config) (exporter.Traces, error) {
+			c, err := consumer.NewTraces(func(_ context.Context, td ptrace.Traces) error {
+				if s.stopped {
+					return errSinkStopped
+				}
+				s.traces = append(s.traces, td)
+				return nil
+			})
+			stop := testComponent{ShutdownFunc: func(context.Context) error {
+				s.stopped = true
+				return nil
+			}}
+			return tracesComponent{testComponent: stop, Traces: c}, err
+		}, component.StabilityLevelDevelopment),
+		exporter.WithMetrics(func(context.Context, exporter.Settings, component.Config) (exporter.Metrics, error) {
+			c, err := consumer.NewMetrics(func(_ context.Context, md pmetric.Metrics) error {
+				s.metrics = append(s.metrics, md)
+				return nil
+			})
+			return metricsComponent{Metrics: c}, err
+		}, component.StabilityLevelDevelopment),
+		exporter.WithLogs(func(context.Context, exporter.Settings, component.Config) (exporter.Logs, error) {
+			c, err := consumer.NewLogs(func(_ context.Context, ld plog.Logs) error {
+				s.logs = append(s.logs, ld)
+				return nil
+			})
+			return logsComponent{Logs: c}, err
+		}, component.StabilityLevelDevelopment),
+	)
+}
+
+// spanCount is a collector connector that emits the number of spans it
+// receives as a metric.
+func spanCount() connector.Factory {
+	return connector.NewFactory(
+		component.MustNewType("spancount"),
+		createEmptyConfig,
+		connector.WithTracesToMetrics(func(_ context.Context, _ connector.Settings, _ component.Config, next consumer.Metrics) (connector.Traces, error) {
+			c, err := consumer.NewTraces(func(ctx context.Context, td ptrace.Traces) error {
+				md := pmetric.NewMetrics()
+				m := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+				m.SetName("span.count")
+				m.SetEmptySum().DataPoints().AppendEmpty().SetIntValue(int64(td.SpanCount()))
+				return next.ConsumeMetrics(ctx, md)
+			})
+			return tracesComponent{Traces: c}, err
+		}, component.StabilityLevelDevelopment),
+	)
+}
+
+func settings() *exporter.Settings {
+	return &exporter.Settings{
+		TelemetrySettings: componenttest.NewNopTelemetrySettings(),
+	}
+}
+
+func TestFactoryConnector(t *testing.T) {
+	var traces, metrics sink
+	f, err := collex.NewFactory(traces.factory(), settings(), collex.WithConnectors(collex.Connector{
+		Factory: spanCount(),
+		Pipelines: map[pipeline.ID][]collex.Exporter{
+			pipeline.NewID(pipeline.SignalMetrics): {{Factory: metrics.factory()}},
+		},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := resource.Empty()
+	spans := tracetest.SpanStubs{
+		{Name: "a", Resource: res},
+		{Name: "b", Resource: res},
+	}.Snapshots()
+	if err := exp.ExportSpans(ctx, spans); err != nil {
+		t.Fatal(err)
+	}
+	if err := exp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(traces.traces) != 1 || traces.traces[0].SpanCount() != 2 {
+		t.Errorf("wrapped exporter did not receive the exported spans: %v", traces.traces)
+	}
+	if len(metrics.metrics) != 1 {
+		t.Fatalf("connector exporter received %d exports, want 1", len(metrics.metrics))
+	}
+	got := metrics.metrics[0].ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0)
+	if v := got.Sum().DataPoints().At(0).IntValue(); v != 2 {
+		t.Errorf("span.count = %d, want 2", v)
+	}
+}
+
+// routeByName is a collector connector that routes spans to the traces
+// pipeline named after the span.
+func routeByName() connector.Factory {
+	return connector.NewFactory(
+		component.MustNewType("routebyname"),
+		createEmptyConfig,
+		connector.WithTracesToTraces(func(_ context.Context, _ connector.Settings, _ component.Config, next consumer.Traces) (connector.Traces, error) {
+			router, ok := next.(connector.TracesRouterAndConsumer)
+			if !ok {
+				return nil, errors.New("next consumer is not a router")
+			}
+			c, err := consumer.NewTraces(func(ctx context.Context, td ptrace.Traces) error {
+				name := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Name()
+				dest, err := router.Consumer(pipeline.NewIDWithName(pipeline.SignalTraces, name))
+				if err != nil {
+					return err
+				}
+				return dest.ConsumeTraces(ctx, td)
+			})
+			return tracesComponent{Traces: c}, err
+		}, component.StabilityLevelDevelopment),
+	)
+}
+
+func TestFactoryRoutingConnector(t *testing.T) {
+	var wrapped, a, b sink
+	f, err := collex.NewFactory(wrapped.factory(), settings(), collex.WithConnectors(collex.Connector{
+		Factory: routeByName(),
+		Pipelines: map[pipeline.ID][]collex.Exporter{
+			pipeline.NewIDWithName(pipeline.SignalTraces, "a"): {{Factory: a.factory()}},
+			pipeline.NewIDWithName(pipeline.SignalTraces, "b"): {{Factory: b.factory()}},
+		},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := resource.Empty()
+	for _, name := range []string{"a", "b", "b"} {
+		spans := tracetest.SpanStubs{{Name: name, Resource: res}}.Snapshots()
+		if err := exp.ExportSpans(ctx, spans); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := exp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := len(wrapped.traces); got != 3 {
+		t.Errorf("wrapped exporter received %d exports, want 3", got)
+	}
+	if got := len(a.traces); got != 1 {
+		t.Errorf("pipeline traces/a received %d exports, want 1", got)
+	}
+	if got := len(b.traces); got != 2 {
+		t.Errorf("pipeline traces/b received %d exports, want 2", got)
+	}
+}
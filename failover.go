@@ -0,0 +1,178 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.uber.org/zap"
+)
+
+// FailoverPolicy configures when a failover exporter routes exports to its
+// secondary exporter.
+//
+// The primary exporter is unhealthy after Failures consecutive failed
+// exports. Exports are then sent to the secondary exporter until
+// RetryInterval has passed, after which a single export probes the primary
+// again, failing back to it if it succeeds. An export the primary fails is
+// sent to the secondary as well, so it is not lost.
+//
+// Permanent errors, e.g. telemetry the backend rejected as invalid, show the
+// primary is up and do not make it unhealthy.
+type FailoverPolicy struct {
+	// Failures is the number of consecutive failed exports after which the
+	// primary is unhealthy. If it is not positive, 1 is used.
+	Failures int
+	// RetryInterval is the time the primary is not exported to once it is
+	// unhealthy.
+	RetryInterval time.Duration
+	// Clock, if not nil, is the Clock RetryInterval is measured with
+	// instead of the system clock.
+	Clock Clock
+}
+
+// NewFailoverPolicy returns a FailoverPolicy that fails over after 3
+// consecutive failed exports and retries the primary every 30 seconds.
+func NewFailoverPolicy() FailoverPolicy {
+	return FailoverPolicy{Failures: 3, RetryInterval: 30 * time.Second}
+}
+
+// breaker returns the breaker tracking the health of the primary exporter.
+func (p FailoverPolicy) breaker() *breaker {
+	cfg := CircuitBreakerConfig{Failures: max(p.Failures, 1), OpenDuration: p.RetryInterval}
+	clock := clockOrSystem(p.Clock)
+	return newBreaker(cfg, zap.NewNop(), component.MustNewID("failover"), clock.Now)
+}
+
+// failover exports with primary, unless b reports it unhealthy, and with
+// secondary if it is or if the export with primary fails.
+func failover(b *breaker, primary, secondary func() error) error {
+	ok, probe := b.allow()
+	if !ok {
+		return secondary()
+	}
+	err := primary()
+	b.done(probe, err)
+	if err == nil {
+		return nil
+	}
+	if sErr := secondary(); sErr != nil {
+		return errors.Join(err, sErr)
+	}
+	return nil
+}
+
+// NewFailoverSpanExporter returns a SpanExporter that exports with primary
+// while it is healthy and with secondary otherwise, failing back to primary
+// automatically, like the failover connector of the collector. The
+// exporters are typically returned by two Factories wrapping the exporters of
+// different backends.
+//
+// Shutting down the returned exporter shuts down both exporters.
+func NewFailoverSpanExporter(primary, secondary trace.SpanExporter, policy FailoverPolicy) trace.SpanExporter {
+	return &failoverSpanExporter{primary: primary, secondary: secondary, health: policy.breaker()}
+}
+
+type failoverSpanExporter struct {
+	primary, secondary trace.SpanExporter
+	health             *breaker
+}
+
+func (e *failoverSpanExporter) ExportSpans(ctx context.Context, spans []trace.ReadOnlySpan) error {
+	return failover(
+		e.health,
+		func() error { return e.primary.ExportSpans(ctx, spans) },
+		func() error { return e.secondary.ExportSpans(ctx, spans) },
+	)
+}
+
+func (e *failoverSpanExporter) Shutdown(ctx context.Context) error {
+	return errors.Join(e.primary.Shutdown(ctx), e.secondary.Shutdown(ctx))
+}
+
+// NewFailoverMetricExporter returns a metric Exporter that exports with
+// primary while it is healthy and with secondary otherwise, failing back to
+// primary automatically. The temporality and aggregation of primary are used.
+//
+// Shutting down the returned exporter shuts down both exporters.
+func NewFailoverMetricExporter(primary, secondary metric.Exporter, policy FailoverPolicy) metric.Exporter {
+	return &failoverMetricExporter{primary: primary, secondary: secondary, health: policy.breaker()}
+}
+
+type failoverMetricExporter struct {
+	primary, secondary metric.Exporter
+	health             *breaker
+}
+
+func (e *failoverMetricExporter) Temporality(k metric.InstrumentKind) metricdata.Temporality {
+	return e.primary.Temporality(k)
+}
+
+func (e *failoverMetricExporter) Aggregation(k metric.InstrumentKind) metric.Aggregation {
+	return e.primary.Aggregation(k)
+}
+
+func (e *failoverMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	return failover(
+		e.health,
+		func() error { return e.primary.Export(ctx, rm) },
+		func() error { return e.secondary.Export(ctx, rm) },
+	)
+}
+
+func (e *failoverMetricExporter) ForceFlush(ctx context.Context) error {
+	return errors.Join(e.primary.ForceFlush(ctx), e.secondary.ForceFlush(ctx))
+}
+
+func (e *failoverMetricExporter) Shutdown(ctx context.Context) error {
+	return errors.Join(e.primary.Shutdown(ctx), e.secondary.Shutdown(ctx))
+}
+
+// NewFailoverLogExporter returns a log Exporter that exports with primary
+// while it is healthy and with secondary otherwise, failing back to primary
+// automatically.
+//
+// Shutting down the returned exporter shuts down both exporters.
+func NewFailoverLogExporter(primary, secondary log.Exporter, policy FailoverPolicy) log.Exporter {
+	return &failoverLogExporter{primary: primary, secondary: secondary, health: policy.breaker()}
+}
+
+type failoverLogExporter struct {
+	primary, secondary log.Exporter
+	health             *breaker
+}
+
+func (e *failoverLogExporter) Export(ctx context.Context, records []log.Record) error {
+	return failover(
+		e.health,
+		func() error { return e.primary.Export(ctx, records) },
+		func() error { return e.secondary.Export(ctx, records) },
+	)
+}
+
+func (e *failoverLogExporter) ForceFlush(ctx context.Context) error {
+	return errors.Join(e.primary.ForceFlush(ctx), e.secondary.ForceFlush(ctx))
+}
+
+func (e *failoverLogExporter) Shutdown(ctx context.Context) error {
+	return errors.Join(e.primary.Shutdown(ctx), e.secondary.Shutdown(ctx))
+}
@@ -0,0 +1,106 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/MrAlias/collex"
+	"github.com/MrAlias/collex/collextest"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// backendExporter returns a SpanExporter of a Factory wrapping b.
+func backendExporter(t *testing.T, b *backend) trace.SpanExporter {
+	t.Helper()
+	f, err := collex.NewFactory(b.factory(), settings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp, err := f.SpanExporter(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return exp
+}
+
+func TestFailoverSpanExporter(t *testing.T) {
+	primary, secondary := &backend{err: errBroken}, &backend{}
+	clock := collextest.NewFakeClock(time.Now())
+	policy := collex.FailoverPolicy{Failures: 2, RetryInterval: time.Minute, Clock: clock}
+	exp := collex.NewFailoverSpanExporter(backendExporter(t, primary), backendExporter(t, secondary), policy)
+	ctx := context.Background()
+	defer func() { _ = exp.Shutdown(ctx) }()
+
+	export := func(pCalls, sCalls int) {
+		t.Helper()
+		if err := exp.ExportSpans(ctx, spans(1)); err != nil {
+			t.Errorf("export error: %v", err)
+		}
+		if primary.calls != pCalls || secondary.calls != sCalls {
+			t.Errorf("exported %d times to the primary and %d to the secondary, want %d and %d", primary.calls, secondary.calls, pCalls, sCalls)
+		}
+	}
+
+	// The exports the primary fails are sent to the secondary.
+	export(1, 1)
+	export(2, 2)
+	// The primary is unhealthy.
+	export(2, 3)
+
+	// Fail back once the primary is healthy.
+	primary.err = nil
+	clock.Advance(time.Minute)
+	export(3, 3)
+	export(4, 3)
+}
+
+func TestFailoverSpanExporterBothFail(t *testing.T) {
+	errDown := errors.New("secondary down")
+	primary, secondary := &backend{err: errBroken}, &backend{err: errDown}
+	exp := collex.NewFailoverSpanExporter(backendExporter(t, primary), backendExporter(t, secondary), collex.NewFailoverPolicy())
+	ctx := context.Background()
+	defer func() { _ = exp.Shutdown(ctx) }()
+
+	err := exp.ExportSpans(ctx, spans(1))
+	if !errors.Is(err, errBroken) || !errors.Is(err, errDown) {
+		t.Errorf("export error = %v, want both errors", err)
+	}
+}
+
+func TestFailoverSpanExporterShutdown(t *testing.T) {
+	var primary, secondary sink
+	newExp := func(s *sink) trace.SpanExporter {
+		f, err := collex.NewFactory(s.factory(), settings())
+		if err != nil {
+			t.Fatal(err)
+		}
+		exp, err := f.SpanExporter(context.Background(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return exp
+	}
+	exp := collex.NewFailoverSpanExporter(newExp(&primary), newExp(&secondary), collex.NewFailoverPolicy())
+	if err := exp.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !primary.stopped || !secondary.stopped {
+		t.Errorf("primary stopped %t, secondary stopped %t, want both", primary.stopped, secondary.stopped)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// fanoutTraces returns a consumer that sends traces to all consumers in cs.
+// Consumers that mutate data are passed a copy so they do not modify the data
+// seen by the other consumers.
+func fanoutTraces(cs []consumer.Traces) consumer.Traces {
+	if len(cs) == 1 {
+		return cs[0]
+	}
+	c, _ := consumer.NewTraces(func(ctx context.Context, td ptrace.Traces) error {
+		var err error
+		for _, c := range cs {
+			data := td
+			if c.Capabilities().MutatesData {
+				data = ptrace.NewTraces()
+				td.CopyTo(data)
+			}
+			err = errors.Join(err, c.ConsumeTraces(ctx, data))
+		}
+		return err
+	})
+	return c
+}
+
+// fanoutMetrics returns a consumer that sends metrics to all consumers in cs.
+// Consumers that mutate data are passed a copy so they do not modify the data
+// seen by the other consumers.
+func fanoutMetrics(cs []consumer.Metrics) consumer.Metrics {
+	if len(cs) == 1 {
+		return cs[0]
+	}
+	c, _ := consumer.NewMetrics(func(ctx context.Context, md pmetric.Metrics) error {
+		var err error
+		for _, c := range cs {
+			data := md
+			if c.Capabilities().MutatesData {
+				data = pmetric.NewMetrics()
+				md.CopyTo(data)
+			}
+			err = errors.Join(err, c.ConsumeMetrics(ctx, data))
+		}
+		return err
+	})
+	return c
+}
+
+// fanoutLogs returns a consumer that sends logs to all consumers in cs.
+// Consumers that mutate data are passed a copy so they do not modify the data
+// seen by the other consumers.
+func fanoutLogs(cs []consumer.Logs) consumer.Logs {
+	if len(cs) == 1 {
+		return cs[0]
+	}
+	c, _ := consumer.NewLogs(func(ctx context.Context, ld plog.Logs) error {
+		var err error
+		for _, c := range cs {
+			data := ld
+			if c.Capabilities().MutatesData {
+				data = plog.NewLogs()
+				ld.CopyTo(data)
+			}
+			err = errors.Join(err, c.ConsumeLogs(ctx, data))
+		}
+		return err
+	})
+	return c
+}
@@ -0,0 +1,162 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// FanoutMode is when the export of a fan-out exporter fails.
+type FanoutMode int
+
+const (
+	// FanoutAll fails the export if any exporter fails it, all exporters
+	// need to succeed.
+	FanoutAll FanoutMode = iota
+	// FanoutBestEffort fails the export only if all exporters fail it. This
+	// suits dual-writing to a new backend during a migration, where failures
+	// of the new backend are not to fail the exports.
+	FanoutBestEffort
+)
+
+// fanout calls export for each of the n exporters of a fan-out exporter
+// concurrently and returns the error of the export for mode.
+func fanout(mode FanoutMode, n int, export func(i int) error) error {
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = export(i)
+		}()
+	}
+	wg.Wait()
+
+	if mode == FanoutBestEffort {
+		for _, err := range errs {
+			if err == nil {
+				return nil
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// callAll calls fn for each of the n exporters of a fan-out exporter and
+// returns their errors.
+func callAll(n int, fn func(i int) error) error {
+	var err error
+	for i := range n {
+		err = errors.Join(err, fn(i))
+	}
+	return err
+}
+
+// NewFanoutSpanExporter returns a SpanExporter sending each export to all the
+// exporters concurrently, e.g. to dual-write to two backends during a
+// migration. The exporters are typically returned by Factories wrapping the
+// exporters of different backends. The export fails according to mode.
+//
+// Shutting down the returned exporter shuts down all the exporters.
+func NewFanoutSpanExporter(mode FanoutMode, exporters ...trace.SpanExporter) trace.SpanExporter {
+	return &fanoutSpanExporter{mode: mode, exps: exporters}
+}
+
+type fanoutSpanExporter struct {
+	mode FanoutMode
+	exps []trace.SpanExporter
+}
+
+func (e *fanoutSpanExporter) ExportSpans(ctx context.Context, spans []trace.ReadOnlySpan) error {
+	return fanout(e.mode, len(e.exps), func(i int) error { return e.exps[i].ExportSpans(ctx, spans) })
+}
+
+func (e *fanoutSpanExporter) Shutdown(ctx context.Context) error {
+	return callAll(len(e.exps), func(i int) error { return e.exps[i].Shutdown(ctx) })
+}
+
+// NewFanoutMetricExporter returns a metric Exporter sending each export to
+// all the exporters concurrently. The export fails according to mode. The
+// temporality and aggregation of the first exporter are used, the exporters
+// need to agree on them.
+//
+// Shutting down the returned exporter shuts down all the exporters.
+func NewFanoutMetricExporter(mode FanoutMode, exporters ...metric.Exporter) metric.Exporter {
+	return &fanoutMetricExporter{mode: mode, exps: exporters}
+}
+
+type fanoutMetricExporter struct {
+	mode FanoutMode
+	exps []metric.Exporter
+}
+
+func (e *fanoutMetricExporter) Temporality(k metric.InstrumentKind) metricdata.Temporality {
+	if len(e.exps) == 0 {
+		return metric.DefaultTemporalitySelector(k)
+	}
+	return e.exps[0].Temporality(k)
+}
+
+func (e *fanoutMetricExporter) Aggregation(k metric.InstrumentKind) metric.Aggregation {
+	if len(e.exps) == 0 {
+		return metric.DefaultAggregationSelector(k)
+	}
+	return e.exps[0].Aggregation(k)
+}
+
+func (e *fanoutMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	return fanout(e.mode, len(e.exps), func(i int) error { return e.exps[i].Export(ctx, rm) })
+}
+
+func (e *fanoutMetricExporter) ForceFlush(ctx context.Context) error {
+	return callAll(len(e.exps), func(i int) error { return e.exps[i].ForceFlush(ctx) })
+}
+
+func (e *fanoutMetricExporter) Shutdown(ctx context.Context) error {
+	return callAll(len(e.exps), func(i int) error { return e.exps[i].Shutdown(ctx) })
+}
+
+// NewFanoutLogExporter returns a log Exporter sending each export to all the
+// exporters concurrently. The export fails according to mode.
+//
+// Shutting down the returned exporter shuts down all the exporters.
+func NewFanoutLogExporter(mode FanoutMode, exporters ...log.Exporter) log.Exporter {
+	return &fanoutLogExporter{mode: mode, exps: exporters}
+}
+
+type fanoutLogExporter struct {
+	mode FanoutMode
+	exps []log.Exporter
+}
+
+func (e *fanoutLogExporter) Export(ctx context.Context, records []log.Record) error {
+	return fanout(e.mode, len(e.exps), func(i int) error { return e.exps[i].Export(ctx, records) })
+}
+
+func (e *fanoutLogExporter) ForceFlush(ctx context.Context) error {
+	return callAll(len(e.exps), func(i int) error { return e.exps[i].ForceFlush(ctx) })
+}
+
+func (e *fanoutLogExporter) Shutdown(ctx context.Context) error {
+	return callAll(len(e.exps), func(i int) error { return e.exps[i].Shutdown(ctx) })
+}
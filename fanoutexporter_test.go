@@ -0,0 +1,60 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/MrAlias/collex"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestFanoutSpanExporter(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		name    string
+		mode    collex.FanoutMode
+		errs    []error
+		wantErr bool
+	}{
+		{name: "AllSucceed", mode: collex.FanoutAll, errs: []error{nil, nil}},
+		{name: "AllOneFails", mode: collex.FanoutAll, errs: []error{nil, errBroken}, wantErr: true},
+		{name: "BestEffortOneFails", mode: collex.FanoutBestEffort, errs: []error{errBroken, nil}},
+		{name: "BestEffortAllFail", mode: collex.FanoutBestEffort, errs: []error{errBroken, errBroken}, wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			backends := make([]*backend, len(tc.errs))
+			var exps []trace.SpanExporter
+			for i, err := range tc.errs {
+				backends[i] = &backend{err: err}
+				exps = append(exps, backendExporter(t, backends[i]))
+			}
+			exp := collex.NewFanoutSpanExporter(tc.mode, exps...)
+			defer func() { _ = exp.Shutdown(ctx) }()
+
+			err := exp.ExportSpans(ctx, spans(1))
+			if tc.wantErr != errors.Is(err, errBroken) {
+				t.Errorf("ExportSpans error = %v, want error %t", err, tc.wantErr)
+			}
+			for i, b := range backends {
+				if b.calls != 1 {
+					t.Errorf("exported %d times to exporter %d, want 1", b.calls, i)
+				}
+			}
+		})
+	}
+}
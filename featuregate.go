@@ -0,0 +1,46 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/collector/featuregate"
+)
+
+// setFeatureGates enables or disables the collector feature gates of the
+// global registry. Each gate is in the format of the collector
+// --feature-gates flag: its ID, or its ID prefixed with "+" to enable it and
+// "-" to disable it.
+func setFeatureGates(gates []string) error {
+	reg := featuregate.GlobalRegistry()
+	var err error
+	for _, g := range gates {
+		id, enabled := g, true
+		if len(id) > 0 {
+			switch id[0] {
+			case '-':
+				id, enabled = id[1:], false
+			case '+':
+				id = id[1:]
+			}
+		}
+		if e := reg.Set(id, enabled); e != nil {
+			err = errors.Join(err, fmt.Errorf("feature gate %q: %w", g, e))
+		}
+	}
+	return err
+}
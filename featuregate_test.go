@@ -0,0 +1,53 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/MrAlias/collex"
+	"go.opentelemetry.io/collector/featuregate"
+)
+
+var testGate = featuregate.GlobalRegistry().MustRegister(
+	"collex.test",
+	featuregate.StageAlpha,
+	featuregate.WithRegisterDescription("Gate used to test collex."),
+)
+
+func TestFactoryFeatureGates(t *testing.T) {
+	t.Cleanup(func() { _ = featuregate.GlobalRegistry().Set(testGate.ID(), false) })
+
+	for _, gate := range []string{"collex.test", "-collex.test", "+collex.test"} {
+		if _, err := collex.NewFactory(new(sink).factory(), settings(), collex.WithFeatureGates(gate)); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := testGate.IsEnabled(), gate[0] != '-'; got != want {
+			t.Errorf("%s: enabled = %t, want %t", gate, got, want)
+		}
+	}
+}
+
+func TestPipelineUnknownFeatureGate(t *testing.T) {
+	_, err := collex.NewPipeline().
+		WithSettings(*settings()).
+		WithFeatureGates("-collex.unknown").
+		WithExporter(new(sink).factory(), nil).
+		Build(context.Background())
+	if err == nil {
+		t.Error("expected an error setting an unknown feature gate")
+	}
+}
@@ -0,0 +1,830 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componentstatus"
+	"go.opentelemetry.io/collector/confmap/xconfmap"
+	"go.opentelemetry.io/collector/connector"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/pipeline"
+	"go.opentelemetry.io/collector/processor"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// pipeNode is a pipeline of the graph. Telemetry passes through its
+// processors, in order, and is then sent to all of its exporters and
+// connectors.
+type pipeNode struct {
+	id    pipeline.ID
+	procs []procNode
+	exps  []*expNode
+	conns []*connNode
+}
+
+// supported returns whether all the processors, exporters, and connectors of
+// p support the signal of p.
+func (p *pipeNode) supported() bool {
+	s := p.id.Signal()
+	for _, n := range p.procs {
+		if processorStability(n.Factory, s) == component.StabilityLevelUndefined {
+			return false
+		}
+	}
+	for _, n := range p.exps {
+		if exporterStability(n.Factory, s) == component.StabilityLevelUndefined {
+			return false
+		}
+	}
+	for _, n := range p.conns {
+		for _, out := range n.pipes {
+			if connectorStability(n.factory, s, out.id.Signal()) == component.StabilityLevelUndefined {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func processorStability(f processor.Factory, s pipeline.Signal) component.StabilityLevel {
+	switch s {
+	case pipeline.SignalTraces:
+		return f.TracesStability()
+	case pipeline.SignalMetrics:
+		return f.MetricsStability()
+	case pipeline.SignalLogs:
+		return f.LogsStability()
+	}
+	return component.StabilityLevelUndefined
+}
+
+func exporterStability(f exporter.Factory, s pipeline.Signal) component.StabilityLevel {
+	switch s {
+	case pipeline.SignalTraces:
+		return f.TracesStability()
+	case pipeline.SignalMetrics:
+		return f.MetricsStability()
+	case pipeline.SignalLogs:
+		return f.LogsStability()
+	}
+	return component.StabilityLevelUndefined
+}
+
+func connectorStability(f connector.Factory, in, out pipeline.Signal) component.StabilityLevel {
+	type pair struct{ in, out pipeline.Signal }
+	switch (pair{in, out}) {
+	case pair{pipeline.SignalTraces, pipeline.SignalTraces}:
+		return f.TracesToTracesStability()
+	case pair{pipeline.SignalTraces, pipeline.SignalMetrics}:
+		return f.TracesToMetricsStability()
+	case pair{pipeline.SignalTraces, pipeline.SignalLogs}:
+		return f.TracesToLogsStability()
+	case pair{pipeline.SignalMetrics, pipeline.SignalTraces}:
+		return f.MetricsToTracesStability()
+	case pair{pipeline.SignalMetrics, pipeline.SignalMetrics}:
+		return f.MetricsToMetricsStability()
+	case pair{pipeline.SignalMetrics, pipeline.SignalLogs}:
+		return f.MetricsToLogsStability()
+	case pair{pipeline.SignalLogs, pipeline.SignalTraces}:
+		return f.LogsToTracesStability()
+	case pair{pipeline.SignalLogs, pipeline.SignalMetrics}:
+		return f.LogsToMetricsStability()
+	case pair{pipeline.SignalLogs, pipeline.SignalLogs}:
+		return f.LogsToLogsStability()
+	}
+	return component.StabilityLevelUndefined
+}
+
+type procNode struct {
+	id component.ID
+	Processor
+}
+
+// expNode is an exporter of the graph. Pipelines of the same signal that
+// share an expNode send telemetry to the same exporter instance.
+type expNode struct {
+	id component.ID
+	Exporter
+}
+
+// connNode is a connector of the graph. It receives telemetry from the
+// pipelines it is an exporter of and emits telemetry to pipes.
+type connNode struct {
+	id      component.ID
+	factory connector.Factory
+	config  component.Config
+	pipes   []*pipeNode
+}
+
+// instance is a component of the graph along with the ID it reports its
+// status with.
+type instance struct {
+	id *componentstatus.InstanceID
+	component.Component
+	// obsreport is true if the component records the metrics of the
+	// exporterhelper of the collector itself.
+	obsreport bool
+	// queue observes the sending queue of an exporter, inFlight counts the
+	// exports being sent to it.
+	queue    *queueObserver
+	inFlight atomic.Int64
+	// exportErr is the error of the last export to an exporter, nil if it
+	// succeeded.
+	exportErr atomic.Pointer[error]
+	// breaker is the circuit breaker of the exports to an exporter, shared
+	// by all the pipelines exporting to it.
+	breaker *breaker
+	// limiter is the rate limiter of the exports to an exporter, shared by
+	// all the pipelines exporting to it.
+	limiter *limiter
+	// deadLetter is the dead-letter writer of the exports to an exporter,
+	// shared by all the pipelines exporting to it so they rotate the same
+	// files.
+	deadLetter *deadLetter
+}
+
+type expKey struct {
+	node   *expNode
+	signal pipeline.Signal
+}
+
+type connKey struct {
+	node    *connNode
+	in, out pipeline.Signal
+}
+
+// graph is a set of connected collector components.
+type graph struct {
+	set exporter.Settings
+
+	// timeout bounds the time the graph takes to shut down, if positive.
+	timeout time.Duration
+	// clock is the Clock of the circuit breakers and rate limiters of the
+	// exporters. The system clock is used if it is nil.
+	clock Clock
+	// fatal, if not nil, is called when a component of the graph reports a
+	// fatal error.
+	fatal func(error)
+	// status is the last status reported by each component of the graph.
+	statusMu sync.Mutex
+	status   map[*componentstatus.InstanceID]*componentstatus.Event
+
+	// exts are the extensions of the graph. They are started before and
+	// shut down after all the other components. host holds the started
+	// extensions while the graph is started.
+	exts *extensionSet
+	host *host
+	// pipeList are all the pipelines of the graph in build order.
+	pipeList []*pipeNode
+
+	// comps are all the components of the graph in start order. A component
+	// is always started after all the components it sends telemetry to.
+	comps []*instance
+
+	exps     map[expKey]*instance
+	conns    map[connKey]*instance
+	pipes    map[*pipeNode]any
+	building map[*pipeNode]bool
+
+	// selfMetrics records the exports of the exporters of the graph.
+	selfMetrics *selfMetrics
+
+	// warns are the warnings about the configuration of the components.
+	warnMu sync.Mutex
+	warns  []Warning
+}
+
+// newGraph builds the extensions and all components reachable from the roots.
+// The consumers of the roots, keyed by signal, are returned along with the
+// graph. The graph needs to be started before it is used.
+//
+// The extensions are created and started, from exts, when the graph is.
+func newGraph(ctx context.Context, set exporter.Settings, exts *extensionSet, roots []*pipeNode) (*graph, map[pipeline.Signal]any, error) {
+	warns, err := validate(roots)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	g := &graph{
+		set:      set,
+		exts:     exts,
+		exps:     make(map[expKey]*instance),
+		conns:    make(map[connKey]*instance),
+		pipes:    make(map[*pipeNode]any),
+		building: make(map[*pipeNode]bool),
+		warns:    warns,
+
+		selfMetrics: newSelfMetrics(set.MeterProvider, set.Logger),
+	}
+
+	var (
+		traces  []consumer.Traces
+		metrics []consumer.Metrics
+		logs    []consumer.Logs
+	)
+	for _, p := range roots {
+		c, err := g.pipeline(ctx, p)
+		if err != nil {
+			return nil, nil, errors.Join(err, g.shutdown(ctx))
+		}
+		switch c := c.(type) {
+		case consumer.Traces:
+			traces = append(traces, c)
+		case consumer.Metrics:
+			metrics = append(metrics, c)
+		case consumer.Logs:
+			logs = append(logs, c)
+		}
+	}
+
+	heads := make(map[pipeline.Signal]any)
+	if len(traces) > 0 {
+		heads[pipeline.SignalTraces] = fanoutTraces(traces)
+	}
+	if len(metrics) > 0 {
+		heads[pipeline.SignalMetrics] = fanoutMetrics(metrics)
+	}
+	if len(logs) > 0 {
+		heads[pipeline.SignalLogs] = fanoutLogs(logs)
+	}
+	return g, heads, nil
+}
+
+// validate returns the errors of the configurations of all the components
+// reachable from roots. Each error names the component and the path of the
+// invalid field in its configuration. The warnings about the deprecated
+// settings of the configurations are returned as well.
+func validate(roots []*pipeNode) ([]Warning, error) {
+	var (
+		warns []Warning
+		err   error
+		seen  = make(map[any]bool)
+		visit func(*pipeNode)
+	)
+	check := func(node any, kind component.Kind, id component.ID, def, cfg component.Config) {
+		if seen[node] {
+			return
+		}
+		seen[node] = true
+		name := strings.ToLower(kind.String())
+		if e := validID(id); e != nil {
+			err = errors.Join(err, fmt.Errorf("%s %s: invalid ID: %w", name, id, e))
+		}
+		if e := xconfmap.Validate(cfg); e != nil {
+			err = errors.Join(err, fmt.Errorf("%s %s: invalid configuration: %w", name, id, e))
+		}
+		warns = append(warns, configWarnings(kind, id, def, cfg)...)
+	}
+	visit = func(p *pipeNode) {
+		if seen[p] {
+			return
+		}
+		seen[p] = true
+		for i := range p.procs {
+			n := &p.procs[i]
+			check(n, component.KindProcessor, n.id, n.Factory.CreateDefaultConfig(), n.config())
+		}
+		for _, n := range p.exps {
+			check(n, component.KindExporter, n.id, n.Factory.CreateDefaultConfig(), n.config())
+		}
+		for _, n := range p.conns {
+			def, cfg := n.factory.CreateDefaultConfig(), n.config
+			if cfg == nil {
+				cfg = def
+			}
+			check(n, component.KindConnector, n.id, def, cfg)
+			for _, out := range n.pipes {
+				visit(out)
+			}
+		}
+	}
+	for _, p := range roots {
+		visit(p)
+	}
+	return warns, err
+}
+
+// validID returns an error if id would not be parsed back from its string,
+// e.g. if its name has characters a collector configuration does not allow.
+func validID(id component.ID) error {
+	var parsed component.ID
+	return parsed.UnmarshalText([]byte(id.String()))
+}
+
+// start starts the extensions and then all other components of the graph.
+// Extensions watching the pipelines are notified once all are started.
+func (g *graph) start(ctx context.Context) error {
+	h, err := g.exts.acquire(ctx, g)
+	if err != nil {
+		return err
+	}
+	g.host = h
+	for _, c := range g.comps {
+		if err := h.startComponent(ctx, g, c.id, c); err != nil {
+			return err
+		}
+	}
+	return g.exts.ready(h)
+}
+
+// shutdown shuts down the components in the reverse order they were started
+// so no component receives data after its downstream consumer has stopped.
+// Extensions watching the pipelines are notified first and all extensions are
+// shut down last.
+//
+// Components draining their queues, like exporters with a sending queue, are
+// given until the timeout of the graph, or the deadline of ctx, to do so.
+func (g *graph) shutdown(ctx context.Context) error {
+	ctx, cancel := g.shutdownContext(ctx)
+	defer cancel()
+	return g.exts.release(ctx, g)
+}
+
+// shutdownContext returns ctx bounded by the timeout of g.
+func (g *graph) shutdownContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if g.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, g.timeout)
+}
+
+func (g *graph) shutdownComponents(ctx context.Context) error {
+	var err error
+	for i := len(g.comps) - 1; i >= 0; i-- {
+		c := g.comps[i]
+		if g.host == nil {
+			err = errors.Join(err, c.Shutdown(ctx))
+			continue
+		}
+		err = errors.Join(err, g.host.shutdownComponent(ctx, g, c.id, c))
+	}
+	return err
+}
+
+// pipeline returns the consumer of p, building it and all the components it
+// sends telemetry to if that has not already been done.
+func (g *graph) pipeline(ctx context.Context, p *pipeNode) (any, error) {
+	if c, ok := g.pipes[p]; ok {
+		return c, nil
+	}
+	if g.building[p] {
+		return nil, fmt.Errorf("pipeline %s: cycle through connectors", p.id)
+	}
+	g.building[p] = true
+	defer delete(g.building, p)
+
+	var (
+		c   any
+		err error
+	)
+	switch p.id.Signal() {
+	case pipeline.SignalTraces:
+		c, err = g.traces(ctx, p)
+	case pipeline.SignalMetrics:
+		c, err = g.metrics(ctx, p)
+	case pipeline.SignalLogs:
+		c, err = g.logs(ctx, p)
+	default:
+		err = fmt.Errorf("pipeline %s: unsupported signal", p.id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	g.pipes[p] = c
+	g.pipeList = append(g.pipeList, p)
+	return c, nil
+}
+
+func (g *graph) traces(ctx context.Context, p *pipeNode) (consumer.Traces, error) {
+	var next []consumer.Traces
+	for _, e := range p.exps {
+		exp, err := g.exporter(ctx, p, e, pipeline.SignalTraces)
+		if err != nil {
+			return nil, err
+		}
+		next = append(next, g.wrapTraces(e, exp.(consumer.Traces)))
+	}
+	for _, c := range p.conns {
+		conns, err := g.connector(ctx, p, c, pipeline.SignalTraces)
+		if err != nil {
+			return nil, err
+		}
+		for _, conn := range conns {
+			next = append(next, conn.(consumer.Traces))
+		}
+	}
+	if len(next) == 0 {
+		return nil, fmt.Errorf("pipeline %s: %w", p.id, errNoExporters)
+	}
+
+	head := fanoutTraces(next)
+	for i := len(p.procs) - 1; i >= 0; i-- {
+		n := p.procs[i]
+		set, created := g.procSettings(n)
+		proc, err := n.Factory.CreateTraces(ctx, set, n.config(), head)
+		created()
+		if err != nil {
+			return nil, fmt.Errorf("processor %s: %w", n.id, err)
+		}
+		g.comps = append(g.comps, &instance{
+			id:        componentstatus.NewInstanceID(n.id, component.KindProcessor, p.id),
+			Component: proc,
+		})
+		head = proc
+	}
+	return head, nil
+}
+
+func (g *graph) metrics(ctx context.Context, p *pipeNode) (consumer.Metrics, error) {
+	var next []consumer.Metrics
+	for _, e := range p.exps {
+		exp, err := g.exporter(ctx, p, e, pipeline.SignalMetrics)
+		if err != nil {
+			return nil, err
+		}
+		next = append(next, g.wrapMetrics(e, exp.(consumer.Metrics)))
+	}
+	for _, c := range p.conns {
+		conns, err := g.connector(ctx, p, c, pipeline.SignalMetrics)
+		if err != nil {
+			return nil, err
+		}
+		for _, conn := range conns {
+			next = append(next, conn.(consumer.Metrics))
+		}
+	}
+	if len(next) == 0 {
+		return nil, fmt.Errorf("pipeline %s: %w", p.id, errNoExporters)
+	}
+
+	head := fanoutMetrics(next)
+	for i := len(p.procs) - 1; i >= 0; i-- {
+		n := p.procs[i]
+		set, created := g.procSettings(n)
+		proc, err := n.Factory.CreateMetrics(ctx, set, n.config(), head)
+		created()
+		if err != nil {
+			return nil, fmt.Errorf("processor %s: %w", n.id, err)
+		}
+		g.comps = append(g.comps, &instance{
+			id:        componentstatus.NewInstanceID(n.id, component.KindProcessor, p.id),
+			Component: proc,
+		})
+		head = proc
+	}
+	return head, nil
+}
+
+func (g *graph) logs(ctx context.Context, p *pipeNode) (consumer.Logs, error) {
+	var next []consumer.Logs
+	for _, e := range p.exps {
+		exp, err := g.exporter(ctx, p, e, pipeline.SignalLogs)
+		if err != nil {
+			return nil, err
+		}
+		next = append(next, g.wrapLogs(e, exp.(consumer.Logs)))
+	}
+	for _, c := range p.conns {
+		conns, err := g.connector(ctx, p, c, pipeline.SignalLogs)
+		if err != nil {
+			return nil, err
+		}
+		for _, conn := range conns {
+			next = append(next, conn.(consumer.Logs))
+		}
+	}
+	if len(next) == 0 {
+		return nil, fmt.Errorf("pipeline %s: %w", p.id, errNoExporters)
+	}
+
+	head := fanoutLogs(next)
+	for i := len(p.procs) - 1; i >= 0; i-- {
+		n := p.procs[i]
+		set, created := g.procSettings(n)
+		proc, err := n.Factory.CreateLogs(ctx, set, n.config(), head)
+		created()
+		if err != nil {
+			return nil, fmt.Errorf("processor %s: %w", n.id, err)
+		}
+		g.comps = append(g.comps, &instance{
+			id:        componentstatus.NewInstanceID(n.id, component.KindProcessor, p.id),
+			Component: proc,
+		})
+		head = proc
+	}
+	return head, nil
+}
+
+// procSettings returns the settings of the processor of n and the function
+// to call once it is created.
+func (g *graph) procSettings(n procNode) (processor.Settings, func()) {
+	tel, created := g.telemetry(component.KindProcessor, n.id)
+	return processor.Settings{
+		ID:                n.id,
+		TelemetrySettings: tel,
+		BuildInfo:         g.set.BuildInfo,
+	}, created
+}
+
+// exporter returns the exporter of n for signal used by the pipeline p. Only
+// a single exporter is created for each signal of n.
+func (g *graph) exporter(ctx context.Context, p *pipeNode, n *expNode, signal pipeline.Signal) (component.Component, error) {
+	key := expKey{node: n, signal: signal}
+	if c, ok := g.exps[key]; ok {
+		c.id = c.id.WithPipelines(p.id)
+		return c.Component, nil
+	}
+
+	set := g.set
+	set.ID = n.id
+	cfg, err := n.signalConfig(signal)
+	if err != nil {
+		return nil, fmt.Errorf("exporter %s: %w", n.id, err)
+	}
+	tel, created := g.telemetry(component.KindExporter, n.id)
+	obsreport, queue := new(atomic.Bool), new(queueObserver)
+	if tel.MeterProvider != nil {
+		tel.MeterProvider = helperWatch{MeterProvider: tel.MeterProvider, used: obsreport, queue: queue}
+	}
+	set.TelemetrySettings = tel
+	var c component.Component
+	switch signal {
+	case pipeline.SignalTraces:
+		c, err = n.Factory.CreateTraces(ctx, set, cfg)
+	case pipeline.SignalMetrics:
+		c, err = n.Factory.CreateMetrics(ctx, set, cfg)
+	case pipeline.SignalLogs:
+		c, err = n.Factory.CreateLogs(ctx, set, cfg)
+	}
+	created()
+	if err != nil {
+		return nil, fmt.Errorf("exporter %s: %w", n.id, err)
+	}
+	inst := &instance{
+		id:        componentstatus.NewInstanceID(n.id, component.KindExporter, p.id),
+		Component: c,
+		obsreport: obsreport.Load(),
+		queue:     queue,
+	}
+	g.exps[key] = inst
+	g.comps = append(g.comps, inst)
+	return c, nil
+}
+
+// wrapTraces returns exp, the consumer of the exporter n, wrapped with its
+// panic recovery, backpressure, size limit, retries, circuit breaker,
+// dead-letter directory, self-metrics, and rate limit.
+func (g *graph) wrapTraces(n *expNode, exp consumer.Traces) consumer.Traces {
+	rec := g.recorder(n, pipeline.SignalTraces)
+	c := recoverTraces(g.panicGuard(n, pipeline.SignalTraces), exp)
+	c = guardTraces(g.sizeGuard(n), dropTraces(g.dropper(n, rec), c))
+	c = breakTraces(g.breaker(n, pipeline.SignalTraces), retryTraces(g.retrier(n), c))
+	c = deadLetterTraces(g.deadLetter(n, pipeline.SignalTraces), c)
+	c = instrumentTraces(rec, c)
+	return limitTraces(g.limiter(n, pipeline.SignalTraces), rec, c)
+}
+
+// wrapMetrics returns exp, the consumer of the exporter n, wrapped with its
+// panic recovery, backpressure, size limit, retries, circuit breaker,
+// dead-letter directory, self-metrics, and rate limit.
+func (g *graph) wrapMetrics(n *expNode, exp consumer.Metrics) consumer.Metrics {
+	rec := g.recorder(n, pipeline.SignalMetrics)
+	c := recoverMetrics(g.panicGuard(n, pipeline.SignalMetrics), exp)
+	c = guardMetrics(g.sizeGuard(n), dropMetrics(g.dropper(n, rec), c))
+	c = breakMetrics(g.breaker(n, pipeline.SignalMetrics), retryMetrics(g.retrier(n), c))
+	c = deadLetterMetrics(g.deadLetter(n, pipeline.SignalMetrics), c)
+	c = instrumentMetrics(rec, c)
+	return limitMetrics(g.limiter(n, pipeline.SignalMetrics), rec, c)
+}
+
+// wrapLogs returns exp, the consumer of the exporter n, wrapped with its
+// panic recovery, backpressure, size limit, retries, circuit breaker,
+// dead-letter directory, self-metrics, and rate limit.
+func (g *graph) wrapLogs(n *expNode, exp consumer.Logs) consumer.Logs {
+	rec := g.recorder(n, pipeline.SignalLogs)
+	c := recoverLogs(g.panicGuard(n, pipeline.SignalLogs), exp)
+	c = guardLogs(g.sizeGuard(n), dropLogs(g.dropper(n, rec), c))
+	c = breakLogs(g.breaker(n, pipeline.SignalLogs), retryLogs(g.retrier(n), c))
+	c = deadLetterLogs(g.deadLetter(n, pipeline.SignalLogs), c)
+	c = instrumentLogs(rec, c)
+	return limitLogs(g.limiter(n, pipeline.SignalLogs), rec, c)
+}
+
+// breaker returns the circuit breaker of the exports of signal to n, or nil
+// if n has none. The pipelines sharing the exporter share its breaker, so
+// their failed exports are counted together. The exporter needs to be
+// created.
+func (g *graph) breaker(n *expNode, signal pipeline.Signal) *breaker {
+	inst := g.exps[expKey{node: n, signal: signal}]
+	if inst.breaker == nil {
+		now := func() time.Time { return g.clockOrSystem().Now() }
+		inst.breaker = newBreaker(n.CircuitBreaker, g.set.Logger, n.id, now)
+	}
+	return inst.breaker
+}
+
+// limiter returns the rate limiter of the exports of signal to n, or nil if
+// n has none. The pipelines sharing the exporter share its limiter, so their
+// exports are limited together. The exporter needs to be created.
+func (g *graph) limiter(n *expNode, signal pipeline.Signal) *limiter {
+	inst := g.exps[expKey{node: n, signal: signal}]
+	if inst.limiter == nil {
+		inst.limiter = newLimiter(n.RateLimit, g.clockOrSystem)
+	}
+	return inst.limiter
+}
+
+// deadLetter returns the dead-letter writer of the exports of signal to n, or
+// nil if n has none. The pipelines sharing the exporter share its writer, so
+// one writer rotates and prunes its files. The exporter needs to be created.
+func (g *graph) deadLetter(n *expNode, signal pipeline.Signal) *deadLetter {
+	inst := g.exps[expKey{node: n, signal: signal}]
+	if inst.deadLetter == nil {
+		now := func() time.Time { return g.clockOrSystem().Now() }
+		inst.deadLetter = newDeadLetter(n.DeadLetter, g.set.Logger, n.id, signal, now)
+	}
+	return inst.deadLetter
+}
+
+// retrier returns the retrier of the exports to n, or nil if n has none.
+func (g *graph) retrier(n *expNode) *retrier {
+	return newRetrier(n.Retry, g.set.Logger, n.id, g.clockOrSystem)
+}
+
+// dropper returns the dropper of the exports to n recorded with rec, or nil
+// if n does not drop them.
+func (g *graph) dropper(n *expNode, rec exportRecorder) *dropper {
+	return newDropper(n.Backpressure, g.set.Logger, n.id, rec)
+}
+
+// panicGuard returns the panicGuard of the exports of signal to n.
+func (g *graph) panicGuard(n *expNode, signal pipeline.Signal) *panicGuard {
+	return newPanicGuard(g.set.Logger, g.selfMetrics, signal, attribute.String("exporter", n.id.String()))
+}
+
+// sizeGuard returns the size guard of the exports to n, or nil if n has no
+// size limit.
+func (g *graph) sizeGuard(n *expNode) *sizeGuard {
+	return newSizeGuard(n.MaxBytes)
+}
+
+// clockOrSystem returns the clock of g, or the system clock if it has none.
+func (g *graph) clockOrSystem() Clock {
+	return clockOrSystem(g.clock)
+}
+
+// recorder returns the exportRecorder of the exports of signal by the
+// exporter of n. The exporter needs to be created.
+func (g *graph) recorder(n *expNode, signal pipeline.Signal) exportRecorder {
+	inst := g.exps[expKey{node: n, signal: signal}]
+	return g.selfMetrics.recorder(n.id, signal, inst)
+}
+
+// connector returns the connectors of n that consume the in signal from the
+// pipeline p, one for each signal of the pipelines n emits to. Only a single
+// connector is created for each pair of signals.
+func (g *graph) connector(ctx context.Context, p *pipeNode, n *connNode, in pipeline.Signal) ([]component.Component, error) {
+	var (
+		traces  = make(map[pipeline.ID]consumer.Traces)
+		metrics = make(map[pipeline.ID]consumer.Metrics)
+		logs    = make(map[pipeline.ID]consumer.Logs)
+	)
+	for _, out := range n.pipes {
+		c, err := g.pipeline(ctx, out)
+		if err != nil {
+			return nil, err
+		}
+		switch c := c.(type) {
+		case consumer.Traces:
+			traces[out.id] = c
+		case consumer.Metrics:
+			metrics[out.id] = c
+		case consumer.Logs:
+			logs[out.id] = c
+		}
+	}
+
+	// Connectors that route telemetry, like the routing connector, look up
+	// the pipelines they emit to by ID. Therefore, the output is always
+	// wrapped in a router even if there is only a single pipeline.
+	var conns []component.Component
+	if len(traces) > 0 {
+		c, err := g.createConnector(ctx, p, n, in, pipeline.SignalTraces, connector.NewTracesRouter(traces))
+		if err != nil {
+			return nil, err
+		}
+		conns = append(conns, c)
+	}
+	if len(metrics) > 0 {
+		c, err := g.createConnector(ctx, p, n, in, pipeline.SignalMetrics, connector.NewMetricsRouter(metrics))
+		if err != nil {
+			return nil, err
+		}
+		conns = append(conns, c)
+	}
+	if len(logs) > 0 {
+		c, err := g.createConnector(ctx, p, n, in, pipeline.SignalLogs, connector.NewLogsRouter(logs))
+		if err != nil {
+			return nil, err
+		}
+		conns = append(conns, c)
+	}
+	if len(conns) == 0 {
+		return nil, fmt.Errorf("connector %s: no pipelines", n.id)
+	}
+	return conns, nil
+}
+
+func (g *graph) createConnector(ctx context.Context, p *pipeNode, n *connNode, in, out pipeline.Signal, next any) (component.Component, error) {
+	key := connKey{node: n, in: in, out: out}
+	if c, ok := g.conns[key]; ok {
+		c.id = c.id.WithPipelines(p.id)
+		return c.Component, nil
+	}
+
+	tel, created := g.telemetry(component.KindConnector, n.id)
+	defer created()
+	set := connector.Settings{
+		ID:                n.id,
+		TelemetrySettings: tel,
+		BuildInfo:         g.set.BuildInfo,
+	}
+	cfg := n.config
+	if cfg == nil {
+		cfg = n.factory.CreateDefaultConfig()
+	}
+
+	var (
+		c   component.Component
+		err error
+	)
+	switch in {
+	case pipeline.SignalTraces:
+		switch out {
+		case pipeline.SignalTraces:
+			c, err = n.factory.CreateTracesToTraces(ctx, set, cfg, next.(consumer.Traces))
+		case pipeline.SignalMetrics:
+			c, err = n.factory.CreateTracesToMetrics(ctx, set, cfg, next.(consumer.Metrics))
+		case pipeline.SignalLogs:
+			c, err = n.factory.CreateTracesToLogs(ctx, set, cfg, next.(consumer.Logs))
+		}
+	case pipeline.SignalMetrics:
+		switch out {
+		case pipeline.SignalTraces:
+			c, err = n.factory.CreateMetricsToTraces(ctx, set, cfg, next.(consumer.Traces))
+		case pipeline.SignalMetrics:
+			c, err = n.factory.CreateMetricsToMetrics(ctx, set, cfg, next.(consumer.Metrics))
+		case pipeline.SignalLogs:
+			c, err = n.factory.CreateMetricsToLogs(ctx, set, cfg, next.(consumer.Logs))
+		}
+	case pipeline.SignalLogs:
+		switch out {
+		case pipeline.SignalTraces:
+			c, err = n.factory.CreateLogsToTraces(ctx, set, cfg, next.(consumer.Traces))
+		case pipeline.SignalMetrics:
+			c, err = n.factory.CreateLogsToMetrics(ctx, set, cfg, next.(consumer.Metrics))
+		case pipeline.SignalLogs:
+			c, err = n.factory.CreateLogsToLogs(ctx, set, cfg, next.(consumer.Logs))
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("connector %s: %w", n.id, err)
+	}
+	// Like in the collector, a connector instance belongs to the pipelines it
+	// consumes from and the ones it emits to.
+	pipes := []pipeline.ID{p.id}
+	for _, o := range n.pipes {
+		if o.id.Signal() == out {
+			pipes = append(pipes, o.id)
+		}
+	}
+	inst := &instance{
+		id:        componentstatus.NewInstanceID(n.id, component.KindConnector, pipes...),
+		Component: c,
+	}
+	g.conns[key] = inst
+	g.comps = append(g.comps, inst)
+	return c, nil
+}
@@ -0,0 +1,138 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pipeline"
+)
+
+// countingExporter is a collector exporter counting the exports it is sent
+// and failing them with err.
+type countingExporter struct {
+	calls atomic.Int64
+	err   error
+}
+
+func (e *countingExporter) factory() exporter.Factory {
+	return exporter.NewFactory(
+		component.MustNewType("counting"),
+		func() component.Config { return &struct{}{} },
+		exporter.WithTraces(func(context.Context, exporter.Settings, component.Config) (exporter.Traces, error) {
+			c, err := consumer.NewTraces(func(context.Context, ptrace.Traces) error {
+				e.calls.Add(1)
+				return e.err
+			})
+			return struct {
+				component.StartFunc
+				component.ShutdownFunc
+				consumer.Traces
+			}{Traces: c}, err
+		}, component.StabilityLevelDevelopment),
+	)
+}
+
+// sharedGraph starts a graph of two traces pipelines sending to the same
+// exporter instance of e, and returns the consumer of both pipelines.
+func sharedGraph(t *testing.T, e Exporter) consumer.Traces {
+	t.Helper()
+	n := e.node()
+	roots := []*pipeNode{
+		{id: pipeline.NewIDWithName(pipeline.SignalTraces, "a"), exps: []*expNode{n}},
+		{id: pipeline.NewIDWithName(pipeline.SignalTraces, "b"), exps: []*expNode{n}},
+	}
+	set := exporter.Settings{TelemetrySettings: componenttest.NewNopTelemetrySettings()}
+	ctx := context.Background()
+	g, heads, err := newGraph(ctx, set, newExtensionSet(set, nil, nil, nil), roots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = g.shutdown(ctx) })
+	return heads[pipeline.SignalTraces].(consumer.Traces)
+}
+
+var errExport = errors.New("export failed")
+
+func oneSpan() ptrace.Traces {
+	td := ptrace.NewTraces()
+	td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty().SetName("span")
+	return td
+}
+
+func TestGraphSharedBreaker(t *testing.T) {
+	exp := &countingExporter{err: errExport}
+	c := sharedGraph(t, Exporter{
+		Factory:        exp.factory(),
+		CircuitBreaker: CircuitBreakerConfig{Failures: 2, OpenDuration: time.Hour},
+	})
+
+	ctx := context.Background()
+	// Each pipeline fails once, opening the breaker they share.
+	_ = c.ConsumeTraces(ctx, oneSpan())
+	_ = c.ConsumeTraces(ctx, oneSpan())
+	if got := exp.calls.Load(); got != 2 {
+		t.Errorf("exporter called %d times, want 2 before the shared breaker opened", got)
+	}
+}
+
+func TestGraphSharedLimiter(t *testing.T) {
+	exp := &countingExporter{}
+	c := sharedGraph(t, Exporter{
+		Factory:   exp.factory(),
+		RateLimit: RateLimitConfig{Limit: 1e-9, Burst: 2, Drop: true},
+	})
+
+	ctx := context.Background()
+	// Each pipeline exports the span, taking the two tokens they share.
+	_ = c.ConsumeTraces(ctx, oneSpan())
+	_ = c.ConsumeTraces(ctx, oneSpan())
+	if got := exp.calls.Load(); got != 2 {
+		t.Errorf("exporter called %d times, want 2 within the shared rate limit", got)
+	}
+}
+
+func TestGraphSharedDeadLetter(t *testing.T) {
+	exp := &countingExporter{err: consumererror.NewPermanent(errExport)}
+	dir := t.TempDir()
+	c := sharedGraph(t, Exporter{
+		Factory:    exp.factory(),
+		DeadLetter: DeadLetterConfig{Dir: dir, MaxFiles: 2},
+	})
+
+	_ = c.ConsumeTraces(context.Background(), oneSpan())
+	// Both pipelines append to the file of the writer they share.
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("dead-letter files %q, want 1", files)
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/collector/component/componentstatus"
+)
+
+var (
+	errNotStarted = errors.New("collex: component not started")
+	errShutDown   = errors.New("collex: component shut down")
+)
+
+// Ready returns nil once all the components of p are started and none of
+// them stopped or failed permanently. It is meant to back the readiness
+// probe of an application.
+func (p *Pipeline) Ready() error { return p.g.ready() }
+
+// Healthy returns an error if a component of p reports an error, or if the
+// last export to an exporter of p failed. The error clears once the
+// component reports it is OK again, or once an export to the exporter
+// succeeds. It is meant to back the liveness, or health, endpoint of an
+// application.
+func (p *Pipeline) Healthy() error { return p.g.healthy() }
+
+// setStatus records ev as the last status of the component of g with id. It
+// does nothing if g is nil.
+func (g *graph) setStatus(id *componentstatus.InstanceID, ev *componentstatus.Event) {
+	if g == nil {
+		return
+	}
+	g.statusMu.Lock()
+	defer g.statusMu.Unlock()
+	if g.status == nil {
+		g.status = make(map[*componentstatus.InstanceID]*componentstatus.Event)
+	}
+	g.status[id] = ev
+}
+
+// lastStatus returns the last status reported by the component of g with id,
+// nil if it has not reported any.
+func (g *graph) lastStatus(id *componentstatus.InstanceID) *componentstatus.Event {
+	g.statusMu.Lock()
+	defer g.statusMu.Unlock()
+	return g.status[id]
+}
+
+// ready returns the errors of the components of g that are not started.
+func (g *graph) ready() error {
+	var err error
+	for _, c := range g.comps {
+		ev := g.lastStatus(c.id)
+		if ev == nil {
+			err = errors.Join(err, componentErr(c.id, errNotStarted))
+			continue
+		}
+		switch ev.Status() {
+		case componentstatus.StatusOK, componentstatus.StatusRecoverableError:
+		case componentstatus.StatusStopping, componentstatus.StatusStopped:
+			err = errors.Join(err, componentErr(c.id, errShutDown))
+		case componentstatus.StatusPermanentError, componentstatus.StatusFatalError:
+			err = errors.Join(err, componentErr(c.id, ev.Err()))
+		default:
+			err = errors.Join(err, componentErr(c.id, errNotStarted))
+		}
+	}
+	return err
+}
+
+// healthy returns the errors reported by the components of g and the errors
+// of the last exports to its exporters.
+func (g *graph) healthy() error {
+	var err error
+	for _, c := range g.comps {
+		if ev := g.lastStatus(c.id); ev != nil && componentstatus.StatusIsError(ev.Status()) {
+			err = errors.Join(err, componentErr(c.id, ev.Err()))
+			continue
+		}
+		if e := c.exportErr.Load(); e != nil {
+			err = errors.Join(err, componentErr(c.id, fmt.Errorf("last export failed: %w", *e)))
+		}
+	}
+	return err
+}
+
+// componentErr returns err annotated with the kind and ID of the component
+// with id.
+func componentErr(id *componentstatus.InstanceID, err error) error {
+	return fmt.Errorf("%s %s: %w", strings.ToLower(id.Kind().String()), id.ComponentID(), err)
+}
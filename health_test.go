@@ -0,0 +1,86 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/MrAlias/collex"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestPipelineReadyHealthy(t *testing.T) {
+	b := new(backend)
+	ctx := context.Background()
+	p, err := collex.NewPipeline().
+		WithSettings(*settings()).
+		WithExporter(b.factory(), nil).
+		Build(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Ready(); err != nil {
+		t.Errorf("Ready = %v, want nil once started", err)
+	}
+	if err := p.Healthy(); err != nil {
+		t.Errorf("Healthy = %v, want nil before any export", err)
+	}
+
+	spans := tracetest.SpanStubs{{Name: "a", Resource: resource.Empty()}}.Snapshots()
+	b.err = errBroken
+	_ = p.SpanExporter().ExportSpans(ctx, spans)
+	if err := p.Healthy(); !errors.Is(err, errBroken) {
+		t.Errorf("Healthy = %v, want %v after a failed export", err, errBroken)
+	}
+	if err := p.Ready(); err != nil {
+		t.Errorf("Ready = %v, want nil after a failed export", err)
+	}
+
+	b.err = nil
+	if err := p.SpanExporter().ExportSpans(ctx, spans); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Healthy(); err != nil {
+		t.Errorf("Healthy = %v, want nil after a successful export", err)
+	}
+
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Ready(); err == nil {
+		t.Error("Ready = nil, want an error once shut down")
+	}
+}
+
+func TestPipelineHealthyStatus(t *testing.T) {
+	ctx := context.Background()
+	p, err := collex.NewPipeline().
+		WithSettings(*settings()).
+		WithExporter(unavailable(), nil).
+		Build(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = p.Shutdown(ctx) }()
+
+	spans := tracetest.SpanStubs{{Name: "a", Resource: resource.Empty()}}.Snapshots()
+	_ = p.SpanExporter().ExportSpans(ctx, spans)
+	if err := p.Healthy(); !errors.Is(err, errUnavailable) {
+		t.Errorf("Healthy = %v, want %v reported by the exporter", err, errUnavailable)
+	}
+}
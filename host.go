@@ -0,0 +1,342 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componentstatus"
+	"go.opentelemetry.io/collector/confmap/xconfmap"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/extension"
+	"go.opentelemetry.io/collector/extension/extensioncapabilities"
+)
+
+// extNode is an extension of a host.
+type extNode struct {
+	id component.ID
+	Extension
+}
+
+// extensionSet creates and starts the extensions shared by graphs. The
+// extensions are started when the first graph is started and shut down after
+// the last graph is shut down, once for all graphs.
+type extensionSet struct {
+	set    exporter.Settings
+	parent component.Host
+	status StatusFunc
+	nodes  []extNode
+
+	mu   sync.Mutex
+	host *host
+	refs int
+}
+
+func newExtensionSet(set exporter.Settings, parent component.Host, status StatusFunc, nodes []extNode) *extensionSet {
+	return &extensionSet{set: set, parent: parent, status: status, nodes: nodes}
+}
+
+// acquire returns the started host of the extensions for g, creating and
+// starting the extensions if no other graph holds them.
+func (s *extensionSet) acquire(ctx context.Context, g *graph) (*host, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.host == nil {
+		h, err := newHost(ctx, s.set, s.parent, s.status, s.nodes)
+		if err != nil {
+			return nil, err
+		}
+		if err := h.start(ctx); err != nil {
+			return nil, errors.Join(err, h.shutdown(ctx))
+		}
+		s.host = h
+	}
+	s.refs++
+	s.host.addPipes(g.pipeList)
+	return s.host, nil
+}
+
+// release shuts down the components of g. If g is the last graph holding the
+// extensions, the extensions watching pipelines are notified before and the
+// extensions are shut down after.
+func (s *extensionSet) release(ctx context.Context, g *graph) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h := g.host
+	var err error
+	if h != nil && s.refs == 1 {
+		err = h.notReady()
+	}
+	err = errors.Join(err, g.shutdownComponents(ctx))
+	if h == nil {
+		return err
+	}
+
+	g.host = nil
+	h.removePipes(g.pipeList)
+	s.refs--
+	if s.refs == 0 {
+		err = errors.Join(err, h.shutdown(ctx))
+		s.host = nil
+	}
+	return err
+}
+
+// ready notifies the extensions of h that watch pipelines that the pipelines
+// are started, unless h was shut down meanwhile. It is serialized with the
+// notifications of release.
+func (s *extensionSet) ready(h *host) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.host != h {
+		return nil
+	}
+	return h.ready()
+}
+
+// host is the component.Host of graphs. It owns the extensions the
+// components of the graphs are able to use.
+type host struct {
+	// exts are the extensions of the host in start order, ids are their IDs.
+	exts []component.Component
+	ids  []component.ID
+	byID map[component.ID]component.Component
+
+	// pipes are the pipelines of the graphs using the host.
+	pipes []*pipeNode
+
+	// mu guards the ids and pipes of the host. They are read while the
+	// zPages of the host are served.
+	mu sync.Mutex
+
+	// parent is the host provided by the user, if any.
+	parent component.Host
+
+	// status is called with the status changes of all components.
+	status   StatusFunc
+	statusMu sync.Mutex
+
+	// isReady is true if the extensions were notified the pipelines are
+	// ready and have not been notified otherwise since.
+	isReady bool
+}
+
+var _ component.Host = (*host)(nil)
+
+// newHost creates the extensions of nodes. The extensions are not started.
+// They are ordered so extensions are started after the extensions they
+// depend on.
+//
+// If parent is not nil, the extensions of parent are also returned by
+// GetExtensions. Without extensions or a status of its own, the host starts
+// components with parent as-is so they have access to everything parent
+// provides.
+func newHost(ctx context.Context, set exporter.Settings, parent component.Host, status StatusFunc, nodes []extNode) (*host, error) {
+	h := &host{
+		parent: parent,
+		status: status,
+		byID:   make(map[component.ID]component.Component, len(nodes)),
+	}
+	for _, n := range nodes {
+		if _, ok := h.byID[n.id]; ok {
+			return nil, fmt.Errorf("extension %s: duplicate ID", n.id)
+		}
+		eSet := extension.Settings{
+			ID:                n.id,
+			TelemetrySettings: set.TelemetrySettings,
+			BuildInfo:         set.BuildInfo,
+		}
+		if err := validID(n.id); err != nil {
+			return nil, fmt.Errorf("extension %s: invalid ID: %w", n.id, err)
+		}
+		cfg := n.config()
+		if err := xconfmap.Validate(cfg); err != nil {
+			return nil, fmt.Errorf("extension %s: invalid configuration: %w", n.id, err)
+		}
+		ext, err := n.Factory.Create(ctx, eSet, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("extension %s: %w", n.id, err)
+		}
+		h.exts = append(h.exts, ext)
+		h.ids = append(h.ids, n.id)
+		h.byID[n.id] = ext
+	}
+	if err := h.sortByDependencies(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// sortByDependencies orders the extensions so each is after the extensions
+// of the host it depends on. Dependencies on extensions of the parent are
+// ignored, the parent manages their lifecycle.
+func (h *host) sortByDependencies() error {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[component.ID]int, len(h.ids))
+	exts := make([]component.Component, 0, len(h.exts))
+	ids := make([]component.ID, 0, len(h.ids))
+
+	var visit func(component.ID) error
+	visit = func(id component.ID) error {
+		switch state[id] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("extension %s: cycle through dependencies", id)
+		}
+		state[id] = visiting
+		ext := h.byID[id]
+		if d, ok := ext.(extensioncapabilities.Dependent); ok {
+			for _, dep := range d.Dependencies() {
+				if _, ok := h.byID[dep]; !ok {
+					continue
+				}
+				if err := visit(dep); err != nil {
+					return err
+				}
+			}
+		}
+		state[id] = visited
+		exts = append(exts, ext)
+		ids = append(ids, id)
+		return nil
+	}
+	for _, id := range h.ids {
+		if err := visit(id); err != nil {
+			return err
+		}
+	}
+	h.mu.Lock()
+	h.exts, h.ids = exts, ids
+	h.mu.Unlock()
+	return nil
+}
+
+func (h *host) addPipes(pipes []*pipeNode) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.pipes = append(h.pipes, pipes...)
+}
+
+func (h *host) removePipes(pipes []*pipeNode) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	remove := make(map[*pipeNode]bool, len(pipes))
+	for _, p := range pipes {
+		remove[p] = true
+	}
+	kept := h.pipes[:0]
+	for _, p := range h.pipes {
+		if !remove[p] {
+			kept = append(kept, p)
+		}
+	}
+	h.pipes = kept
+}
+
+// component returns the host the component with id of the graph g is started
+// with. The graph is nil for extensions. Components of a graph always report
+// their status to it.
+func (h *host) component(g *graph, id *componentstatus.InstanceID) component.Host {
+	if g == nil && h.parent != nil && len(h.exts) == 0 && h.status == nil {
+		return h.parent
+	}
+	return &statusHost{host: h, g: g, id: id}
+}
+
+// GetExtensions returns the extensions of the host keyed by ID. Extensions of
+// the host take precedence over the ones of its parent with the same ID.
+func (h *host) GetExtensions() map[component.ID]component.Component {
+	exts := make(map[component.ID]component.Component, len(h.byID))
+	if h.parent != nil {
+		for id, ext := range h.parent.GetExtensions() {
+			exts[id] = ext
+		}
+	}
+	for id, ext := range h.byID {
+		exts[id] = ext
+	}
+	return exts
+}
+
+func (h *host) start(ctx context.Context) error {
+	for i, ext := range h.exts {
+		id := componentstatus.NewInstanceID(h.ids[i], component.KindExtension)
+		if err := h.startComponent(ctx, nil, id, ext); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shutdown shuts down the extensions in the reverse order they were started.
+func (h *host) shutdown(ctx context.Context) error {
+	var err error
+	for i := len(h.exts) - 1; i >= 0; i-- {
+		id := componentstatus.NewInstanceID(h.ids[i], component.KindExtension)
+		err = errors.Join(err, h.shutdownComponent(ctx, nil, id, h.exts[i]))
+	}
+	return err
+}
+
+// ready notifies the extensions that watch pipelines, like the health check
+// extension, that all the pipelines are started. Nothing is done if they were
+// already notified.
+func (h *host) ready() error {
+	if h.isReady {
+		return nil
+	}
+
+	var err error
+	for i, ext := range h.exts {
+		if w, ok := ext.(extensioncapabilities.PipelineWatcher); ok {
+			if e := w.Ready(); e != nil {
+				err = errors.Join(err, fmt.Errorf("extension %s: %w", h.ids[i], e))
+			}
+		}
+	}
+	h.isReady = true
+	return err
+}
+
+// notReady notifies the extensions that watch pipelines that the pipelines
+// are about to be shut down. Nothing is done if ready was not called.
+func (h *host) notReady() error {
+	if !h.isReady {
+		return nil
+	}
+	h.isReady = false
+
+	var err error
+	for i, ext := range h.exts {
+		if w, ok := ext.(extensioncapabilities.PipelineWatcher); ok {
+			if e := w.NotReady(); e != nil {
+				err = errors.Join(err, fmt.Errorf("extension %s: %w", h.ids[i], e))
+			}
+		}
+	}
+	return err
+}
@@ -0,0 +1,207 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kafka provides OpenTelemetry Go exporters publishing OTLP telemetry
+// to Kafka with the Kafka exporter of the collector contrib repository,
+// configured with Go options instead of a collector configuration.
+package kafka // import "github.com/MrAlias/collex/kafka"
+
+import (
+	"context"
+
+	"github.com/MrAlias/collex"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/kafkaexporter"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/pipeline"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Option configures the exporters.
+type Option interface {
+	apply(config) config
+}
+
+type config struct {
+	// conf is the collector configuration of the Kafka exporter.
+	conf map[string]any
+	// topics are the topics of the signals.
+	topics map[pipeline.Signal]string
+	set    *exporter.Settings
+	opts   []collex.Option
+}
+
+type optionFunc func(config) config
+
+func (fn optionFunc) apply(c config) config {
+	return fn(c)
+}
+
+// auth returns the auth block of the configuration of c.
+func (c config) auth() map[string]any {
+	auth, ok := c.conf["auth"].(map[string]any)
+	if !ok {
+		auth = make(map[string]any)
+		c.conf["auth"] = auth
+	}
+	return auth
+}
+
+// WithBrokers returns an Option that sets the addresses of the Kafka brokers,
+// e.g. kafka-0:9092.
+func WithBrokers(brokers ...string) Option {
+	return optionFunc(func(c config) config {
+		c.conf["brokers"] = brokers
+		return c
+	})
+}
+
+// topic returns an Option setting the topic of signal.
+func topic(signal pipeline.Signal, name string) Option {
+	return optionFunc(func(c config) config {
+		c.topics[signal] = name
+		return c
+	})
+}
+
+// WithTracesTopic returns an Option that sets the topic spans are published
+// to. The otlp_spans topic is used by default.
+func WithTracesTopic(name string) Option {
+	return topic(pipeline.SignalTraces, name)
+}
+
+// WithMetricsTopic returns an Option that sets the topic metrics are
+// published to. The otlp_metrics topic is used by default.
+func WithMetricsTopic(name string) Option {
+	return topic(pipeline.SignalMetrics, name)
+}
+
+// WithLogsTopic returns an Option that sets the topic log records are
+// published to. The otlp_logs topic is used by default.
+func WithLogsTopic(name string) Option {
+	return topic(pipeline.SignalLogs, name)
+}
+
+// WithEncoding returns an Option that sets the encoding of the published
+// messages, e.g. otlp_json. They are encoded as otlp_proto by default.
+func WithEncoding(encoding string) Option {
+	return optionFunc(func(c config) config {
+		c.conf["encoding"] = encoding
+		return c
+	})
+}
+
+// WithProtocolVersion returns an Option that sets the Kafka protocol version
+// of the brokers, e.g. 2.1.0.
+func WithProtocolVersion(version string) Option {
+	return optionFunc(func(c config) config {
+		c.conf["protocol_version"] = version
+		return c
+	})
+}
+
+// WithSASL returns an Option that authenticates the exporters with SASL using
+// mechanism, e.g. SCRAM-SHA-512, and the credentials of username.
+func WithSASL(mechanism, username, password string) Option {
+	return optionFunc(func(c config) config {
+		c.auth()["sasl"] = map[string]any{
+			"mechanism": mechanism,
+			"username":  username,
+			"password":  password,
+		}
+		return c
+	})
+}
+
+// WithTLS returns an Option that connects the exporters to the brokers with
+// TLS, verifying them with the CA certificate in caFile. If certFile and
+// keyFile are not empty, the exporters authenticate with the client
+// certificate in them.
+func WithTLS(caFile, certFile, keyFile string) Option {
+	return optionFunc(func(c config) config {
+		c.auth()["tls"] = map[string]any{
+			"ca_file":   caFile,
+			"cert_file": certFile,
+			"key_file":  keyFile,
+		}
+		return c
+	})
+}
+
+// WithSettings returns an Option that sets the settings the Kafka exporter
+// is created with, e.g. its logger. Default settings are used otherwise, like
+// with collex.NewFactory.
+func WithSettings(set *exporter.Settings) Option {
+	return optionFunc(func(c config) config {
+		c.set = set
+		return c
+	})
+}
+
+// WithFactoryOptions returns an Option that configures the collex.Factory
+// wrapping the Kafka exporter with opts, e.g. collex.WithRetry.
+func WithFactoryOptions(opts ...collex.Option) Option {
+	return optionFunc(func(c config) config {
+		c.opts = append(c.opts, opts...)
+		return c
+	})
+}
+
+// newFactory returns the Factory of the Kafka exporter and its configuration
+// for signal set by opts.
+func newFactory(signal pipeline.Signal, opts []Option) (*collex.Factory, component.Config, error) {
+	c := config{conf: make(map[string]any), topics: make(map[pipeline.Signal]string)}
+	for _, opt := range opts {
+		c = opt.apply(c)
+	}
+	if name, ok := c.topics[signal]; ok {
+		c.conf["topic"] = name
+	}
+	k := kafkaexporter.NewFactory()
+	cfg, err := collex.ConfigFromMap(k, c.conf)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := collex.NewFactory(k, c.set, c.opts...)
+	return f, cfg, err
+}
+
+// NewSpanExporter returns a SpanExporter publishing spans to Kafka.
+func NewSpanExporter(ctx context.Context, opts ...Option) (trace.SpanExporter, error) {
+	f, cfg, err := newFactory(pipeline.SignalTraces, opts)
+	if err != nil {
+		return nil, err
+	}
+	return f.SpanExporter(ctx, cfg)
+}
+
+// NewMetricExporter returns a metric Exporter publishing metrics to Kafka.
+func NewMetricExporter(ctx context.Context, opts ...Option) (metric.Exporter, error) {
+	f, cfg, err := newFactory(pipeline.SignalMetrics, opts)
+	if err != nil {
+		return nil, err
+	}
+	return f.MetricExporter(ctx, cfg)
+}
+
+// NewLogExporter returns a log Exporter publishing log records to Kafka.
+func NewLogExporter(ctx context.Context, opts ...Option) (log.Exporter, error) {
+	f, cfg, err := newFactory(pipeline.SignalLogs, opts)
+	if err != nil {
+		return nil, err
+	}
+	return f.LogExporter(ctx, cfg)
+}
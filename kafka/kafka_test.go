@@ -0,0 +1,89 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka
+
+import (
+	"testing"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/kafkaexporter"
+	"go.opentelemetry.io/collector/pipeline"
+)
+
+func exporterConfig(t *testing.T, signal pipeline.Signal, opts ...Option) *kafkaexporter.Config {
+	t.Helper()
+	_, cfg, err := newFactory(signal, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg.(*kafkaexporter.Config)
+}
+
+func TestWithBrokers(t *testing.T) {
+	cfg := exporterConfig(t, pipeline.SignalTraces, WithBrokers("kafka-0:9092", "kafka-1:9092"))
+	if len(cfg.Brokers) != 2 || cfg.Brokers[0] != "kafka-0:9092" || cfg.Brokers[1] != "kafka-1:9092" {
+		t.Errorf("brokers = %v, want [kafka-0:9092 kafka-1:9092]", cfg.Brokers)
+	}
+}
+
+func TestTopics(t *testing.T) {
+	opts := []Option{
+		WithTracesTopic("app-spans"),
+		WithMetricsTopic("app-metrics"),
+		WithLogsTopic("app-logs"),
+	}
+	for signal, want := range map[pipeline.Signal]string{
+		pipeline.SignalTraces:  "app-spans",
+		pipeline.SignalMetrics: "app-metrics",
+		pipeline.SignalLogs:    "app-logs",
+	} {
+		if got := exporterConfig(t, signal, opts...).Topic; got != want {
+			t.Errorf("%s topic = %q, want %q", signal, got, want)
+		}
+	}
+
+	// The signals without a topic keep the default of the exporter.
+	def := kafkaexporter.NewFactory().CreateDefaultConfig().(*kafkaexporter.Config).Topic
+	if got := exporterConfig(t, pipeline.SignalLogs, WithTracesTopic("app-spans")).Topic; got != def {
+		t.Errorf("logs topic = %q, want the default %q", got, def)
+	}
+}
+
+func TestWithSASL(t *testing.T) {
+	cfg := exporterConfig(t, pipeline.SignalTraces, WithSASL("SCRAM-SHA-512", "user", "secret"))
+	sasl := cfg.Authentication.SASL
+	if sasl == nil {
+		t.Fatal("SASL not configured")
+	}
+	if sasl.Mechanism != "SCRAM-SHA-512" || sasl.Username != "user" || sasl.Password != "secret" {
+		t.Errorf("SASL = %+v, want SCRAM-SHA-512 with user and secret", sasl)
+	}
+}
+
+func TestWithTLS(t *testing.T) {
+	cfg := exporterConfig(t, pipeline.SignalTraces,
+		WithSASL("PLAIN", "user", "secret"),
+		WithTLS("ca.pem", "cert.pem", "key.pem"),
+	)
+	tls := cfg.Authentication.TLS
+	if tls == nil {
+		t.Fatal("TLS not configured")
+	}
+	if tls.CAFile != "ca.pem" || tls.CertFile != "cert.pem" || tls.KeyFile != "key.pem" {
+		t.Errorf("TLS files = %q, %q, %q, want ca.pem, cert.pem, key.pem", tls.CAFile, tls.CertFile, tls.KeyFile)
+	}
+	if cfg.Authentication.SASL == nil {
+		t.Error("SASL configuration overwritten by TLS")
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/collector/confmap"
+)
+
+const kubernetesScheme = "k8s"
+
+// NewKubernetesProviderFactory returns a factory of confmap providers that
+// retrieve configuration from the files Kubernetes mounts in a pod, with the
+// k8s scheme, e.g. k8s:/etc/collex/pipeline.yaml. Use it as one of the
+// Providers of Factories, or pass it to ConfigFromURI.
+//
+// The key of a mounted ConfigMap is retrieved as YAML. The labels and
+// annotations files of a downwardAPI volume, lines of key="value", are
+// retrieved as a map, so they can be referred to by a configuration, e.g.
+// ${k8s:/etc/podinfo/labels}. Any other downwardAPI file, like the pod name,
+// is retrieved as its value.
+//
+// Kubernetes replaces all the files of a mounted volume at once when the
+// ConfigMap is updated. A ConfigWatcher with the k8s URI of the file reloads
+// the configuration of exporters on each update.
+func NewKubernetesProviderFactory() confmap.ProviderFactory {
+	return confmap.NewProviderFactory(func(confmap.ProviderSettings) confmap.Provider {
+		return kubernetesProvider{}
+	})
+}
+
+type kubernetesProvider struct{}
+
+func (kubernetesProvider) Retrieve(_ context.Context, uri string, _ confmap.WatcherFunc) (*confmap.Retrieved, error) {
+	path, ok := strings.CutPrefix(uri, kubernetesScheme+":")
+	if !ok {
+		return nil, fmt.Errorf("%q uri is not supported by %q provider", uri, kubernetesScheme)
+	}
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the file %v: %w", uri, err)
+	}
+	if m, ok := parseDownwardAPI(data); ok {
+		return confmap.NewRetrieved(m)
+	}
+	return confmap.NewRetrievedFromYAML(data)
+}
+
+func (kubernetesProvider) Scheme() string {
+	return kubernetesScheme
+}
+
+func (kubernetesProvider) Shutdown(context.Context) error {
+	return nil
+}
+
+// parseDownwardAPI returns the map of a downwardAPI labels or annotations
+// file, lines of key="value" with a quoted value. It returns false if data is
+// not in this format.
+func parseDownwardAPI(data []byte) (map[string]any, bool) {
+	m := make(map[string]any)
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		line := s.Text()
+		if line == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok || k == "" || !strings.HasPrefix(v, `"`) {
+			return nil, false
+		}
+		value, err := strconv.Unquote(v)
+		if err != nil {
+			return nil, false
+		}
+		m[k] = value
+	}
+	return m, s.Err() == nil && len(m) > 0
+}
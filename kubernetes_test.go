@@ -0,0 +1,97 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/MrAlias/collex"
+	"go.opentelemetry.io/collector/confmap"
+)
+
+// mountConfigMap writes data to the key of a ConfigMap volume mounted at dir
+// the way the kubelet does, replacing the ..data link to the files at once.
+func mountConfigMap(t *testing.T, dir, version, key, data string) {
+	t.Helper()
+	if err := os.Mkdir(filepath.Join(dir, version), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, version, key), data)
+	tmp := filepath.Join(dir, "..data_tmp")
+	if err := os.Symlink(version, tmp); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, key)
+	if _, err := os.Lstat(link); os.IsNotExist(err) {
+		if err := os.Symlink(filepath.Join("..data", key), link); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestKubernetesProviderConfigMap(t *testing.T) {
+	dir := t.TempDir()
+	uri := "k8s:" + filepath.Join(dir, "exporter.yaml")
+	ctx := context.Background()
+	var e endpoints
+	for _, endpoint := range []string{"a", "b"} {
+		mountConfigMap(t, dir, "..v_"+endpoint, "exporter.yaml", "endpoint: "+endpoint)
+		cfg, err := collex.ConfigFromURI(ctx, e.factory(), uri, collex.NewKubernetesProviderFactory())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := cfg.(*endpointConfig).Endpoint; got != endpoint {
+			t.Errorf("endpoint = %q, want %q", got, endpoint)
+		}
+	}
+}
+
+func TestKubernetesProviderDownwardAPI(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "labels"), "app=\"shop\"\ntier=\"web \\\"frontend\\\"\"\n")
+	writeFile(t, filepath.Join(dir, "name"), "shop-7d9f")
+
+	ctx := context.Background()
+	p := collex.NewKubernetesProviderFactory().Create(confmap.ProviderSettings{})
+	ret, err := p.Retrieve(ctx, "k8s:"+filepath.Join(dir, "labels"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ret.AsRaw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"app": "shop", "tier": `web "frontend"`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("labels = %v, want %v", got, want)
+	}
+
+	var e endpoints
+	data := []byte("endpoint: ${k8s:" + filepath.Join(dir, "name") + "}")
+	cfg, err := collex.ConfigFromYAML(e.factory(), data, collex.NewKubernetesProviderFactory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.(*endpointConfig).Endpoint; got != "shop-7d9f" {
+		t.Errorf("endpoint = %q, want shop-7d9f", got)
+	}
+}
@@ -0,0 +1,388 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/otel/sdk/trace"
+	api "go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+var (
+	errNoEndpoints      = errors.New("collex: no endpoints to load balance across")
+	errBalancerShutDown = errors.New("collex: load-balancing exporter shut down")
+)
+
+// Resolver resolves the endpoints a load-balancing exporter exports to.
+type Resolver interface {
+	// Endpoints returns the current endpoints.
+	Endpoints(ctx context.Context) ([]string, error)
+}
+
+// ResolverFunc is a function resolving the endpoints of a load-balancing
+// exporter, e.g. from the Kubernetes endpoints of a service.
+type ResolverFunc func(ctx context.Context) ([]string, error)
+
+// Endpoints returns f(ctx).
+func (f ResolverFunc) Endpoints(ctx context.Context) ([]string, error) {
+	return f(ctx)
+}
+
+// StaticResolver resolves a fixed list of endpoints.
+type StaticResolver []string
+
+// Endpoints returns r.
+func (r StaticResolver) Endpoints(context.Context) ([]string, error) {
+	return r, nil
+}
+
+// DNSResolver resolves the endpoints from the addresses of a hostname, e.g. a
+// headless Kubernetes service of the collectors of a tail-sampling tier.
+type DNSResolver struct {
+	// Hostname is the hostname resolved.
+	Hostname string
+	// Port is the port of the endpoints. If empty, the endpoints are the
+	// addresses.
+	Port string
+	// Resolver, if not nil, is the resolver used instead of
+	// net.DefaultResolver.
+	Resolver *net.Resolver
+}
+
+// Endpoints returns the addresses of r.Hostname joined with r.Port.
+func (r DNSResolver) Endpoints(ctx context.Context) ([]string, error) {
+	res := r.Resolver
+	if res == nil {
+		res = net.DefaultResolver
+	}
+	addrs, err := res.LookupHost(ctx, r.Hostname)
+	if err != nil {
+		return nil, err
+	}
+	if r.Port != "" {
+		for i, addr := range addrs {
+			addrs[i] = net.JoinHostPort(addr, r.Port)
+		}
+	}
+	return addrs, nil
+}
+
+// LoadBalancingConfig configures a load-balancing exporter.
+type LoadBalancingConfig struct {
+	// Resolver resolves the endpoints exported to.
+	Resolver Resolver
+	// Config returns the configuration of the exporter of endpoint. If it
+	// returns an error, the endpoint is not exported to. If it is nil, the
+	// factory default configuration is used.
+	Config func(endpoint string) (component.Config, error)
+	// Interval is the interval the endpoints are resolved at. If it is not
+	// positive, 30 seconds is used.
+	Interval time.Duration
+	// Clock, if not nil, is the Clock Interval is measured with instead of
+	// the system clock.
+	Clock Clock
+}
+
+// ringReplicas is the number of points of each endpoint on the hash ring,
+// spreading the traces evenly across the endpoints.
+const ringReplicas = 100
+
+// ring is a consistent hash ring of endpoints. When an endpoint is added or
+// removed, only the traces of about one endpoint move.
+type ring struct {
+	hashes    []uint64
+	endpoints []string
+}
+
+func newRing(endpoints []string) ring {
+	type point struct {
+		hash     uint64
+		endpoint string
+	}
+	points := make([]point, 0, len(endpoints)*ringReplicas)
+	for _, ep := range endpoints {
+		for i := range ringReplicas {
+			points = append(points, point{hash: hash([]byte(ep + "#" + strconv.Itoa(i))), endpoint: ep})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].hash < points[j].hash })
+
+	r := ring{hashes: make([]uint64, len(points)), endpoints: make([]string, len(points))}
+	for i, p := range points {
+		r.hashes[i], r.endpoints[i] = p.hash, p.endpoint
+	}
+	return r
+}
+
+func hash(b []byte) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write(b)
+	return h.Sum64()
+}
+
+// endpoint returns the endpoint of the trace id. The ring needs to have
+// endpoints.
+func (r ring) endpoint(id api.TraceID) string {
+	h := hash(id[:])
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.endpoints[i]
+}
+
+// minResolveRetry is the interval a failed resolution of the endpoints of a
+// load-balancing exporter is first retried after. It doubles with each
+// failure, up to the resolution interval.
+const minResolveRetry = time.Second
+
+// NewLoadBalancingSpanExporter returns a SpanExporter exporting the spans of
+// each trace to the same endpoint of those cfg.Resolver resolves, with an
+// exporter of f configured with cfg.Config for the endpoint, like the
+// load-balancing exporter of the collector. This allows an application to
+// export directly to a scaled-out tier of tail-sampling collectors.
+//
+// The endpoints are resolved in the background, every cfg.Interval. Exporters
+// are created for new endpoints and those of removed endpoints are shut down.
+// If the endpoints cannot be resolved, the previous ones are kept and the
+// resolution is retried with an exponential backoff. The exports wait for the
+// first resolution only.
+//
+// Shutting down the returned exporter stops the resolution and shuts down the
+// exporters of all the endpoints.
+func NewLoadBalancingSpanExporter(f *Factory, cfg LoadBalancingConfig) trace.SpanExporter {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 30 * time.Second
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	b := &balancer{
+		f:        f,
+		cfg:      cfg,
+		clock:    clockOrSystem(cfg.Clock),
+		log:      f.createCfg.Logger,
+		resolved: make(chan struct{}),
+		stop:     stop,
+		done:     make(chan struct{}),
+		exps:     make(map[string]trace.SpanExporter),
+	}
+	go b.run(ctx)
+	return b
+}
+
+type balancer struct {
+	f     *Factory
+	cfg   LoadBalancingConfig
+	clock Clock
+	log   *zap.Logger
+
+	// resolved is closed once the endpoints were first resolved, whether
+	// they could be or not.
+	resolved chan struct{}
+	// stop stops the resolution of the endpoints, done is closed once it
+	// stopped.
+	stop context.CancelFunc
+	done chan struct{}
+
+	// mu is held for reading by the exports, so the exporters of removed
+	// endpoints are only shut down once the exports to them are done.
+	mu   sync.RWMutex
+	ring ring
+	exps map[string]trace.SpanExporter
+	// resolveErr is the error of the last resolution, nil if it succeeded.
+	resolveErr error
+	closed     bool
+}
+
+// run resolves the endpoints of b every cfg.Interval, or sooner with an
+// exponential backoff after failures, until ctx is done.
+func (b *balancer) run(ctx context.Context) {
+	defer close(b.done)
+	retry := minResolveRetry
+	for first := true; ; first = false {
+		err := b.resolve(ctx)
+		if first {
+			close(b.resolved)
+		}
+		if errors.Is(err, errBalancerShutDown) {
+			return
+		}
+		wait := b.cfg.Interval
+		if err != nil {
+			b.log.Warn("Failed to resolve endpoints, using the previous ones", zap.Error(err))
+			wait = min(retry, wait)
+			retry *= 2
+		} else {
+			retry = minResolveRetry
+		}
+
+		t := b.clock.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C():
+		}
+	}
+}
+
+// resolve resolves the endpoints of b, updating the exporters.
+func (b *balancer) resolve(ctx context.Context) error {
+	endpoints, err := b.cfg.Resolver.Endpoints(ctx)
+	if err != nil {
+		err = fmt.Errorf("collex: resolving endpoints: %w", err)
+		b.mu.Lock()
+		b.resolveErr = err
+		b.mu.Unlock()
+		return err
+	}
+	slices.Sort(endpoints)
+	endpoints = slices.Compact(endpoints)
+
+	b.mu.RLock()
+	current := b.exps
+	b.mu.RUnlock()
+
+	exps := make(map[string]trace.SpanExporter, len(endpoints))
+	for _, ep := range endpoints {
+		if exp, ok := current[ep]; ok {
+			exps[ep] = exp
+			continue
+		}
+		exp, err := b.newExporter(ctx, ep)
+		if err != nil {
+			b.log.Warn("Failed to create exporter of endpoint", zap.String("endpoint", ep), zap.Error(err))
+			continue
+		}
+		exps[ep] = exp
+	}
+	eps := make([]string, 0, len(exps))
+	for ep := range exps {
+		eps = append(eps, ep)
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		for ep, exp := range exps {
+			if _, ok := current[ep]; !ok {
+				_ = exp.Shutdown(ctx)
+			}
+		}
+		return errBalancerShutDown
+	}
+	var stale []trace.SpanExporter
+	for ep, exp := range current {
+		if _, ok := exps[ep]; !ok {
+			stale = append(stale, exp)
+		}
+	}
+	b.ring, b.exps, b.resolveErr = newRing(eps), exps, nil
+	b.mu.Unlock()
+
+	for _, exp := range stale {
+		if err := exp.Shutdown(ctx); err != nil {
+			b.log.Warn("Failed to shut down exporter of removed endpoint", zap.Error(err))
+		}
+	}
+	return nil
+}
+
+func (b *balancer) newExporter(ctx context.Context, endpoint string) (trace.SpanExporter, error) {
+	var cfg component.Config
+	if b.cfg.Config != nil {
+		var err error
+		if cfg, err = b.cfg.Config(endpoint); err != nil {
+			return nil, err
+		}
+	}
+	exp, err := b.f.SpanExporter(ctx, cfg)
+	if err != nil && exp != nil {
+		if b.f.restart.Enabled {
+			// The exporter keeps trying to start its components.
+			b.log.Warn("Failed to start exporter of endpoint", zap.String("endpoint", endpoint), zap.Error(err))
+			return exp, nil
+		}
+		return nil, errors.Join(err, exp.Shutdown(ctx))
+	}
+	return exp, err
+}
+
+func (b *balancer) ExportSpans(ctx context.Context, spans []trace.ReadOnlySpan) error {
+	select {
+	case <-b.resolved:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return errBalancerShutDown
+	}
+	if len(b.exps) == 0 {
+		return errors.Join(errNoEndpoints, b.resolveErr)
+	}
+
+	var (
+		endpoints []string
+		parts     = make(map[string][]trace.ReadOnlySpan)
+	)
+	for _, s := range spans {
+		ep := b.ring.endpoint(s.SpanContext().TraceID())
+		if _, ok := parts[ep]; !ok {
+			endpoints = append(endpoints, ep)
+		}
+		parts[ep] = append(parts[ep], s)
+	}
+	return fanout(FanoutAll, len(endpoints), func(i int) error {
+		return b.exps[endpoints[i]].ExportSpans(ctx, parts[endpoints[i]])
+	})
+}
+
+func (b *balancer) Shutdown(ctx context.Context) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	exps := b.exps
+	b.exps = nil
+	b.mu.Unlock()
+
+	b.stop()
+	var err error
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	for _, exp := range exps {
+		err = errors.Join(err, exp.Shutdown(ctx))
+	}
+	return err
+}
@@ -0,0 +1,197 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MrAlias/collex"
+	"github.com/MrAlias/collex/collextest"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	api "go.opentelemetry.io/otel/trace"
+)
+
+func traceSpans(id byte, n int) []trace.ReadOnlySpan {
+	sc := api.NewSpanContext(api.SpanContextConfig{TraceID: api.TraceID{id, 1}})
+	stubs := make(tracetest.SpanStubs, n)
+	for i := range stubs {
+		stubs[i].Name, stubs[i].SpanContext = "span", sc
+	}
+	return stubs.Snapshots()
+}
+
+func TestLoadBalancingSpanExporter(t *testing.T) {
+	b := newTenantBackends()
+	f, err := collex.NewFactory(b.factory(), settings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		mu        sync.Mutex
+		endpoints = []string{"a:4317", "b:4317", "c:4317"}
+	)
+	clock := collextest.NewFakeClock(time.Now())
+	exp := collex.NewLoadBalancingSpanExporter(f, collex.LoadBalancingConfig{
+		Resolver: collex.ResolverFunc(func(context.Context) ([]string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return endpoints, nil
+		}),
+		Config: func(endpoint string) (component.Config, error) {
+			return &tenantConfig{Tenant: endpoint}, nil
+		},
+		Interval: time.Minute,
+		Clock:    clock,
+	})
+	ctx := context.Background()
+
+	// endpoint exports the spans of the trace id and returns the endpoint
+	// they were exported to.
+	endpoint := func(id byte) string {
+		t.Helper()
+		before := maps.Clone(b.spans)
+		if err := exp.ExportSpans(ctx, traceSpans(id, 2)); err != nil {
+			t.Fatal(err)
+		}
+		for ep, n := range b.spans {
+			if n-before[ep] == 2 {
+				return ep
+			}
+		}
+		t.Fatalf("spans of trace %d not exported to a single endpoint: %v", id, b.spans)
+		return ""
+	}
+
+	const traces = 30
+	first := make(map[byte]string)
+	used := make(map[string]bool)
+	for id := range byte(traces) {
+		first[id] = endpoint(id)
+		used[first[id]] = true
+		if again := endpoint(id); again != first[id] {
+			t.Errorf("trace %d exported to %s and then %s", id, first[id], again)
+		}
+	}
+	if len(used) != 3 {
+		t.Errorf("traces exported to %v, want all 3 endpoints", used)
+	}
+
+	mu.Lock()
+	endpoints = []string{"a:4317", "b:4317"}
+	mu.Unlock()
+	// Wait for the endpoints to be resolved again in the background.
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	clock.BlockUntil(1)
+	var moved int
+	for id := range byte(traces) {
+		ep := endpoint(id)
+		if ep != first[id] {
+			moved++
+			if first[id] != "c:4317" {
+				t.Errorf("trace %d moved from %s, only the traces of the removed endpoint should", id, first[id])
+			}
+		}
+	}
+	if moved == 0 {
+		t.Error("no trace of the removed endpoint moved")
+	}
+	if b.stopped["c:4317"] != 1 {
+		t.Errorf("exporter of the removed endpoint stopped %d times, want 1", b.stopped["c:4317"])
+	}
+
+	if err := exp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if b.stopped["a:4317"] != 1 || b.stopped["b:4317"] != 1 {
+		t.Errorf("got stopped %v, want all endpoints stopped", b.stopped)
+	}
+}
+
+func TestLoadBalancingNoEndpoints(t *testing.T) {
+	b := newTenantBackends()
+	f, err := collex.NewFactory(b.factory(), settings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := collex.NewLoadBalancingSpanExporter(f, collex.LoadBalancingConfig{Resolver: collex.StaticResolver{}})
+	if err := exp.ExportSpans(context.Background(), spans(1)); err == nil {
+		t.Error("export without endpoints succeeded")
+	}
+}
+
+func TestLoadBalancingResolveRetry(t *testing.T) {
+	b := newTenantBackends()
+	f, err := collex.NewFactory(b.factory(), settings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		mu    sync.Mutex
+		calls int
+		hang  = make(chan struct{})
+	)
+	clock := collextest.NewFakeClock(time.Now())
+	exp := collex.NewLoadBalancingSpanExporter(f, collex.LoadBalancingConfig{
+		Resolver: collex.ResolverFunc(func(ctx context.Context) ([]string, error) {
+			mu.Lock()
+			calls++
+			n := calls
+			mu.Unlock()
+			switch n {
+			case 1:
+				return nil, errBroken
+			case 2:
+				return []string{"a:4317"}, nil
+			}
+			select {
+			case <-hang:
+			case <-ctx.Done():
+			}
+			return nil, ctx.Err()
+		}),
+		Config: func(endpoint string) (component.Config, error) {
+			return &tenantConfig{Tenant: endpoint}, nil
+		},
+		Clock: clock,
+	})
+	ctx := context.Background()
+	defer func() { _ = exp.Shutdown(ctx) }()
+	defer close(hang)
+
+	if err := exp.ExportSpans(ctx, spans(1)); !errors.Is(err, errBroken) {
+		t.Fatalf("export error = %v, want the resolution error", err)
+	}
+	// The failed resolution is retried long before the 30s interval.
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	clock.BlockUntil(1)
+	if err := exp.ExportSpans(ctx, spans(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Exports do not wait for a hung resolution.
+	clock.Advance(30 * time.Second)
+	if err := exp.ExportSpans(ctx, spans(1)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package loadbalancing provides OpenTelemetry Go exporters load balancing
+// OTLP telemetry across a set of backends, e.g. the collectors of a
+// tail-sampling tier, with the load-balancing exporter of the collector
+// contrib repository, configured with Go options instead of a collector
+// configuration.
+//
+// The backends are resolved from a static list, the addresses of a DNS
+// hostname, or the endpoints of a Kubernetes service, and are updated as they
+// change. All the telemetry with the same routing key, e.g. all the spans of
+// a trace, is exported to the same backend, asynchronously from the sending
+// queue of the OTLP exporter of the backend.
+package loadbalancing // import "github.com/MrAlias/collex/loadbalancing"
+
+import (
+	"context"
+	"time"
+
+	"github.com/MrAlias/collex"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Option configures the exporters.
+type Option interface {
+	apply(config) config
+}
+
+type config struct {
+	// conf is the collector configuration of the load-balancing exporter.
+	conf map[string]any
+	set  *exporter.Settings
+	opts []collex.Option
+}
+
+type optionFunc func(config) config
+
+func (fn optionFunc) apply(c config) config {
+	return fn(c)
+}
+
+// block returns the block of the configuration of c at the path of keys,
+// adding the blocks missing.
+func (c config) block(keys ...string) map[string]any {
+	m := c.conf
+	for _, key := range keys {
+		b, ok := m[key].(map[string]any)
+		if !ok {
+			b = make(map[string]any)
+			m[key] = b
+		}
+		m = b
+	}
+	return m
+}
+
+// WithStaticResolver returns an Option that load balances across the fixed
+// backends at endpoints, e.g. collector-0:4317.
+func WithStaticResolver(endpoints ...string) Option {
+	return optionFunc(func(c config) config {
+		c.block("resolver", "static")["hostnames"] = endpoints
+		return c
+	})
+}
+
+// WithDNSResolver returns an Option that load balances across the addresses
+// of hostname, e.g. a headless Kubernetes service, with port. The port of
+// OTLP/gRPC, 4317, is used if port is empty.
+//
+// The addresses are resolved again every interval, or every 5 seconds if
+// interval is zero.
+func WithDNSResolver(hostname, port string, interval time.Duration) Option {
+	return optionFunc(func(c config) config {
+		dns := c.block("resolver", "dns")
+		dns["hostname"] = hostname
+		if port != "" {
+			dns["port"] = port
+		}
+		if interval > 0 {
+			dns["interval"] = interval
+		}
+		return c
+	})
+}
+
+// WithKubernetesResolver returns an Option that load balances across the
+// endpoints of the Kubernetes service, e.g. collectors.observability for the
+// collectors service of the observability namespace, with each of ports. The
+// port of OTLP/gRPC, 4317, is used if ports is empty.
+//
+// The endpoints are watched with the Kubernetes API, which the exporters must
+// be running in a pod of the cluster, with the permission to list and watch
+// the endpoints of the service, to access.
+func WithKubernetesResolver(service string, ports ...int32) Option {
+	return optionFunc(func(c config) config {
+		k8s := c.block("resolver", "k8s")
+		k8s["service"] = service
+		if len(ports) > 0 {
+			k8s["ports"] = ports
+		}
+		return c
+	})
+}
+
+// WithRoutingKey returns an Option that sets the key the telemetry is routed
+// to the backends by. Spans are routed by traceID, the default, or service.
+// Metrics are routed by service, the default, resource, metric, or streamID.
+// Log records are always routed by their trace ID.
+func WithRoutingKey(key string) Option {
+	return optionFunc(func(c config) config {
+		c.conf["routing_key"] = key
+		return c
+	})
+}
+
+// WithInsecure returns an Option that exports to the backends without TLS.
+func WithInsecure() Option {
+	return optionFunc(func(c config) config {
+		c.block("protocol", "otlp", "tls")["insecure"] = true
+		return c
+	})
+}
+
+// WithHeaders returns an Option that sets the headers sent to the backends
+// with each export.
+func WithHeaders(headers map[string]string) Option {
+	return optionFunc(func(c config) config {
+		h := make(map[string]any, len(headers))
+		for k, v := range headers {
+			h[k] = v
+		}
+		c.block("protocol", "otlp")["headers"] = h
+		return c
+	})
+}
+
+// WithSettings returns an Option that sets the settings the load-balancing
+// exporter is created with, e.g. its logger. Default settings are used
+// otherwise, like with collex.NewFactory.
+func WithSettings(set *exporter.Settings) Option {
+	return optionFunc(func(c config) config {
+		c.set = set
+		return c
+	})
+}
+
+// WithFactoryOptions returns an Option that configures the collex.Factory
+// wrapping the load-balancing exporter with opts, e.g. collex.WithRetry.
+func WithFactoryOptions(opts ...collex.Option) Option {
+	return optionFunc(func(c config) config {
+		c.opts = append(c.opts, opts...)
+		return c
+	})
+}
+
+// newFactory returns the Factory of the load-balancing exporter and its
+// configuration set by opts.
+func newFactory(opts []Option) (*collex.Factory, component.Config, error) {
+	c := config{conf: make(map[string]any)}
+	for _, opt := range opts {
+		c = opt.apply(c)
+	}
+	lb := loadbalancingexporter.NewFactory()
+	cfg, err := collex.ConfigFromMap(lb, c.conf)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := collex.NewFactory(lb, c.set, c.opts...)
+	return f, cfg, err
+}
+
+// NewSpanExporter returns a SpanExporter load balancing spans across the
+// backends.
+func NewSpanExporter(ctx context.Context, opts ...Option) (trace.SpanExporter, error) {
+	f, cfg, err := newFactory(opts)
+	if err != nil {
+		return nil, err
+	}
+	return f.SpanExporter(ctx, cfg)
+}
+
+// NewMetricExporter returns a metric Exporter load balancing metrics across
+// the backends.
+func NewMetricExporter(ctx context.Context, opts ...Option) (metric.Exporter, error) {
+	f, cfg, err := newFactory(opts)
+	if err != nil {
+		return nil, err
+	}
+	return f.MetricExporter(ctx, cfg)
+}
+
+// NewLogExporter returns a log Exporter load balancing log records across the
+// backends.
+func NewLogExporter(ctx context.Context, opts ...Option) (log.Exporter, error) {
+	f, cfg, err := newFactory(opts)
+	if err != nil {
+		return nil, err
+	}
+	return f.LogExporter(ctx, cfg)
+}
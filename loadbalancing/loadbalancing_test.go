@@ -0,0 +1,158 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadbalancing
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/MrAlias/collex/collextest"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	api "go.opentelemetry.io/otel/trace"
+)
+
+func exporterConfig(t *testing.T, opts ...Option) *loadbalancingexporter.Config {
+	t.Helper()
+	_, cfg, err := newFactory(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg.(*loadbalancingexporter.Config)
+}
+
+func TestWithStaticResolver(t *testing.T) {
+	cfg := exporterConfig(t, WithStaticResolver("collector-0:4317", "collector-1:4317"))
+	if r := cfg.Resolver.Static; r == nil || !slices.Equal(r.Hostnames, []string{"collector-0:4317", "collector-1:4317"}) {
+		t.Errorf("static resolver = %+v, want collector-0:4317, collector-1:4317", r)
+	}
+}
+
+func TestWithDNSResolver(t *testing.T) {
+	cfg := exporterConfig(t, WithDNSResolver("collectors.observability", "4318", time.Minute))
+	want := loadbalancingexporter.DNSResolver{Hostname: "collectors.observability", Port: "4318", Interval: time.Minute}
+	if r := cfg.Resolver.DNS; r == nil || *r != want {
+		t.Errorf("DNS resolver = %+v, want %+v", r, want)
+	}
+
+	cfg = exporterConfig(t, WithDNSResolver("collectors", "", 0))
+	if r := cfg.Resolver.DNS; r == nil || r.Port != "" || r.Interval != 0 {
+		t.Errorf("DNS resolver = %+v, want default port and interval", r)
+	}
+}
+
+func TestWithKubernetesResolver(t *testing.T) {
+	cfg := exporterConfig(t, WithKubernetesResolver("collectors.observability", 4317, 55690))
+	r := cfg.Resolver.K8sSvc
+	if r == nil || r.Service != "collectors.observability" || !slices.Equal(r.Ports, []int32{4317, 55690}) {
+		t.Errorf("k8s resolver = %+v, want collectors.observability, ports 4317, 55690", r)
+	}
+}
+
+func TestResolverRequired(t *testing.T) {
+	if _, err := NewSpanExporter(context.Background()); err == nil {
+		t.Error("no error without a resolver")
+	}
+}
+
+func TestOptions(t *testing.T) {
+	cfg := exporterConfig(t,
+		WithStaticResolver("collector:4317"),
+		WithRoutingKey("service"),
+		WithInsecure(),
+		WithHeaders(map[string]string{"tenant": "a"}),
+	)
+	if cfg.RoutingKey != "service" {
+		t.Errorf("routing key = %q, want service", cfg.RoutingKey)
+	}
+	otlp := cfg.Protocol.OTLP.ClientConfig
+	if !otlp.TLSSetting.Insecure {
+		t.Error("TLS not disabled")
+	}
+	if got := otlp.Headers["tenant"]; got != "a" {
+		t.Errorf("tenant header = %q, want a", got)
+	}
+}
+
+// traceSpans returns n spans of each of traces.
+func traceSpans(traces, n int) []trace.ReadOnlySpan {
+	var spans []trace.ReadOnlySpan
+	for i := range traces {
+		for j := range n {
+			spans = append(spans, tracetest.SpanStub{
+				Name: fmt.Sprintf("span-%d-%d", i, j),
+				SpanContext: api.NewSpanContext(api.SpanContextConfig{
+					TraceID: api.TraceID{byte(i + 1)},
+					SpanID:  api.SpanID{byte(i + 1), byte(j + 1)},
+				}),
+			}.Snapshot())
+		}
+	}
+	return spans
+}
+
+func TestSpanExporter(t *testing.T) {
+	backends := []*collextest.OTLPServer{collextest.NewOTLPServer(t), collextest.NewOTLPServer(t)}
+
+	ctx := context.Background()
+	exp, err := NewSpanExporter(ctx,
+		WithStaticResolver(backends[0].GRPCEndpoint, backends[1].GRPCEndpoint),
+		WithInsecure(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = exp.Shutdown(ctx) })
+
+	spans := traceSpans(8, 3)
+	if err := exp.ExportSpans(ctx, spans); err != nil {
+		t.Fatal(err)
+	}
+
+	// The backends are exported to asynchronously, from the sending queues
+	// of their OTLP exporters.
+	collextest.Eventually(t, 5*time.Second, func(context.Context) error {
+		var n int
+		// backend is the index of the backend of each trace.
+		backend := make(map[string]int)
+		for i, b := range backends {
+			for _, td := range b.Traces() {
+				n += td.SpanCount()
+				rss := td.ResourceSpans()
+				for j := range rss.Len() {
+					sss := rss.At(j).ScopeSpans()
+					for k := range sss.Len() {
+						ss := sss.At(k).Spans()
+						for l := range ss.Len() {
+							id := ss.At(l).TraceID().String()
+							if got, ok := backend[id]; ok && got != i {
+								t.Errorf("trace %s exported to backends %d and %d", id, got, i)
+							}
+							backend[id] = i
+						}
+					}
+				}
+			}
+		}
+		if n != len(spans) {
+			return fmt.Errorf("%d spans exported, want %d", n, len(spans))
+		}
+		return nil
+	})
+}
@@ -0,0 +1,85 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"context"
+	"errors"
+
+	"github.com/MrAlias/collex/transmute"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pipeline"
+	"go.opentelemetry.io/otel/sdk/log"
+)
+
+var errNoLogs = errors.New("collex: no logs pipelines")
+
+type logExporter struct {
+	// next is the consumer the exported log records are sent to.
+	next consumer.Logs
+	// export sends the exports to next through the middleware.
+	export Export
+	// sup owns the components the exporter sends to. It is nil if the
+	// components are owned by a Pipeline.
+	sup *supervisor
+	// metadata is the client.Info metadata of the exports with none.
+	metadata map[string][]string
+	// tracer traces the exports. It is nil if they are not traced.
+	tracer *exportTracer
+	// errs reports the failed exports. It is nil if they are not reported.
+	errs *exportErrors
+	// shed sheds exports under memory pressure. It is nil if none are.
+	shed *shedder
+	// panics recovers the panics of the exports.
+	panics *panicGuard
+}
+
+func (e *logExporter) Export(ctx context.Context, records []log.Record) error {
+	if e.next == nil {
+		return errNoLogs
+	}
+	if kept := e.shed.logs(ctx, records); len(kept) < len(records) {
+		if len(kept) == 0 {
+			return nil
+		}
+		records = kept
+	}
+	ctx, end := e.tracer.start(ctx, len(records))
+	err := e.panics.do(ctx, func() error {
+		b := Batch{Signal: pipeline.SignalLogs, Logs: transmute.Logs(records)}
+		return e.export(withDefaultMetadata(ctx, e.metadata), b)
+	})
+	end(err)
+	e.errs.report(err, len(records))
+	return err
+}
+
+func (e *logExporter) consume(ctx context.Context, b Batch) error {
+	return e.next.ConsumeLogs(ctx, b.Logs)
+}
+
+// ForceFlush does nothing, the exporter holds no state.
+func (e *logExporter) ForceFlush(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// Shutdown shuts down the components owned by the exporter. It is safe to
+// call Shutdown multiple times.
+func (e *logExporter) Shutdown(ctx context.Context) error {
+	if e.sup == nil {
+		return nil
+	}
+	return e.sup.shutdown(ctx)
+}
@@ -0,0 +1,160 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/MrAlias/collex"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/processor"
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+func TestPipelineLogs(t *testing.T) {
+	ctx := context.Background()
+	s := new(sink)
+	p, err := collex.NewPipeline().
+		WithSettings(*settings()).
+		WithExporter(s.factory(), nil).
+		Build(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lp := sdklog.NewLoggerProvider(sdklog.WithProcessor(sdklog.NewSimpleProcessor(p.LogExporter())))
+	var r log.Record
+	r.SetSeverity(log.SeverityWarn)
+	r.SetBody(log.StringValue("disk almost full"))
+	r.AddAttributes(log.Int("usage", 95))
+	lp.Logger("test").Emit(ctx, r)
+	if err := lp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.logs) != 1 {
+		t.Fatalf("got %d logs, want 1", len(s.logs))
+	}
+	sl := s.logs[0].ResourceLogs().At(0).ScopeLogs().At(0)
+	if got := sl.Scope().Name(); got != "test" {
+		t.Errorf("scope name = %q, want %q", got, "test")
+	}
+	lr := sl.LogRecords().At(0)
+	if got := lr.SeverityNumber(); got != plog.SeverityNumberWarn {
+		t.Errorf("severity = %s, want %s", got, plog.SeverityNumberWarn)
+	}
+	if got := lr.Body().Str(); got != "disk almost full" {
+		t.Errorf("body = %q, want %q", got, "disk almost full")
+	}
+	if v, ok := lr.Attributes().Get("usage"); !ok || v.Int() != 95 {
+		t.Errorf("usage attribute = %v, want 95", v.AsRaw())
+	}
+}
+
+// parseJSON is a collector processor that parses JSON log bodies into
+// attributes and sets the severity from the "level" attribute.
+func parseJSON() processor.Factory {
+	severities := map[string]plog.SeverityNumber{
+		"info":  plog.SeverityNumberInfo,
+		"error": plog.SeverityNumberError,
+	}
+	return processor.NewFactory(
+		component.MustNewType("parse_json"),
+		createEmptyConfig,
+		processor.WithLogs(func(_ context.Context, _ processor.Settings, _ component.Config, next consumer.Logs) (processor.Logs, error) {
+			c, err := consumer.NewLogs(func(ctx context.Context, ld plog.Logs) error {
+				rls := ld.ResourceLogs()
+				for i := 0; i < rls.Len(); i++ {
+					sls := rls.At(i).ScopeLogs()
+					for j := 0; j < sls.Len(); j++ {
+						lrs := sls.At(j).LogRecords()
+						for k := 0; k < lrs.Len(); k++ {
+							lr := lrs.At(k)
+							var body map[string]any
+							if err := json.Unmarshal([]byte(lr.Body().Str()), &body); err != nil {
+								continue
+							}
+							if err := lr.Attributes().FromRaw(body); err != nil {
+								return err
+							}
+							if level, ok := lr.Attributes().Get("level"); ok {
+								lr.SetSeverityNumber(severities[level.Str()])
+								lr.SetSeverityText(level.Str())
+							}
+						}
+					}
+				}
+				return next.ConsumeLogs(ctx, ld)
+			}, consumer.WithCapabilities(consumer.Capabilities{MutatesData: true}))
+			return logsComponent{Logs: c}, err
+		}, component.StabilityLevelDevelopment),
+	)
+}
+
+func TestPipelineFromYAMLLogsProcessor(t *testing.T) {
+	const conf = `
+processors:
+  parse_json:
+exporters:
+  sink:
+service:
+  pipelines:
+    logs:
+      processors: [parse_json]
+      exporters: [sink]
+`
+	var s sink
+	expF, procF := s.factory(), parseJSON()
+	factories := collex.Factories{
+		Processors: map[component.Type]processor.Factory{procF.Type(): procF},
+		Exporters:  map[component.Type]exporter.Factory{expF.Type(): expF},
+	}
+
+	ctx := context.Background()
+	p, err := collex.PipelineFromYAML(ctx, factories, []byte(conf), settings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lp := sdklog.NewLoggerProvider(sdklog.WithProcessor(sdklog.NewSimpleProcessor(p.LogExporter())))
+	var r log.Record
+	r.SetBody(log.StringValue(`{"level":"error","user":"alice"}`))
+	lp.Logger("test").Emit(ctx, r)
+	if err := lp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.logs) != 1 {
+		t.Fatalf("exporter received %d exports, want 1", len(s.logs))
+	}
+	lr := s.logs[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0)
+	if got := lr.SeverityNumber(); got != plog.SeverityNumberError {
+		t.Errorf("severity = %s, want %s", got, plog.SeverityNumberError)
+	}
+	if v, ok := lr.Attributes().Get("user"); !ok || v.Str() != "alice" {
+		t.Errorf("user attribute = %v, want alice", v.AsRaw())
+	}
+}
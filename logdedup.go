@@ -0,0 +1,135 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// dedupLogger returns log logging each distinct error once per window. The
+// repeats are suppressed and counted, the next entry logged for the error
+// after the window has the number suppressed.
+func dedupLogger(log *zap.Logger, window time.Duration, clock Clock) *zap.Logger {
+	return log.WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return &dedupCore{Core: c, window: window, clock: clock, seen: &dedupSeen{}}
+	}))
+}
+
+// dedupCore is a zapcore.Core deduplicating the entries with an error field,
+// e.g. those logged for each failed export while a backend is down. Entries
+// without an error are all written.
+type dedupCore struct {
+	zapcore.Core
+	window time.Duration
+	clock  Clock
+	// seen is shared by the cores derived from the core with With.
+	seen *dedupSeen
+}
+
+// dedupKey identifies identical entries.
+type dedupKey struct {
+	level       zapcore.Level
+	logger, msg string
+	err         string
+}
+
+// dedupWindow is the window an entry was last written in.
+type dedupWindow struct {
+	start      time.Time
+	suppressed int
+}
+
+type dedupSeen struct {
+	mu      sync.Mutex
+	windows map[dedupKey]*dedupWindow
+}
+
+func (c *dedupCore) With(fields []zapcore.Field) zapcore.Core {
+	return &dedupCore{Core: c.Core.With(fields), window: c.window, clock: c.clock, seen: c.seen}
+}
+
+func (c *dedupCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *dedupCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	err, ok := errorField(fields)
+	if !ok {
+		return c.Core.Write(ent, fields)
+	}
+	suppressed, write := c.seen.write(dedupKey{
+		level:  ent.Level,
+		logger: ent.LoggerName,
+		msg:    ent.Message,
+		err:    err,
+	}, c.clock.Now(), c.window)
+	if !write {
+		return nil
+	}
+	if suppressed > 0 {
+		fields = append(fields[:len(fields):len(fields)],
+			zap.Int("suppressed", suppressed),
+			zap.Duration("suppressed_window", c.window),
+		)
+	}
+	return c.Core.Write(ent, fields)
+}
+
+// write returns whether the entry with key is written at now, and the number
+// of identical entries suppressed in the previous window if it is. Windows
+// that ended are dropped when a new one starts.
+func (s *dedupSeen) write(key dedupKey, now time.Time, window time.Duration) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if w, ok := s.windows[key]; ok && now.Sub(w.start) < window {
+		w.suppressed++
+		return 0, false
+	}
+
+	var suppressed int
+	if w, ok := s.windows[key]; ok {
+		suppressed = w.suppressed
+	}
+	for k, w := range s.windows {
+		if now.Sub(w.start) >= window {
+			delete(s.windows, k)
+		}
+	}
+	if s.windows == nil {
+		s.windows = make(map[dedupKey]*dedupWindow)
+	}
+	s.windows[key] = &dedupWindow{start: now}
+	return suppressed, true
+}
+
+// errorField returns the message of the error field of fields, if any.
+func errorField(fields []zapcore.Field) (string, bool) {
+	for _, f := range fields {
+		if f.Type != zapcore.ErrorType {
+			continue
+		}
+		if err, ok := f.Interface.(error); ok {
+			return err.Error(), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,85 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/MrAlias/collex"
+	"github.com/MrAlias/collex/collextest"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// noisy returns a collector exporter failing each export and logging its
+// error, like an exporter whose backend is down.
+func noisy() exporter.Factory {
+	return exporter.NewFactory(
+		component.MustNewType("noisy"),
+		createEmptyConfig,
+		exporter.WithTraces(func(_ context.Context, set exporter.Settings, _ component.Config) (exporter.Traces, error) {
+			c, err := consumer.NewTraces(func(context.Context, ptrace.Traces) error {
+				set.Logger.Error("Exporting failed", zap.Error(errBroken))
+				set.Logger.Info("Exported batch")
+				return errBroken
+			})
+			return tracesComponent{Traces: c}, err
+		}, component.StabilityLevelDevelopment),
+	)
+}
+
+func TestWithLogDedup(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	set := settings()
+	set.Logger = zap.New(core)
+	clock := collextest.NewFakeClock(time.Now())
+	f, err := collex.NewFactory(noisy(), set, collex.WithLogDedup(time.Minute), collex.WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = exp.Shutdown(ctx) }()
+
+	for range 5 {
+		_ = exp.ExportSpans(ctx, spans(1))
+	}
+	if n := logs.FilterMessage("Exporting failed").Len(); n != 1 {
+		t.Errorf("logged %d identical errors in a window, want 1", n)
+	}
+	if n := logs.FilterMessage("Exported batch").Len(); n != 5 {
+		t.Errorf("logged %d entries without an error, want all 5", n)
+	}
+
+	clock.Advance(time.Minute)
+	_ = exp.ExportSpans(ctx, spans(1))
+	errs := logs.FilterMessage("Exporting failed").All()
+	if len(errs) != 2 {
+		t.Fatalf("logged %d errors, want 2 once the window passed", len(errs))
+	}
+	if got := errs[1].ContextMap()["suppressed"]; got != int64(4) {
+		t.Errorf("suppressed = %v, want 4", got)
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"net/http"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// logLevel is the level of the logger of the default Settings.
+var logLevel = zap.NewAtomicLevelAt(zap.InfoLevel)
+
+// SetLogLevel sets the level of the logger of the default Settings, those of
+// the Factories and Pipelines created without Settings. It takes effect for
+// the components already running, e.g. to debug an exporter in production
+// temporarily without restarting the application.
+func SetLogLevel(l zapcore.Level) {
+	logLevel.SetLevel(l)
+}
+
+// LogLevel returns the level of the logger of the default Settings.
+func LogLevel() zapcore.Level {
+	return logLevel.Level()
+}
+
+// LogLevelHandler returns an http.Handler serving the level of the logger of
+// the default Settings. A GET request returns the level as JSON, e.g.
+// {"level":"info"}, and a PUT request with the same body sets it.
+func LogLevelHandler() http.Handler {
+	return logLevel
+}
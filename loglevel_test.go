@@ -0,0 +1,56 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MrAlias/collex"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestLogLevel(t *testing.T) {
+	t.Cleanup(func() { collex.SetLogLevel(zapcore.InfoLevel) })
+	if got := collex.LogLevel(); got != zapcore.InfoLevel {
+		t.Errorf("LogLevel = %s, want %s by default", got, zapcore.InfoLevel)
+	}
+	collex.SetLogLevel(zapcore.DebugLevel)
+	if got := collex.LogLevel(); got != zapcore.DebugLevel {
+		t.Errorf("LogLevel = %s, want %s once set", got, zapcore.DebugLevel)
+	}
+}
+
+func TestLogLevelHandler(t *testing.T) {
+	t.Cleanup(func() { collex.SetLogLevel(zapcore.InfoLevel) })
+	h := collex.LogLevelHandler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/loglevel", strings.NewReader(`{"level":"debug"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if got := collex.LogLevel(); got != zapcore.DebugLevel {
+		t.Errorf("LogLevel = %s, want %s once PUT", got, zapcore.DebugLevel)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/loglevel", nil))
+	if got := strings.TrimSpace(rec.Body.String()); got != `{"level":"debug"}` {
+		t.Errorf("GET = %s, want the debug level", got)
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package loki provides an OpenTelemetry Go log exporter sending log records
+// to Grafana Loki with the Loki exporter of the collector contrib repository,
+// configured with Go options instead of a collector configuration.
+//
+// The factory of the Loki exporter is passed to NewLogExporter instead of
+// being imported by this package. The exporter is deprecated in favor of the
+// native OTLP ingestion of Loki and is not released with every version of the
+// collector, so the application picks the version matching its collector
+// dependencies.
+package loki // import "github.com/MrAlias/collex/loki"
+
+import (
+	"context"
+	"strings"
+
+	"github.com/MrAlias/collex"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/otel/sdk/log"
+)
+
+// The attributes the Loki exporter reads its label mapping and line format
+// from.
+const (
+	resourceLabelsHint  = "loki.resource.labels"
+	attributeLabelsHint = "loki.attribute.labels"
+	formatHint          = "loki.format"
+)
+
+// Option configures the exporter.
+type Option interface {
+	apply(config) config
+}
+
+type config struct {
+	// conf is the collector configuration of the Loki exporter.
+	conf map[string]any
+	// resourceLabels and attributeLabels are the keys of the resource and log
+	// record attributes mapped to Loki labels.
+	resourceLabels  []string
+	attributeLabels []string
+	// format is the format of the log lines, if set.
+	format string
+	set    *exporter.Settings
+	opts   []collex.Option
+}
+
+type optionFunc func(config) config
+
+func (fn optionFunc) apply(c config) config {
+	return fn(c)
+}
+
+// headers returns the HTTP headers of the configuration of c.
+func (c config) headers() map[string]any {
+	h, ok := c.conf["headers"].(map[string]any)
+	if !ok {
+		h = make(map[string]any)
+		c.conf["headers"] = h
+	}
+	return h
+}
+
+// WithEndpoint returns an Option that sets the URL of the Loki push API,
+// e.g. http://loki:3100/loki/api/v1/push.
+func WithEndpoint(url string) Option {
+	return optionFunc(func(c config) config {
+		c.conf["endpoint"] = url
+		return c
+	})
+}
+
+// WithTenant returns an Option that sets the tenant the log records are
+// pushed to in a multi-tenant Loki.
+func WithTenant(id string) Option {
+	return optionFunc(func(c config) config {
+		c.headers()["X-Scope-OrgID"] = id
+		return c
+	})
+}
+
+// WithHeaders returns an Option that adds headers to the requests sent to
+// Loki.
+func WithHeaders(headers map[string]string) Option {
+	return optionFunc(func(c config) config {
+		h := c.headers()
+		for k, v := range headers {
+			h[k] = v
+		}
+		return c
+	})
+}
+
+// WithAuthenticator returns an Option that authenticates the requests sent to
+// Loki with the authenticator extension ext, e.g. the basicauth extension of
+// the collector contrib repository. The extension is added to the
+// collex.Factory wrapping the exporter.
+func WithAuthenticator(ext collex.Extension) Option {
+	return optionFunc(func(c config) config {
+		id := component.NewIDWithName(ext.Factory.Type(), ext.Name)
+		c.conf["auth"] = map[string]any{"authenticator": id.String()}
+		c.opts = append(c.opts, collex.WithExtensions(ext))
+		return c
+	})
+}
+
+// WithResourceLabels returns an Option that maps the resource attributes with
+// keys to Loki labels, e.g. service.name. Only the exporter, job, instance,
+// and level labels are set by default.
+func WithResourceLabels(keys ...string) Option {
+	return optionFunc(func(c config) config {
+		c.resourceLabels = append(c.resourceLabels, keys...)
+		return c
+	})
+}
+
+// WithAttributeLabels returns an Option that maps the log record attributes
+// with keys to Loki labels. Attributes with many distinct values, e.g. user
+// IDs, should not be mapped since each label value creates a stream.
+func WithAttributeLabels(keys ...string) Option {
+	return optionFunc(func(c config) config {
+		c.attributeLabels = append(c.attributeLabels, keys...)
+		return c
+	})
+}
+
+// WithFormat returns an Option that sets the format of the log lines, json,
+// logfmt, or raw. Log records are pushed as JSON by default.
+func WithFormat(format string) Option {
+	return optionFunc(func(c config) config {
+		c.format = format
+		return c
+	})
+}
+
+// WithSettings returns an Option that sets the settings the Loki exporter is
+// created with, e.g. its logger. Default settings are used otherwise, like
+// with collex.NewFactory.
+func WithSettings(set *exporter.Settings) Option {
+	return optionFunc(func(c config) config {
+		c.set = set
+		return c
+	})
+}
+
+// WithFactoryOptions returns an Option that configures the collex.Factory
+// wrapping the Loki exporter with opts, e.g. collex.WithRetry.
+func WithFactoryOptions(opts ...collex.Option) Option {
+	return optionFunc(func(c config) config {
+		c.opts = append(c.opts, opts...)
+		return c
+	})
+}
+
+// hint adds keys to the comma-separated list of the hint attribute of m,
+// keeping the keys already set in it.
+func hint(m pcommon.Map, name string, keys []string) {
+	if v, ok := m.Get(name); ok && v.Str() != "" {
+		keys = append([]string{v.Str()}, keys...)
+	}
+	m.PutStr(name, strings.Join(keys, ","))
+}
+
+// hints returns the Middleware setting the attributes the Loki exporter maps
+// labels and formats lines with on the exported log records.
+func (c config) hints() collex.Middleware {
+	return func(next collex.Export) collex.Export {
+		return func(ctx context.Context, b collex.Batch) error {
+			rls := b.Logs.ResourceLogs()
+			for i := 0; i < rls.Len(); i++ {
+				rl := rls.At(i)
+				if len(c.resourceLabels) > 0 {
+					hint(rl.Resource().Attributes(), resourceLabelsHint, c.resourceLabels)
+				}
+				if c.format != "" {
+					rl.Resource().Attributes().PutStr(formatHint, c.format)
+				}
+				if len(c.attributeLabels) == 0 {
+					continue
+				}
+				sls := rl.ScopeLogs()
+				for j := 0; j < sls.Len(); j++ {
+					lrs := sls.At(j).LogRecords()
+					for k := 0; k < lrs.Len(); k++ {
+						hint(lrs.At(k).Attributes(), attributeLabelsHint, c.attributeLabels)
+					}
+				}
+			}
+			return next(ctx, b)
+		}
+	}
+}
+
+// NewLogExporter returns a log Exporter sending log records to Loki with the
+// Loki exporter of f, e.g. lokiexporter.NewFactory().
+func NewLogExporter(ctx context.Context, f exporter.Factory, opts ...Option) (log.Exporter, error) {
+	c := config{conf: make(map[string]any)}
+	for _, opt := range opts {
+		c = opt.apply(c)
+	}
+	cfg, err := collex.ConfigFromMap(f, c.conf)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.resourceLabels) > 0 || len(c.attributeLabels) > 0 || c.format != "" {
+		// Prepended, so the other middleware export the records with the hints.
+		c.opts = append([]collex.Option{collex.WithMiddleware(c.hints())}, c.opts...)
+	}
+	cf, err := collex.NewFactory(f, c.set, c.opts...)
+	if err != nil {
+		return nil, err
+	}
+	return cf.LogExporter(ctx, cfg)
+}
@@ -0,0 +1,138 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loki_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/MrAlias/collex/loki"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// lokiConfig has the configuration keys of the Loki exporter the options set.
+type lokiConfig struct {
+	Endpoint string            `mapstructure:"endpoint"`
+	Headers  map[string]string `mapstructure:"headers"`
+}
+
+// sink is a stand-in for the Loki exporter recording the logs it is sent.
+type sink struct {
+	mu   sync.Mutex
+	cfg  *lokiConfig
+	logs []plog.Logs
+}
+
+func (s *sink) factory() exporter.Factory {
+	return exporter.NewFactory(
+		component.MustNewType("loki"),
+		func() component.Config { return &lokiConfig{} },
+		exporter.WithLogs(func(_ context.Context, _ exporter.Settings, cfg component.Config) (exporter.Logs, error) {
+			s.cfg = cfg.(*lokiConfig)
+			c, err := consumer.NewLogs(func(_ context.Context, ld plog.Logs) error {
+				s.mu.Lock()
+				defer s.mu.Unlock()
+				s.logs = append(s.logs, ld)
+				return nil
+			})
+			return struct {
+				component.StartFunc
+				component.ShutdownFunc
+				consumer.Logs
+			}{Logs: c}, err
+		}, component.StabilityLevelDevelopment),
+	)
+}
+
+func TestNewLogExporter(t *testing.T) {
+	var s sink
+	ctx := context.Background()
+	exp, err := loki.NewLogExporter(ctx, s.factory(),
+		loki.WithEndpoint("http://loki:3100/loki/api/v1/push"),
+		loki.WithTenant("team-a"),
+		loki.WithResourceLabels("service.name", "deployment.environment"),
+		loki.WithAttributeLabels("http.route"),
+		loki.WithFormat("logfmt"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.cfg.Endpoint != "http://loki:3100/loki/api/v1/push" || s.cfg.Headers["X-Scope-OrgID"] != "team-a" {
+		t.Errorf("configuration = %+v, want the endpoint and the tenant header", s.cfg)
+	}
+
+	res := resource.NewSchemaless(attribute.String("service.name", "checkout"))
+	lp := sdklog.NewLoggerProvider(sdklog.WithResource(res), sdklog.WithProcessor(sdklog.NewSimpleProcessor(exp)))
+	var r otellog.Record
+	r.SetBody(otellog.StringValue("order placed"))
+	r.AddAttributes(otellog.String("http.route", "/orders"))
+	lp.Logger("test").Emit(ctx, r)
+	if err := lp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.logs) != 1 || s.logs[0].LogRecordCount() != 1 {
+		t.Fatalf("exported %d batches, want 1 of 1 log record", len(s.logs))
+	}
+	rl := s.logs[0].ResourceLogs().At(0)
+	for name, want := range map[string]string{
+		"loki.resource.labels": "service.name,deployment.environment",
+		"loki.format":          "logfmt",
+	} {
+		if v, ok := rl.Resource().Attributes().Get(name); !ok || v.Str() != want {
+			t.Errorf("resource attribute %s = %q, want %q", name, v.AsString(), want)
+		}
+	}
+	lr := rl.ScopeLogs().At(0).LogRecords().At(0)
+	if v, ok := lr.Attributes().Get("loki.attribute.labels"); !ok || v.Str() != "http.route" {
+		t.Errorf("log record attribute loki.attribute.labels = %q, want http.route", v.AsString())
+	}
+}
+
+func TestNewLogExporterWithoutHints(t *testing.T) {
+	var s sink
+	ctx := context.Background()
+	exp, err := loki.NewLogExporter(ctx, s.factory(), loki.WithEndpoint("http://loki:3100/loki/api/v1/push"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lp := sdklog.NewLoggerProvider(sdklog.WithProcessor(sdklog.NewSimpleProcessor(exp)))
+	lp.Logger("test").Emit(ctx, otellog.Record{})
+	if err := lp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rl := s.logs[0].ResourceLogs().At(0)
+	for _, name := range []string{"loki.resource.labels", "loki.format"} {
+		if _, ok := rl.Resource().Attributes().Get(name); ok {
+			t.Errorf("resource attribute %s set without its option", name)
+		}
+	}
+	if _, ok := rl.ScopeLogs().At(0).LogRecords().At(0).Attributes().Get("loki.attribute.labels"); ok {
+		t.Error("log record attribute loki.attribute.labels set without its option")
+	}
+}
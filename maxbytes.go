@@ -0,0 +1,293 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// ErrBatchTooLarge is the permanent error of the telemetry larger than the
+// MaxBytes limit of an exporter.
+var ErrBatchTooLarge = errors.New("collex: batch too large")
+
+// MaxBytesConfig limits the size of the telemetry sent to an exporter in one
+// call, e.g. to stay under the maximum message size of a gRPC backend that
+// would otherwise reject the whole batch. The zero value sets no limit.
+type MaxBytesConfig struct {
+	// Limit is the maximum size, in bytes of its OTLP protobuf encoding, of
+	// the telemetry of a call. There is no limit if it is zero.
+	Limit int
+	// Reject rejects larger telemetry with ErrBatchTooLarge instead of
+	// splitting it into calls under the limit. A single span, metric data
+	// point, or log record larger than the limit is always rejected.
+	Reject bool
+}
+
+// sizeGuard keeps the telemetry sent to an exporter under its MaxBytes limit.
+type sizeGuard struct {
+	cfg MaxBytesConfig
+}
+
+// newSizeGuard returns the sizeGuard of cfg, or nil if cfg sets no limit.
+func newSizeGuard(cfg MaxBytesConfig) *sizeGuard {
+	if cfg.Limit <= 0 {
+		return nil
+	}
+	return &sizeGuard{cfg: cfg}
+}
+
+// batchOps are the operations of a sizeGuard on the collector data of a
+// signal.
+type batchOps[T any] struct {
+	size  func(T) int
+	items func(T) int
+	// split returns the first n items of the data and the others.
+	split func(T, int) (T, T)
+	// merge moves the data of src to dst.
+	merge func(src, dst T)
+	empty func() T
+	// rejected returns the data rejected by a partially failed call.
+	rejected func(error) (T, bool)
+	// failed returns err of a call that failed to send data.
+	failed func(err error, data T) error
+}
+
+// guard sends data to consume in calls under the limit of g. If any call
+// fails, the error returned holds the data of all the failed calls.
+func guard[T any](ctx context.Context, g *sizeGuard, ops batchOps[T], data T, consume func(context.Context, T) error) error {
+	size := ops.size(data)
+	if size <= g.cfg.Limit {
+		return consume(ctx, data)
+	}
+	n := ops.items(data)
+	if g.cfg.Reject || n <= 1 {
+		return consumererror.NewPermanent(fmt.Errorf("%w: %d bytes, limit of %d bytes", ErrBatchTooLarge, size, g.cfg.Limit))
+	}
+
+	head, tail := ops.split(data, n/2)
+	var (
+		err    error
+		failed = ops.empty()
+	)
+	for _, part := range []T{head, tail} {
+		pErr := guard(ctx, g, ops, part, consume)
+		if pErr == nil {
+			continue
+		}
+		err = errors.Join(err, pErr)
+		if rejected, ok := ops.rejected(pErr); ok {
+			part = rejected
+		}
+		ops.merge(part, failed)
+	}
+	if err == nil {
+		return nil
+	}
+	return ops.failed(err, failed)
+}
+
+// outside returns a function called once for each item, in order, returning
+// whether the item is outside of the items from index from to index to.
+func outside(from, to int) func() bool {
+	var i int
+	return func() bool {
+		drop := i < from || i >= to
+		i++
+		return drop
+	}
+}
+
+var traceOps = batchOps[ptrace.Traces]{
+	size:  (&ptrace.ProtoMarshaler{}).TracesSize,
+	items: ptrace.Traces.SpanCount,
+	split: func(td ptrace.Traces, n int) (ptrace.Traces, ptrace.Traces) {
+		head, tail := ptrace.NewTraces(), ptrace.NewTraces()
+		td.CopyTo(head)
+		td.CopyTo(tail)
+		keepSpans(head, outside(0, n))
+		keepSpans(tail, outside(n, math.MaxInt))
+		return head, tail
+	},
+	merge: func(src, dst ptrace.Traces) { src.ResourceSpans().MoveAndAppendTo(dst.ResourceSpans()) },
+	empty: ptrace.NewTraces,
+	rejected: func(err error) (ptrace.Traces, bool) {
+		var rejected consumererror.Traces
+		if errors.As(err, &rejected) {
+			return rejected.Data(), true
+		}
+		return ptrace.Traces{}, false
+	},
+	failed: func(err error, td ptrace.Traces) error { return consumererror.NewTraces(err, td) },
+}
+
+// keepSpans removes the spans of td for which drop returns true.
+func keepSpans(td ptrace.Traces, drop func() bool) {
+	td.ResourceSpans().RemoveIf(func(rs ptrace.ResourceSpans) bool {
+		rs.ScopeSpans().RemoveIf(func(ss ptrace.ScopeSpans) bool {
+			ss.Spans().RemoveIf(func(ptrace.Span) bool { return drop() })
+			return ss.Spans().Len() == 0
+		})
+		return rs.ScopeSpans().Len() == 0
+	})
+}
+
+var metricOps = batchOps[pmetric.Metrics]{
+	size:  (&pmetric.ProtoMarshaler{}).MetricsSize,
+	items: pmetric.Metrics.DataPointCount,
+	split: func(md pmetric.Metrics, n int) (pmetric.Metrics, pmetric.Metrics) {
+		head, tail := pmetric.NewMetrics(), pmetric.NewMetrics()
+		md.CopyTo(head)
+		md.CopyTo(tail)
+		keepDataPoints(head, outside(0, n))
+		keepDataPoints(tail, outside(n, math.MaxInt))
+		return head, tail
+	},
+	merge: func(src, dst pmetric.Metrics) { src.ResourceMetrics().MoveAndAppendTo(dst.ResourceMetrics()) },
+	empty: pmetric.NewMetrics,
+	rejected: func(err error) (pmetric.Metrics, bool) {
+		var rejected consumererror.Metrics
+		if errors.As(err, &rejected) {
+			return rejected.Data(), true
+		}
+		return pmetric.Metrics{}, false
+	},
+	failed: func(err error, md pmetric.Metrics) error { return consumererror.NewMetrics(err, md) },
+}
+
+// keepDataPoints removes the data points of md for which drop returns true.
+func keepDataPoints(md pmetric.Metrics, drop func() bool) {
+	md.ResourceMetrics().RemoveIf(func(rm pmetric.ResourceMetrics) bool {
+		rm.ScopeMetrics().RemoveIf(func(sm pmetric.ScopeMetrics) bool {
+			sm.Metrics().RemoveIf(func(m pmetric.Metric) bool {
+				switch m.Type() {
+				case pmetric.MetricTypeGauge:
+					dps := m.Gauge().DataPoints()
+					dps.RemoveIf(func(pmetric.NumberDataPoint) bool { return drop() })
+					return dps.Len() == 0
+				case pmetric.MetricTypeSum:
+					dps := m.Sum().DataPoints()
+					dps.RemoveIf(func(pmetric.NumberDataPoint) bool { return drop() })
+					return dps.Len() == 0
+				case pmetric.MetricTypeHistogram:
+					dps := m.Histogram().DataPoints()
+					dps.RemoveIf(func(pmetric.HistogramDataPoint) bool { return drop() })
+					return dps.Len() == 0
+				case pmetric.MetricTypeExponentialHistogram:
+					dps := m.ExponentialHistogram().DataPoints()
+					dps.RemoveIf(func(pmetric.ExponentialHistogramDataPoint) bool { return drop() })
+					return dps.Len() == 0
+				case pmetric.MetricTypeSummary:
+					dps := m.Summary().DataPoints()
+					dps.RemoveIf(func(pmetric.SummaryDataPoint) bool { return drop() })
+					return dps.Len() == 0
+				}
+				return true
+			})
+			return sm.Metrics().Len() == 0
+		})
+		return rm.ScopeMetrics().Len() == 0
+	})
+}
+
+var logOps = batchOps[plog.Logs]{
+	size:  (&plog.ProtoMarshaler{}).LogsSize,
+	items: plog.Logs.LogRecordCount,
+	split: func(ld plog.Logs, n int) (plog.Logs, plog.Logs) {
+		head, tail := plog.NewLogs(), plog.NewLogs()
+		ld.CopyTo(head)
+		ld.CopyTo(tail)
+		keepLogRecords(head, outside(0, n))
+		keepLogRecords(tail, outside(n, math.MaxInt))
+		return head, tail
+	},
+	merge: func(src, dst plog.Logs) { src.ResourceLogs().MoveAndAppendTo(dst.ResourceLogs()) },
+	empty: plog.NewLogs,
+	rejected: func(err error) (plog.Logs, bool) {
+		var rejected consumererror.Logs
+		if errors.As(err, &rejected) {
+			return rejected.Data(), true
+		}
+		return plog.Logs{}, false
+	},
+	failed: func(err error, ld plog.Logs) error { return consumererror.NewLogs(err, ld) },
+}
+
+// keepLogRecords removes the log records of ld for which drop returns true.
+func keepLogRecords(ld plog.Logs, drop func() bool) {
+	ld.ResourceLogs().RemoveIf(func(rl plog.ResourceLogs) bool {
+		rl.ScopeLogs().RemoveIf(func(sl plog.ScopeLogs) bool {
+			sl.LogRecords().RemoveIf(func(plog.LogRecord) bool { return drop() })
+			return sl.LogRecords().Len() == 0
+		})
+		return rl.ScopeLogs().Len() == 0
+	})
+}
+
+// guardTraces returns next receiving its spans in calls under the limit of
+// g. It returns next if g is nil.
+func guardTraces(g *sizeGuard, next consumer.Traces) consumer.Traces {
+	if g == nil {
+		return next
+	}
+	c, err := consumer.NewTraces(func(ctx context.Context, td ptrace.Traces) error {
+		return guard(ctx, g, traceOps, td, next.ConsumeTraces)
+	}, consumer.WithCapabilities(next.Capabilities()))
+	if err != nil {
+		// Only returned for a nil function.
+		panic(err)
+	}
+	return c
+}
+
+// guardMetrics returns next receiving its metrics in calls under the limit of
+// g. It returns next if g is nil.
+func guardMetrics(g *sizeGuard, next consumer.Metrics) consumer.Metrics {
+	if g == nil {
+		return next
+	}
+	c, err := consumer.NewMetrics(func(ctx context.Context, md pmetric.Metrics) error {
+		return guard(ctx, g, metricOps, md, next.ConsumeMetrics)
+	}, consumer.WithCapabilities(next.Capabilities()))
+	if err != nil {
+		// Only returned for a nil function.
+		panic(err)
+	}
+	return c
+}
+
+// guardLogs returns next receiving its log records in calls under the limit
+// of g. It returns next if g is nil.
+func guardLogs(g *sizeGuard, next consumer.Logs) consumer.Logs {
+	if g == nil {
+		return next
+	}
+	c, err := consumer.NewLogs(func(ctx context.Context, ld plog.Logs) error {
+		return guard(ctx, g, logOps, ld, next.ConsumeLogs)
+	}, consumer.WithCapabilities(next.Capabilities()))
+	if err != nil {
+		// Only returned for a nil function.
+		panic(err)
+	}
+	return c
+}
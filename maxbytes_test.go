@@ -0,0 +1,166 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/MrAlias/collex"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// paddedSpans returns spans with an attribute of size bytes each.
+func paddedSpans(sizes ...int) []trace.ReadOnlySpan {
+	stubs := make(tracetest.SpanStubs, len(sizes))
+	for i, size := range sizes {
+		stubs[i] = tracetest.SpanStub{
+			Name:       "padded",
+			Resource:   resource.Empty(),
+			Attributes: []attribute.KeyValue{attribute.String("pad", strings.Repeat("x", size))},
+		}
+	}
+	return stubs.Snapshots()
+}
+
+func TestWithMaxBytesSplit(t *testing.T) {
+	const limit = 1024
+	var s sink
+	f, err := collex.NewFactory(s.factory(), settings(), collex.WithMaxBytes(collex.MaxBytesConfig{Limit: limit}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = exp.Shutdown(ctx) }()
+
+	if err := exp.ExportSpans(ctx, paddedSpans(300, 300, 300, 300, 300, 300, 300, 300)); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.traces) < 2 {
+		t.Fatalf("sent %d calls, want the export split", len(s.traces))
+	}
+	var spans int
+	for _, td := range s.traces {
+		spans += td.SpanCount()
+		if size := (&ptrace.ProtoMarshaler{}).TracesSize(td); size > limit {
+			t.Errorf("sent %d bytes, over the limit of %d", size, limit)
+		}
+	}
+	if spans != 8 {
+		t.Errorf("sent %d spans, want 8", spans)
+	}
+}
+
+func TestWithMaxBytesOversizedItem(t *testing.T) {
+	var s sink
+	f, err := collex.NewFactory(s.factory(), settings(), collex.WithMaxBytes(collex.MaxBytesConfig{Limit: 1024}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = exp.Shutdown(ctx) }()
+
+	err = exp.ExportSpans(ctx, paddedSpans(100, 2048))
+	if !errors.Is(err, collex.ErrBatchTooLarge) {
+		t.Errorf("ExportSpans error = %v, want %v", err, collex.ErrBatchTooLarge)
+	}
+	if len(s.traces) != 1 || s.traces[0].SpanCount() != 1 {
+		t.Errorf("sent %v, want the span under the limit", s.traces)
+	}
+}
+
+func TestWithMaxBytesReject(t *testing.T) {
+	var s sink
+	cfg := collex.MaxBytesConfig{Limit: 1024, Reject: true}
+	f, err := collex.NewFactory(s.factory(), settings(), collex.WithMaxBytes(cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = exp.Shutdown(ctx) }()
+
+	if err := exp.ExportSpans(ctx, paddedSpans(300, 300, 300, 300)); !errors.Is(err, collex.ErrBatchTooLarge) {
+		t.Errorf("ExportSpans error = %v, want %v", err, collex.ErrBatchTooLarge)
+	}
+	if len(s.traces) != 0 {
+		t.Errorf("sent %d calls, want none", len(s.traces))
+	}
+	if err := exp.ExportSpans(ctx, paddedSpans(300)); err != nil {
+		t.Errorf("ExportSpans error = %v under the limit", err)
+	}
+}
+
+func TestWithMaxBytesSplitDataPoints(t *testing.T) {
+	const limit = 512
+	var s sink
+	f, err := collex.NewFactory(s.factory(), settings(), collex.WithMaxBytes(collex.MaxBytesConfig{Limit: limit}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	exp, err := f.MetricExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = exp.Shutdown(ctx) }()
+
+	dps := make([]metricdata.DataPoint[int64], 100)
+	for i := range dps {
+		dps[i] = metricdata.DataPoint[int64]{Attributes: attribute.NewSet(attribute.Int("id", i)), Value: int64(i)}
+	}
+	rm := &metricdata.ResourceMetrics{
+		Resource: resource.Empty(),
+		ScopeMetrics: []metricdata.ScopeMetrics{{Metrics: []metricdata.Metrics{{
+			Name: "requests",
+			Data: metricdata.Sum[int64]{Temporality: metricdata.CumulativeTemporality, IsMonotonic: true, DataPoints: dps},
+		}}}},
+	}
+	if err := exp.Export(ctx, rm); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	for _, md := range s.metrics {
+		n += md.DataPointCount()
+		if size := (&pmetric.ProtoMarshaler{}).MetricsSize(md); size > limit {
+			t.Errorf("sent %d bytes, over the limit of %d", size, limit)
+		}
+		if m := md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0); m.Name() != "requests" || !m.Sum().IsMonotonic() {
+			t.Errorf("sent metric %q, want the monotonic requests sum", m.Name())
+		}
+	}
+	if len(s.metrics) < 2 || n != 100 {
+		t.Errorf("sent %d data points in %d calls, want 100 split", n, len(s.metrics))
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"context"
+	"reflect"
+
+	"go.opentelemetry.io/collector/client"
+)
+
+// ContextWithMetadata returns a copy of ctx carrying md as the metadata of
+// its collector client.Info. Any other client information of ctx is kept.
+//
+// Telemetry exported with the returned context reaches the collector
+// components with md, like telemetry received by a collector receiver from a
+// client. This is how extensions like headers_setter, or processors grouping
+// by metadata keys, tell requests of different tenants apart.
+func ContextWithMetadata(ctx context.Context, md map[string][]string) context.Context {
+	info := client.FromContext(ctx)
+	info.Metadata = client.NewMetadata(md)
+	return client.NewContext(ctx, info)
+}
+
+// withDefaultMetadata returns ctx carrying md as the metadata of its
+// client.Info if md is not empty and ctx carries no client.Info yet.
+func withDefaultMetadata(ctx context.Context, md map[string][]string) context.Context {
+	if len(md) == 0 || !reflect.DeepEqual(client.FromContext(ctx), client.Info{}) {
+		return ctx
+	}
+	return client.NewContext(ctx, client.Info{Metadata: client.NewMetadata(md)})
+}
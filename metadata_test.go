@@ -0,0 +1,78 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/MrAlias/collex"
+	"go.opentelemetry.io/collector/client"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// tenants returns the factory of an exporter that records the "tenant"
+// metadata of the client.Info of each export.
+func tenants(got *[]string) exporter.Factory {
+	return exporter.NewFactory(
+		component.MustNewType("tenants"),
+		createEmptyConfig,
+		exporter.WithTraces(func(context.Context, exporter.Settings, component.Config) (exporter.Traces, error) {
+			c, err := consumer.NewTraces(func(ctx context.Context, _ ptrace.Traces) error {
+				*got = append(*got, client.FromContext(ctx).Metadata.Get("tenant")...)
+				return nil
+			})
+			return tracesComponent{Traces: c}, err
+		}, component.StabilityLevelDevelopment),
+	)
+}
+
+func TestFactoryMetadata(t *testing.T) {
+	var got []string
+	f, err := collex.NewFactory(tenants(&got), settings(), collex.WithMetadata(map[string][]string{
+		"tenant": {"default"},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans := tracetest.SpanStubs{{Name: "a", Resource: resource.Empty()}}.Snapshots()
+	if err := exp.ExportSpans(ctx, spans); err != nil {
+		t.Fatal(err)
+	}
+	acme := collex.ContextWithMetadata(ctx, map[string][]string{"tenant": {"acme"}})
+	if err := exp.ExportSpans(acme, spans); err != nil {
+		t.Fatal(err)
+	}
+	if err := exp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"default", "acme"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tenants = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"context"
+	"errors"
+
+	"github.com/MrAlias/collex/transmute"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pipeline"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+var errNoMetrics = errors.New("collex: no metrics pipelines")
+
+type metricExporter struct {
+	// next is the consumer the exported metrics are sent to.
+	next consumer.Metrics
+	// export sends the exports to next through the middleware.
+	export Export
+	// sup owns the components the exporter sends to. It is nil if the
+	// components are owned by a Pipeline.
+	sup *supervisor
+	// metadata is the client.Info metadata of the exports with none.
+	metadata map[string][]string
+	// temporality selects the temporality of the exported metrics. If nil,
+	// metric.DefaultTemporalitySelector is used.
+	temporality metric.TemporalitySelector
+	// tracer traces the exports. It is nil if they are not traced.
+	tracer *exportTracer
+	// errs reports the failed exports. It is nil if they are not reported.
+	errs *exportErrors
+	// shed sheds exports under memory pressure. It is nil if none are.
+	shed *shedder
+	// panics recovers the panics of the exports.
+	panics *panicGuard
+}
+
+func (e *metricExporter) Temporality(k metric.InstrumentKind) metricdata.Temporality {
+	if e.temporality == nil {
+		return metric.DefaultTemporalitySelector(k)
+	}
+	return e.temporality(k)
+}
+
+func (e *metricExporter) Aggregation(k metric.InstrumentKind) metric.Aggregation {
+	return metric.DefaultAggregationSelector(k)
+}
+
+func (e *metricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	if e.next == nil {
+		return errNoMetrics
+	}
+	if e.shed.metrics(ctx, rm) {
+		return nil
+	}
+	n := dataPoints(rm)
+	ctx, end := e.tracer.start(ctx, n)
+	err := e.panics.do(ctx, func() error {
+		b := Batch{Signal: pipeline.SignalMetrics, Metrics: transmute.Metrics(rm)}
+		return e.export(withDefaultMetadata(ctx, e.metadata), b)
+	})
+	end(err)
+	e.errs.report(err, n)
+	return err
+}
+
+func (e *metricExporter) consume(ctx context.Context, b Batch) error {
+	return e.next.ConsumeMetrics(ctx, b.Metrics)
+}
+
+// ForceFlush does nothing, the exporter holds no state.
+func (e *metricExporter) ForceFlush(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// Shutdown shuts down the components owned by the exporter. It is safe to
+// call Shutdown multiple times.
+func (e *metricExporter) Shutdown(ctx context.Context) error {
+	if e.sup == nil {
+		return nil
+	}
+	return e.sup.shutdown(ctx)
+}
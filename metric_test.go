@@ -0,0 +1,185 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/MrAlias/collex"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/processor"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestPipelineMetrics(t *testing.T) {
+	ctx := context.Background()
+	s := new(sink)
+	p, err := collex.NewPipeline().
+		WithSettings(*settings()).
+		WithExporter(s.factory(), nil).
+		Build(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mp := metric.NewMeterProvider(metric.WithReader(p.MetricReader()))
+	c, err := mp.Meter("test").Int64Counter("requests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Add(ctx, 3)
+	if err := mp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.metrics) == 0 {
+		t.Fatal("no metrics exported")
+	}
+	sm := s.metrics[0].ResourceMetrics().At(0).ScopeMetrics().At(0)
+	if got := sm.Scope().Name(); got != "test" {
+		t.Errorf("scope name = %q, want %q", got, "test")
+	}
+	m := sm.Metrics().At(0)
+	if got := m.Name(); got != "requests" {
+		t.Errorf("metric name = %q, want %q", got, "requests")
+	}
+	if got := m.Sum().AggregationTemporality(); got != pmetric.AggregationTemporalityCumulative {
+		t.Errorf("temporality = %s, want cumulative", got)
+	}
+	if got := m.Sum().DataPoints().At(0).IntValue(); got != 3 {
+		t.Errorf("value = %d, want 3", got)
+	}
+}
+
+func TestPipelineMetricsDelta(t *testing.T) {
+	ctx := context.Background()
+	s := new(sink)
+	p, err := collex.NewPipeline().
+		WithSettings(*settings()).
+		WithExporter(s.factory(), nil).
+		WithTemporalitySelector(func(metric.InstrumentKind) metricdata.Temporality {
+			return metricdata.DeltaTemporality
+		}).
+		Build(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mp := metric.NewMeterProvider(metric.WithReader(p.MetricReader()))
+	c, err := mp.Meter("test").Int64Counter("requests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Add(ctx, 3)
+	if err := mp.ForceFlush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	c.Add(ctx, 1)
+	if err := mp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.metrics) != 2 {
+		t.Fatalf("got %d exports, want 2", len(s.metrics))
+	}
+	for i, want := range []int64{3, 1} {
+		sum := s.metrics[i].ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Sum()
+		if got := sum.AggregationTemporality(); got != pmetric.AggregationTemporalityDelta {
+			t.Errorf("export %d: temporality = %s, want delta", i, got)
+		}
+		if got := sum.DataPoints().At(0).IntValue(); got != want {
+			t.Errorf("export %d: value = %d, want %d", i, got, want)
+		}
+	}
+}
+
+// renameScale is a collector processor that renames a gauge and scales its
+// values.
+func renameScale(from, to string, factor float64) processor.Factory {
+	return processor.NewFactory(
+		component.MustNewType("rename"),
+		createEmptyConfig,
+		processor.WithMetrics(func(_ context.Context, _ processor.Settings, _ component.Config, next consumer.Metrics) (processor.Metrics, error) {
+			c, err := consumer.NewMetrics(func(ctx context.Context, md pmetric.Metrics) error {
+				rms := md.ResourceMetrics()
+				for i := 0; i < rms.Len(); i++ {
+					sms := rms.At(i).ScopeMetrics()
+					for j := 0; j < sms.Len(); j++ {
+						ms := sms.At(j).Metrics()
+						for k := 0; k < ms.Len(); k++ {
+							m := ms.At(k)
+							if m.Name() != from || m.Type() != pmetric.MetricTypeGauge {
+								continue
+							}
+							m.SetName(to)
+							dps := m.Gauge().DataPoints()
+							for l := 0; l < dps.Len(); l++ {
+								dps.At(l).SetDoubleValue(dps.At(l).DoubleValue() * factor)
+							}
+						}
+					}
+				}
+				return next.ConsumeMetrics(ctx, md)
+			}, consumer.WithCapabilities(consumer.Capabilities{MutatesData: true}))
+			return metricsComponent{Metrics: c}, err
+		}, component.StabilityLevelDevelopment),
+	)
+}
+
+func TestFactoryMetricsProcessor(t *testing.T) {
+	s := new(sink)
+	f, err := collex.NewFactory(s.factory(), settings(), collex.WithProcessors(
+		collex.Processor{Factory: renameScale("latency_ms", "latency", 0.001)},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	exp, err := f.MetricExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mp := metric.NewMeterProvider(metric.WithReader(metric.NewPeriodicReader(exp)))
+	g, err := mp.Meter("test").Float64Gauge("latency_ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Record(ctx, 250)
+	if err := mp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.metrics) != 1 {
+		t.Fatalf("exporter received %d exports, want 1", len(s.metrics))
+	}
+	m := s.metrics[0].ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0)
+	if got := m.Name(); got != "latency" {
+		t.Errorf("metric name = %q, want %q", got, "latency")
+	}
+	if got := m.Gauge().DataPoints().At(0).DoubleValue(); got != 0.25 {
+		t.Errorf("value = %v, want 0.25", got)
+	}
+}
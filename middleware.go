@@ -0,0 +1,77 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pipeline"
+)
+
+// Batch is the telemetry of an export of an exporter returned by a Factory,
+// converted to the collector data of its signal. Only the field of Signal is
+// set.
+type Batch struct {
+	Signal  pipeline.Signal
+	Traces  ptrace.Traces
+	Metrics pmetric.Metrics
+	Logs    plog.Logs
+}
+
+// Items returns the number of spans, metric data points, or log records of b.
+func (b Batch) Items() int {
+	switch b.Signal {
+	case pipeline.SignalTraces:
+		return b.Traces.SpanCount()
+	case pipeline.SignalMetrics:
+		return b.Metrics.DataPointCount()
+	case pipeline.SignalLogs:
+		return b.Logs.LogRecordCount()
+	}
+	return 0
+}
+
+// Export sends a Batch to the processors, connectors, and wrapped exporter of
+// an exporter returned by a Factory.
+type Export func(ctx context.Context, b Batch) error
+
+// Middleware wraps the Export of the exporters of a Factory, e.g. to log,
+// measure, or mutate each export, or to return without calling next.
+type Middleware func(next Export) Export
+
+// exportTimeout returns the Middleware bounding each export with a deadline
+// of d, unless its context has an earlier one.
+func exportTimeout(d time.Duration) Middleware {
+	return func(next Export) Export {
+		return func(ctx context.Context, b Batch) error {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			return next(ctx, b)
+		}
+	}
+}
+
+// chain returns export wrapped by the middleware of f, the first one
+// outermost.
+func (f *Factory) chain(export Export) Export {
+	for i := len(f.middleware) - 1; i >= 0; i-- {
+		export = f.middleware[i](export)
+	}
+	return export
+}
@@ -0,0 +1,151 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/MrAlias/collex"
+	"github.com/MrAlias/collex/collextest"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// tag returns a Middleware appending name to calls before and after each
+// export.
+func tag(name string, calls *[]string) collex.Middleware {
+	return func(next collex.Export) collex.Export {
+		return func(ctx context.Context, b collex.Batch) error {
+			*calls = append(*calls, name+" before")
+			err := next(ctx, b)
+			*calls = append(*calls, name+" after")
+			return err
+		}
+	}
+}
+
+func TestWithMiddleware(t *testing.T) {
+	var calls []string
+	rename := func(next collex.Export) collex.Export {
+		return func(ctx context.Context, b collex.Batch) error {
+			calls = append(calls, "rename")
+			spans := b.Traces.ResourceSpans().At(0).ScopeSpans().At(0).Spans()
+			for i := range spans.Len() {
+				spans.At(i).SetName("renamed")
+			}
+			return next(ctx, b)
+		}
+	}
+
+	var s sink
+	f, err := collex.NewFactory(
+		s.factory(), settings(),
+		collex.WithMiddleware(tag("a", &calls), tag("b", &calls)),
+		collex.WithMiddleware(rename),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = exp.Shutdown(ctx) }()
+
+	if err := exp.ExportSpans(ctx, tracetest.SpanStubs{{Name: "span"}}.Snapshots()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"a before", "b before", "rename", "b after", "a after"}
+	if !slices.Equal(calls, want) {
+		t.Errorf("middleware called %q, want %q", calls, want)
+	}
+	if len(s.traces) != 1 {
+		t.Fatalf("%d exports, want 1", len(s.traces))
+	}
+	if got := s.traces[0].ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Name(); got != "renamed" {
+		t.Errorf("exported span %q, want it renamed", got)
+	}
+}
+
+func TestWithMiddlewareShortCircuit(t *testing.T) {
+	var items int
+	drop := func(collex.Export) collex.Export {
+		return func(_ context.Context, b collex.Batch) error {
+			items += b.Items()
+			return nil
+		}
+	}
+
+	var s sink
+	f, err := collex.NewFactory(s.factory(), settings(), collex.WithMiddleware(drop))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	exp, err := f.LogExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = exp.Shutdown(ctx) }()
+
+	if err := exp.Export(ctx, make([]log.Record, 3)); err != nil {
+		t.Fatal(err)
+	}
+	if items != 3 {
+		t.Errorf("middleware got %d log records, want 3", items)
+	}
+	if len(s.logs) != 0 {
+		t.Errorf("%d exports reached the exporter, want 0", len(s.logs))
+	}
+}
+
+func TestWithExportTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	mock := collextest.NewMockExporter(collextest.Block(release))
+
+	var deadline bool
+	observe := func(next collex.Export) collex.Export {
+		return func(ctx context.Context, b collex.Batch) error {
+			_, deadline = ctx.Deadline()
+			return next(ctx, b)
+		}
+	}
+	f, err := collex.NewFactory(mock.Factory(), settings(), collex.WithExportTimeout(10*time.Millisecond), collex.WithMiddleware(observe))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	cfg := collex.NewHelperConfig()
+	cfg.QueueConfig.Enabled = false
+	exp, err := f.SpanExporter(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = exp.Shutdown(ctx) }()
+
+	if err := exp.ExportSpans(ctx, spans(1)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ExportSpans error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if !deadline {
+		t.Error("middleware called without the export deadline")
+	}
+}
@@ -0,0 +1,248 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/trace"
+	api "go.opentelemetry.io/otel/trace"
+)
+
+// mirrorInFlight is the number of exports a mirroring exporter sends to its
+// secondary exporter concurrently. The exports sampled while it is reached are
+// dropped.
+const mirrorInFlight = 4
+
+// errMirrorSaturated is the error reported for the exports a mirroring
+// exporter drops instead of sending them to its secondary exporter.
+var errMirrorSaturated = errors.New("collex: mirror secondary saturated")
+
+// mirror samples the telemetry a mirroring exporter sends to its secondary
+// exporter, and sends it asynchronously.
+type mirror struct {
+	ratio float64
+	// bound is the upper bound of the trace IDs sampled, like the
+	// TraceIDRatioBased sampler of the SDK.
+	bound uint64
+
+	// inFlight holds a token per export being sent to the secondary.
+	inFlight chan struct{}
+	wg       sync.WaitGroup
+}
+
+func newMirror(ratio float64) *mirror {
+	ratio = min(max(ratio, 0), 1)
+	return &mirror{
+		ratio:    ratio,
+		bound:    uint64(ratio * (1 << 63)),
+		inFlight: make(chan struct{}, mirrorInFlight),
+	}
+}
+
+// traceID returns whether the telemetry of the trace id is sampled. All the
+// telemetry of a trace is sampled, or none of it. Telemetry without a trace
+// is sampled randomly.
+func (m *mirror) traceID(id api.TraceID) bool {
+	if !id.IsValid() {
+		return m.random()
+	}
+	return binary.BigEndian.Uint64(id[8:16])>>1 < m.bound
+}
+
+// random returns whether telemetry is sampled, with a probability of the
+// ratio.
+func (m *mirror) random() bool {
+	return m.ratio == 1 || rand.Float64() < m.ratio
+}
+
+// export calls primary and returns its error. If sampled is true, secondary
+// is called in the background without waiting for it, its telemetry needs not
+// to be reused by the caller. If mirrorInFlight calls of secondary are still
+// running, secondary is not called and the drop is reported to the error
+// handler of OpenTelemetry, so a slow secondary does not slow primary down.
+func (m *mirror) export(sampled bool, primary, secondary func() error) error {
+	if sampled {
+		select {
+		case m.inFlight <- struct{}{}:
+			m.wg.Add(1)
+			go func() {
+				defer func() {
+					<-m.inFlight
+					m.wg.Done()
+				}()
+				_ = secondary()
+			}()
+		default:
+			otel.Handle(errMirrorSaturated)
+		}
+	}
+	return primary()
+}
+
+// wait waits for the calls of the secondary exporter to return, or for ctx to
+// be done.
+func (m *mirror) wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("mirror: %w", ctx.Err())
+	}
+}
+
+// NewMirrorSpanExporter returns a SpanExporter exporting all spans with
+// primary and the ratio, from 0 to 1, of them with secondary, e.g. to
+// evaluate a new backend with production traffic without the cost of
+// writing all of it twice. Spans are sampled by trace ID, so the secondary
+// receives whole traces.
+//
+// Only the errors of primary fail the exports. Those of secondary are
+// reported by the Factory that returned it, e.g. to a function set with
+// WithExportErrorFunc. The exports to secondary are sent in the background,
+// so a slow secondary does not slow primary down. At most 4 are sent
+// concurrently, the spans sampled while they are are dropped and reported to
+// the error handler of OpenTelemetry. Shutting down the returned exporter
+// waits for them and shuts down both exporters.
+func NewMirrorSpanExporter(primary, secondary trace.SpanExporter, ratio float64) trace.SpanExporter {
+	return &mirrorSpanExporter{primary: primary, secondary: secondary, mirror: newMirror(ratio)}
+}
+
+type mirrorSpanExporter struct {
+	primary, secondary trace.SpanExporter
+	mirror             *mirror
+}
+
+func (e *mirrorSpanExporter) ExportSpans(ctx context.Context, spans []trace.ReadOnlySpan) error {
+	var sampled []trace.ReadOnlySpan
+	for _, s := range spans {
+		if e.mirror.traceID(s.SpanContext().TraceID()) {
+			sampled = append(sampled, s)
+		}
+	}
+	return e.mirror.export(
+		len(sampled) > 0,
+		func() error { return e.primary.ExportSpans(ctx, spans) },
+		func() error { return e.secondary.ExportSpans(context.WithoutCancel(ctx), sampled) },
+	)
+}
+
+func (e *mirrorSpanExporter) Shutdown(ctx context.Context) error {
+	err := e.mirror.wait(ctx)
+	return errors.Join(err, e.primary.Shutdown(ctx), e.secondary.Shutdown(ctx))
+}
+
+// NewMirrorMetricExporter returns a metric Exporter exporting all metrics
+// with primary and the ratio, from 0 to 1, of the exports with secondary.
+// The temporality and aggregation of primary are used.
+//
+// Only the errors of primary fail the exports. The exports to secondary are
+// sent in the background, and dropped like those of NewMirrorSpanExporter.
+// Shutting down the returned exporter shuts down both exporters.
+func NewMirrorMetricExporter(primary, secondary metric.Exporter, ratio float64) metric.Exporter {
+	return &mirrorMetricExporter{primary: primary, secondary: secondary, mirror: newMirror(ratio)}
+}
+
+type mirrorMetricExporter struct {
+	primary, secondary metric.Exporter
+	mirror             *mirror
+}
+
+func (e *mirrorMetricExporter) Temporality(k metric.InstrumentKind) metricdata.Temporality {
+	return e.primary.Temporality(k)
+}
+
+func (e *mirrorMetricExporter) Aggregation(k metric.InstrumentKind) metric.Aggregation {
+	return e.primary.Aggregation(k)
+}
+
+func (e *mirrorMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	return e.mirror.export(
+		e.mirror.random(),
+		func() error { return e.primary.Export(ctx, rm) },
+		func() error {
+			// The SDK reuses rm once the export returns.
+			rm := deepCopy(reflect.ValueOf(rm)).Interface().(*metricdata.ResourceMetrics)
+			return e.secondary.Export(context.WithoutCancel(ctx), rm)
+		},
+	)
+}
+
+func (e *mirrorMetricExporter) ForceFlush(ctx context.Context) error {
+	err := e.mirror.wait(ctx)
+	return errors.Join(err, e.primary.ForceFlush(ctx), e.secondary.ForceFlush(ctx))
+}
+
+func (e *mirrorMetricExporter) Shutdown(ctx context.Context) error {
+	err := e.mirror.wait(ctx)
+	return errors.Join(err, e.primary.Shutdown(ctx), e.secondary.Shutdown(ctx))
+}
+
+// NewMirrorLogExporter returns a log Exporter exporting all log records with
+// primary and the ratio, from 0 to 1, of them with secondary. Log records of
+// a trace are sampled by its trace ID, like spans.
+//
+// Only the errors of primary fail the exports. The exports to secondary are
+// sent in the background, and dropped like those of NewMirrorSpanExporter.
+// Shutting down the returned exporter shuts down both exporters.
+func NewMirrorLogExporter(primary, secondary log.Exporter, ratio float64) log.Exporter {
+	return &mirrorLogExporter{primary: primary, secondary: secondary, mirror: newMirror(ratio)}
+}
+
+type mirrorLogExporter struct {
+	primary, secondary log.Exporter
+	mirror             *mirror
+}
+
+func (e *mirrorLogExporter) Export(ctx context.Context, records []log.Record) error {
+	var sampled []log.Record
+	for _, r := range records {
+		if e.mirror.traceID(r.TraceID()) {
+			// The SDK reuses the attributes of records once the export
+			// returns.
+			sampled = append(sampled, r.Clone())
+		}
+	}
+	return e.mirror.export(
+		len(sampled) > 0,
+		func() error { return e.primary.Export(ctx, records) },
+		func() error { return e.secondary.Export(context.WithoutCancel(ctx), sampled) },
+	)
+}
+
+func (e *mirrorLogExporter) ForceFlush(ctx context.Context) error {
+	err := e.mirror.wait(ctx)
+	return errors.Join(err, e.primary.ForceFlush(ctx), e.secondary.ForceFlush(ctx))
+}
+
+func (e *mirrorLogExporter) Shutdown(ctx context.Context) error {
+	err := e.mirror.wait(ctx)
+	return errors.Join(err, e.primary.Shutdown(ctx), e.secondary.Shutdown(ctx))
+}
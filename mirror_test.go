@@ -0,0 +1,131 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync/atomic"
+	"testing"
+
+	"github.com/MrAlias/collex"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	api "go.opentelemetry.io/otel/trace"
+)
+
+func TestMirrorSpanExporter(t *testing.T) {
+	var primary, secondary sink
+	exp := collex.NewMirrorSpanExporter(sinkSpanExporter(t, &primary), sinkSpanExporter(t, &secondary), 0.5)
+	ctx := context.Background()
+
+	const traces = 100
+	r := rand.New(rand.NewPCG(1, 2))
+	stubs := make(tracetest.SpanStubs, 0, 2*traces)
+	for range traces {
+		var id api.TraceID
+		for i := range id {
+			id[i] = byte(r.Uint32())
+		}
+		sc := api.NewSpanContext(api.SpanContextConfig{TraceID: id})
+		stubs = append(stubs, tracetest.SpanStub{Name: "parent", SpanContext: sc}, tracetest.SpanStub{Name: "child", SpanContext: sc})
+	}
+	if err := exp.ExportSpans(ctx, stubs.Snapshots()); err != nil {
+		t.Fatal(err)
+	}
+	// Wait for the export to the secondary.
+	if err := exp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := len(spanNames(&primary)); got != 2*traces {
+		t.Errorf("primary got %d spans, want %d", got, 2*traces)
+	}
+	mirrored := make(map[pcommon.TraceID]int)
+	for _, td := range secondary.traces {
+		ss := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans()
+		for i := range ss.Len() {
+			mirrored[ss.At(i).TraceID()]++
+		}
+	}
+	if len(mirrored) == 0 || len(mirrored) == traces {
+		t.Errorf("%d of %d traces mirrored, want about half", len(mirrored), traces)
+	}
+	for id, n := range mirrored {
+		if n != 2 {
+			t.Errorf("trace %s mirrored with %d spans, want the whole trace", id, n)
+		}
+	}
+}
+
+func TestMirrorSecondaryError(t *testing.T) {
+	var primary sink
+	b := &backend{err: errBroken}
+	exp := collex.NewMirrorSpanExporter(sinkSpanExporter(t, &primary), backendExporter(t, b), 1)
+	ctx := context.Background()
+
+	if err := exp.ExportSpans(ctx, spans(1)); err != nil {
+		t.Errorf("export failed with the error of the secondary: %v", err)
+	}
+	if err := exp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if b.calls != 1 {
+		t.Errorf("secondary called %d times, want 1", b.calls)
+	}
+}
+
+// hungSpanExporter is a SpanExporter whose exports block until release is
+// closed.
+type hungSpanExporter struct {
+	release chan struct{}
+	calls   atomic.Int64
+}
+
+func (e *hungSpanExporter) ExportSpans(context.Context, []trace.ReadOnlySpan) error {
+	e.calls.Add(1)
+	<-e.release
+	return nil
+}
+
+func (e *hungSpanExporter) Shutdown(context.Context) error { return nil }
+
+func TestMirrorHungSecondary(t *testing.T) {
+	var primary sink
+	secondary := &hungSpanExporter{release: make(chan struct{})}
+	exp := collex.NewMirrorSpanExporter(sinkSpanExporter(t, &primary), secondary, 1)
+	ctx := context.Background()
+
+	// The primary exports while the secondary hangs, the exports above the
+	// ones in flight to the secondary are not mirrored.
+	const exports = 6
+	for range exports {
+		if err := exp.ExportSpans(ctx, spans(1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := len(spanNames(&primary)); got != exports {
+		t.Errorf("primary got %d spans, want %d", got, exports)
+	}
+
+	close(secondary.release)
+	if err := exp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := secondary.calls.Load(); got != 4 {
+		t.Errorf("secondary called %d times, want the 4 in flight", got)
+	}
+}
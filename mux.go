@@ -0,0 +1,141 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/pipeline"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+var errMuxShutDown = errors.New("collex: mux shut down")
+
+// Mux provides the exporters of each signal from a different Factory, e.g.
+// spans exported to ClickHouse, metrics with Prometheus remote write, and
+// logs to Loki, as a single object with a single Shutdown.
+type Mux struct {
+	traces, metrics, logs *Factory
+
+	mu       sync.Mutex
+	shutdown []func(context.Context) error
+	stopped  bool
+}
+
+// NewMux returns a Mux providing the exporters of spans from traces, of
+// metrics from metrics, and of log records from logs. Any of them can be nil
+// if the signal is not exported, creating the exporters of the signal then
+// fails.
+func NewMux(traces, metrics, logs *Factory) *Mux {
+	return &Mux{traces: traces, metrics: metrics, logs: logs}
+}
+
+// factory returns f after checking the exporters of signal can be created
+// with it.
+func (m *Mux) factory(f *Factory, signal pipeline.Signal) (*Factory, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stopped {
+		return nil, errMuxShutDown
+	}
+	if f == nil {
+		return nil, fmt.Errorf("collex: no %s factory", signal)
+	}
+	return f, nil
+}
+
+// add adds the shutdown function of an exporter of the Mux. If the Mux was
+// shut down while the exporter was created, it is shut down immediately.
+func (m *Mux) add(ctx context.Context, shutdown func(context.Context) error) error {
+	m.mu.Lock()
+	if m.stopped {
+		m.mu.Unlock()
+		return errors.Join(errMuxShutDown, shutdown(ctx))
+	}
+	m.shutdown = append(m.shutdown, shutdown)
+	m.mu.Unlock()
+	return nil
+}
+
+// SpanExporter returns a SpanExporter of the traces Factory configured with
+// cfg. If cfg is nil the factory default configuration is used.
+func (m *Mux) SpanExporter(ctx context.Context, cfg component.Config) (trace.SpanExporter, error) {
+	f, err := m.factory(m.traces, pipeline.SignalTraces)
+	if err != nil {
+		return nil, err
+	}
+	exp, err := f.SpanExporter(ctx, cfg)
+	if exp == nil {
+		return nil, err
+	}
+	if aErr := m.add(ctx, exp.Shutdown); aErr != nil {
+		return nil, errors.Join(err, aErr)
+	}
+	return exp, err
+}
+
+// MetricExporter returns a metric Exporter of the metrics Factory configured
+// with cfg. If cfg is nil the factory default configuration is used.
+func (m *Mux) MetricExporter(ctx context.Context, cfg component.Config) (metric.Exporter, error) {
+	f, err := m.factory(m.metrics, pipeline.SignalMetrics)
+	if err != nil {
+		return nil, err
+	}
+	exp, err := f.MetricExporter(ctx, cfg)
+	if exp == nil {
+		return nil, err
+	}
+	if aErr := m.add(ctx, exp.Shutdown); aErr != nil {
+		return nil, errors.Join(err, aErr)
+	}
+	return exp, err
+}
+
+// LogExporter returns a log Exporter of the logs Factory configured with cfg.
+// If cfg is nil the factory default configuration is used.
+func (m *Mux) LogExporter(ctx context.Context, cfg component.Config) (log.Exporter, error) {
+	f, err := m.factory(m.logs, pipeline.SignalLogs)
+	if err != nil {
+		return nil, err
+	}
+	exp, err := f.LogExporter(ctx, cfg)
+	if exp == nil {
+		return nil, err
+	}
+	if aErr := m.add(ctx, exp.Shutdown); aErr != nil {
+		return nil, errors.Join(err, aErr)
+	}
+	return exp, err
+}
+
+// Shutdown shuts down all the exporters returned by the Mux concurrently, so
+// the sending queues of all the backends drain within the deadline of ctx.
+// Exporters already shut down, e.g. with their provider, are not affected. No
+// exporters can be created afterwards.
+func (m *Mux) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	m.stopped = true
+	shutdown := m.shutdown
+	m.shutdown = nil
+	m.mu.Unlock()
+
+	return fanout(FanoutAll, len(shutdown), func(i int) error { return shutdown[i](ctx) })
+}
@@ -0,0 +1,64 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/MrAlias/collex"
+)
+
+func TestMux(t *testing.T) {
+	var traces, metrics sink
+	tf, err := collex.NewFactory(traces.factory(), settings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mf, err := collex.NewFactory(metrics.factory(), settings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := collex.NewMux(tf, mf, nil)
+	ctx := context.Background()
+
+	spanExp, err := mux.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mux.MetricExporter(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mux.LogExporter(ctx, nil); err == nil {
+		t.Error("log exporter created without a logs factory")
+	}
+
+	if err := spanExp.ExportSpans(ctx, spans(2)); err != nil {
+		t.Fatal(err)
+	}
+	if len(traces.traces) != 1 || len(metrics.traces) != 0 {
+		t.Errorf("spans sent to the traces factory %d times and the metrics factory %d times, want 1 and 0", len(traces.traces), len(metrics.traces))
+	}
+
+	if err := mux.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !traces.stopped {
+		t.Error("span exporter not shut down with the mux")
+	}
+	if _, err := mux.SpanExporter(ctx, nil); err == nil {
+		t.Error("span exporter created after shutdown")
+	}
+}
@@ -0,0 +1,253 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/open-telemetry/opamp-go/client"
+	"github.com/open-telemetry/opamp-go/client/types"
+	"github.com/open-telemetry/opamp-go/protobufs"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/confmap"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+const defaultOpAMPConfigName = "exporter"
+
+// opampCapabilities are the capabilities an OpAMPClient reports to the
+// server.
+const opampCapabilities = protobufs.AgentCapabilities_AgentCapabilities_ReportsStatus |
+	protobufs.AgentCapabilities_AgentCapabilities_AcceptsRemoteConfig |
+	protobufs.AgentCapabilities_AgentCapabilities_ReportsRemoteConfig |
+	protobufs.AgentCapabilities_AgentCapabilities_ReportsEffectiveConfig
+
+// identifyingAttributes are the resource attributes identifying an
+// application to an OpAMP server. The others describe it.
+var identifyingAttributes = map[attribute.Key]bool{
+	"service.name":        true,
+	"service.namespace":   true,
+	"service.instance.id": true,
+	"service.version":     true,
+}
+
+// OpAMPClient connects exporters returned by a Factory to an OpAMP server
+// managing a fleet of applications. The exporter configurations pushed by the
+// server are applied to the exporters like RemoteConfig does, and the
+// configuration they use is reported back to the server.
+type OpAMPClient struct {
+	// Factory is the ExporterFactory passed to NewFactory. Pushed
+	// configurations are loaded with it.
+	Factory component.Factory
+	// Providers are additional confmap providers used to load pushed
+	// configurations, see ConfigFromYAML.
+	Providers []confmap.ProviderFactory
+	// ServerURL is the URL of the OpAMP server. The client connects with a
+	// WebSocket if its scheme is ws or wss, e.g.
+	// wss://opamp.example.com/v1/opamp, and polls the server with plain
+	// HTTP requests otherwise.
+	ServerURL string
+	// Header, if not nil, are the headers sent to the server, e.g. to
+	// authenticate the application.
+	Header http.Header
+	// TLSConfig, if not nil, is the TLS configuration of the connection to
+	// the server.
+	TLSConfig *tls.Config
+	// InstanceUID identifies the application to the server. If it is zero, a
+	// new one is generated when Run is called.
+	InstanceUID [16]byte
+	// Resource describes the application to the server. Its service
+	// attributes, e.g. service.name, identify the application and the others
+	// describe it. If nil, the default resource of the OpenTelemetry Go SDK is
+	// used.
+	Resource *resource.Resource
+	// ConfigName is the name of the file, in the configuration pushed by the
+	// server, holding the YAML of the exporter configuration. It is also the
+	// name the effective configuration is reported with. If empty, exporter
+	// is used.
+	ConfigName string
+	// ErrorFunc, if not nil, is called with the errors loading or applying a
+	// pushed configuration, and with the errors connecting to the server.
+	// The exporters keep their previous configuration when a pushed one
+	// fails, and the failure is reported to the server.
+	ErrorFunc func(error)
+}
+
+// Run connects to the OpAMP server and applies the configurations it pushes
+// to exps, exporters returned by a Factory, until ctx is done. The
+// configuration of the first of exps is reported to the server as the
+// effective configuration.
+//
+// An error is returned if the client cannot be started or if exps were not
+// returned by a Factory. Otherwise, the error of ctx is returned once it is
+// done and the client has disconnected.
+//
+//	c := collex.OpAMPClient{Factory: otlpexporter.NewFactory(), ServerURL: "wss://opamp.example.com/v1/opamp"}
+//	go func() { _ = c.Run(ctx, spanExporter, logExporter) }()
+func (c OpAMPClient) Run(ctx context.Context, exps ...any) error {
+	sups, err := supervisorsOf(exps)
+	if err != nil {
+		return err
+	}
+	uid := types.InstanceUid(c.InstanceUID)
+	if uid == (types.InstanceUid{}) {
+		id, err := uuid.NewV7()
+		if err != nil {
+			return err
+		}
+		uid = types.InstanceUid(id)
+	}
+
+	var oc client.OpAMPClient
+	if strings.HasPrefix(c.ServerURL, "ws://") || strings.HasPrefix(c.ServerURL, "wss://") {
+		oc = client.NewWebSocket(nil)
+	} else {
+		oc = client.NewHTTP(nil)
+	}
+	if err := oc.SetAgentDescription(c.description()); err != nil {
+		return err
+	}
+	h := opampHandler{c: c, client: oc, sups: sups}
+	err = oc.Start(ctx, types.StartSettings{
+		OpAMPServerURL: c.ServerURL,
+		Header:         c.Header,
+		TLSConfig:      c.TLSConfig,
+		InstanceUid:    uid,
+		Capabilities:   opampCapabilities,
+		Callbacks: types.Callbacks{
+			OnConnectFailed:    func(_ context.Context, err error) { c.report(err) },
+			OnMessage:          h.onMessage,
+			GetEffectiveConfig: h.effectiveConfig,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	<-ctx.Done()
+	if err := oc.Stop(context.WithoutCancel(ctx)); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// configName returns the name of the exporter configuration file.
+func (c OpAMPClient) configName() string {
+	if c.ConfigName == "" {
+		return defaultOpAMPConfigName
+	}
+	return c.ConfigName
+}
+
+// report calls the ErrorFunc of c with err, if both are not nil.
+func (c OpAMPClient) report(err error) {
+	if err != nil && c.ErrorFunc != nil {
+		c.ErrorFunc(err)
+	}
+}
+
+// description returns the description of the application sent to the
+// server.
+func (c OpAMPClient) description() *protobufs.AgentDescription {
+	res := c.Resource
+	if res == nil {
+		res = resource.Default()
+	}
+	descr := new(protobufs.AgentDescription)
+	for _, kv := range res.Attributes() {
+		v := &protobufs.KeyValue{
+			Key: string(kv.Key),
+			Value: &protobufs.AnyValue{
+				Value: &protobufs.AnyValue_StringValue{StringValue: kv.Value.Emit()},
+			},
+		}
+		if identifyingAttributes[kv.Key] {
+			descr.IdentifyingAttributes = append(descr.IdentifyingAttributes, v)
+		} else {
+			descr.NonIdentifyingAttributes = append(descr.NonIdentifyingAttributes, v)
+		}
+	}
+	return descr
+}
+
+// opampHandler handles the messages of an OpAMP server for the exporters of
+// sups.
+type opampHandler struct {
+	c      OpAMPClient
+	client client.OpAMPClient
+	sups   []*supervisor
+}
+
+// onMessage applies the configuration pushed in msg, if any, and reports
+// whether it was applied to the server.
+func (h opampHandler) onMessage(ctx context.Context, msg *types.MessageData) {
+	if msg.RemoteConfig == nil {
+		return
+	}
+	err := h.apply(ctx, msg.RemoteConfig)
+	h.c.report(err)
+
+	status := &protobufs.RemoteConfigStatus{
+		LastRemoteConfigHash: msg.RemoteConfig.ConfigHash,
+		Status:               protobufs.RemoteConfigStatuses_RemoteConfigStatuses_APPLIED,
+	}
+	if err != nil {
+		status.Status = protobufs.RemoteConfigStatuses_RemoteConfigStatuses_FAILED
+		status.ErrorMessage = err.Error()
+	}
+	h.c.report(h.client.SetRemoteConfigStatus(status))
+	if err == nil {
+		h.c.report(h.client.UpdateEffectiveConfig(ctx))
+	}
+}
+
+// apply applies the exporter configuration of remote to the exporters.
+func (h opampHandler) apply(ctx context.Context, remote *protobufs.AgentRemoteConfig) error {
+	name := h.c.configName()
+	file, ok := remote.GetConfig().GetConfigMap()[name]
+	if !ok {
+		return fmt.Errorf("collex: no %s configuration pushed", name)
+	}
+	cfg, err := ConfigFromYAML(h.c.Factory, file.Body, h.c.Providers...)
+	if err != nil {
+		return err
+	}
+	return applyConfig(ctx, cfg, h.sups)
+}
+
+// effectiveConfig returns the configuration of the first exporter, reported
+// to the server as the effective configuration.
+func (h opampHandler) effectiveConfig(context.Context) (*protobufs.EffectiveConfig, error) {
+	if len(h.sups) == 0 {
+		return nil, nil
+	}
+	body, err := ConfigToYAML(h.sups[0].config())
+	if err != nil {
+		return nil, err
+	}
+	return &protobufs.EffectiveConfig{
+		ConfigMap: &protobufs.AgentConfigMap{
+			ConfigMap: map[string]*protobufs.AgentConfigFile{
+				h.c.configName(): {Body: body, ContentType: "text/yaml"},
+			},
+		},
+	}, nil
+}
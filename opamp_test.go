@@ -0,0 +1,175 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MrAlias/collex"
+	"github.com/open-telemetry/opamp-go/protobufs"
+	"github.com/open-telemetry/opamp-go/server"
+	"github.com/open-telemetry/opamp-go/server/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// opampServer is an OpAMP server pushing exporter configurations to the
+// application connected to it.
+type opampServer struct {
+	url   string
+	conns chan types.Connection
+	msgs  chan *protobufs.AgentToServer
+}
+
+func newOpAMPServer(t *testing.T) *opampServer {
+	s := &opampServer{
+		conns: make(chan types.Connection, 1),
+		msgs:  make(chan *protobufs.AgentToServer, 100),
+	}
+	handler, connContext, err := server.New(nil).Attach(server.Settings{
+		Callbacks: types.Callbacks{
+			OnConnecting: func(*http.Request) types.ConnectionResponse {
+				return types.ConnectionResponse{Accept: true, ConnectionCallbacks: types.ConnectionCallbacks{
+					OnConnected: func(_ context.Context, conn types.Connection) { s.conns <- conn },
+					OnMessage: func(_ context.Context, _ types.Connection, msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+						s.msgs <- msg
+						return &protobufs.ServerToAgent{InstanceUid: msg.InstanceUid}
+					},
+				}}
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(handler))
+	ts.Config.ConnContext = connContext
+	ts.Start()
+	t.Cleanup(ts.Close)
+	s.url = "ws" + strings.TrimPrefix(ts.URL, "http")
+	return s
+}
+
+// push sends the exporter configuration yaml to the application connected
+// to s with hash.
+func (s *opampServer) push(t *testing.T, conn types.Connection, yaml, hash string) {
+	t.Helper()
+	err := conn.Send(context.Background(), &protobufs.ServerToAgent{
+		RemoteConfig: &protobufs.AgentRemoteConfig{
+			Config: &protobufs.AgentConfigMap{ConfigMap: map[string]*protobufs.AgentConfigFile{
+				"exporter": {Body: []byte(yaml), ContentType: "text/yaml"},
+			}},
+			ConfigHash: []byte(hash),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// status waits for the application to report the status of the
+// configuration with hash, and returns it with the last effective
+// configuration reported.
+func (s *opampServer) status(t *testing.T, hash string) (*protobufs.RemoteConfigStatus, string) {
+	t.Helper()
+	var effective string
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case msg := <-s.msgs:
+			if f := msg.GetEffectiveConfig().GetConfigMap().GetConfigMap()["exporter"]; f != nil {
+				effective = string(f.Body)
+			}
+			if st := msg.GetRemoteConfigStatus(); st != nil && string(st.LastRemoteConfigHash) == hash {
+				return st, effective
+			}
+		case <-timeout:
+			t.Fatalf("no status reported for configuration %q", hash)
+		}
+	}
+}
+
+func TestOpAMPClient(t *testing.T) {
+	var e endpoints
+	f, err := collex.NewFactory(e.factory(), settings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	exp, err := f.SpanExporter(ctx, &endpointConfig{Endpoint: "old"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = exp.Shutdown(context.Background()) })
+
+	s := newOpAMPServer(t)
+	c := collex.OpAMPClient{
+		Factory:   e.factory(),
+		ServerURL: s.url,
+		Resource:  resource.NewSchemaless(attribute.String("service.name", "app")),
+	}
+	done := make(chan error, 1)
+	go func() { done <- c.Run(ctx, exp) }()
+
+	var conn types.Connection
+	select {
+	case conn = <-s.conns:
+	case <-time.After(10 * time.Second):
+		t.Fatal("client did not connect")
+	}
+	msg := <-s.msgs
+	id := msg.GetAgentDescription().GetIdentifyingAttributes()
+	if len(id) != 1 || id[0].Key != "service.name" || id[0].Value.GetStringValue() != "app" {
+		t.Errorf("identifying attributes = %v, want service.name=app", id)
+	}
+
+	s.push(t, conn, "endpoint: new", "1")
+	st, effective := s.status(t, "1")
+	if st.Status != protobufs.RemoteConfigStatuses_RemoteConfigStatuses_APPLIED {
+		t.Errorf("status = %v (%s), want applied", st.Status, st.ErrorMessage)
+	}
+	if effective != "endpoint: new\n" {
+		t.Errorf("effective configuration = %q, want %q", effective, "endpoint: new\n")
+	}
+
+	// Configurations that fail are reported and not applied.
+	s.push(t, conn, "endpoint: down", "2")
+	if st, _ := s.status(t, "2"); st.Status != protobufs.RemoteConfigStatuses_RemoteConfigStatuses_FAILED || st.ErrorMessage == "" {
+		t.Errorf("status = %v (%q), want failed with an error message", st.Status, st.ErrorMessage)
+	}
+	if out, _ := (collex.RemoteConfig{}).EffectiveConfig(exp); string(out) != "endpoint: new\n" {
+		t.Errorf("exporter configuration = %q, want the last applied", out)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("Run returned %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Run did not return once its context was done")
+	}
+
+	invalid := collex.OpAMPClient{ServerURL: s.url}
+	if err := invalid.Run(context.Background(), struct{}{}); err == nil {
+		t.Error("expected an error for an exporter not returned by a Factory")
+	}
+}
@@ -0,0 +1,444 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/configretry"
+	"go.opentelemetry.io/collector/pipeline"
+	"go.opentelemetry.io/otel/sdk/metric"
+	api "go.opentelemetry.io/otel/trace"
+)
+
+// Option configures a Factory.
+type Option interface {
+	apply(config) config
+}
+
+type config struct {
+	processors   []Processor
+	connectors   []Connector
+	extensions   []Extension
+	host         component.Host
+	status       StatusFunc
+	onErr        ErrorFunc
+	temporality  metric.TemporalitySelector
+	gates        []string
+	timeout      time.Duration
+	restart      RestartConfig
+	metadata     map[string][]string
+	headers      map[string]string
+	overrides    map[pipeline.Signal]map[string]any
+	clock        Clock
+	tracing      api.TracerProvider
+	onExportErr  ExportErrorFunc
+	middleware   []Middleware
+	breaker      CircuitBreakerConfig
+	rateLimit    RateLimitConfig
+	deadLetter   DeadLetterConfig
+	retry        configretry.BackOffConfig
+	backpressure *BackpressureConfig
+	debugDump    bool
+	shedding     *SheddingConfig
+	maxBytes     MaxBytesConfig
+	expTimeout   time.Duration
+	logDedup     time.Duration
+}
+
+func newConfig(opts []Option) config {
+	var c config
+	for _, o := range opts {
+		c = o.apply(c)
+	}
+	return c
+}
+
+type optionFunc func(config) config
+
+func (fn optionFunc) apply(c config) config {
+	return fn(c)
+}
+
+// WithProcessors returns an Option that sets collector processors the
+// telemetry passes through, in the order they are provided, before it is sent
+// to the wrapped exporter.
+//
+// This is how a memory limiter processor is placed in front of the wrapped
+// exporter so telemetry is refused under memory pressure, the same way it
+// would be in a collector deployment.
+func WithProcessors(p ...Processor) Option {
+	return optionFunc(func(c config) config {
+		c.processors = append(c.processors, p...)
+		return c
+	})
+}
+
+// WithConnectors returns an Option that sets collector connectors that
+// receive the same telemetry as the wrapped exporter, after it has passed
+// through any processors.
+//
+// This is how the spanmetrics connector is run in-process to generate metrics
+// from the exported spans and send them to a collector metrics exporter.
+func WithConnectors(c ...Connector) Option {
+	return optionFunc(func(cfg config) config {
+		cfg.connectors = append(cfg.connectors, c...)
+		return cfg
+	})
+}
+
+// WithExtensions returns an Option that sets collector extensions made
+// available to the wrapped exporter, and all other components, through their
+// host. Many exporters depend on extensions, e.g. for authentication or
+// persistent storage, and fail to start if the extension they refer to is
+// not found.
+//
+// The extensions are shared by all the exporters created by the Factory. They
+// are started, once, before the first exporter and shut down after the last
+// exporter is shut down. Extensions that depend on other extensions are
+// started after and shut down before them.
+func WithExtensions(e ...Extension) Option {
+	return optionFunc(func(c config) config {
+		c.extensions = append(c.extensions, e...)
+		return c
+	})
+}
+
+// WithHost returns an Option that sets the host the wrapped exporter, and all
+// other components, are started with. This allows collex to be embedded in a
+// system that manages its own extensions and component status.
+//
+// The extensions of host are available to the components along with the ones
+// set with WithExtensions. If no extensions or StatusFunc are set, the
+// components are started with host as-is. Otherwise, the status changes of
+// the components are also reported to host if it is a
+// componentstatus.Reporter.
+func WithHost(host component.Host) Option {
+	return optionFunc(func(c config) config {
+		c.host = host
+		return c
+	})
+}
+
+// WithStatusFunc returns an Option that sets fn to be called with the status
+// changes of the wrapped exporter and all other components. This is how an
+// application reacts when the wrapped exporter reports it is unable to export,
+// e.g. by failing a readiness probe or switching to a fallback.
+func WithStatusFunc(fn StatusFunc) Option {
+	return optionFunc(func(c config) config {
+		c.status = fn
+		return c
+	})
+}
+
+// WithErrorFunc returns an Option that sets fn to be called when the wrapped
+// exporter, or any other component, reports a fatal or permanent error. This
+// is how an application pages, fails its readiness, or falls back to another
+// exporter instead of the error only being logged.
+//
+// It is called along with the StatusFunc set with WithStatusFunc, if any.
+func WithErrorFunc(fn ErrorFunc) Option {
+	return optionFunc(func(c config) config {
+		c.onErr = fn
+		return c
+	})
+}
+
+// WithExportErrorFunc returns an Option that sets fn to be called whenever an
+// export of an exporter of the Factory fails. Unlike the ErrorFunc set with
+// WithErrorFunc, it is called with the error of each export, so an
+// application can count the telemetry it lost or fall back to another
+// exporter without wrapping the exporters itself.
+func WithExportErrorFunc(fn ExportErrorFunc) Option {
+	return optionFunc(func(c config) config {
+		c.onExportErr = fn
+		return c
+	})
+}
+
+// WithMiddleware returns an Option that wraps the exports of the exporters of
+// the Factory with mw, like HTTP middleware. The first Middleware is the
+// outermost, it is called first with each export. Each export is passed to
+// the middleware after it is converted to collector data and before it is
+// sent to any processors.
+//
+// Middleware from multiple WithMiddleware options is appended in order.
+func WithMiddleware(mw ...Middleware) Option {
+	return optionFunc(func(c config) config {
+		c.middleware = append(c.middleware, mw...)
+		return c
+	})
+}
+
+// WithCircuitBreaker returns an Option that guards the wrapped exporter with a
+// circuit breaker configured with cfg. Once it is open, exports fail fast with
+// ErrCircuitOpen instead of waiting on a backend that is down. See
+// NewCircuitBreakerConfig for the defaults.
+//
+// The breaker is kept by each exporter of the Factory, for the exporter it
+// wraps. It is reset when the wrapped exporter is recreated or swapped.
+func WithCircuitBreaker(cfg CircuitBreakerConfig) Option {
+	return optionFunc(func(c config) config {
+		c.breaker = cfg
+		return c
+	})
+}
+
+// WithRateLimit returns an Option that limits the rate of the exports to the
+// wrapped exporter, in items or bytes per second, with the token bucket
+// configured by cfg. Exports exceeding the limit wait, or are dropped if
+// cfg.Drop is true.
+//
+// The bucket is kept by each exporter of the Factory. It is reset when the
+// wrapped exporter is recreated or swapped.
+func WithRateLimit(cfg RateLimitConfig) Option {
+	return optionFunc(func(c config) config {
+		c.rateLimit = cfg
+		return c
+	})
+}
+
+// WithDeadLetter returns an Option that writes the telemetry the wrapped
+// exporter fails to export, permanently or once the retries of WithRetry were
+// exhausted, to the dead-letter directory of cfg, so it can be replayed later
+// instead of being lost. See DeadLetterConfig for the failures it does not
+// see.
+func WithDeadLetter(cfg DeadLetterConfig) Option {
+	return optionFunc(func(c config) config {
+		c.deadLetter = cfg
+		return c
+	})
+}
+
+// WithRetry returns an Option that retries the exports the wrapped exporter
+// fails with the exponential backoff of cfg, until they succeed, fail with a
+// permanent error, their context is done, or cfg.MaxElapsedTime passes. Only
+// the rejected telemetry of an export that partially failed is retried.
+//
+// This is for exporters without retries of their own. Exporters built with
+// the exporterhelper of the collector are configured to retry instead.
+func WithRetry(cfg configretry.BackOffConfig) Option {
+	return optionFunc(func(c config) config {
+		c.retry = cfg
+		return c
+	})
+}
+
+// WithBackpressure returns an Option that sets what the exports of the
+// exporters of the Factory do when the sending queue of the wrapped exporter
+// is full: block until it has room, or be dropped if cfg.Drop is true.
+// Without it, the configuration of the wrapped exporter decides.
+func WithBackpressure(cfg BackpressureConfig) Option {
+	return optionFunc(func(c config) config {
+		c.backpressure = &cfg
+		return c
+	})
+}
+
+// WithMaxBytes returns an Option that limits the size of the telemetry sent
+// to the wrapped exporter in one call, configured with cfg. Larger exports
+// are split into calls under the limit, or rejected with ErrBatchTooLarge,
+// instead of failing as a whole in the backend, e.g. over the maximum
+// message size of a gRPC server.
+func WithMaxBytes(cfg MaxBytesConfig) Option {
+	return optionFunc(func(c config) config {
+		c.maxBytes = cfg
+		return c
+	})
+}
+
+// WithShedding returns an Option that sheds the telemetry of the exporters
+// of the Factory under memory pressure, configured with cfg, before the
+// application runs out of memory. Low-priority telemetry is shed first, all
+// telemetry once the pressure keeps rising. Shed telemetry is counted by the
+// collex.exporter.dropped metric with the memory_pressure reason. See
+// NewSheddingConfig for the defaults.
+func WithShedding(cfg SheddingConfig) Option {
+	return optionFunc(func(c config) config {
+		c.shedding = &cfg
+		return c
+	})
+}
+
+// WithLogDedup returns an Option that logs each distinct error of the
+// components of the Factory, like the wrapped exporter, once per window. A
+// backend that is down then produces a periodic error with the number of
+// identical ones suppressed, rather than one for each export flooding the
+// logs of the application. Entries without an error are all logged.
+func WithLogDedup(window time.Duration) Option {
+	return optionFunc(func(c config) config {
+		c.logDedup = window
+		return c
+	})
+}
+
+// WithDebugDump returns an Option that logs each export of the exporters of
+// the Factory, converted to OTLP JSON, at debug level with the logger of the
+// Factory settings. It shows what is sent to the processors and wrapped
+// exporter, after any Middleware, when telemetry never shows up in a backend.
+//
+// The logger needs debug level enabled. Setting the DebugDumpEnv environment
+// variable has the same effect.
+func WithDebugDump() Option {
+	return optionFunc(func(c config) config {
+		c.debugDump = true
+		return c
+	})
+}
+
+// WithFeatureGates returns an Option that enables or disables collector
+// feature gates before the Factory is created. Each gate is in the format of
+// the --feature-gates flag of the collector: its ID, or its ID prefixed with
+// "+" to enable it and "-" to disable it.
+//
+// Feature gates are global to the process. They affect all the collector
+// components of the application, not only the ones of the Factory.
+func WithFeatureGates(gates ...string) Option {
+	return optionFunc(func(c config) config {
+		c.gates = append(c.gates, gates...)
+		return c
+	})
+}
+
+// WithExportTimeout returns an Option that bounds each export of the
+// exporters of the Factory with a deadline of d, independent of the timeout
+// configuration of the wrapped exporter. This aligns the exports with the
+// export timeout of the OpenTelemetry Go SDK processor or reader the exporter
+// is registered with. Exports whose context has an earlier deadline keep it.
+//
+// With a sending queue, the exports return once the telemetry is queued and
+// d only bounds the time waiting for room in the queue.
+func WithExportTimeout(d time.Duration) Option {
+	return optionFunc(func(c config) config {
+		c.expTimeout = d
+		return c
+	})
+}
+
+// WithShutdownTimeout returns an Option that bounds the time the exporters of
+// the Factory take to shut down. The wrapped exporter is stopped only after
+// the processors in front of it have flushed and its sending queue is drained,
+// or once the timeout has passed. By default, only the deadline of the
+// context passed to Shutdown is used.
+//
+// This keeps short-lived jobs from losing the tail of their telemetry while
+// ensuring they still terminate when the destination is unavailable.
+func WithShutdownTimeout(d time.Duration) Option {
+	return optionFunc(func(c config) config {
+		c.timeout = d
+		return c
+	})
+}
+
+// WithRestart returns an Option that has the wrapped exporter recreated, with
+// all the other components of the exporters of the Factory, when it reports a
+// fatal error or fails to start. Exporters are recreated with the backoff of
+// cfg and telemetry is buffered, up to cfg.MaxBuffered exports, until they
+// are started again. See NewRestartConfig for the defaults.
+//
+// Without this, an exporter that fails stays in the TracerProvider,
+// MeterProvider, or LoggerProvider it is registered with, unable to export.
+func WithRestart(cfg RestartConfig) Option {
+	return optionFunc(func(c config) config {
+		c.restart = cfg
+		return c
+	})
+}
+
+// WithClock returns an Option that sets the Clock the timing logic of the
+// exporters uses, e.g. the backoff between attempts to recreate them. The
+// system clock is used by default.
+//
+// This lets tests control time instead of sleeping.
+func WithClock(clock Clock) Option {
+	return optionFunc(func(c config) config {
+		c.clock = clock
+		return c
+	})
+}
+
+// WithTracing returns an Option that traces the exports of the exporters of
+// the Factory with tp. Each export is traced by a span with the number of
+// items exported and the error of the export, if any. The exports are not
+// traced by default.
+//
+// tp should be dedicated to the telemetry of collex, not one the exporters
+// are registered with. If it is, the exports of the spans of collex itself
+// are not traced, otherwise each would create a span to export endlessly.
+// The span exporter then needs to be registered with a batching span
+// processor, a synchronous one would deadlock exporting the span of its own
+// export.
+func WithTracing(tp api.TracerProvider) Option {
+	return optionFunc(func(c config) config {
+		c.tracing = tp
+		return c
+	})
+}
+
+// WithMetadata returns an Option that sets md as the collector client.Info
+// metadata of the telemetry exported without any, e.g. the telemetry batched
+// by the OpenTelemetry Go SDK. Use ContextWithMetadata to set the metadata of
+// each export instead.
+//
+// This allows an exporter to be dedicated to a tenant when extensions like
+// headers_setter take a tenant header from the metadata.
+func WithMetadata(md map[string][]string) Option {
+	return optionFunc(func(c config) config {
+		c.metadata = md
+		return c
+	})
+}
+
+// WithHeaders returns an Option that adds headers, e.g. a tenant ID or an API
+// key, to every request of the wrapped exporter. The headers replace the
+// configured headers with the same name. They are only added if the
+// exporter configuration has HTTP or gRPC client settings, see
+// ConfigureClient.
+func WithHeaders(headers map[string]string) Option {
+	return optionFunc(func(c config) config {
+		c.headers = headers
+		return c
+	})
+}
+
+// WithSignalOverrides returns an Option that overrides settings of the
+// configuration of the wrapped exporter for a signal. The settings are keyed
+// like in a collector configuration, e.g. {"table": "otel_logs"}, and
+// override the configuration passed to the LogExporter for logs.
+//
+// This allows a single base configuration to be shared by the exporters of
+// all signals instead of a near-duplicate configuration for each.
+func WithSignalOverrides(overrides map[pipeline.Signal]map[string]any) Option {
+	return optionFunc(func(c config) config {
+		c.overrides = overrides
+		return c
+	})
+}
+
+// WithTemporalitySelector returns an Option that sets the temporality of the
+// metrics exported by the MetricExporter of the Factory. By default, the
+// metric.DefaultTemporalitySelector is used.
+//
+// Delta temporality reduces the state the OpenTelemetry Go SDK needs to keep.
+// When the wrapped exporter only accepts cumulative metrics, place the
+// deltatocumulative processor first with WithProcessors so the metrics are
+// accumulated in the pipeline instead.
+func WithTemporalitySelector(s metric.TemporalitySelector) Option {
+	return optionFunc(func(c config) config {
+		c.temporality = s
+		return c
+	})
+}
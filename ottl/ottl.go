@@ -0,0 +1,159 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ottl provides conditions of the routes of the routing exporters of
+// collex written in the OpenTelemetry Transformation Language (OTTL), like
+// those of the routing connector of the collector.
+package ottl // import "github.com/MrAlias/collex/ottl"
+
+import (
+	"context"
+	"errors"
+
+	"github.com/MrAlias/collex"
+	pkgottl "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottldatapoint"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottllog"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottlspan"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/ottlfuncs"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+// errNoCondition is returned when a condition is created without OTTL
+// conditions.
+var errNoCondition = errors.New("ottl: no condition")
+
+// Option configures the conditions.
+type Option interface {
+	apply(config) config
+}
+
+type config struct {
+	set component.TelemetrySettings
+}
+
+type optionFunc func(config) config
+
+func (fn optionFunc) apply(c config) config {
+	return fn(c)
+}
+
+func newConfig(opts []Option) config {
+	c := config{set: component.TelemetrySettings{Logger: zap.NewNop()}}
+	for _, opt := range opts {
+		c = opt.apply(c)
+	}
+	return c
+}
+
+// WithTelemetrySettings returns an Option that sets the telemetry settings the
+// conditions are parsed and evaluated with. The errors of their evaluation are
+// logged with its Logger. A no-op Logger is used by default.
+func WithTelemetrySettings(set component.TelemetrySettings) Option {
+	return optionFunc(func(c config) config {
+		c.set = set
+		return c
+	})
+}
+
+// condition returns the evaluation of the OTTL conditions parsed by the
+// parser newParser returns. It is satisfied if any of the conditions is. The
+// conditions failing to evaluate are logged and not satisfied.
+func condition[K any](conditions []string, opts []Option, newParser func(map[string]pkgottl.Factory[K], component.TelemetrySettings) (pkgottl.Parser[K], error)) (func(context.Context, K) bool, error) {
+	if len(conditions) == 0 {
+		return nil, errNoCondition
+	}
+	c := newConfig(opts)
+	p, err := newParser(ottlfuncs.StandardConverters[K](), c.set)
+	if err != nil {
+		return nil, err
+	}
+	conds, err := p.ParseConditions(conditions)
+	if err != nil {
+		return nil, err
+	}
+	seq := pkgottl.NewConditionSequence(
+		conds,
+		c.set,
+		pkgottl.WithLogicOperation[K](pkgottl.Or),
+		pkgottl.WithConditionSequenceErrorMode[K](pkgottl.IgnoreError),
+	)
+	return func(ctx context.Context, tCtx K) bool {
+		ok, err := seq.Eval(ctx, tCtx)
+		return err == nil && ok
+	}, nil
+}
+
+// NewSpanCondition returns the condition of a collex.SpanRoute satisfied by
+// the spans satisfying any of the OTTL conditions of the span context, e.g.
+//
+//	attributes["http.route"] == "/checkout"
+//
+// The standard converters of OTTL can be used. An error is returned if a
+// condition is not valid.
+func NewSpanCondition(conditions []string, opts ...Option) (collex.SpanCondition, error) {
+	eval, err := condition(conditions, opts, func(f map[string]pkgottl.Factory[ottlspan.TransformContext], set component.TelemetrySettings) (pkgottl.Parser[ottlspan.TransformContext], error) {
+		return ottlspan.NewParser(f, set)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, span ptrace.Span, scope pcommon.InstrumentationScope, res pcommon.Resource) bool {
+		return eval(ctx, ottlspan.NewTransformContext(span, scope, res, ptrace.NewScopeSpans(), ptrace.NewResourceSpans()))
+	}, nil
+}
+
+// NewDataPointCondition returns the condition of a collex.MetricRoute
+// satisfied by the data points satisfying any of the OTTL conditions of the
+// datapoint context, e.g.
+//
+//	metric.name == "http.server.request.duration" and attributes["http.response.status_code"] >= 500
+//
+// The standard converters of OTTL can be used. An error is returned if a
+// condition is not valid.
+func NewDataPointCondition(conditions []string, opts ...Option) (collex.DataPointCondition, error) {
+	eval, err := condition(conditions, opts, func(f map[string]pkgottl.Factory[ottldatapoint.TransformContext], set component.TelemetrySettings) (pkgottl.Parser[ottldatapoint.TransformContext], error) {
+		return ottldatapoint.NewParser(f, set)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, dp any, m pmetric.Metric, scope pcommon.InstrumentationScope, res pcommon.Resource) bool {
+		return eval(ctx, ottldatapoint.NewTransformContext(dp, m, pmetric.NewMetricSlice(), scope, res, pmetric.NewScopeMetrics(), pmetric.NewResourceMetrics()))
+	}, nil
+}
+
+// NewLogCondition returns the condition of a collex.LogRoute satisfied by the
+// log records satisfying any of the OTTL conditions of the log context, e.g.
+//
+//	severity_number >= SEVERITY_NUMBER_ERROR
+//
+// The standard converters of OTTL can be used. An error is returned if a
+// condition is not valid.
+func NewLogCondition(conditions []string, opts ...Option) (collex.LogCondition, error) {
+	eval, err := condition(conditions, opts, func(f map[string]pkgottl.Factory[ottllog.TransformContext], set component.TelemetrySettings) (pkgottl.Parser[ottllog.TransformContext], error) {
+		return ottllog.NewParser(f, set)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, lr plog.LogRecord, scope pcommon.InstrumentationScope, res pcommon.Resource) bool {
+		return eval(ctx, ottllog.NewTransformContext(lr, scope, res, plog.NewScopeLogs(), plog.NewResourceLogs()))
+	}, nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ottl_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/MrAlias/collex/ottl"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestNewSpanCondition(t *testing.T) {
+	cond, err := ottl.NewSpanCondition([]string{
+		`attributes["http.route"] == "/checkout"`,
+		`resource.attributes["env"] == "staging"`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	span := func(route string) ptrace.Span {
+		s := ptrace.NewSpan()
+		s.Attributes().PutStr("http.route", route)
+		return s
+	}
+	scope, res := pcommon.NewInstrumentationScope(), pcommon.NewResource()
+	ctx := context.Background()
+	if !cond(ctx, span("/checkout"), scope, res) {
+		t.Error("span of /checkout does not satisfy the condition")
+	}
+	if cond(ctx, span("/browse"), scope, res) {
+		t.Error("span of /browse satisfies the condition")
+	}
+	res.Attributes().PutStr("env", "staging")
+	if !cond(ctx, span("/browse"), scope, res) {
+		t.Error("span of staging does not satisfy the second condition")
+	}
+}
+
+func TestNewDataPointCondition(t *testing.T) {
+	cond, err := ottl.NewDataPointCondition([]string{`metric.name == "requests" and attributes["status"] == "error"`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := pmetric.NewMetric()
+	m.SetName("requests")
+	dps := m.SetEmptySum().DataPoints()
+	ok, failed := dps.AppendEmpty(), dps.AppendEmpty()
+	ok.Attributes().PutStr("status", "ok")
+	failed.Attributes().PutStr("status", "error")
+	scope, res := pcommon.NewInstrumentationScope(), pcommon.NewResource()
+	ctx := context.Background()
+	if cond(ctx, ok, m, scope, res) {
+		t.Error("ok data point satisfies the condition")
+	}
+	if !cond(ctx, failed, m, scope, res) {
+		t.Error("error data point does not satisfy the condition")
+	}
+}
+
+func TestNewLogCondition(t *testing.T) {
+	cond, err := ottl.NewLogCondition([]string{`severity_number >= SEVERITY_NUMBER_ERROR`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record := func(sev plog.SeverityNumber) plog.LogRecord {
+		lr := plog.NewLogRecord()
+		lr.SetSeverityNumber(sev)
+		return lr
+	}
+	scope, res := pcommon.NewInstrumentationScope(), pcommon.NewResource()
+	ctx := context.Background()
+	if cond(ctx, record(plog.SeverityNumberInfo), scope, res) {
+		t.Error("info record satisfies the condition")
+	}
+	if !cond(ctx, record(plog.SeverityNumberFatal), scope, res) {
+		t.Error("fatal record does not satisfy the condition")
+	}
+}
+
+func TestNewConditionInvalid(t *testing.T) {
+	if _, err := ottl.NewSpanCondition([]string{`attributes["http.route"] ==`}); err == nil {
+		t.Error("invalid condition parsed")
+	}
+	if _, err := ottl.NewLogCondition(nil); err == nil {
+		t.Error("condition created without OTTL conditions")
+	}
+}
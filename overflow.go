@@ -0,0 +1,167 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"go.opentelemetry.io/collector/exporter/exporterqueue"
+	"go.opentelemetry.io/collector/pipeline"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// OverflowPolicy is what a routing exporter does with the telemetry of a
+// route whose exporter is saturated, its sending queue full, so one slow
+// backend does not stall the telemetry of the others.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock exports the telemetry to the route anyway, waiting for
+	// its sending queue to have room if the queue blocks. It is the default.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowSpill exports the telemetry to the fallback exporter instead,
+	// or drops it if there is none.
+	OverflowSpill
+	// OverflowDrop drops the telemetry, counted by the
+	// collex.exporter.dropped metric with the overflow reason.
+	OverflowDrop
+)
+
+// RoutingOption configures a routing exporter.
+type RoutingOption interface {
+	apply(routingConfig) routingConfig
+}
+
+type routingConfig struct {
+	mp metric.MeterProvider
+}
+
+type routingOptionFunc func(routingConfig) routingConfig
+
+func (fn routingOptionFunc) apply(c routingConfig) routingConfig {
+	return fn(c)
+}
+
+// WithRoutingMeterProvider returns a RoutingOption that sets the
+// MeterProvider the telemetry a routing exporter drops is counted with. The
+// global MeterProvider is used by default.
+func WithRoutingMeterProvider(mp metric.MeterProvider) RoutingOption {
+	return routingOptionFunc(func(c routingConfig) routingConfig {
+		c.mp = mp
+		return c
+	})
+}
+
+// overflow applies the OverflowPolicy of the routes of a routing exporter.
+type overflow struct {
+	policies []OverflowPolicy
+	// exps are the exporters of the routes, their Stats show whether they
+	// are saturated.
+	exps []any
+	// fallback is whether the routing exporter has a fallback exporter.
+	fallback bool
+	dropped  metric.Int64Counter
+	// attrs are the attributes of the telemetry dropped of each route.
+	attrs []metric.MeasurementOption
+}
+
+// overflowRoute is the overflow configuration of a route.
+type overflowRoute struct {
+	name   string
+	policy OverflowPolicy
+	exp    any
+}
+
+func newOverflow(opts []RoutingOption, signal pipeline.Signal, routes []overflowRoute, fallback bool) *overflow {
+	var c routingConfig
+	for _, opt := range opts {
+		c = opt.apply(c)
+	}
+	o := &overflow{fallback: fallback}
+	for i, r := range routes {
+		name := r.name
+		if name == "" {
+			name = strconv.Itoa(i)
+		}
+		o.policies = append(o.policies, r.policy)
+		o.exps = append(o.exps, r.exp)
+		o.attrs = append(o.attrs, metric.WithAttributeSet(attribute.NewSet(
+			attribute.String("exporter", name),
+			attribute.String("signal", signal.String()),
+			attribute.String("reason", "overflow"),
+		)))
+	}
+
+	mp := c.mp
+	if mp == nil {
+		mp = otel.GetMeterProvider()
+	}
+	var err error
+	o.dropped, err = mp.Meter(scopeName).Int64Counter(
+		"collex.exporter.dropped",
+		metric.WithDescription("Number of spans, metric data points, or log records dropped before they were sent to the exporter."),
+		metric.WithUnit("{item}"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+	return o
+}
+
+// saturated returns whether exp, an exporter returned by a Factory, has a
+// full sending queue.
+func saturated(exp any) bool {
+	s, ok := exp.(interface{ Stats() Stats })
+	if !ok {
+		return false
+	}
+	st := s.Stats()
+	return st.QueueCapacity > 0 && st.QueueSize >= st.QueueCapacity
+}
+
+// deliver concurrently sends each of the parts of an export, one for each
+// route followed by one for the fallback, to its destination. A part of a
+// route whose exporter is saturated, or refuses it with a full queue, is sent
+// to the fallback or dropped according to the policy of the route.
+//
+// send sends the part to the exporter of dest and items returns the number
+// of items of the part.
+func (o *overflow) deliver(ctx context.Context, send func(dest, part int) error, items func(part int) int) error {
+	fallback := len(o.policies)
+	return fanout(FanoutAll, fallback+1, func(i int) error {
+		n := items(i)
+		if n == 0 {
+			return nil
+		}
+		if i == fallback || o.policies[i] == OverflowBlock {
+			return send(i, i)
+		}
+		if !saturated(o.exps[i]) {
+			err := send(i, i)
+			if !errors.Is(err, exporterqueue.ErrQueueIsFull) {
+				return err
+			}
+		}
+		if o.policies[i] == OverflowSpill && o.fallback {
+			return send(fallback, i)
+		}
+		o.dropped.Add(ctx, int64(n), o.attrs[i])
+		return nil
+	})
+}
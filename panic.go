@@ -0,0 +1,115 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pipeline"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+)
+
+// ErrPanic is the permanent error of an export that panicked, e.g. in the
+// conversion of the telemetry or in a buggy exporter, instead of crashing the
+// application.
+var ErrPanic = errors.New("collex: export panicked")
+
+// panicGuard recovers the panics of the exports of an exporter. A nil
+// panicGuard recovers them without logging or counting them.
+type panicGuard struct {
+	log    *zap.Logger
+	panics metric.Int64Counter
+	attrs  metric.MeasurementOption
+}
+
+// newPanicGuard returns the panicGuard of the exports of signal logging the
+// panics with log and counting them with m. The panics are logged and counted
+// with the attrs and signal attributes.
+func newPanicGuard(log *zap.Logger, m *selfMetrics, signal pipeline.Signal, attrs ...attribute.KeyValue) *panicGuard {
+	attrs = append(attrs[:len(attrs):len(attrs)], attribute.String("signal", signal.String()))
+	fields := make([]zap.Field, len(attrs))
+	for i, a := range attrs {
+		fields[i] = zap.String(string(a.Key), a.Value.Emit())
+	}
+	return &panicGuard{
+		log:    log.With(fields...),
+		panics: m.panics,
+		attrs:  metric.WithAttributeSet(attribute.NewSet(attrs...)),
+	}
+}
+
+// do calls export and returns its error, or ErrPanic if it panicked. Only
+// the panics of the goroutine calling do are recovered, not those of the
+// goroutines of the exporter, e.g. consuming its sending queue.
+func (g *panicGuard) do(ctx context.Context, export func() error) (err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		err = consumererror.NewPermanent(fmt.Errorf("%w: %v", ErrPanic, r))
+		if g != nil {
+			g.log.Error("Recovered from a panic in an export", zap.Any("panic", r), zap.ByteString("stack", debug.Stack()))
+			g.panics.Add(ctx, 1, g.attrs)
+		}
+	}()
+	return export()
+}
+
+// recoverTraces returns next recovering its panics with g.
+func recoverTraces(g *panicGuard, next consumer.Traces) consumer.Traces {
+	c, err := consumer.NewTraces(func(ctx context.Context, td ptrace.Traces) error {
+		return g.do(ctx, func() error { return next.ConsumeTraces(ctx, td) })
+	}, consumer.WithCapabilities(next.Capabilities()))
+	if err != nil {
+		// Only returned for a nil function.
+		panic(err)
+	}
+	return c
+}
+
+// recoverMetrics returns next recovering its panics with g.
+func recoverMetrics(g *panicGuard, next consumer.Metrics) consumer.Metrics {
+	c, err := consumer.NewMetrics(func(ctx context.Context, md pmetric.Metrics) error {
+		return g.do(ctx, func() error { return next.ConsumeMetrics(ctx, md) })
+	}, consumer.WithCapabilities(next.Capabilities()))
+	if err != nil {
+		// Only returned for a nil function.
+		panic(err)
+	}
+	return c
+}
+
+// recoverLogs returns next recovering its panics with g.
+func recoverLogs(g *panicGuard, next consumer.Logs) consumer.Logs {
+	c, err := consumer.NewLogs(func(ctx context.Context, ld plog.Logs) error {
+		return g.do(ctx, func() error { return next.ConsumeLogs(ctx, ld) })
+	}, consumer.WithCapabilities(next.Capabilities()))
+	if err != nil {
+		// Only returned for a nil function.
+		panic(err)
+	}
+	return c
+}
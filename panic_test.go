@@ -0,0 +1,87 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/MrAlias/collex"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// buggy returns a collector exporter panicking on each export.
+func buggy() exporter.Factory {
+	return exporter.NewFactory(
+		component.MustNewType("buggy"),
+		createEmptyConfig,
+		exporter.WithTraces(func(context.Context, exporter.Settings, component.Config) (exporter.Traces, error) {
+			c, err := consumer.NewTraces(func(context.Context, ptrace.Traces) error {
+				var td *ptrace.Traces
+				_ = td.SpanCount()
+				return nil
+			})
+			return tracesComponent{Traces: c}, err
+		}, component.StabilityLevelDevelopment),
+	)
+}
+
+func TestExportPanic(t *testing.T) {
+	set, reader := meteredSettings()
+	f, err := collex.NewFactory(buggy(), set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = exp.Shutdown(ctx) }()
+
+	err = exp.ExportSpans(ctx, spans(1))
+	if !errors.Is(err, collex.ErrPanic) || !consumererror.IsPermanent(err) {
+		t.Errorf("ExportSpans error = %v, want a permanent %v", err, collex.ErrPanic)
+	}
+	if n := exp.(statser).Stats().InFlight; n != 0 {
+		t.Errorf("%d exports in flight after the panic, want 0", n)
+	}
+	attrs := []attribute.KeyValue{attribute.String("exporter", "buggy"), attribute.String("signal", "traces")}
+	if n := intValue(t, "panics", collect(t, reader, collexScope)["collex.exporter.panics"], attrs...); n != 1 {
+		t.Errorf("recorded %d panics, want 1", n)
+	}
+}
+
+func TestPipelineExportPanic(t *testing.T) {
+	ctx := context.Background()
+	p, err := collex.NewPipeline().
+		WithSettings(*settings()).
+		WithExporter(buggy(), nil).
+		Build(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = p.Shutdown(ctx) }()
+
+	if err := p.SpanExporter().ExportSpans(ctx, spans(1)); !errors.Is(err, collex.ErrPanic) {
+		t.Errorf("ExportSpans error = %v, want %v", err, collex.ErrPanic)
+	}
+}
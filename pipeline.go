@@ -0,0 +1,375 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/pipeline"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+var (
+	errNoExporters = errors.New("no exporters or connectors")
+	errNoSignals   = errors.New("collex: no signal supported by all components")
+)
+
+// PipelineBuilder composes collector processors, exporters, and connectors
+// into a Pipeline.
+type PipelineBuilder struct {
+	settings    *exporter.Settings
+	processors  []Processor
+	exporters   []Exporter
+	connectors  []Connector
+	extensions  []Extension
+	host        component.Host
+	status      StatusFunc
+	onErr       ErrorFunc
+	temporality metric.TemporalitySelector
+	gates       []string
+	timeout     time.Duration
+	metadata    map[string][]string
+	headers     map[string]string
+}
+
+// NewPipeline returns a PipelineBuilder with no components.
+//
+// For example, a pipeline of processors that sends spans to an exporter is
+// built with the following.
+//
+//	p, err := collex.NewPipeline().
+//		WithProcessors(p1, p2).
+//		WithExporter(f, cfg).
+//		Build(ctx)
+func NewPipeline() *PipelineBuilder {
+	return &PipelineBuilder{}
+}
+
+// WithSettings sets the settings used to create all the components of the
+// pipeline. If not set, the same default Settings as NewFactory are used.
+func (b *PipelineBuilder) WithSettings(set exporter.Settings) *PipelineBuilder {
+	b.settings = &set
+	return b
+}
+
+// WithProcessors appends processors to the pipeline. Telemetry passes through
+// the processors in the order they are added.
+func (b *PipelineBuilder) WithProcessors(p ...Processor) *PipelineBuilder {
+	b.processors = append(b.processors, p...)
+	return b
+}
+
+// WithExporter adds the exporter created by f to the pipeline. If cfg is nil,
+// the default configuration of f is used. All exporters of the pipeline
+// receive the telemetry output by the last processor.
+func (b *PipelineBuilder) WithExporter(f exporter.Factory, cfg component.Config) *PipelineBuilder {
+	b.exporters = append(b.exporters, Exporter{Factory: f, Config: cfg})
+	return b
+}
+
+// WithExporters adds exporters to the pipeline, e.g. exporters of the same
+// type told apart by their names.
+func (b *PipelineBuilder) WithExporters(e ...Exporter) *PipelineBuilder {
+	b.exporters = append(b.exporters, e...)
+	return b
+}
+
+// WithConnectors adds connectors to the pipeline. Like exporters, connectors
+// receive the telemetry output by the last processor.
+func (b *PipelineBuilder) WithConnectors(c ...Connector) *PipelineBuilder {
+	b.connectors = append(b.connectors, c...)
+	return b
+}
+
+// WithExtensions adds extensions to the pipeline. The extensions are made
+// available to all the components of the pipeline through their host.
+func (b *PipelineBuilder) WithExtensions(e ...Extension) *PipelineBuilder {
+	b.extensions = append(b.extensions, e...)
+	return b
+}
+
+// WithHost sets the host the components of the pipeline are started with.
+// The extensions of host are available to the components along with the ones
+// added with WithExtensions. If no extensions or StatusFunc are added, the
+// components are started with host as-is.
+func (b *PipelineBuilder) WithHost(host component.Host) *PipelineBuilder {
+	b.host = host
+	return b
+}
+
+// WithStatusFunc sets fn to be called with the status changes of all the
+// components of the pipeline.
+func (b *PipelineBuilder) WithStatusFunc(fn StatusFunc) *PipelineBuilder {
+	b.status = fn
+	return b
+}
+
+// WithErrorFunc sets fn to be called when a component of the pipeline reports
+// a fatal or permanent error.
+func (b *PipelineBuilder) WithErrorFunc(fn ErrorFunc) *PipelineBuilder {
+	b.onErr = fn
+	return b
+}
+
+// WithFeatureGates enables or disables collector feature gates when the
+// pipeline is built. Each gate is in the format of the --feature-gates flag of
+// the collector: its ID, or its ID prefixed with "+" to enable it and "-" to
+// disable it. Feature gates are global to the process.
+func (b *PipelineBuilder) WithFeatureGates(gates ...string) *PipelineBuilder {
+	b.gates = append(b.gates, gates...)
+	return b
+}
+
+// WithShutdownTimeout bounds the time Shutdown of the pipeline takes. The
+// processors and readers returned by the pipeline are flushed, and the
+// exporters drain their sending queue, until the timeout has passed.
+func (b *PipelineBuilder) WithShutdownTimeout(d time.Duration) *PipelineBuilder {
+	b.timeout = d
+	return b
+}
+
+// WithMetadata sets md as the collector client.Info metadata of the telemetry
+// sent into the pipeline without any.
+func (b *PipelineBuilder) WithMetadata(md map[string][]string) *PipelineBuilder {
+	b.metadata = md
+	return b
+}
+
+// WithHeaders adds headers to every request of the exporters of the pipeline
+// whose configuration has HTTP or gRPC client settings. See WithHeaders.
+func (b *PipelineBuilder) WithHeaders(headers map[string]string) *PipelineBuilder {
+	b.headers = headers
+	return b
+}
+
+// WithTemporalitySelector sets the temporality of the metrics sent into the
+// pipeline by its MetricExporter and MetricReader. If not set, the
+// metric.DefaultTemporalitySelector is used.
+//
+// When delta metrics are sent to exporters that only accept cumulative
+// metrics, add the deltatocumulative processor to the pipeline.
+func (b *PipelineBuilder) WithTemporalitySelector(s metric.TemporalitySelector) *PipelineBuilder {
+	b.temporality = s
+	return b
+}
+
+// Build creates and starts all the components of the pipeline. Components
+// are started from the last to the first so no component receives telemetry
+// before its downstream consumers are ready.
+//
+// The pipeline handles every signal supported by all of its components. An
+// error is returned if there is no such signal.
+func (b *PipelineBuilder) Build(ctx context.Context) (*Pipeline, error) {
+	set := b.settings
+	if set == nil {
+		var err error
+		set, err = defaultSettings()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := setFeatureGates(b.gates); err != nil {
+		return nil, err
+	}
+
+	exps := make([]Exporter, len(b.exporters))
+	for i, e := range b.exporters {
+		exps[i] = e.withHeaders(b.headers)
+	}
+	roots := signalRoots(newPipeNode(pipeline.ID{}, b.processors, exps, b.connectors))
+	if len(roots) == 0 {
+		return nil, errNoSignals
+	}
+	p, err := newPipeline(ctx, *set, newExtensionSet(*set, b.host, statusWithErrors(b.status, b.onErr), extNodes(b.extensions)), roots)
+	if err != nil {
+		return nil, err
+	}
+	p.metrics.temporality = b.temporality
+	p.spans.metadata = b.metadata
+	p.metrics.metadata = b.metadata
+	p.logs.metadata = b.metadata
+	p.g.timeout = b.timeout
+	return p, nil
+}
+
+// signalRoots returns a root pipeline for each signal supported by all the
+// components of n. All roots share the same nodes so components, and the
+// pipelines connectors emit to, are only created once for each signal.
+func signalRoots(n *pipeNode) []*pipeNode {
+	var roots []*pipeNode
+	for _, s := range []pipeline.Signal{pipeline.SignalTraces, pipeline.SignalMetrics, pipeline.SignalLogs} {
+		root := &pipeNode{id: pipeline.NewID(s), procs: n.procs, exps: n.exps, conns: n.conns}
+		if root.supported() {
+			roots = append(roots, root)
+		}
+	}
+	return roots
+}
+
+func extNodes(exts []Extension) []extNode {
+	nodes := make([]extNode, len(exts))
+	for i, e := range exts {
+		nodes[i] = e.node()
+	}
+	return nodes
+}
+
+func newPipeNode(id pipeline.ID, procs []Processor, exps []Exporter, conns []Connector) *pipeNode {
+	p := &pipeNode{id: id}
+	for _, proc := range procs {
+		p.procs = append(p.procs, proc.node())
+	}
+	for _, e := range exps {
+		p.exps = append(p.exps, e.node())
+	}
+	for _, c := range conns {
+		p.conns = append(p.conns, c.node())
+	}
+	return p
+}
+
+// Pipeline is a started graph of collector components. It provides
+// OpenTelemetry Go exporters and processors that send telemetry into the
+// graph.
+type Pipeline struct {
+	g       *graph
+	spans   *spanExporter
+	metrics *metricExporter
+	logs    *logExporter
+
+	// sdk are the shutdown functions of the processors and readers returned
+	// by the Pipeline.
+	sdkMu sync.Mutex
+	sdk   []func(context.Context) error
+}
+
+func newPipeline(ctx context.Context, set exporter.Settings, exts *extensionSet, roots []*pipeNode) (*Pipeline, error) {
+	g, heads, err := newGraph(ctx, set, exts, roots)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Pipeline{
+		g:       g,
+		spans:   &spanExporter{panics: newPanicGuard(set.Logger, g.selfMetrics, pipeline.SignalTraces)},
+		metrics: &metricExporter{panics: newPanicGuard(set.Logger, g.selfMetrics, pipeline.SignalMetrics)},
+		logs:    &logExporter{panics: newPanicGuard(set.Logger, g.selfMetrics, pipeline.SignalLogs)},
+	}
+	if c, ok := heads[pipeline.SignalTraces]; ok {
+		p.spans.next = c.(consumer.Traces)
+		p.spans.export = p.spans.consume
+	}
+	if c, ok := heads[pipeline.SignalMetrics]; ok {
+		p.metrics.next = c.(consumer.Metrics)
+		p.metrics.export = p.metrics.consume
+	}
+	if c, ok := heads[pipeline.SignalLogs]; ok {
+		p.logs.next = c.(consumer.Logs)
+		p.logs.export = p.logs.consume
+	}
+	if err := g.start(ctx); err != nil {
+		return nil, errors.Join(err, p.Shutdown(ctx))
+	}
+	return p, nil
+}
+
+// SpanExporter returns an OpenTelemetry Go SpanExporter that sends spans into
+// the pipeline. The components of the pipeline are not shut down when the
+// returned SpanExporter is, Shutdown needs to be called for that.
+func (p *Pipeline) SpanExporter() trace.SpanExporter {
+	return p.spans
+}
+
+// SpanProcessor returns an OpenTelemetry Go SpanProcessor that batches spans
+// before sending them into the pipeline.
+func (p *Pipeline) SpanProcessor(opts ...trace.BatchSpanProcessorOption) trace.SpanProcessor {
+	sp := trace.NewBatchSpanProcessor(p.spans, opts...)
+	p.addSDK(sp.Shutdown)
+	return sp
+}
+
+// MetricExporter returns an OpenTelemetry Go metric Exporter that sends
+// metrics into the pipeline. The components of the pipeline are not shut down
+// when the returned Exporter is, Shutdown needs to be called for that.
+func (p *Pipeline) MetricExporter() metric.Exporter {
+	return p.metrics
+}
+
+// MetricReader returns an OpenTelemetry Go Reader that periodically collects
+// and sends metrics into the pipeline.
+func (p *Pipeline) MetricReader(opts ...metric.PeriodicReaderOption) metric.Reader {
+	r := metric.NewPeriodicReader(p.metrics, opts...)
+	p.addSDK(func(ctx context.Context) error {
+		// The reader has likely been shut down with its MeterProvider.
+		if err := r.Shutdown(ctx); !errors.Is(err, metric.ErrReaderShutdown) {
+			return err
+		}
+		return nil
+	})
+	return r
+}
+
+// LogExporter returns an OpenTelemetry Go log Exporter that sends log records
+// into the pipeline. The components of the pipeline are not shut down when the
+// returned Exporter is, Shutdown needs to be called for that.
+func (p *Pipeline) LogExporter() log.Exporter {
+	return p.logs
+}
+
+// LogProcessor returns an OpenTelemetry Go log Processor that batches log
+// records before sending them into the pipeline.
+func (p *Pipeline) LogProcessor(opts ...log.BatchProcessorOption) log.Processor {
+	lp := log.NewBatchProcessor(p.logs, opts...)
+	p.addSDK(lp.Shutdown)
+	return lp
+}
+
+func (p *Pipeline) addSDK(shutdown func(context.Context) error) {
+	p.sdkMu.Lock()
+	defer p.sdkMu.Unlock()
+	p.sdk = append(p.sdk, shutdown)
+}
+
+// Shutdown shuts down all the components of the pipeline in the reverse order
+// they were started. The processors and readers returned by the Pipeline are
+// shut down first so any telemetry they buffer is flushed into the pipeline.
+// The exporters of the pipeline then drain their sending queue before they
+// are stopped.
+//
+// All of this is bounded by the deadline of ctx and the timeout set with
+// WithShutdownTimeout.
+func (p *Pipeline) Shutdown(ctx context.Context) error {
+	ctx, cancel := p.g.shutdownContext(ctx)
+	defer cancel()
+
+	p.sdkMu.Lock()
+	sdk := p.sdk
+	p.sdk = nil
+	p.sdkMu.Unlock()
+
+	var err error
+	for _, shutdown := range sdk {
+		err = errors.Join(err, shutdown(ctx))
+	}
+	return errors.Join(err, p.g.shutdown(ctx))
+}
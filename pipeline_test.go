@@ -0,0 +1,230 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MrAlias/collex"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/configauth"
+	"go.opentelemetry.io/collector/config/confighttp"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/processor"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// holdSpans is a collector processor that holds spans until it is shut down
+// and then releases them in a single batch.
+func holdSpans() processor.Factory {
+	return processor.NewFactory(
+		component.MustNewType("hold"),
+		createEmptyConfig,
+		processor.WithTraces(func(_ context.Context, _ processor.Settings, _ component.Config, next consumer.Traces) (processor.Traces, error) {
+			held := ptrace.NewTraces()
+			c, err := consumer.NewTraces(func(_ context.Context, td ptrace.Traces) error {
+				td.ResourceSpans().MoveAndAppendTo(held.ResourceSpans())
+				return nil
+			}, consumer.WithCapabilities(consumer.Capabilities{MutatesData: true}))
+			release := testComponent{ShutdownFunc: func(ctx context.Context) error {
+				if held.SpanCount() == 0 {
+					return nil
+				}
+				td := held
+				held = ptrace.NewTraces()
+				return next.ConsumeTraces(ctx, td)
+			}}
+			return tracesComponent{testComponent: release, Traces: c}, err
+		}, component.StabilityLevelDevelopment),
+	)
+}
+
+func TestPipelineShutdownReleasesHeldSpans(t *testing.T) {
+	ctx := context.Background()
+	s := new(sink)
+	p, err := collex.NewPipeline().
+		WithSettings(*settings()).
+		WithProcessors(collex.Processor{Factory: holdSpans()}).
+		WithExporter(s.factory(), nil).
+		Build(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := p.SpanExporter()
+	tid := trace.TraceID{1}
+	res := resource.Empty()
+	for _, name := range []string{"child", "parent"} {
+		sc := trace.NewSpanContext(trace.SpanContextConfig{TraceID: tid})
+		spans := tracetest.SpanStubs{{Name: name, SpanContext: sc, Resource: res}}.Snapshots()
+		if err := exp.ExportSpans(ctx, spans); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(s.traces) != 0 {
+		t.Fatalf("exporter received %d exports before the spans were released", len(s.traces))
+	}
+
+	// The processor needs to be shut down before the exporter so the spans
+	// it holds are still exported.
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.traces) != 1 {
+		t.Fatalf("exporter received %d exports, want 1", len(s.traces))
+	}
+	if got := s.traces[0].SpanCount(); got != 2 {
+		t.Errorf("exported %d spans of the trace together, want 2", got)
+	}
+}
+
+func TestPipelineShutdownFlushesSpanProcessor(t *testing.T) {
+	ctx := context.Background()
+	s := new(sink)
+	p, err := collex.NewPipeline().
+		WithSettings(*settings()).
+		WithExporter(s.factory(), nil).
+		Build(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(p.SpanProcessor()))
+	_, span := tp.Tracer("test").Start(ctx, "span")
+	span.End()
+
+	// The TracerProvider is not shut down, the pipeline flushes the spans
+	// batched by its processor.
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.traces) != 1 {
+		t.Fatalf("exporter received %d exports, want 1", len(s.traces))
+	}
+}
+
+// hangingExporter returns the factory of an exporter that, like an exporter
+// unable to drain its sending queue, does not stop before ctx is done.
+func hangingExporter() exporter.Factory {
+	return exporter.NewFactory(
+		component.MustNewType("hanging"),
+		createEmptyConfig,
+		exporter.WithTraces(func(context.Context, exporter.Settings, component.Config) (exporter.Traces, error) {
+			c, err := consumer.NewTraces(func(context.Context, ptrace.Traces) error { return nil })
+			hang := testComponent{ShutdownFunc: func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			}}
+			return tracesComponent{testComponent: hang, Traces: c}, err
+		}, component.StabilityLevelDevelopment),
+	)
+}
+
+func TestPipelineShutdownTimeout(t *testing.T) {
+	ctx := context.Background()
+	p, err := collex.NewPipeline().
+		WithSettings(*settings()).
+		WithShutdownTimeout(10*time.Millisecond).
+		WithExporter(hangingExporter(), nil).
+		Build(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestFactoryShutdownTimeout(t *testing.T) {
+	f, err := collex.NewFactory(hangingExporter(), settings(), collex.WithShutdownTimeout(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := exp.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestPipelineNamedComponents(t *testing.T) {
+	srv := authServer(t, "Authorization", "Bearer secret")
+	newCfg := func() *httpConfig {
+		cfg := &httpConfig{ClientConfig: confighttp.NewDefaultClientConfig()}
+		cfg.Endpoint = srv.URL
+		cfg.Auth = &configauth.Authentication{AuthenticatorID: component.MustNewIDWithName("clientauth", "primary")}
+		return cfg
+	}
+	set := func(v string) func(*http.Request) {
+		return func(req *http.Request) { req.Header.Set("Authorization", v) }
+	}
+
+	ctx := context.Background()
+	p, err := collex.NewPipeline().
+		WithExtensions(
+			collex.Extension{Factory: clientAuth(set("Bearer wrong"))},
+			collex.Extension{Factory: clientAuth(set("Bearer secret")), Name: "primary"},
+		).
+		WithExporters(
+			collex.Exporter{Factory: httpExporter(), Config: newCfg(), Name: "a"},
+			collex.Exporter{Factory: httpExporter(), Config: newCfg(), Name: "b"},
+		).
+		WithSettings(*settings()).
+		Build(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans := tracetest.SpanStubs{{Name: "a", Resource: resource.Empty()}}.Snapshots()
+	if err := p.SpanExporter().ExportSpans(ctx, spans); err != nil {
+		t.Error(err)
+	}
+	out, err := p.ConfigYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"http/a:", "http/b:", "clientauth/primary:"} {
+		if !strings.Contains(string(out), id) {
+			t.Errorf("configuration has no %s\n%s", id, out)
+		}
+	}
+}
+
+func TestPipelineInvalidName(t *testing.T) {
+	var s sink
+	_, err := collex.NewPipeline().
+		WithExporters(collex.Exporter{Factory: s.factory(), Name: "not valid"}).
+		WithSettings(*settings()).
+		Build(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "exporter sink/not valid: invalid ID") {
+		t.Errorf("error = %v, want an invalid ID error", err)
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/processor"
+)
+
+// Processor is an OpenTelemetry collector processor used to process
+// telemetry before it is exported.
+type Processor struct {
+	// Factory creates the processor.
+	Factory processor.Factory
+	// Config is the processor configuration. If nil, the Factory default
+	// configuration is used.
+	Config component.Config
+	// Name is the name of the component ID, e.g. primary for batch/primary.
+	// Components of the same type are told apart by their names, like in
+	// a collector configuration.
+	Name string
+}
+
+func (p Processor) config() component.Config {
+	if p.Config == nil {
+		return p.Factory.CreateDefaultConfig()
+	}
+	return p.Config
+}
+
+func (p Processor) node() procNode {
+	return procNode{id: component.NewIDWithName(p.Factory.Type(), p.Name), Processor: p}
+}
@@ -0,0 +1,90 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/MrAlias/collex"
+	"go.opentelemetry.io/collector/processor/memorylimiterprocessor"
+)
+
+// memoryLimiter returns the memory limiter processor refusing data once the
+// heap is above limitMiB minus spikeMiB.
+func memoryLimiter(limitMiB, spikeMiB uint32) collex.Processor {
+	f := memorylimiterprocessor.NewFactory()
+	cfg := f.CreateDefaultConfig().(*memorylimiterprocessor.Config)
+	cfg.CheckInterval = 10 * time.Millisecond
+	cfg.MemoryLimitMiB = limitMiB
+	cfg.MemorySpikeLimitMiB = spikeMiB
+	return collex.Processor{Factory: f, Config: cfg}
+}
+
+func TestMemoryLimiterProcessor(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("BelowLimit", func(t *testing.T) {
+		var s sink
+		f, err := collex.NewFactory(s.factory(), settings(), collex.WithProcessors(memoryLimiter(1<<20, 1)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		exp, err := f.SpanExporter(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = exp.Shutdown(ctx) })
+
+		// Wait for the limiter to have checked the memory usage at least once.
+		time.Sleep(50 * time.Millisecond)
+		if err := exp.ExportSpans(ctx, spans(3)); err != nil {
+			t.Fatal(err)
+		}
+		if len(s.traces) != 1 || s.traces[0].SpanCount() != 3 {
+			t.Fatalf("exporter received %v, want 3 spans", s.traces)
+		}
+	})
+
+	t.Run("AboveLimit", func(t *testing.T) {
+		var s sink
+		// The heap of the test binary is always above the 1 MiB soft limit.
+		f, err := collex.NewFactory(s.factory(), settings(), collex.WithProcessors(memoryLimiter(2, 1)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		exp, err := f.SpanExporter(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = exp.Shutdown(ctx) })
+
+		deadline := time.Now().Add(5 * time.Second)
+		for exp.ExportSpans(ctx, spans(1)) == nil {
+			if time.Now().After(deadline) {
+				t.Fatal("spans not refused above the memory limit")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		n := len(s.traces)
+		if err := exp.ExportSpans(ctx, spans(1)); err == nil {
+			t.Fatal("spans not refused above the memory limit")
+		}
+		if len(s.traces) != n {
+			t.Error("refused spans were exported")
+		}
+	})
+}
@@ -0,0 +1,177 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package prometheusremotewrite provides an OpenTelemetry Go metric exporter
+// sending metrics to a Prometheus remote write endpoint with the Prometheus
+// remote write exporter of the collector contrib repository, configured with
+// Go options instead of a collector configuration.
+package prometheusremotewrite // import "github.com/MrAlias/collex/prometheusremotewrite"
+
+import (
+	"context"
+
+	"github.com/MrAlias/collex"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/prometheusremotewriteexporter"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/otel/sdk/metric"
+)
+
+// Option configures the exporter.
+type Option interface {
+	apply(config) config
+}
+
+type config struct {
+	// conf is the collector configuration of the Prometheus remote write
+	// exporter.
+	conf map[string]any
+	set  *exporter.Settings
+	opts []collex.Option
+}
+
+type optionFunc func(config) config
+
+func (fn optionFunc) apply(c config) config {
+	return fn(c)
+}
+
+// set returns an Option setting the configuration key to v.
+func set(key string, v any) Option {
+	return optionFunc(func(c config) config {
+		c.conf[key] = v
+		return c
+	})
+}
+
+// headers returns the HTTP headers of the configuration of c.
+func (c config) headers() map[string]any {
+	h, ok := c.conf["headers"].(map[string]any)
+	if !ok {
+		h = make(map[string]any)
+		c.conf["headers"] = h
+	}
+	return h
+}
+
+// WithEndpoint returns an Option that sets the URL of the remote write
+// endpoint, e.g. http://prometheus:9090/api/v1/write.
+func WithEndpoint(url string) Option {
+	return set("endpoint", url)
+}
+
+// WithNamespace returns an Option that sets the prefix added to the name of
+// all the exported metrics.
+func WithNamespace(namespace string) Option {
+	return set("namespace", namespace)
+}
+
+// WithExternalLabels returns an Option that sets labels added to all the
+// exported series, e.g. the cluster a service runs in.
+func WithExternalLabels(labels map[string]string) Option {
+	return set("external_labels", labels)
+}
+
+// WithResourceToTelemetryConversion returns an Option that sets whether the
+// resource attributes are added to the labels of all the exported series.
+func WithResourceToTelemetryConversion(enabled bool) Option {
+	return set("resource_to_telemetry_conversion", map[string]any{"enabled": enabled})
+}
+
+// WithWAL returns an Option that enables the write-ahead log of the exporter
+// in directory. Metrics are written to it before they are sent, so they are
+// not lost if the service restarts while the endpoint is unavailable.
+func WithWAL(directory string) Option {
+	return set("wal", map[string]any{"directory": directory})
+}
+
+// WithHeaders returns an Option that adds headers to the requests sent to the
+// endpoint, e.g. X-Scope-OrgID for a multi-tenant backend.
+func WithHeaders(headers map[string]string) Option {
+	return optionFunc(func(c config) config {
+		h := c.headers()
+		for k, v := range headers {
+			h[k] = v
+		}
+		return c
+	})
+}
+
+// WithBearerToken returns an Option that authenticates the requests sent to
+// the endpoint with token.
+func WithBearerToken(token string) Option {
+	return optionFunc(func(c config) config {
+		c.headers()["Authorization"] = "Bearer " + token
+		return c
+	})
+}
+
+// WithAuthenticator returns an Option that authenticates the requests sent to
+// the endpoint with the authenticator extension ext, e.g. the basicauth or
+// oauth2client extension of the collector contrib repository. The extension
+// is added to the collex.Factory wrapping the exporter.
+func WithAuthenticator(ext collex.Extension) Option {
+	return optionFunc(func(c config) config {
+		id := component.NewIDWithName(ext.Factory.Type(), ext.Name)
+		c.conf["auth"] = map[string]any{"authenticator": id.String()}
+		c.opts = append(c.opts, collex.WithExtensions(ext))
+		return c
+	})
+}
+
+// WithSettings returns an Option that sets the settings the Prometheus remote
+// write exporter is created with, e.g. its logger. Default settings are used
+// otherwise, like with collex.NewFactory.
+func WithSettings(set *exporter.Settings) Option {
+	return optionFunc(func(c config) config {
+		c.set = set
+		return c
+	})
+}
+
+// WithFactoryOptions returns an Option that configures the collex.Factory
+// wrapping the Prometheus remote write exporter with opts, e.g.
+// collex.WithRetry.
+func WithFactoryOptions(opts ...collex.Option) Option {
+	return optionFunc(func(c config) config {
+		c.opts = append(c.opts, opts...)
+		return c
+	})
+}
+
+// newFactory returns the Factory of the Prometheus remote write exporter and
+// its configuration set by opts.
+func newFactory(opts []Option) (*collex.Factory, component.Config, error) {
+	c := config{conf: make(map[string]any)}
+	for _, opt := range opts {
+		c = opt.apply(c)
+	}
+	prw := prometheusremotewriteexporter.NewFactory()
+	cfg, err := collex.ConfigFromMap(prw, c.conf)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := collex.NewFactory(prw, c.set, c.opts...)
+	return f, cfg, err
+}
+
+// NewMetricExporter returns a metric Exporter sending metrics to a Prometheus
+// remote write endpoint.
+func NewMetricExporter(ctx context.Context, opts ...Option) (metric.Exporter, error) {
+	f, cfg, err := newFactory(opts)
+	if err != nil {
+		return nil, err
+	}
+	return f.MetricExporter(ctx, cfg)
+}
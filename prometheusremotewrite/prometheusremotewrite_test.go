@@ -0,0 +1,138 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheusremotewrite
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MrAlias/collex"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/prometheusremotewriteexporter"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/extension"
+	"go.opentelemetry.io/collector/extension/auth"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+func exporterConfig(t *testing.T, opts ...Option) *prometheusremotewriteexporter.Config {
+	t.Helper()
+	_, cfg, err := newFactory(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg.(*prometheusremotewriteexporter.Config)
+}
+
+// headers returns the headers of the first request a metric exporter created
+// with opts sends to a remote write endpoint.
+func headers(t *testing.T, opts ...Option) http.Header {
+	t.Helper()
+	got := make(chan http.Header, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case got <- r.Header.Clone():
+		default:
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	opts = append(opts, WithEndpoint(srv.URL), set("remote_write_queue", map[string]any{"enabled": false}))
+	exp, err := NewMetricExporter(ctx, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = exp.Shutdown(ctx) }()
+
+	rm := &metricdata.ResourceMetrics{
+		Resource: resource.Empty(),
+		ScopeMetrics: []metricdata.ScopeMetrics{{
+			Scope: instrumentation.Scope{Name: "test"},
+			Metrics: []metricdata.Metrics{{
+				Name: "requests",
+				Data: metricdata.Gauge[int64]{DataPoints: []metricdata.DataPoint[int64]{{Time: time.Now(), Value: 1}}},
+			}},
+		}},
+	}
+	if err := exp.Export(ctx, rm); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case h := <-got:
+		return h
+	case <-time.After(5 * time.Second):
+		t.Fatal("no request sent to the endpoint")
+		return nil
+	}
+}
+
+func TestWithWAL(t *testing.T) {
+	cfg := exporterConfig(t, WithWAL("/var/lib/app/wal"))
+	if cfg.WAL == nil || cfg.WAL.Directory != "/var/lib/app/wal" {
+		t.Errorf("WAL = %+v, want the /var/lib/app/wal directory", cfg.WAL)
+	}
+	if cfg := exporterConfig(t); cfg.WAL != nil {
+		t.Errorf("WAL = %+v by default, want none", cfg.WAL)
+	}
+}
+
+func TestWithBearerToken(t *testing.T) {
+	h := headers(t, WithBearerToken("token"), WithHeaders(map[string]string{"X-Scope-OrgID": "tenant"}))
+	if got := h.Get("Authorization"); got != "Bearer token" {
+		t.Errorf("Authorization = %q, want Bearer token", got)
+	}
+	if got := h.Get("X-Scope-OrgID"); got != "tenant" {
+		t.Errorf("X-Scope-OrgID = %q, want tenant", got)
+	}
+}
+
+// authTransport adds the X-Auth header to the requests.
+type authTransport struct{ base http.RoundTripper }
+
+func (t authTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.Header.Set("X-Auth", "authenticated")
+	return t.base.RoundTrip(r)
+}
+
+func TestWithAuthenticator(t *testing.T) {
+	ext := collex.Extension{
+		Factory: extension.NewFactory(
+			component.MustNewType("testauth"),
+			func() component.Config { return &struct{}{} },
+			func(context.Context, extension.Settings, component.Config) (extension.Extension, error) {
+				return auth.NewClient(auth.WithClientRoundTripper(func(base http.RoundTripper) (http.RoundTripper, error) {
+					return authTransport{base: base}, nil
+				})), nil
+			},
+			component.StabilityLevelDevelopment,
+		),
+		Name: "prw",
+	}
+
+	cfg := exporterConfig(t, WithAuthenticator(ext))
+	if cfg.ClientConfig.Auth == nil || cfg.ClientConfig.Auth.AuthenticatorID.String() != "testauth/prw" {
+		t.Errorf("authenticator = %+v, want testauth/prw", cfg.ClientConfig.Auth)
+	}
+	if got := headers(t, WithAuthenticator(ext)).Get("X-Auth"); got != "authenticated" {
+		t.Errorf("request not sent through the authenticator, X-Auth = %q", got)
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// RateLimitConfig configures the token bucket rate limiting the exports to an
+// exporter, e.g. for a backend with a strict ingest quota.
+//
+// The bucket holds up to Burst tokens and is refilled with Limit tokens each
+// second. Each export takes a token for each of its items, or for each byte
+// of its OTLP protobuf encoding if Bytes is true. An export for which there
+// are not enough tokens waits for them, unless its context is done first, or
+// is dropped if Drop is true.
+type RateLimitConfig struct {
+	// Limit is the number of items, or bytes, exported each second. Exports
+	// are not limited if it is not positive.
+	Limit float64
+	// Burst is the number of items, or bytes, exported at once above Limit.
+	// If it is not positive, Limit rounded up is used. An export larger
+	// than Burst waits for the bucket to be full and its excess to be
+	// refilled, or is always dropped if Drop is true.
+	Burst int
+	// Bytes limits the bytes exported instead of the items.
+	Bytes bool
+	// Drop drops the exports exceeding the limit instead of waiting. The
+	// items dropped are counted by the collex.exporter.dropped metric.
+	Drop bool
+}
+
+// limiter is the token bucket of the exports of a signal to an exporter.
+type limiter struct {
+	cfg   RateLimitConfig
+	burst float64
+	clock func() Clock
+
+	mu     sync.Mutex
+	tokens float64
+	// last is when the bucket was last refilled, zero before the first
+	// export.
+	last time.Time
+}
+
+// newLimiter returns the limiter of cfg, or nil if cfg does not limit
+// exports.
+func newLimiter(cfg RateLimitConfig, clock func() Clock) *limiter {
+	if cfg.Limit <= 0 {
+		return nil
+	}
+	burst := float64(cfg.Burst)
+	if burst <= 0 {
+		burst = math.Ceil(cfg.Limit)
+	}
+	return &limiter{cfg: cfg, burst: burst, clock: clock, tokens: burst}
+}
+
+// take takes n tokens, waiting for them if needed. It returns false if the
+// export of n is dropped, or the error of ctx if it is done while waiting.
+func (l *limiter) take(ctx context.Context, n int) (bool, error) {
+	l.mu.Lock()
+	now := l.clock().Now()
+	if l.last.IsZero() {
+		l.last = now
+	}
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.cfg.Limit)
+	l.last = now
+	if l.cfg.Drop && l.tokens < float64(n) {
+		l.mu.Unlock()
+		return false, nil
+	}
+	// Waiting exports are queued by taking their tokens in advance.
+	l.tokens -= float64(n)
+	debt := -l.tokens
+	l.mu.Unlock()
+	if debt <= 0 {
+		return true, nil
+	}
+
+	t := l.clock().NewTimer(time.Duration(debt / l.cfg.Limit * float64(time.Second)))
+	select {
+	case <-t.C():
+		return true, nil
+	case <-ctx.Done():
+		t.Stop()
+		l.mu.Lock()
+		l.tokens += float64(n)
+		l.mu.Unlock()
+		return false, ctx.Err()
+	}
+}
+
+// limitTraces returns next with its exports limited by l. Dropped exports are
+// recorded with r. It returns next if l is nil.
+func limitTraces(l *limiter, r exportRecorder, next consumer.Traces) consumer.Traces {
+	if l == nil {
+		return next
+	}
+	var sizer ptrace.ProtoMarshaler
+	c, err := consumer.NewTraces(func(ctx context.Context, td ptrace.Traces) error {
+		n := td.SpanCount()
+		if l.cfg.Bytes {
+			n = sizer.TracesSize(td)
+		}
+		if ok, err := l.take(ctx, n); !ok {
+			if err == nil {
+				r.dropped(ctx, td.SpanCount(), "rate_limit")
+			}
+			return err
+		}
+		return next.ConsumeTraces(ctx, td)
+	}, consumer.WithCapabilities(next.Capabilities()))
+	if err != nil {
+		// Only returned for a nil function.
+		panic(err)
+	}
+	return c
+}
+
+// limitMetrics returns next with its exports limited by l. Dropped exports
+// are recorded with r. It returns next if l is nil.
+func limitMetrics(l *limiter, r exportRecorder, next consumer.Metrics) consumer.Metrics {
+	if l == nil {
+		return next
+	}
+	var sizer pmetric.ProtoMarshaler
+	c, err := consumer.NewMetrics(func(ctx context.Context, md pmetric.Metrics) error {
+		n := md.DataPointCount()
+		if l.cfg.Bytes {
+			n = sizer.MetricsSize(md)
+		}
+		if ok, err := l.take(ctx, n); !ok {
+			if err == nil {
+				r.dropped(ctx, md.DataPointCount(), "rate_limit")
+			}
+			return err
+		}
+		return next.ConsumeMetrics(ctx, md)
+	}, consumer.WithCapabilities(next.Capabilities()))
+	if err != nil {
+		// Only returned for a nil function.
+		panic(err)
+	}
+	return c
+}
+
+// limitLogs returns next with its exports limited by l. Dropped exports are
+// recorded with r. It returns next if l is nil.
+func limitLogs(l *limiter, r exportRecorder, next consumer.Logs) consumer.Logs {
+	if l == nil {
+		return next
+	}
+	var sizer plog.ProtoMarshaler
+	c, err := consumer.NewLogs(func(ctx context.Context, ld plog.Logs) error {
+		n := ld.LogRecordCount()
+		if l.cfg.Bytes {
+			n = sizer.LogsSize(ld)
+		}
+		if ok, err := l.take(ctx, n); !ok {
+			if err == nil {
+				r.dropped(ctx, ld.LogRecordCount(), "rate_limit")
+			}
+			return err
+		}
+		return next.ConsumeLogs(ctx, ld)
+	}, consumer.WithCapabilities(next.Capabilities()))
+	if err != nil {
+		// Only returned for a nil function.
+		panic(err)
+	}
+	return c
+}
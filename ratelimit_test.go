@@ -0,0 +1,143 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/MrAlias/collex"
+	"github.com/MrAlias/collex/collextest"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// spans returns n spans.
+func spans(n int) []trace.ReadOnlySpan {
+	stubs := make(tracetest.SpanStubs, n)
+	for i := range stubs {
+		stubs[i].Name = "span"
+	}
+	return stubs.Snapshots()
+}
+
+func TestWithRateLimit(t *testing.T) {
+	var s sink
+	clock := collextest.NewFakeClock(time.Now())
+	cfg := collex.RateLimitConfig{Limit: 10, Burst: 10}
+	f, err := collex.NewFactory(s.factory(), settings(), collex.WithRateLimit(cfg), collex.WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = exp.Shutdown(ctx) }()
+
+	// The burst is exported at once.
+	if err := exp.ExportSpans(ctx, spans(10)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The next export waits for its tokens to be refilled.
+	done := make(chan error, 1)
+	go func() { done <- exp.ExportSpans(ctx, spans(5)) }()
+	clock.BlockUntil(1)
+	select {
+	case err := <-done:
+		t.Fatalf("export over the limit returned before waiting: %v", err)
+	default:
+	}
+	clock.Advance(500 * time.Millisecond)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if len(s.traces) != 2 {
+		t.Errorf("%d exports, want 2", len(s.traces))
+	}
+}
+
+func TestWithRateLimitCanceled(t *testing.T) {
+	var s sink
+	clock := collextest.NewFakeClock(time.Now())
+	cfg := collex.RateLimitConfig{Limit: 1, Burst: 1}
+	f, err := collex.NewFactory(s.factory(), settings(), collex.WithRateLimit(cfg), collex.WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = exp.Shutdown(ctx) }()
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := exp.ExportSpans(canceled, spans(2)); err != context.Canceled {
+		t.Fatalf("export error = %v, want %v", err, context.Canceled)
+	}
+	// The tokens of the canceled export are given back.
+	if err := exp.ExportSpans(ctx, spans(1)); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.traces) != 1 {
+		t.Errorf("%d exports, want 1", len(s.traces))
+	}
+}
+
+func TestWithRateLimitDrop(t *testing.T) {
+	set, reader := meteredSettings()
+	var s sink
+	clock := collextest.NewFakeClock(time.Now())
+	cfg := collex.RateLimitConfig{Limit: 1, Burst: 2, Drop: true}
+	f, err := collex.NewFactory(s.factory(), set, collex.WithRateLimit(cfg), collex.WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = exp.Shutdown(ctx) }()
+
+	for _, n := range []int{2, 3, 1} {
+		if err := exp.ExportSpans(ctx, spans(n)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	clock.Advance(time.Second)
+	if err := exp.ExportSpans(ctx, spans(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.traces) != 2 {
+		t.Errorf("%d exports, want 2", len(s.traces))
+	}
+	attrs := []attribute.KeyValue{
+		attribute.String("exporter", "sink"),
+		attribute.String("signal", "traces"),
+		attribute.String("reason", "rate_limit"),
+	}
+	got := collect(t, reader, collexScope)
+	if n := intValue(t, "dropped", got["collex.exporter.dropped"], attrs...); n != 4 {
+		t.Errorf("dropped %d spans, want 4", n)
+	}
+}
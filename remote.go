@@ -0,0 +1,70 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"context"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/confmap"
+)
+
+// RemoteConfig applies the exporter configurations pushed by a remote
+// configuration service, like an OpAMP server managing a fleet of
+// applications, to exporters returned by a Factory. The configuration the
+// exporters use is reported back to the service with EffectiveConfig.
+//
+// RemoteConfig does not implement a protocol. It is called from the client of
+// the service when it receives a remote configuration. OpAMPClient is such a
+// client for OpAMP servers.
+type RemoteConfig struct {
+	// Factory is the ExporterFactory passed to NewFactory. Pushed
+	// configurations are loaded with it.
+	Factory component.Factory
+	// Providers are additional confmap providers used to load pushed
+	// configurations, see ConfigFromYAML.
+	Providers []confmap.ProviderFactory
+}
+
+// Apply swaps exps, exporters returned by a Factory, to the configuration in
+// data, the YAML of the exporter configuration pushed by the service. Only
+// the exporters not already configured with it are swapped.
+//
+// An error is returned if the configuration is invalid, an exporter fails to
+// start with it, or exps were not returned by a Factory. The exporters that
+// failed keep their previous configuration.
+func (r RemoteConfig) Apply(ctx context.Context, data []byte, exps ...any) error {
+	sups, err := supervisorsOf(exps)
+	if err != nil {
+		return err
+	}
+	cfg, err := ConfigFromYAML(r.Factory, data, r.Providers...)
+	if err != nil {
+		return err
+	}
+	return applyConfig(ctx, cfg, sups)
+}
+
+// EffectiveConfig returns the YAML of the configuration exp, an exporter
+// returned by a Factory, uses to report it to the service. Secrets are
+// redacted like ConfigToYAML does. An error is returned if exp was not
+// returned by a Factory.
+func (RemoteConfig) EffectiveConfig(exp any) ([]byte, error) {
+	sup := supervisorOf(exp)
+	if sup == nil {
+		return nil, errNotSwappable
+	}
+	return ConfigToYAML(sup.config())
+}
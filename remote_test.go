@@ -0,0 +1,71 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/MrAlias/collex"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRemoteConfig(t *testing.T) {
+	var e endpoints
+	f, err := collex.NewFactory(e.factory(), settings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, &endpointConfig{Endpoint: "old"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := collex.RemoteConfig{Factory: e.factory()}
+
+	if err := r.Apply(ctx, []byte("endpoint: new"), exp); err != nil {
+		t.Fatal(err)
+	}
+	// Configurations that fail are reported and not applied.
+	if err := r.Apply(ctx, []byte("endpoint: down"), exp); err == nil {
+		t.Error("expected an error for an exporter failing to start")
+	}
+	if err := r.Apply(ctx, []byte("endpoint: [invalid"), exp); err == nil {
+		t.Error("expected an error for an invalid configuration")
+	}
+	out, err := r.EffectiveConfig(exp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(out), "endpoint: new\n"; got != want {
+		t.Errorf("effective configuration = %q, want %q", got, want)
+	}
+
+	spans := tracetest.SpanStubs{{Name: "a", Resource: resource.Empty()}}.Snapshots()
+	if err := exp.ExportSpans(ctx, spans); err != nil {
+		t.Fatal(err)
+	}
+	if err := exp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := e.spans["new"]; got != 1 {
+		t.Errorf("new endpoint got %d spans, want 1", got)
+	}
+
+	if err := r.Apply(ctx, []byte("endpoint: new"), struct{}{}); err == nil {
+		t.Error("expected an error for an exporter not returned by a Factory")
+	}
+}
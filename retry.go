@@ -0,0 +1,157 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cenkalti/backoff/v4"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/configretry"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+// errRetriesExhausted is the error of the exports that still failed once the
+// backoff of their retries stopped.
+var errRetriesExhausted = errors.New("no more retries left")
+
+// retrier retries the failed exports to an exporter with an exponential
+// backoff.
+type retrier struct {
+	cfg   configretry.BackOffConfig
+	log   *zap.Logger
+	clock func() Clock
+}
+
+// newRetrier returns the retrier of the exports to the exporter with id, or
+// nil if cfg is not enabled.
+func newRetrier(cfg configretry.BackOffConfig, log *zap.Logger, id component.ID, clock func() Clock) *retrier {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &retrier{cfg: cfg, log: log.With(zap.String("exporter", id.String())), clock: clock}
+}
+
+// do calls fn until it succeeds, fails with a permanent error, ctx is done,
+// or the backoff of r stops. The last error of fn is returned.
+func (r *retrier) do(ctx context.Context, fn func() error) error {
+	clock := r.clock()
+	b := backoff.NewExponentialBackOff(
+		backoff.WithInitialInterval(r.cfg.InitialInterval),
+		backoff.WithRandomizationFactor(r.cfg.RandomizationFactor),
+		backoff.WithMultiplier(r.cfg.Multiplier),
+		backoff.WithMaxInterval(r.cfg.MaxInterval),
+		backoff.WithMaxElapsedTime(r.cfg.MaxElapsedTime),
+		backoff.WithClockProvider(clock),
+	)
+	for {
+		err := fn()
+		if err == nil || consumererror.IsPermanent(err) {
+			return err
+		}
+		d := b.NextBackOff()
+		if d == backoff.Stop {
+			return fmt.Errorf("%w: %w", errRetriesExhausted, err)
+		}
+		r.log.Info("Exporting failed. Will retry the request after interval.", zap.Error(err), zap.Duration("interval", d))
+
+		t := clock.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-t.C():
+		}
+	}
+}
+
+// retryTraces returns next retrying its failed exports with r. Only the
+// rejected spans of a partially failed export are retried. It returns next
+// if r is nil.
+func retryTraces(r *retrier, next consumer.Traces) consumer.Traces {
+	if r == nil {
+		return next
+	}
+	c, err := consumer.NewTraces(func(ctx context.Context, td ptrace.Traces) error {
+		return r.do(ctx, func() error {
+			err := next.ConsumeTraces(ctx, td)
+			var rejected consumererror.Traces
+			if errors.As(err, &rejected) {
+				td = rejected.Data()
+			}
+			return err
+		})
+	}, consumer.WithCapabilities(next.Capabilities()))
+	if err != nil {
+		// Only returned for a nil function.
+		panic(err)
+	}
+	return c
+}
+
+// retryMetrics returns next retrying its failed exports with r. Only the
+// rejected metrics of a partially failed export are retried. It returns next
+// if r is nil.
+func retryMetrics(r *retrier, next consumer.Metrics) consumer.Metrics {
+	if r == nil {
+		return next
+	}
+	c, err := consumer.NewMetrics(func(ctx context.Context, md pmetric.Metrics) error {
+		return r.do(ctx, func() error {
+			err := next.ConsumeMetrics(ctx, md)
+			var rejected consumererror.Metrics
+			if errors.As(err, &rejected) {
+				md = rejected.Data()
+			}
+			return err
+		})
+	}, consumer.WithCapabilities(next.Capabilities()))
+	if err != nil {
+		// Only returned for a nil function.
+		panic(err)
+	}
+	return c
+}
+
+// retryLogs returns next retrying its failed exports with r. Only the
+// rejected log records of a partially failed export are retried. It returns
+// next if r is nil.
+func retryLogs(r *retrier, next consumer.Logs) consumer.Logs {
+	if r == nil {
+		return next
+	}
+	c, err := consumer.NewLogs(func(ctx context.Context, ld plog.Logs) error {
+		return r.do(ctx, func() error {
+			err := next.ConsumeLogs(ctx, ld)
+			var rejected consumererror.Logs
+			if errors.As(err, &rejected) {
+				ld = rejected.Data()
+			}
+			return err
+		})
+	}, consumer.WithCapabilities(next.Capabilities()))
+	if err != nil {
+		// Only returned for a nil function.
+		panic(err)
+	}
+	return c
+}
@@ -0,0 +1,129 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/MrAlias/collex"
+	"github.com/MrAlias/collex/collextest"
+	"go.opentelemetry.io/collector/config/configretry"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// retryExporter returns a SpanExporter of mock, without retries of its own,
+// retried with cfg on clock.
+func retryExporter(t *testing.T, mock *collextest.MockExporter, cfg configretry.BackOffConfig, clock collex.Clock) trace.SpanExporter {
+	t.Helper()
+	f, err := collex.NewFactory(mock.Factory(), settings(), collex.WithRetry(cfg), collex.WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	helper := collex.NewHelperConfig()
+	helper.RetryConfig.Enabled = false
+	helper.QueueConfig.Enabled = false
+	exp, err := f.SpanExporter(context.Background(), helper)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = exp.Shutdown(context.Background()) })
+	return exp
+}
+
+func retryConfig() configretry.BackOffConfig {
+	return configretry.BackOffConfig{
+		Enabled:         true,
+		InitialInterval: time.Second,
+		MaxInterval:     time.Minute,
+		Multiplier:      2,
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	mock := collextest.NewMockExporter(
+		collextest.FailTransient(errBroken, 0),
+		collextest.FailTransient(errBroken, 0),
+		collextest.Succeed(),
+	)
+	clock := collextest.NewFakeClock(time.Now())
+	exp := retryExporter(t, mock, retryConfig(), clock)
+
+	done := make(chan error, 1)
+	go func() { done <- exp.ExportSpans(context.Background(), spans(1)) }()
+	for _, d := range []time.Duration{time.Second, 2 * time.Second} {
+		clock.BlockUntil(1)
+		clock.Advance(d)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if mock.Calls() != 3 || len(mock.Traces()) != 1 {
+		t.Errorf("%d calls exported %d times, want 3 calls exporting once", mock.Calls(), len(mock.Traces()))
+	}
+}
+
+func TestWithRetryPermanent(t *testing.T) {
+	mock := collextest.NewMockExporter(collextest.FailPermanent(errBroken))
+	exp := retryExporter(t, mock, retryConfig(), collextest.NewFakeClock(time.Now()))
+
+	if err := exp.ExportSpans(context.Background(), spans(1)); !errors.Is(err, errBroken) {
+		t.Fatalf("export error = %v, want %v", err, errBroken)
+	}
+	if mock.Calls() != 1 {
+		t.Errorf("%d calls, want 1", mock.Calls())
+	}
+}
+
+func TestWithRetryMaxElapsedTime(t *testing.T) {
+	mock := collextest.NewMockExporter(
+		collextest.FailTransient(errBroken, 0),
+		collextest.FailTransient(errBroken, 0),
+		collextest.Succeed(),
+	)
+	clock := collextest.NewFakeClock(time.Now())
+	cfg := retryConfig()
+	cfg.MaxElapsedTime = 2 * time.Second
+	exp := retryExporter(t, mock, cfg, clock)
+
+	done := make(chan error, 1)
+	go func() { done <- exp.ExportSpans(context.Background(), spans(1)) }()
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	// The next retry would be after the maximum elapsed time.
+	if err := <-done; !errors.Is(err, errBroken) {
+		t.Fatalf("export error = %v, want %v", err, errBroken)
+	}
+	if mock.Calls() != 2 {
+		t.Errorf("%d calls, want 2", mock.Calls())
+	}
+}
+
+func TestWithRetryCanceled(t *testing.T) {
+	mock := collextest.NewMockExporter(collextest.FailTransient(errBroken, 0))
+	clock := collextest.NewFakeClock(time.Now())
+	exp := retryExporter(t, mock, retryConfig(), clock)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- exp.ExportSpans(ctx, spans(1)) }()
+	clock.BlockUntil(1)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) || !errors.Is(err, errBroken) {
+		t.Fatalf("export error = %v, want the export and context errors", err)
+	}
+}
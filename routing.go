@@ -0,0 +1,424 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pipeline"
+	"go.opentelemetry.io/otel/attribute"
+	api "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Matcher matches telemetry by its attributes, those of its resource, and its
+// instrumentation scope. The telemetry needs to have all the attributes, with
+// the same values. The zero Matcher matches all telemetry.
+type Matcher struct {
+	// Resource are the attributes the resource of the telemetry needs to
+	// have.
+	Resource []attribute.KeyValue
+	// Attributes are the attributes a span or log record needs to have.
+	// Metrics are only matched by their resource and scope.
+	Attributes []attribute.KeyValue
+	// Scope, if not empty, is the name of the instrumentation scope of the
+	// telemetry, e.g. the instrumentation library that produced it. If it
+	// ends with *, it is a prefix of the name, e.g. github.com/acme/*
+	// matches all the libraries of that organization.
+	Scope string
+}
+
+// matchResource returns whether res has the resource attributes of m.
+func (m Matcher) matchResource(res *resource.Resource) bool {
+	set := res.Set()
+	for _, kv := range m.Resource {
+		if v, ok := set.Value(kv.Key); !ok || v != kv.Value {
+			return false
+		}
+	}
+	return true
+}
+
+// matchScope returns whether name matches the instrumentation scope of m.
+func (m Matcher) matchScope(name string) bool {
+	if prefix, ok := strings.CutSuffix(m.Scope, "*"); ok {
+		return strings.HasPrefix(name, prefix)
+	}
+	return m.Scope == "" || m.Scope == name
+}
+
+// match returns whether the telemetry of res and the instrumentation scope
+// scope with the attributes found by lookup is matched by m.
+func (m Matcher) match(res *resource.Resource, scope string, lookup func(attribute.Key) (attribute.Value, bool)) bool {
+	if !m.matchResource(res) || !m.matchScope(scope) {
+		return false
+	}
+	for _, kv := range m.Attributes {
+		if v, ok := lookup(kv.Key); !ok || v != kv.Value {
+			return false
+		}
+	}
+	return true
+}
+
+// route returns the index of the first of n routes for which match returns
+// true, or n if there is none, the index of the fallback.
+func route(n int, match func(i int) bool) int {
+	for i := range n {
+		if match(i) {
+			return i
+		}
+	}
+	return n
+}
+
+// SpanRoute routes the spans matched by its Matcher, and its Condition if
+// any, to its Exporter.
+type SpanRoute struct {
+	Matcher
+	// Condition, if not nil, also needs to be satisfied by the spans.
+	Condition SpanCondition
+	Exporter  trace.SpanExporter
+	// Name identifies the route in the metrics of the telemetry dropped
+	// by its Overflow policy. If empty, the index of the route is used.
+	Name string
+	// Overflow is what is done with the telemetry of the route when its
+	// Exporter is saturated.
+	Overflow OverflowPolicy
+}
+
+// NewRoutingSpanExporter returns a SpanExporter sending each span to the
+// exporter of the first of routes that matches it, or to fallback if none
+// does, e.g. the spans of a staging environment to a debug exporter and the
+// others to the production backend. A batch is split by destination and the
+// parts are exported concurrently. Spans are dropped if they match no route
+// and fallback is nil.
+//
+// Shutting down the returned exporter shuts down the exporters of all routes
+// and fallback.
+func NewRoutingSpanExporter(routes []SpanRoute, fallback trace.SpanExporter, opts ...RoutingOption) trace.SpanExporter {
+	e := &routingSpanExporter{exps: make([]trace.SpanExporter, 0, len(routes)+1)}
+	var overflows []overflowRoute
+	for _, r := range routes {
+		e.matchers = append(e.matchers, r.Matcher)
+		e.conds = append(e.conds, r.Condition)
+		e.conditional = e.conditional || r.Condition != nil
+		e.exps = append(e.exps, r.Exporter)
+		overflows = append(overflows, overflowRoute{name: r.Name, policy: r.Overflow, exp: r.Exporter})
+	}
+	e.exps = append(e.exps, fallback)
+	e.overflow = newOverflow(opts, pipeline.SignalTraces, overflows, fallback != nil)
+	return e
+}
+
+type routingSpanExporter struct {
+	matchers []Matcher
+	conds    []SpanCondition
+	// conditional is true if any route has a condition.
+	conditional bool
+	// exps are the exporters of the matchers, followed by the fallback.
+	exps     []trace.SpanExporter
+	overflow *overflow
+}
+
+func (e *routingSpanExporter) ExportSpans(ctx context.Context, spans []trace.ReadOnlySpan) error {
+	parts := make([][]trace.ReadOnlySpan, len(e.exps))
+	var data []spanData
+	if e.conditional {
+		data = spansData(spans)
+	}
+	for j, s := range spans {
+		lookup := func(k attribute.Key) (attribute.Value, bool) {
+			for _, kv := range s.Attributes() {
+				if kv.Key == k {
+					return kv.Value, true
+				}
+			}
+			return attribute.Value{}, false
+		}
+		i := route(len(e.matchers), func(i int) bool {
+			return e.matchers[i].match(s.Resource(), s.InstrumentationScope().Name, lookup) && e.conds[i].eval(ctx, data, j)
+		})
+		parts[i] = append(parts[i], s)
+	}
+	return e.overflow.deliver(ctx, func(dest, part int) error {
+		if e.exps[dest] == nil {
+			return nil
+		}
+		return e.exps[dest].ExportSpans(ctx, parts[part])
+	}, func(part int) int { return len(parts[part]) })
+}
+
+func (e *routingSpanExporter) Shutdown(ctx context.Context) error {
+	return callAll(len(e.exps), func(i int) error {
+		if e.exps[i] == nil {
+			return nil
+		}
+		return e.exps[i].Shutdown(ctx)
+	})
+}
+
+// MetricRoute routes the metrics whose resource is matched by its Matcher,
+// and the data points satisfying its Condition if any, to its Exporter.
+type MetricRoute struct {
+	Matcher
+	// Condition, if not nil, also needs to be satisfied by the data points.
+	// Exports are then split by the data points.
+	Condition DataPointCondition
+	Exporter  metric.Exporter
+	// Name identifies the route in the metrics of the telemetry dropped
+	// by its Overflow policy. If empty, the index of the route is used.
+	Name string
+	// Overflow is what is done with the telemetry of the route when its
+	// Exporter is saturated.
+	Overflow OverflowPolicy
+}
+
+// NewRoutingMetricExporter returns a metric Exporter sending the metrics of a
+// resource to the exporter of the first of routes that matches the resource,
+// or to fallback if none does. If any route has a Scope or a Condition, the
+// data points are routed individually instead, exporting the parts
+// concurrently. Metrics are dropped if they match no route and fallback is
+// nil. The temporality and aggregation of fallback, or of the first route if
+// fallback is nil, are used, the exporters need to agree on them.
+//
+// Shutting down the returned exporter shuts down the exporters of all routes
+// and fallback.
+func NewRoutingMetricExporter(routes []MetricRoute, fallback metric.Exporter, opts ...RoutingOption) metric.Exporter {
+	e := &routingMetricExporter{exps: make([]metric.Exporter, 0, len(routes)+1), selector: fallback}
+	var overflows []overflowRoute
+	for _, r := range routes {
+		e.matchers = append(e.matchers, r.Matcher)
+		e.conds = append(e.conds, r.Condition)
+		e.exps = append(e.exps, r.Exporter)
+		e.split = e.split || r.Condition != nil || r.Scope != ""
+		overflows = append(overflows, overflowRoute{name: r.Name, policy: r.Overflow, exp: r.Exporter})
+	}
+	e.exps = append(e.exps, fallback)
+	e.overflow = newOverflow(opts, pipeline.SignalMetrics, overflows, fallback != nil)
+	if e.selector == nil && len(routes) > 0 {
+		e.selector = routes[0].Exporter
+	}
+	return e
+}
+
+type routingMetricExporter struct {
+	matchers []Matcher
+	conds    []DataPointCondition
+	// split is whether any route has a condition or a scope, the exports
+	// are split by data point then.
+	split bool
+	// exps are the exporters of the matchers, followed by the fallback.
+	exps     []metric.Exporter
+	overflow *overflow
+	// selector is the exporter whose temporality and aggregation are used.
+	selector metric.Exporter
+}
+
+func (e *routingMetricExporter) Temporality(k metric.InstrumentKind) metricdata.Temporality {
+	if e.selector == nil {
+		return metric.DefaultTemporalitySelector(k)
+	}
+	return e.selector.Temporality(k)
+}
+
+func (e *routingMetricExporter) Aggregation(k metric.InstrumentKind) metric.Aggregation {
+	if e.selector == nil {
+		return metric.DefaultAggregationSelector(k)
+	}
+	return e.selector.Aggregation(k)
+}
+
+func (e *routingMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	if e.split {
+		return e.exportSplit(ctx, rm)
+	}
+	i := route(len(e.matchers), func(i int) bool { return e.matchers[i].matchResource(rm.Resource) })
+	return e.overflow.deliver(ctx, func(dest, _ int) error {
+		if e.exps[dest] == nil {
+			return nil
+		}
+		return e.exps[dest].Export(ctx, rm)
+	}, func(part int) int {
+		if part != i {
+			return 0
+		}
+		return dataPoints(rm)
+	})
+}
+
+// exportSplit exports each data point of rm to the exporter of the first
+// route that matches its resource and whose condition it satisfies.
+func (e *routingMetricExporter) exportSplit(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	parts := splitMetrics(rm, len(e.exps), func(dp any, m pmetric.Metric, scope pcommon.InstrumentationScope, res pcommon.Resource) int {
+		return route(len(e.matchers), func(i int) bool {
+			if !e.matchers[i].matchResource(rm.Resource) || !e.matchers[i].matchScope(scope.Name()) {
+				return false
+			}
+			return e.conds[i] == nil || e.conds[i](ctx, dp, m, scope, res)
+		})
+	})
+	return e.overflow.deliver(ctx, func(dest, part int) error {
+		if e.exps[dest] == nil {
+			return nil
+		}
+		return e.exps[dest].Export(ctx, parts[part])
+	}, func(part int) int {
+		if parts[part] == nil {
+			return 0
+		}
+		return dataPoints(parts[part])
+	})
+}
+
+func (e *routingMetricExporter) ForceFlush(ctx context.Context) error {
+	return callAll(len(e.exps), func(i int) error {
+		if e.exps[i] == nil {
+			return nil
+		}
+		return e.exps[i].ForceFlush(ctx)
+	})
+}
+
+func (e *routingMetricExporter) Shutdown(ctx context.Context) error {
+	return callAll(len(e.exps), func(i int) error {
+		if e.exps[i] == nil {
+			return nil
+		}
+		return e.exps[i].Shutdown(ctx)
+	})
+}
+
+// LogRoute routes the log records matched by its Matcher, and its Condition
+// if any, to its Exporter.
+type LogRoute struct {
+	Matcher
+	// Condition, if not nil, also needs to be satisfied by the log records.
+	Condition LogCondition
+	Exporter  log.Exporter
+	// Name identifies the route in the metrics of the telemetry dropped
+	// by its Overflow policy. If empty, the index of the route is used.
+	Name string
+	// Overflow is what is done with the telemetry of the route when its
+	// Exporter is saturated.
+	Overflow OverflowPolicy
+}
+
+// NewRoutingLogExporter returns a log Exporter sending each log record to the
+// exporter of the first of routes that matches it, or to fallback if none
+// does. A batch is split by destination and the parts are exported
+// concurrently. Log records are dropped if they match no route and fallback
+// is nil.
+//
+// Shutting down the returned exporter shuts down the exporters of all routes
+// and fallback.
+func NewRoutingLogExporter(routes []LogRoute, fallback log.Exporter, opts ...RoutingOption) log.Exporter {
+	e := &routingLogExporter{exps: make([]log.Exporter, 0, len(routes)+1)}
+	var overflows []overflowRoute
+	for _, r := range routes {
+		e.matchers = append(e.matchers, r.Matcher)
+		e.conds = append(e.conds, r.Condition)
+		e.conditional = e.conditional || r.Condition != nil
+		e.exps = append(e.exps, r.Exporter)
+		overflows = append(overflows, overflowRoute{name: r.Name, policy: r.Overflow, exp: r.Exporter})
+	}
+	e.exps = append(e.exps, fallback)
+	e.overflow = newOverflow(opts, pipeline.SignalLogs, overflows, fallback != nil)
+	return e
+}
+
+type routingLogExporter struct {
+	matchers []Matcher
+	conds    []LogCondition
+	// conditional is true if any route has a condition.
+	conditional bool
+	// exps are the exporters of the matchers, followed by the fallback.
+	exps     []log.Exporter
+	overflow *overflow
+}
+
+func (e *routingLogExporter) Export(ctx context.Context, records []log.Record) error {
+	parts := make([][]log.Record, len(e.exps))
+	var data []logData
+	if e.conditional {
+		data = logsData(records)
+	}
+	for j, r := range records {
+		res, lookup := r.Resource(), recordAttribute(r)
+		i := route(len(e.matchers), func(i int) bool {
+			return e.matchers[i].match(&res, r.InstrumentationScope().Name, lookup) && e.conds[i].eval(ctx, data, j)
+		})
+		parts[i] = append(parts[i], r)
+	}
+	return e.overflow.deliver(ctx, func(dest, part int) error {
+		if e.exps[dest] == nil {
+			return nil
+		}
+		return e.exps[dest].Export(ctx, parts[part])
+	}, func(part int) int { return len(parts[part]) })
+}
+
+func (e *routingLogExporter) ForceFlush(ctx context.Context) error {
+	return callAll(len(e.exps), func(i int) error {
+		if e.exps[i] == nil {
+			return nil
+		}
+		return e.exps[i].ForceFlush(ctx)
+	})
+}
+
+func (e *routingLogExporter) Shutdown(ctx context.Context) error {
+	return callAll(len(e.exps), func(i int) error {
+		if e.exps[i] == nil {
+			return nil
+		}
+		return e.exps[i].Shutdown(ctx)
+	})
+}
+
+// recordAttribute returns the lookup of the attributes of r. Attributes with
+// values that are not a bool, number, or string are not found.
+func recordAttribute(r log.Record) func(attribute.Key) (attribute.Value, bool) {
+	return func(k attribute.Key) (attribute.Value, bool) {
+		var (
+			v     attribute.Value
+			found bool
+		)
+		r.WalkAttributes(func(kv api.KeyValue) bool {
+			if kv.Key != string(k) {
+				return true
+			}
+			switch kv.Value.Kind() {
+			case api.KindBool:
+				v, found = attribute.BoolValue(kv.Value.AsBool()), true
+			case api.KindInt64:
+				v, found = attribute.Int64Value(kv.Value.AsInt64()), true
+			case api.KindFloat64:
+				v, found = attribute.Float64Value(kv.Value.AsFloat64()), true
+			case api.KindString:
+				v, found = attribute.StringValue(kv.Value.AsString()), true
+			}
+			return false
+		})
+		return v, found
+	}
+}
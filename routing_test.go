@@ -0,0 +1,340 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/MrAlias/collex"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// sinkSpanExporter returns a SpanExporter of a Factory wrapping s.
+func sinkSpanExporter(t *testing.T, s *sink) trace.SpanExporter {
+	t.Helper()
+	f, err := collex.NewFactory(s.factory(), settings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp, err := f.SpanExporter(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return exp
+}
+
+func spanNames(s *sink) []string {
+	var names []string
+	for _, td := range s.traces {
+		rss := td.ResourceSpans()
+		for i := range rss.Len() {
+			sss := rss.At(i).ScopeSpans()
+			for j := range sss.Len() {
+				ss := sss.At(j).Spans()
+				for k := range ss.Len() {
+					names = append(names, ss.At(k).Name())
+				}
+			}
+		}
+	}
+	return names
+}
+
+func TestRoutingSpanExporter(t *testing.T) {
+	var debug, errs, prod sink
+	exp := collex.NewRoutingSpanExporter([]collex.SpanRoute{
+		{
+			Matcher:  collex.Matcher{Resource: []attribute.KeyValue{attribute.String("env", "staging")}},
+			Exporter: sinkSpanExporter(t, &debug),
+		},
+		{
+			Matcher:  collex.Matcher{Attributes: []attribute.KeyValue{attribute.Bool("error", true)}},
+			Exporter: sinkSpanExporter(t, &errs),
+		},
+	}, sinkSpanExporter(t, &prod))
+	ctx := context.Background()
+	defer func() { _ = exp.Shutdown(ctx) }()
+
+	staging := resource.NewSchemaless(attribute.String("env", "staging"))
+	production := resource.NewSchemaless(attribute.String("env", "production"))
+	spans := tracetest.SpanStubs{
+		{Name: "staging", Resource: staging, Attributes: []attribute.KeyValue{attribute.Bool("error", true)}},
+		{Name: "failed", Resource: production, Attributes: []attribute.KeyValue{attribute.Bool("error", true)}},
+		{Name: "ok", Resource: production},
+		{Name: "ok again", Resource: production},
+	}.Snapshots()
+	if err := exp.ExportSpans(ctx, spans); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		s    *sink
+		want []string
+	}{
+		{"debug", &debug, []string{"staging"}},
+		{"errors", &errs, []string{"failed"}},
+		{"fallback", &prod, []string{"ok", "ok again"}},
+	} {
+		if got := spanNames(tc.s); !slices.Equal(got, tc.want) {
+			t.Errorf("%s exporter got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+	if prod.traces[0].ResourceSpans().Len() != 1 {
+		t.Errorf("fallback got %d resources, want the spans grouped by resource", prod.traces[0].ResourceSpans().Len())
+	}
+}
+
+func TestRoutingLogExporter(t *testing.T) {
+	var audit, other sink
+	f, err := collex.NewFactory(audit.factory(), settings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	auditExp, err := f.LogExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err = collex.NewFactory(other.factory(), settings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherExp, err := f.LogExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := collex.NewRoutingLogExporter([]collex.LogRoute{{
+		Matcher:  collex.Matcher{Attributes: []attribute.KeyValue{attribute.String("category", "audit")}},
+		Exporter: auditExp,
+	}}, otherExp)
+
+	lp := sdklog.NewLoggerProvider(sdklog.WithProcessor(sdklog.NewSimpleProcessor(exp)))
+	for _, category := range []string{"audit", "access", ""} {
+		var r log.Record
+		if category != "" {
+			r.AddAttributes(log.String("category", category))
+		}
+		lp.Logger("test").Emit(ctx, r)
+	}
+	if err := lp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	count := func(s *sink) (n int) {
+		for _, ld := range s.logs {
+			n += ld.LogRecordCount()
+		}
+		return n
+	}
+	if got := count(&audit); got != 1 {
+		t.Errorf("audit exporter got %d records, want 1", got)
+	}
+	if got := count(&other); got != 2 {
+		t.Errorf("fallback got %d records, want 2", got)
+	}
+}
+
+func TestRoutingConditions(t *testing.T) {
+	var checkout, other sink
+	exp := collex.NewRoutingSpanExporter([]collex.SpanRoute{{
+		Condition: func(_ context.Context, span ptrace.Span, scope pcommon.InstrumentationScope, _ pcommon.Resource) bool {
+			return span.Name() == "checkout" && scope.Name() == "shop"
+		},
+		Exporter: sinkSpanExporter(t, &checkout),
+	}}, sinkSpanExporter(t, &other))
+	ctx := context.Background()
+	defer func() { _ = exp.Shutdown(ctx) }()
+
+	// The spans of the scopes are interleaved, each is evaluated with its own
+	// collector data.
+	shop, web := instrumentation.Scope{Name: "shop"}, instrumentation.Scope{Name: "web"}
+	spans := tracetest.SpanStubs{
+		{Name: "checkout", InstrumentationScope: shop},
+		{Name: "checkout", InstrumentationScope: web},
+		{Name: "browse", InstrumentationScope: shop},
+		{Name: "checkout", InstrumentationScope: shop},
+	}.Snapshots()
+	if err := exp.ExportSpans(ctx, spans); err != nil {
+		t.Fatal(err)
+	}
+	if got := spanNames(&checkout); !slices.Equal(got, []string{"checkout", "checkout"}) {
+		t.Errorf("conditional route got %v, want [checkout checkout]", got)
+	}
+	got := spanNames(&other)
+	slices.Sort(got)
+	if !slices.Equal(got, []string{"browse", "checkout"}) {
+		t.Errorf("fallback got %v, want [browse checkout]", got)
+	}
+}
+
+func TestRoutingMetricConditions(t *testing.T) {
+	metricExporter := func(s *sink) metric.Exporter {
+		f, err := collex.NewFactory(s.factory(), settings())
+		if err != nil {
+			t.Fatal(err)
+		}
+		exp, err := f.MetricExporter(context.Background(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return exp
+	}
+	var errs, other sink
+	exp := collex.NewRoutingMetricExporter([]collex.MetricRoute{{
+		Condition: func(_ context.Context, dp any, _ pmetric.Metric, _ pcommon.InstrumentationScope, _ pcommon.Resource) bool {
+			v, ok := dp.(pmetric.NumberDataPoint).Attributes().Get("status")
+			return ok && v.Str() == "error"
+		},
+		Exporter: metricExporter(&errs),
+	}}, metricExporter(&other))
+	ctx := context.Background()
+	defer func() { _ = exp.Shutdown(ctx) }()
+
+	point := func(status string, v int64) metricdata.DataPoint[int64] {
+		return metricdata.DataPoint[int64]{Attributes: attribute.NewSet(attribute.String("status", status)), Value: v}
+	}
+	rm := &metricdata.ResourceMetrics{
+		Resource: resource.Empty(),
+		ScopeMetrics: []metricdata.ScopeMetrics{{
+			Metrics: []metricdata.Metrics{
+				{Name: "requests", Data: metricdata.Sum[int64]{
+					Temporality: metricdata.CumulativeTemporality,
+					IsMonotonic: true,
+					DataPoints:  []metricdata.DataPoint[int64]{point("ok", 10), point("error", 2), point("ok", 5)},
+				}},
+				{Name: "latency", Data: metricdata.Gauge[int64]{
+					DataPoints: []metricdata.DataPoint[int64]{point("error", 300)},
+				}},
+			},
+		}},
+	}
+	if err := exp.Export(ctx, rm); err != nil {
+		t.Fatal(err)
+	}
+	if len(errs.metrics) != 1 || errs.metrics[0].DataPointCount() != 2 || errs.metrics[0].MetricCount() != 2 {
+		t.Errorf("conditional route got %v, want the 2 error data points of 2 metrics", errs.metrics)
+	}
+	if len(other.metrics) != 1 || other.metrics[0].DataPointCount() != 2 || other.metrics[0].MetricCount() != 1 {
+		t.Errorf("fallback got %v, want the 2 ok data points of 1 metric", other.metrics)
+	}
+	if got := len(rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Sum[int64]).DataPoints); got != 3 {
+		t.Errorf("exported metrics modified, %d data points left, want 3", got)
+	}
+}
+
+func TestRoutingScope(t *testing.T) {
+	var framework, internal, business sink
+	exp := collex.NewRoutingSpanExporter([]collex.SpanRoute{
+		{
+			Matcher:  collex.Matcher{Scope: "go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"},
+			Exporter: sinkSpanExporter(t, &framework),
+		},
+		{
+			Matcher:  collex.Matcher{Scope: "github.com/acme/*"},
+			Exporter: sinkSpanExporter(t, &internal),
+		},
+	}, sinkSpanExporter(t, &business))
+	ctx := context.Background()
+	defer func() { _ = exp.Shutdown(ctx) }()
+
+	scope := func(name string) instrumentation.Scope { return instrumentation.Scope{Name: name} }
+	spans := tracetest.SpanStubs{
+		{Name: "GET /", InstrumentationScope: scope("go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp")},
+		{Name: "cache", InstrumentationScope: scope("github.com/acme/cache")},
+		{Name: "checkout", InstrumentationScope: scope("github.com/shop/checkout")},
+	}.Snapshots()
+	if err := exp.ExportSpans(ctx, spans); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name string
+		s    *sink
+		want []string
+	}{
+		{"framework", &framework, []string{"GET /"}},
+		{"internal", &internal, []string{"cache"}},
+		{"business", &business, []string{"checkout"}},
+	} {
+		if got := spanNames(tc.s); !slices.Equal(got, tc.want) {
+			t.Errorf("%s exporter got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+// saturatedExporter is a SpanExporter whose sending queue is full.
+type saturatedExporter struct{ trace.SpanExporter }
+
+func (saturatedExporter) Stats() collex.Stats {
+	return collex.Stats{QueueSize: 10, QueueCapacity: 10}
+}
+
+func TestRoutingOverflow(t *testing.T) {
+	tests := []struct {
+		policy          collex.OverflowPolicy
+		route, fallback int
+		dropped         int64
+	}{
+		{policy: collex.OverflowBlock, route: 2},
+		{policy: collex.OverflowSpill, fallback: 2},
+		{policy: collex.OverflowDrop, dropped: 2},
+	}
+	for _, tc := range tests {
+		var slow, other sink
+		reader := metric.NewManualReader()
+		exp := collex.NewRoutingSpanExporter([]collex.SpanRoute{{
+			Matcher:  collex.Matcher{Resource: []attribute.KeyValue{attribute.String("tenant", "slow")}},
+			Exporter: saturatedExporter{sinkSpanExporter(t, &slow)},
+			Name:     "slow",
+			Overflow: tc.policy,
+		}}, sinkSpanExporter(t, &other), collex.WithRoutingMeterProvider(metric.NewMeterProvider(metric.WithReader(reader))))
+		ctx := context.Background()
+
+		res := resource.NewSchemaless(attribute.String("tenant", "slow"))
+		if err := exp.ExportSpans(ctx, tracetest.SpanStubs{{Resource: res}, {Resource: res}}.Snapshots()); err != nil {
+			t.Fatal(err)
+		}
+		if got := len(spanNames(&slow)); got != tc.route {
+			t.Errorf("policy %d: route got %d spans, want %d", tc.policy, got, tc.route)
+		}
+		if got := len(spanNames(&other)); got != tc.fallback {
+			t.Errorf("policy %d: fallback got %d spans, want %d", tc.policy, got, tc.fallback)
+		}
+		if tc.dropped > 0 {
+			got := collect(t, reader, collexScope)
+			attrs := []attribute.KeyValue{
+				attribute.String("exporter", "slow"),
+				attribute.String("signal", "traces"),
+				attribute.String("reason", "overflow"),
+			}
+			if n := intValue(t, "dropped", got["collex.exporter.dropped"], attrs...); n != tc.dropped {
+				t.Errorf("policy %d: dropped %d spans, want %d", tc.policy, n, tc.dropped)
+			}
+		}
+		_ = exp.Shutdown(ctx)
+	}
+}
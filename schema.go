@@ -0,0 +1,189 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/confmap"
+)
+
+// Field describes a setting of a component configuration.
+type Field struct {
+	// Path is the path of the setting in the configuration, the keys of its
+	// parents and its own key joined by "::", e.g. sending_queue::queue_size.
+	Path string
+	// Type is the Go type of the setting.
+	Type reflect.Type
+	// Default is the value of the setting in the default configuration, or
+	// nil if it has none, e.g. it is a field of a nil struct pointer.
+	Default any
+	// Tag is the struct tag of the setting. It holds the mapstructure key of
+	// the setting and any documentation tags of the component.
+	Tag reflect.StructTag
+}
+
+// ConfigSchema returns the settings of the default configuration of the
+// component created by f, e.g. an exporter Factory passed to NewFactory, in
+// the order they are declared. Settings of nested structs are described
+// individually, others, like lists and maps, as a whole.
+//
+// This allows tools to render configuration UIs or generate documentation
+// for the component, whichever it is.
+func ConfigSchema(f component.Factory) ([]Field, error) {
+	def := reflect.ValueOf(f.CreateDefaultConfig())
+	if def.Kind() != reflect.Pointer || def.IsNil() || def.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%s: default configuration %T is not a pointer to a struct", f.Type(), f.CreateDefaultConfig())
+	}
+	var fields []Field
+	walkConfig(def.Elem().Type(), def.Elem(), "", map[reflect.Type]bool{}, func(f Field) {
+		fields = append(fields, f)
+	})
+	return fields, nil
+}
+
+// Change is a setting of a configuration that differs from the default
+// configuration.
+type Change struct {
+	Field
+	// Value is the value of the setting in the configuration, or nil if it
+	// has none.
+	Value any
+}
+
+// ConfigDiff returns the settings of cfg, a configuration of the component
+// created by f, that differ from the default configuration of f, in the order
+// they are declared. See ConfigSchema for how settings are described.
+//
+// Values are compared deeply. Secrets, settings of type configopaque.String,
+// are redacted when a Change is formatted, so the changes can be logged at
+// startup.
+func ConfigDiff(f component.Factory, cfg component.Config) ([]Change, error) {
+	def := f.CreateDefaultConfig()
+	v := reflect.ValueOf(cfg)
+	if reflect.TypeOf(def) != v.Type() {
+		return nil, fmt.Errorf("%s: configuration is %T, not %T", f.Type(), cfg, def)
+	}
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%s: configuration %T is not a pointer to a struct", f.Type(), cfg)
+	}
+	return diff(def, cfg), nil
+}
+
+// diff returns the settings of cfg that differ from def, a configuration of
+// the same type.
+func diff(def, cfg component.Config) []Change {
+	d, v := reflect.ValueOf(def), reflect.ValueOf(cfg)
+	if !d.IsValid() || !v.IsValid() || d.Type() != v.Type() || v.Kind() != reflect.Pointer || v.IsNil() || d.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	var defs []Field
+	walkConfig(v.Elem().Type(), d.Elem(), "", map[reflect.Type]bool{}, func(f Field) {
+		defs = append(defs, f)
+	})
+	var changes []Change
+	i := 0
+	walkConfig(v.Elem().Type(), v.Elem(), "", map[reflect.Type]bool{}, func(f Field) {
+		d := defs[i]
+		i++
+		if !reflect.DeepEqual(d.Default, f.Default) {
+			changes = append(changes, Change{Field: d, Value: f.Default})
+		}
+	})
+	return changes
+}
+
+var textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+
+// walkConfig calls fn with each setting of the struct t, its Default set to
+// the value of the setting in v. The value is nil if v is invalid, e.g. for
+// the settings of a nil struct pointer. Settings of nested structs are walked
+// unless the struct is unmarshaled from text or already being walked, so the
+// settings only depend on t.
+func walkConfig(t reflect.Type, v reflect.Value, prefix string, walking map[reflect.Type]bool, fn func(Field)) {
+	walking[t] = true
+	defer delete(walking, t)
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		key, squash, skip := mapstructureKey(sf)
+		if skip {
+			continue
+		}
+		var fv reflect.Value
+		if v.IsValid() {
+			fv = v.Field(i)
+		}
+		path := prefix
+		if !squash {
+			path = joinPath(prefix, key)
+		}
+
+		st, sv := sf.Type, fv
+		if st.Kind() == reflect.Pointer {
+			st = st.Elem()
+			if sv.IsValid() && !sv.IsNil() {
+				sv = sv.Elem()
+			} else {
+				sv = reflect.Value{}
+			}
+		}
+		if st.Kind() == reflect.Struct && !reflect.PointerTo(st).Implements(textUnmarshalerType) && !walking[st] {
+			walkConfig(st, sv, path, walking, fn)
+			continue
+		}
+
+		f := Field{Path: path, Type: sf.Type, Tag: sf.Tag}
+		if sv.IsValid() {
+			f.Default = sv.Interface()
+		}
+		fn(f)
+	}
+}
+
+// mapstructureKey returns the configuration key of sf, whether its settings
+// are squashed into its parent, and whether it is skipped.
+func mapstructureKey(sf reflect.StructField) (key string, squash, skip bool) {
+	tag, ok := sf.Tag.Lookup(confmap.MapstructureTag)
+	if !ok {
+		return sf.Name, false, false
+	}
+	name, opts, _ := strings.Cut(tag, ",")
+	if name == "-" {
+		return "", false, true
+	}
+	for _, o := range strings.Split(opts, ",") {
+		if o == "squash" {
+			squash = true
+		}
+	}
+	if name == "" {
+		name = sf.Name
+	}
+	return name, squash, false
+}
+
+func joinPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + confmap.KeyDelimiter + key
+}
@@ -0,0 +1,115 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MrAlias/collex"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/configopaque"
+	"go.opentelemetry.io/collector/exporter"
+)
+
+type documentedConfig struct {
+	Endpoint string       `mapstructure:"endpoint" doc:"Address of the backend."`
+	Batch    *batchConfig `mapstructure:"batch"`
+}
+
+type batchConfig struct {
+	Size int `mapstructure:"size" doc:"Spans in a batch."`
+}
+
+func TestConfigSchema(t *testing.T) {
+	f := exporter.NewFactory(component.MustNewType("documented"), func() component.Config {
+		return &documentedConfig{Endpoint: "localhost:4317"}
+	})
+	fields, err := collex.ConfigSchema(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 2 {
+		t.Fatalf("got %d fields, want 2: %+v", len(fields), fields)
+	}
+	if got := fields[0]; got.Path != "endpoint" || got.Default != "localhost:4317" || got.Tag.Get("doc") != "Address of the backend." {
+		t.Errorf("endpoint field = %+v", got)
+	}
+	// Fields of a nil struct pointer have no default.
+	if got := fields[1]; got.Path != "batch::size" || got.Default != nil || got.Tag.Get("doc") != "Spans in a batch." {
+		t.Errorf("batch::size field = %+v", got)
+	}
+}
+
+func TestConfigSchemaClient(t *testing.T) {
+	fields, err := collex.ConfigSchema(grpcExporter())
+	if err != nil {
+		t.Fatal(err)
+	}
+	byPath := make(map[string]collex.Field)
+	for _, f := range fields {
+		byPath[f.Path] = f
+	}
+	for path, want := range map[string]any{
+		"timeout":         5 * time.Second,
+		"keepalive::time": 10 * time.Second,
+		"tls::insecure":   false,
+		"balancer_name":   "round_robin",
+	} {
+		f, ok := byPath[path]
+		if !ok {
+			t.Errorf("no %s field", path)
+			continue
+		}
+		if f.Default != want {
+			t.Errorf("%s default = %v, want %v", path, f.Default, want)
+		}
+	}
+}
+
+func TestConfigDiff(t *testing.T) {
+	f := grpcExporter()
+	cfg, err := collex.Configure(f, func(cfg *grpcConfig) error {
+		cfg.ClientConfig.Endpoint = "collector:4317"
+		cfg.ClientConfig.Headers = map[string]configopaque.String{"api-key": "secret"}
+		cfg.ClientConfig.Keepalive.Time = time.Minute
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes, err := collex.ConfigDiff(f, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, c := range changes {
+		paths = append(paths, c.Path)
+	}
+	if want := []string{"endpoint", "keepalive::time", "headers"}; !slices.Equal(paths, want) {
+		t.Errorf("changed settings = %v, want %v", paths, want)
+	}
+	if out := fmt.Sprint(changes); strings.Contains(out, "secret") {
+		t.Errorf("secret not redacted: %s", out)
+	}
+
+	var e endpoints
+	if _, err := collex.ConfigDiff(f, e.factory().CreateDefaultConfig()); err == nil {
+		t.Error("expected an error for a configuration of another type")
+	}
+}
@@ -0,0 +1,314 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pipeline"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	"go.uber.org/zap"
+)
+
+// scopeName is the instrumentation scope of the telemetry collex emits about
+// itself.
+const scopeName = "github.com/MrAlias/collex"
+
+// helperScope is the instrumentation scope of the metrics the exporterhelper
+// of the collector records about the exports of an exporter.
+const helperScope = "go.opentelemetry.io/collector/exporter/exporterhelper"
+
+// selfMetrics are the instruments of the metrics collex records about the
+// exports of the wrapped exporters.
+//
+// The numbers of items sent and failed use the names and attributes of the
+// metrics the exporterhelper of the collector records, so the dashboards and
+// alerts of collector deployments work for collex. They are not recorded for
+// exporters using the exporterhelper, those record them already.
+type selfMetrics struct {
+	sent     map[pipeline.Signal]metric.Int64Counter
+	failed   map[pipeline.Signal]metric.Int64Counter
+	size     metric.Int64Histogram
+	duration metric.Float64Histogram
+	dropped  metric.Int64Counter
+	inFlight metric.Int64UpDownCounter
+	panics   metric.Int64Counter
+}
+
+// newSelfMetrics returns the selfMetrics recorded with mp. Instruments that
+// cannot be created are logged with log and not recorded.
+func newSelfMetrics(mp metric.MeterProvider, log *zap.Logger) *selfMetrics {
+	if mp == nil {
+		mp = noop.NewMeterProvider()
+	}
+	m := mp.Meter(scopeName)
+
+	sm := selfMetrics{
+		sent:   make(map[pipeline.Signal]metric.Int64Counter),
+		failed: make(map[pipeline.Signal]metric.Int64Counter),
+	}
+	var err error
+	counter := func(name, desc, unit string) metric.Int64Counter {
+		c, cErr := m.Int64Counter(name, metric.WithDescription(desc), metric.WithUnit(unit))
+		err = errors.Join(err, cErr)
+		return c
+	}
+	sm.sent[pipeline.SignalTraces] = counter(
+		"otelcol_exporter_sent_spans",
+		"Number of spans successfully sent to destination.",
+		"{spans}",
+	)
+	sm.failed[pipeline.SignalTraces] = counter(
+		"otelcol_exporter_send_failed_spans",
+		"Number of spans in failed attempts to send to destination.",
+		"{spans}",
+	)
+	sm.sent[pipeline.SignalMetrics] = counter(
+		"otelcol_exporter_sent_metric_points",
+		"Number of metric points successfully sent to destination.",
+		"{datapoints}",
+	)
+	sm.failed[pipeline.SignalMetrics] = counter(
+		"otelcol_exporter_send_failed_metric_points",
+		"Number of metric points in failed attempts to send to destination.",
+		"{datapoints}",
+	)
+	sm.sent[pipeline.SignalLogs] = counter(
+		"otelcol_exporter_sent_log_records",
+		"Number of log record successfully sent to destination.",
+		"{records}",
+	)
+	sm.failed[pipeline.SignalLogs] = counter(
+		"otelcol_exporter_send_failed_log_records",
+		"Number of log records in failed attempts to send to destination.",
+		"{records}",
+	)
+
+	sm.dropped = counter(
+		"collex.exporter.dropped",
+		"Number of spans, metric data points, or log records dropped before they were sent to the exporter.",
+		"{item}",
+	)
+	sm.panics = counter(
+		"collex.exporter.panics",
+		"Number of exports that panicked and were recovered.",
+		"{export}",
+	)
+
+	var hErr error
+	sm.inFlight, hErr = m.Int64UpDownCounter(
+		"collex.exporter.in_flight",
+		metric.WithUnit("{export}"),
+		metric.WithDescription("Number of exports being sent to the wrapped exporter."),
+	)
+	err = errors.Join(err, hErr)
+	sm.size, hErr = m.Int64Histogram(
+		"collex.exporter.batch.size",
+		metric.WithUnit("{item}"),
+		metric.WithDescription("Number of spans, metric data points, or log records of an export."),
+	)
+	err = errors.Join(err, hErr)
+	sm.duration, hErr = m.Float64Histogram(
+		"collex.exporter.duration",
+		metric.WithUnit("s"),
+		metric.WithDescription("Duration of an export of the wrapped exporter."),
+	)
+	err = errors.Join(err, hErr)
+	if err != nil && log != nil {
+		log.Warn("failed to create collex metrics", zap.Error(err))
+	}
+	return &sm
+}
+
+// exportRecorder records the exports of an exporter.
+type exportRecorder struct {
+	m *selfMetrics
+	// sent and failed are nil if the exporter records them itself.
+	sent, failed metric.Int64Counter
+	// expAttrs are the attributes of the sent and failed metrics, attrs
+	// those of the others.
+	expAttrs, attrs metric.MeasurementOption
+	// inst is the instance of the exporter. Its exports in flight and the
+	// error of its last export are recorded with it.
+	inst *instance
+}
+
+// recorder returns the exportRecorder of the exports of signal by the
+// exporter with id, created as inst. If obsreport is true for inst, the
+// exporter records the metrics of the exporterhelper itself.
+func (m *selfMetrics) recorder(id component.ID, signal pipeline.Signal, inst *instance) exportRecorder {
+	exp := attribute.String("exporter", id.String())
+	r := exportRecorder{
+		m:        m,
+		expAttrs: metric.WithAttributeSet(attribute.NewSet(exp)),
+		attrs:    metric.WithAttributeSet(attribute.NewSet(exp, attribute.String("signal", signal.String()))),
+		inst:     inst,
+	}
+	if !inst.obsreport {
+		r.sent, r.failed = m.sent[signal], m.failed[signal]
+	}
+	return r
+}
+
+// start records the start of an export and returns its start time.
+func (r exportRecorder) start(ctx context.Context) time.Time {
+	r.inst.inFlight.Add(1)
+	r.m.inFlight.Add(ctx, 1, r.attrs)
+	return time.Now()
+}
+
+// record records the end of an export of n items, started at start, that
+// returned err.
+func (r exportRecorder) record(ctx context.Context, n int, start time.Time, err error) {
+	r.inst.inFlight.Add(-1)
+	if err != nil {
+		r.inst.exportErr.Store(&err)
+	} else {
+		r.inst.exportErr.Store(nil)
+	}
+	r.m.inFlight.Add(ctx, -1, r.attrs)
+	r.m.duration.Record(ctx, time.Since(start).Seconds(), r.attrs)
+	r.m.size.Record(ctx, int64(n), r.attrs)
+	if r.sent == nil {
+		return
+	}
+	sent, failed := int64(n), int64(0)
+	if err != nil {
+		sent, failed = 0, int64(n)
+	}
+	r.sent.Add(ctx, sent, r.expAttrs)
+	r.failed.Add(ctx, failed, r.expAttrs)
+}
+
+// dropped records n items dropped before they were sent to the exporter, for
+// reason.
+func (r exportRecorder) dropped(ctx context.Context, n int, reason string) {
+	r.m.dropped.Add(ctx, int64(n), r.attrs, metric.WithAttributes(attribute.String("reason", reason)))
+}
+
+// helperWatch is a MeterProvider that records whether the meter of the
+// exporterhelper is used, i.e. whether an exporter records the metrics of
+// the exporterhelper itself.
+type helperWatch struct {
+	metric.MeterProvider
+	used *atomic.Bool
+	// queue observes the sending queue of the exporter.
+	queue *queueObserver
+}
+
+func (w helperWatch) Meter(name string, opts ...metric.MeterOption) metric.Meter {
+	m := w.MeterProvider.Meter(name, opts...)
+	if name != helperScope {
+		return m
+	}
+	w.used.Store(true)
+	return queueMeter{Meter: m, queue: w.queue}
+}
+
+// instrumentTraces returns next recording its exports with r.
+func instrumentTraces(r exportRecorder, next consumer.Traces) consumer.Traces {
+	c, err := consumer.NewTraces(func(ctx context.Context, td ptrace.Traces) error {
+		n, start := td.SpanCount(), r.start(ctx)
+		err := next.ConsumeTraces(ctx, td)
+		r.record(ctx, n, start, err)
+		return err
+	}, consumer.WithCapabilities(next.Capabilities()))
+	if err != nil {
+		// Only returned for a nil function.
+		panic(err)
+	}
+	return c
+}
+
+// instrumentMetrics returns next recording its exports with r.
+func instrumentMetrics(r exportRecorder, next consumer.Metrics) consumer.Metrics {
+	c, err := consumer.NewMetrics(func(ctx context.Context, md pmetric.Metrics) error {
+		n, start := md.DataPointCount(), r.start(ctx)
+		err := next.ConsumeMetrics(ctx, md)
+		r.record(ctx, n, start, err)
+		return err
+	}, consumer.WithCapabilities(next.Capabilities()))
+	if err != nil {
+		// Only returned for a nil function.
+		panic(err)
+	}
+	return c
+}
+
+// instrumentLogs returns next recording its exports with r.
+func instrumentLogs(r exportRecorder, next consumer.Logs) consumer.Logs {
+	c, err := consumer.NewLogs(func(ctx context.Context, ld plog.Logs) error {
+		n, start := ld.LogRecordCount(), r.start(ctx)
+		err := next.ConsumeLogs(ctx, ld)
+		r.record(ctx, n, start, err)
+		return err
+	}, consumer.WithCapabilities(next.Capabilities()))
+	if err != nil {
+		// Only returned for a nil function.
+		panic(err)
+	}
+	return c
+}
+
+// observeBuffer records the number of exports s buffers while it recreates
+// the components of the exporter with id, and the maximum it buffers, as the
+// size and capacity of the sending queue of the exporter. It returns the
+// function that stops recording them.
+func observeBuffer(mp metric.MeterProvider, id component.ID, signal pipeline.Signal, s *supervisor) (func(), error) {
+	if mp == nil {
+		return func() {}, nil
+	}
+	m := mp.Meter(scopeName)
+	size, err := m.Int64ObservableGauge(
+		queueSizeName,
+		metric.WithDescription("Current size of the retry queue (in batches)"),
+		metric.WithUnit("{batches}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	capacity, err := m.Int64ObservableGauge(
+		queueCapacityName,
+		metric.WithDescription("Fixed capacity of the retry queue (in batches)"),
+		metric.WithUnit("{batches}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := metric.WithAttributeSet(attribute.NewSet(
+		attribute.String("exporter", id.String()),
+		attribute.String("data_type", signal.String()),
+	))
+	reg, err := m.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(size, int64(s.buffered()), attrs)
+		o.ObserveInt64(capacity, int64(s.restart.MaxBuffered), attrs)
+		return nil
+	}, size, capacity)
+	if err != nil {
+		return nil, err
+	}
+	return func() { _ = reg.Unregister() }, nil
+}
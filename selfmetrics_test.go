@@ -0,0 +1,223 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/MrAlias/collex"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+const (
+	collexScope = "github.com/MrAlias/collex"
+	helperScope = "go.opentelemetry.io/collector/exporter/exporterhelper"
+)
+
+// meteredSettings returns settings recording metrics with the returned
+// reader.
+func meteredSettings() (*exporter.Settings, *metric.ManualReader) {
+	reader := metric.NewManualReader()
+	set := settings()
+	set.MeterProvider = metric.NewMeterProvider(metric.WithReader(reader))
+	return set, reader
+}
+
+// collect returns the metrics of scope collected by reader, keyed by name.
+func collect(t *testing.T, reader metric.Reader, scope string) map[string]metricdata.Aggregation {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]metricdata.Aggregation)
+	for _, sm := range rm.ScopeMetrics {
+		if sm.Scope.Name != scope {
+			continue
+		}
+		for _, m := range sm.Metrics {
+			got[m.Name] = m.Data
+		}
+	}
+	return got
+}
+
+// intValue returns the value of the single data point of the int64 sum or
+// gauge agg, checking its attributes are attrs.
+func intValue(t *testing.T, name string, agg metricdata.Aggregation, attrs ...attribute.KeyValue) int64 {
+	t.Helper()
+	var dps []metricdata.DataPoint[int64]
+	switch agg := agg.(type) {
+	case metricdata.Sum[int64]:
+		dps = agg.DataPoints
+	case metricdata.Gauge[int64]:
+		dps = agg.DataPoints
+	}
+	if len(dps) != 1 {
+		t.Fatalf("%s: %v", name, agg)
+	}
+	if want := attribute.NewSet(attrs...); !dps[0].Attributes.Equals(&want) {
+		t.Errorf("%s attributes: %v", name, dps[0].Attributes.ToSlice())
+	}
+	return dps[0].Value
+}
+
+// flaky returns a collector exporter, not built with the exporterhelper,
+// whose first export fails.
+func flaky() exporter.Factory {
+	var calls int
+	return exporter.NewFactory(
+		component.MustNewType("flaky"),
+		createEmptyConfig,
+		exporter.WithTraces(func(context.Context, exporter.Settings, component.Config) (exporter.Traces, error) {
+			c, err := consumer.NewTraces(func(context.Context, ptrace.Traces) error {
+				calls++
+				if calls == 1 {
+					return errBroken
+				}
+				return nil
+			})
+			return tracesComponent{Traces: c}, err
+		}, component.StabilityLevelDevelopment),
+	)
+}
+
+func TestSelfMetrics(t *testing.T) {
+	set, reader := meteredSettings()
+	factory, err := collex.NewFactory(flaky(), set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	exp, err := factory.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = exp.Shutdown(ctx) }()
+
+	spans := tracetest.SpanStubs{{Name: "a"}, {Name: "b"}}.Snapshots()
+	if err := exp.ExportSpans(ctx, spans); err == nil {
+		t.Fatal("first export succeeded")
+	}
+	spans = tracetest.SpanStubs{{Name: "c"}, {Name: "d"}, {Name: "e"}}.Snapshots()
+	if err := exp.ExportSpans(ctx, spans); err != nil {
+		t.Fatal(err)
+	}
+
+	got := collect(t, reader, collexScope)
+	exporterAttr := attribute.String("exporter", "flaky")
+	if n := intValue(t, "sent", got["otelcol_exporter_sent_spans"], exporterAttr); n != 3 {
+		t.Errorf("sent %d spans, want 3", n)
+	}
+	if n := intValue(t, "failed", got["otelcol_exporter_send_failed_spans"], exporterAttr); n != 2 {
+		t.Errorf("failed to send %d spans, want 2", n)
+	}
+
+	size, ok := got["collex.exporter.batch.size"].(metricdata.Histogram[int64])
+	if !ok || len(size.DataPoints) != 1 {
+		t.Fatalf("batch size: %v", got["collex.exporter.batch.size"])
+	}
+	if dp := size.DataPoints[0]; dp.Count != 2 || dp.Sum != 5 {
+		t.Errorf("batch size of %d exports summing to %d, want 2 summing to 5", dp.Count, dp.Sum)
+	}
+	duration, ok := got["collex.exporter.duration"].(metricdata.Histogram[float64])
+	if !ok || len(duration.DataPoints) != 1 || duration.DataPoints[0].Count != 2 {
+		t.Errorf("duration: %v", got["collex.exporter.duration"])
+	}
+}
+
+func TestSelfMetricsExporterHelper(t *testing.T) {
+	c, err := consumer.NewTraces(func(context.Context, ptrace.Traces) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := collex.NewConsumerFactory(component.MustNewType("raw"), collex.Consumers{Traces: c})
+	cfg := f.CreateDefaultConfig().(*collex.HelperConfig)
+	cfg.QueueConfig.Enabled = false
+
+	set, reader := meteredSettings()
+	factory, err := collex.NewFactory(f, set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	exp, err := factory.SpanExporter(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = exp.Shutdown(ctx) }()
+	if err := exp.ExportSpans(ctx, tracetest.SpanStubs{{Name: "a"}}.Snapshots()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The exporterhelper records the spans sent, collex does not count them
+	// twice.
+	if _, ok := collect(t, reader, collexScope)["otelcol_exporter_sent_spans"]; ok {
+		t.Error("collex recorded the spans sent by an exporterhelper exporter")
+	}
+	sent := collect(t, reader, helperScope)["otelcol_exporter_sent_spans"]
+	if n := intValue(t, "sent", sent, attribute.String("exporter", "raw")); n != 1 {
+		t.Errorf("sent %d spans, want 1", n)
+	}
+}
+
+func TestSelfMetricsRestartQueue(t *testing.T) {
+	frag := &fragile{failStart: 1}
+	cfg := restartConfig()
+	cfg.InitialInterval, cfg.MaxInterval = time.Hour, time.Hour
+	cfg.MaxBuffered = 5
+	set, reader := meteredSettings()
+	f, err := collex.NewFactory(frag.factory(), set, collex.WithRestart(cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans := tracetest.SpanStubs{{Name: "a"}}.Snapshots()
+	for range 2 {
+		if err := exp.ExportSpans(ctx, spans); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := collect(t, reader, collexScope)
+	attrs := []attribute.KeyValue{attribute.String("exporter", "fragile"), attribute.String("data_type", "traces")}
+	if n := intValue(t, "queue size", got["otelcol_exporter_queue_size"], attrs...); n != 2 {
+		t.Errorf("queue size %d, want 2", n)
+	}
+	if n := intValue(t, "queue capacity", got["otelcol_exporter_queue_capacity"], attrs...); n != 5 {
+		t.Errorf("queue capacity %d, want 5", n)
+	}
+
+	if err := exp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := collect(t, reader, collexScope)["otelcol_exporter_queue_size"]; ok {
+		t.Error("queue size recorded after shutdown")
+	}
+}
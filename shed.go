@@ -0,0 +1,236 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"context"
+	"runtime/metrics"
+	"sync/atomic"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/pipeline"
+	"go.opentelemetry.io/otel/attribute"
+	api "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.uber.org/zap"
+)
+
+// SheddingConfig configures how the exporters of a Factory shed telemetry
+// under memory pressure, see WithShedding. The memory pressure is the heap in
+// use relative to HeapLimit, or the value returned by Pressure.
+type SheddingConfig struct {
+	// HeapLimit is the size of the heap, in bytes, the memory pressure is
+	// relative to.
+	HeapLimit uint64
+	// Pressure, if not nil, returns the memory pressure instead of
+	// HeapLimit, from 0 to 1 at the limit. It lets an application signal the
+	// pressure it measures itself, e.g. against the memory limit of its
+	// container.
+	Pressure func() float64
+	// Soft is the pressure from which low-priority telemetry is shed: spans
+	// with an unsampled parent and log records less severe than info. No
+	// telemetry is shed this way if it is zero.
+	Soft float64
+	// Hard is the pressure from which all telemetry is shed. No telemetry is
+	// shed this way if it is zero.
+	Hard float64
+}
+
+// NewSheddingConfig returns a SheddingConfig of a heap limited to heapLimit
+// bytes. Low-priority telemetry is shed from 80% of the limit and all
+// telemetry from 95%.
+func NewSheddingConfig(heapLimit uint64) SheddingConfig {
+	return SheddingConfig{HeapLimit: heapLimit, Soft: 0.8, Hard: 0.95}
+}
+
+// shedLevel is the telemetry a shedder sheds.
+type shedLevel int32
+
+const (
+	shedNone shedLevel = iota
+	shedLow
+	shedAll
+)
+
+func (l shedLevel) String() string {
+	switch l {
+	case shedLow:
+		return "low_priority"
+	case shedAll:
+		return "all"
+	}
+	return "none"
+}
+
+// heapObjects is the runtime/metrics metric of the heap in use.
+const heapObjects = "/memory/classes/heap/objects:bytes"
+
+// shedder sheds the telemetry of the exporters of a Factory under memory
+// pressure. A nil shedder sheds nothing.
+type shedder struct {
+	cfg     SheddingConfig
+	log     *zap.Logger
+	dropped metric.Int64Counter
+	attrs   map[pipeline.Signal]metric.MeasurementOption
+	// level is the last shedLevel of the shedder, its changes are logged.
+	level atomic.Int32
+}
+
+// newShedder returns the shedder of the exporters of the wrapped exporter of
+// type typ, or nil if cfg sheds nothing.
+func newShedder(cfg *SheddingConfig, set exporter.Settings, typ component.Type) *shedder {
+	if cfg == nil || (cfg.Soft <= 0 && cfg.Hard <= 0) || (cfg.Pressure == nil && cfg.HeapLimit == 0) {
+		return nil
+	}
+	exp := attribute.String("exporter", component.NewID(typ).String())
+	reason := attribute.String("reason", "memory_pressure")
+	s := &shedder{
+		cfg:     *cfg,
+		log:     set.Logger.With(zap.String("exporter", component.NewID(typ).String())),
+		dropped: newSelfMetrics(set.MeterProvider, set.Logger).dropped,
+		attrs:   make(map[pipeline.Signal]metric.MeasurementOption),
+	}
+	for _, signal := range []pipeline.Signal{pipeline.SignalTraces, pipeline.SignalMetrics, pipeline.SignalLogs} {
+		s.attrs[signal] = metric.WithAttributeSet(attribute.NewSet(exp, attribute.String("signal", signal.String()), reason))
+	}
+	return s
+}
+
+// pressure returns the current memory pressure.
+func (s *shedder) pressure() float64 {
+	if s.cfg.Pressure != nil {
+		return s.cfg.Pressure()
+	}
+	sample := []metrics.Sample{{Name: heapObjects}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return float64(sample[0].Value.Uint64()) / float64(s.cfg.HeapLimit)
+}
+
+// shedLevel returns the telemetry to shed at the current memory pressure.
+func (s *shedder) shedLevel() shedLevel {
+	p, l := s.pressure(), shedNone
+	switch {
+	case s.cfg.Hard > 0 && p >= s.cfg.Hard:
+		l = shedAll
+	case s.cfg.Soft > 0 && p >= s.cfg.Soft:
+		l = shedLow
+	}
+	if prev := shedLevel(s.level.Swap(int32(l))); prev != l {
+		if l == shedNone {
+			s.log.Info("Stopped shedding telemetry", zap.Float64("memory_pressure", p))
+		} else {
+			s.log.Warn("Shedding telemetry under memory pressure", zap.Stringer("shed", l), zap.Float64("memory_pressure", p))
+		}
+	}
+	return l
+}
+
+// record records n items of signal shed.
+func (s *shedder) record(ctx context.Context, signal pipeline.Signal, n int) {
+	if n > 0 {
+		s.dropped.Add(ctx, int64(n), s.attrs[signal])
+	}
+}
+
+// spans returns the spans not shed.
+func (s *shedder) spans(ctx context.Context, spans []trace.ReadOnlySpan) []trace.ReadOnlySpan {
+	if s == nil || len(spans) == 0 {
+		return spans
+	}
+	var kept []trace.ReadOnlySpan
+	switch s.shedLevel() {
+	case shedNone:
+		return spans
+	case shedLow:
+		kept = make([]trace.ReadOnlySpan, 0, len(spans))
+		for _, span := range spans {
+			if p := span.Parent(); p.IsValid() && !p.IsSampled() {
+				continue
+			}
+			kept = append(kept, span)
+		}
+	}
+	s.record(ctx, pipeline.SignalTraces, len(spans)-len(kept))
+	return kept
+}
+
+// logs returns the log records not shed.
+func (s *shedder) logs(ctx context.Context, records []log.Record) []log.Record {
+	if s == nil || len(records) == 0 {
+		return records
+	}
+	var kept []log.Record
+	switch s.shedLevel() {
+	case shedNone:
+		return records
+	case shedLow:
+		kept = make([]log.Record, 0, len(records))
+		for _, r := range records {
+			if sev := r.Severity(); sev != api.SeverityUndefined && sev < api.SeverityInfo {
+				continue
+			}
+			kept = append(kept, r)
+		}
+	}
+	s.record(ctx, pipeline.SignalLogs, len(records)-len(kept))
+	return kept
+}
+
+// metrics returns whether rm is shed. Metrics are only shed with all the
+// other telemetry.
+func (s *shedder) metrics(ctx context.Context, rm *metricdata.ResourceMetrics) bool {
+	if s == nil || s.shedLevel() != shedAll {
+		return false
+	}
+	s.record(ctx, pipeline.SignalMetrics, dataPoints(rm))
+	return true
+}
+
+// dataPoints returns the number of data points of rm.
+func dataPoints(rm *metricdata.ResourceMetrics) int {
+	var n int
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch d := m.Data.(type) {
+			case metricdata.Gauge[int64]:
+				n += len(d.DataPoints)
+			case metricdata.Gauge[float64]:
+				n += len(d.DataPoints)
+			case metricdata.Sum[int64]:
+				n += len(d.DataPoints)
+			case metricdata.Sum[float64]:
+				n += len(d.DataPoints)
+			case metricdata.Histogram[int64]:
+				n += len(d.DataPoints)
+			case metricdata.Histogram[float64]:
+				n += len(d.DataPoints)
+			case metricdata.ExponentialHistogram[int64]:
+				n += len(d.DataPoints)
+			case metricdata.ExponentialHistogram[float64]:
+				n += len(d.DataPoints)
+			case metricdata.Summary:
+				n += len(d.DataPoints)
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,144 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/MrAlias/collex"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type pressure float64
+
+func (p *pressure) shedding() collex.SheddingConfig {
+	cfg := collex.NewSheddingConfig(0)
+	cfg.Pressure = func() float64 { return float64(*p) }
+	return cfg
+}
+
+func TestWithSheddingSpans(t *testing.T) {
+	p := new(pressure)
+	var s sink
+	set, reader := meteredSettings()
+	f, err := collex.NewFactory(s.factory(), set, collex.WithShedding(p.shedding()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = exp.Shutdown(ctx) }()
+
+	unsampled := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{1},
+	})
+	spans := tracetest.SpanStubs{
+		{Name: "root", Resource: resource.Empty()},
+		{Name: "child", Parent: unsampled, Resource: resource.Empty()},
+	}.Snapshots()
+
+	for _, level := range []pressure{0.5, 0.8, 0.95} {
+		*p = level
+		if err := exp.ExportSpans(ctx, spans); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var got []int
+	for _, td := range s.traces {
+		got = append(got, td.SpanCount())
+	}
+	if len(got) != 2 || got[0] != 2 || got[1] != 1 {
+		t.Errorf("exported %v spans, want [2 1]: none shed, then the child of an unsampled parent, then all", got)
+	}
+
+	attrs := []attribute.KeyValue{
+		attribute.String("exporter", "sink"),
+		attribute.String("signal", "traces"),
+		attribute.String("reason", "memory_pressure"),
+	}
+	if n := intValue(t, "dropped", collect(t, reader, collexScope)["collex.exporter.dropped"], attrs...); n != 3 {
+		t.Errorf("recorded %d spans shed, want 3", n)
+	}
+}
+
+func TestWithSheddingLogs(t *testing.T) {
+	p := new(pressure)
+	var s sink
+	f, err := collex.NewFactory(s.factory(), settings(), collex.WithShedding(p.shedding()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	exp, err := f.LogExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = exp.Shutdown(ctx) }()
+
+	records := make([]sdklog.Record, 3)
+	records[0].SetSeverity(log.SeverityDebug)
+	records[1].SetSeverity(log.SeverityInfo)
+
+	*p = 0.9
+	if err := exp.Export(ctx, records); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.logs) != 1 || s.logs[0].LogRecordCount() != 2 {
+		t.Fatalf("exported %v, want the info and unset severity records", s.logs)
+	}
+}
+
+func TestWithSheddingMetrics(t *testing.T) {
+	p := new(pressure)
+	var s sink
+	f, err := collex.NewFactory(s.factory(), settings(), collex.WithShedding(p.shedding()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	exp, err := f.MetricExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = exp.Shutdown(ctx) }()
+
+	rm := &metricdata.ResourceMetrics{
+		Resource: resource.Empty(),
+		ScopeMetrics: []metricdata.ScopeMetrics{{Metrics: []metricdata.Metrics{{
+			Name: "requests",
+			Data: metricdata.Gauge[int64]{DataPoints: []metricdata.DataPoint[int64]{{Value: 1}}},
+		}}}},
+	}
+	for _, level := range []pressure{0.9, 1} {
+		*p = level
+		if err := exp.Export(ctx, rm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(s.metrics) != 1 {
+		t.Errorf("exported %d batches, want 1: metrics are only shed with all telemetry", len(s.metrics))
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Stats are the state of the exports of an exporter returned by a Factory,
+// or of a Pipeline. They show capacity issues before telemetry is dropped.
+type Stats struct {
+	// QueueSize is the number of batches in the sending queues of the
+	// wrapped exporters.
+	QueueSize int64
+	// QueueCapacity is the number of batches the sending queues of the
+	// wrapped exporters hold. It is zero for exporters without a sending
+	// queue.
+	QueueCapacity int64
+	// InFlight is the number of exports being sent to the wrapped
+	// exporters.
+	InFlight int64
+	// Buffered is the number of exports buffered while the wrapped exporter
+	// is recreated, see WithRestart.
+	Buffered int
+}
+
+// Stats returns the Stats of the exporter.
+func (e *spanExporter) Stats() Stats { return e.sup.stats() }
+
+// Stats returns the Stats of the exporter.
+func (e *metricExporter) Stats() Stats { return e.sup.stats() }
+
+// Stats returns the Stats of the exporter.
+func (e *logExporter) Stats() Stats { return e.sup.stats() }
+
+// Stats returns the Stats of the exporters of p.
+func (p *Pipeline) Stats() Stats { return p.g.stats() }
+
+// stats returns the Stats of the current components of s. It returns no
+// Stats if s is nil.
+func (s *supervisor) stats() Stats {
+	if s == nil {
+		return Stats{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var st Stats
+	if s.running && s.cur != nil {
+		st = s.cur.g.stats()
+	}
+	st.Buffered = len(s.buf)
+	return st
+}
+
+// stats returns the Stats of the exporters of g.
+func (g *graph) stats() Stats {
+	var st Stats
+	for _, inst := range g.exps {
+		st.InFlight += inst.inFlight.Load()
+		size, capacity := inst.queue.observe()
+		st.QueueSize += size
+		st.QueueCapacity += capacity
+	}
+	return st
+}
+
+// queueObserver observes the size and capacity of the sending queue of an
+// exporter built with the exporterhelper of the collector. The exporterhelper
+// does not expose its queue, the callbacks it records the
+// otelcol_exporter_queue_size and otelcol_exporter_queue_capacity metrics with
+// are called instead.
+type queueObserver struct {
+	mu sync.Mutex
+	// callbacks are the callbacks observing the queue.
+	callbacks []metric.Callback
+}
+
+// observe returns the size and capacity of the queue, zero if it is not
+// observed.
+func (q *queueObserver) observe() (size, capacity int64) {
+	q.mu.Lock()
+	callbacks := q.callbacks
+	q.mu.Unlock()
+	var o queueObservation
+	for _, cb := range callbacks {
+		_ = cb(context.Background(), &o)
+	}
+	return o.size, o.capacity
+}
+
+// queueGauge is a gauge of the sending queue of an exporter. The instruments
+// of a Meter cannot be told apart, e.g. those of a no-op Meter, so the gauges
+// of the queue are wrapped by a queueMeter.
+type queueGauge struct {
+	metric.Int64ObservableGauge
+	name string
+}
+
+// queueObservation is the metric.Observer the callbacks of a queueObserver
+// are called with.
+type queueObservation struct {
+	metric.Observer
+	size, capacity int64
+}
+
+func (o *queueObservation) ObserveInt64(inst metric.Int64Observable, v int64, _ ...metric.ObserveOption) {
+	g, ok := inst.(*queueGauge)
+	if !ok {
+		return
+	}
+	switch g.name {
+	case queueSizeName:
+		o.size += v
+	case queueCapacityName:
+		o.capacity += v
+	}
+}
+
+func (o *queueObservation) ObserveFloat64(metric.Float64Observable, float64, ...metric.ObserveOption) {
+	// The queue metrics are int64 gauges.
+}
+
+// unwrapObserver is the metric.Observer of a Meter passed to the callbacks
+// observing the gauges of a queueMeter. It unwraps the queueGauge observed.
+type unwrapObserver struct {
+	metric.Observer
+}
+
+func (o unwrapObserver) ObserveInt64(inst metric.Int64Observable, v int64, opts ...metric.ObserveOption) {
+	if g, ok := inst.(*queueGauge); ok {
+		inst = g.Int64ObservableGauge
+	}
+	o.Observer.ObserveInt64(inst, v, opts...)
+}
+
+const (
+	queueSizeName     = "otelcol_exporter_queue_size"
+	queueCapacityName = "otelcol_exporter_queue_capacity"
+)
+
+// queueMeter is the Meter of the exporterhelper of an exporter. It records
+// the callbacks of the metrics of its sending queue with a queueObserver.
+type queueMeter struct {
+	metric.Meter
+	queue *queueObserver
+}
+
+func (m queueMeter) Int64ObservableGauge(name string, opts ...metric.Int64ObservableGaugeOption) (metric.Int64ObservableGauge, error) {
+	g, err := m.Meter.Int64ObservableGauge(name, opts...)
+	if err != nil || (name != queueSizeName && name != queueCapacityName) {
+		return g, err
+	}
+	return &queueGauge{Int64ObservableGauge: g, name: name}, nil
+}
+
+func (m queueMeter) RegisterCallback(f metric.Callback, instruments ...metric.Observable) (metric.Registration, error) {
+	var queue bool
+	insts := make([]metric.Observable, len(instruments))
+	for i, inst := range instruments {
+		if g, ok := inst.(*queueGauge); ok {
+			inst, queue = g.Int64ObservableGauge, true
+		}
+		insts[i] = inst
+	}
+	if !queue {
+		return m.Meter.RegisterCallback(f, instruments...)
+	}
+
+	reg, err := m.Meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		return f(ctx, unwrapObserver{o})
+	}, insts...)
+	if err != nil {
+		return reg, err
+	}
+	m.queue.mu.Lock()
+	defer m.queue.mu.Unlock()
+	m.queue.callbacks = append(m.queue.callbacks, f)
+	return reg, nil
+}
@@ -0,0 +1,100 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/MrAlias/collex"
+	"github.com/MrAlias/collex/collextest"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type statser interface {
+	Stats() collex.Stats
+}
+
+func TestStatsQueue(t *testing.T) {
+	release := make(chan struct{})
+	mock := collextest.NewMockExporter(collextest.Block(release))
+	set, reader := meteredSettings()
+	f, err := collex.NewFactory(mock.Factory(), set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, stuckQueue())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = exp.Shutdown(ctx) }()
+	defer close(release)
+
+	// Fill the queue while its consumer is stuck.
+	for exp.ExportSpans(ctx, spans(1)) == nil {
+	}
+	got := exp.(statser).Stats()
+	if got.QueueSize != 1 || got.QueueCapacity != 1 {
+		t.Errorf("queue of %d/%d batches, want 1/1", got.QueueSize, got.QueueCapacity)
+	}
+
+	// The exporterhelper still records the queue metrics itself.
+	attrs := []attribute.KeyValue{attribute.String("exporter", "mock"), attribute.String("data_type", "traces")}
+	if n := intValue(t, "queue size", collect(t, reader, helperScope)["otelcol_exporter_queue_size"], attrs...); n != 1 {
+		t.Errorf("recorded a queue of %d batches, want 1", n)
+	}
+}
+
+func TestStatsInFlight(t *testing.T) {
+	release := make(chan struct{})
+	mock := collextest.NewMockExporter(collextest.Block(release))
+	set, reader := meteredSettings()
+	f, err := collex.NewFactory(mock.Factory(), set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	cfg := collex.NewHelperConfig()
+	cfg.QueueConfig.Enabled = false
+	exp, err := f.SpanExporter(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = exp.Shutdown(ctx) }()
+
+	done := make(chan error, 1)
+	go func() { done <- exp.ExportSpans(ctx, spans(1)) }()
+	for deadline := time.Now().Add(5 * time.Second); mock.Calls() == 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+
+	if got := exp.(statser).Stats(); got.InFlight != 1 || got.QueueCapacity != 0 {
+		t.Errorf("stats %+v, want 1 export in flight without a queue", got)
+	}
+	attrs := []attribute.KeyValue{attribute.String("exporter", "mock"), attribute.String("signal", "traces")}
+	if n := intValue(t, "in flight", collect(t, reader, collexScope)["collex.exporter.in_flight"], attrs...); n != 1 {
+		t.Errorf("%d exports in flight, want 1", n)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if got := exp.(statser).Stats(); got.InFlight != 0 {
+		t.Errorf("%d exports in flight once done, want 0", got.InFlight)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"context"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componentstatus"
+)
+
+// StatusFunc is called with the status changes of collector components. The
+// instance identifies the component, its kind, and the pipelines it belongs
+// to.
+//
+// Components report a componentstatus.StatusOK once started and may later
+// report a componentstatus.StatusRecoverableError or
+// componentstatus.StatusPermanentError, e.g. when an exporter is unable to
+// reach its endpoint. Calls are serialized and need to return quickly, they
+// block the component reporting its status.
+type StatusFunc func(*componentstatus.InstanceID, *componentstatus.Event)
+
+// ErrorFunc is called with the fatal and permanent errors reported by
+// collector components. The instance identifies the component, its kind, and
+// the pipelines it belongs to.
+//
+// A fatal error means the component, and the pipelines it belongs to, are no
+// longer able to process telemetry. A permanent error means the component
+// is unable to recover without intervention, e.g. a rejected credential.
+type ErrorFunc func(*componentstatus.InstanceID, error)
+
+// statusWithErrors returns a StatusFunc calling status, if not nil, with all
+// status changes and onErr, if not nil, with the fatal and permanent errors.
+func statusWithErrors(status StatusFunc, onErr ErrorFunc) StatusFunc {
+	if onErr == nil {
+		return status
+	}
+	return func(id *componentstatus.InstanceID, ev *componentstatus.Event) {
+		if status != nil {
+			status(id, ev)
+		}
+		switch ev.Status() {
+		case componentstatus.StatusFatalError, componentstatus.StatusPermanentError:
+			onErr(id, ev.Err())
+		}
+	}
+}
+
+// statusHost is the host of a single component. It reports the status of the
+// component to the host it belongs to and fatal errors to the graph of the
+// component, if any.
+type statusHost struct {
+	*host
+	g  *graph
+	id *componentstatus.InstanceID
+}
+
+var _ componentstatus.Reporter = (*statusHost)(nil)
+
+// Report reports the status change ev of the component of h.
+func (h *statusHost) Report(ev *componentstatus.Event) {
+	h.report(h.id, ev)
+	h.g.setStatus(h.id, ev)
+	if ev.Status() == componentstatus.StatusFatalError && h.g != nil && h.g.fatal != nil {
+		h.g.fatal(ev.Err())
+	}
+}
+
+// report sends the status change ev of the component with id to the
+// extensions watching component status, like the health check extension, and
+// the StatusFunc of h. It is also reported to the parent of h if it accepts
+// component status.
+func (h *host) report(id *componentstatus.InstanceID, ev *componentstatus.Event) {
+	h.statusMu.Lock()
+	defer h.statusMu.Unlock()
+
+	for _, ext := range h.exts {
+		if w, ok := ext.(componentstatus.Watcher); ok {
+			w.ComponentStatusChanged(id, ev)
+		}
+	}
+	if r, ok := h.parent.(componentstatus.Reporter); ok {
+		r.Report(ev)
+	}
+	if h.status != nil {
+		h.status(id, ev)
+	}
+}
+
+// startComponent starts c, a component of the graph g, and reports its status
+// changes. The status is reported the same way the collector does, c is
+// starting and then either OK or in a permanent error if it failed to start.
+func (h *host) startComponent(ctx context.Context, g *graph, id *componentstatus.InstanceID, c component.Component) error {
+	h.reportTo(g, id, componentstatus.NewEvent(componentstatus.StatusStarting))
+	if err := c.Start(ctx, h.component(g, id)); err != nil {
+		h.reportTo(g, id, componentstatus.NewPermanentErrorEvent(err))
+		return err
+	}
+	h.reportTo(g, id, componentstatus.NewEvent(componentstatus.StatusOK))
+	return nil
+}
+
+// shutdownComponent shuts down c, a component of the graph g, and reports its
+// status changes.
+func (h *host) shutdownComponent(ctx context.Context, g *graph, id *componentstatus.InstanceID, c component.Component) error {
+	h.reportTo(g, id, componentstatus.NewEvent(componentstatus.StatusStopping))
+	if err := c.Shutdown(ctx); err != nil {
+		h.reportTo(g, id, componentstatus.NewPermanentErrorEvent(err))
+		return err
+	}
+	h.reportTo(g, id, componentstatus.NewEvent(componentstatus.StatusStopped))
+	return nil
+}
+
+// reportTo reports the status change ev of the component with id and records
+// it with g, the graph of the component, if not nil.
+func (h *host) reportTo(g *graph, id *componentstatus.InstanceID, ev *componentstatus.Event) {
+	h.report(id, ev)
+	g.setStatus(id, ev)
+}
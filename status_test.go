@@ -0,0 +1,205 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/MrAlias/collex"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componentstatus"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/extension"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var errUnavailable = errors.New("endpoint unavailable")
+
+// unavailable returns the factory of an exporter that, like exporters unable
+// to reach their endpoint, reports a recoverable error for every export.
+func unavailable() exporter.Factory {
+	return exporter.NewFactory(
+		component.MustNewType("unavailable"),
+		createEmptyConfig,
+		exporter.WithTraces(func(context.Context, exporter.Settings, component.Config) (exporter.Traces, error) {
+			var host component.Host
+			c, err := consumer.NewTraces(func(context.Context, ptrace.Traces) error {
+				componentstatus.ReportStatus(host, componentstatus.NewRecoverableErrorEvent(errUnavailable))
+				return errUnavailable
+			})
+			return tracesComponent{
+				testComponent: testComponent{StartFunc: func(_ context.Context, h component.Host) error {
+					host = h
+					return nil
+				}},
+				Traces: c,
+			}, err
+		}, component.StabilityLevelDevelopment),
+	)
+}
+
+// statusRecorder records the status changes of components.
+type statusRecorder struct {
+	events []string
+}
+
+func (r *statusRecorder) record(id *componentstatus.InstanceID, ev *componentstatus.Event) {
+	r.events = append(r.events, id.Kind().String()+" "+id.ComponentID().String()+" "+ev.Status().String())
+}
+
+func TestFactoryStatusFunc(t *testing.T) {
+	var r statusRecorder
+	f, err := collex.NewFactory(unavailable(), settings(), collex.WithStatusFunc(r.record))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans := tracetest.SpanStubs{{Name: "a", Resource: resource.Empty()}}.Snapshots()
+	if err := exp.ExportSpans(ctx, spans); !errors.Is(err, errUnavailable) {
+		t.Errorf("ExportSpans error = %v, want %v", err, errUnavailable)
+	}
+	if err := exp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"Exporter unavailable StatusStarting",
+		"Exporter unavailable StatusOK",
+		"Exporter unavailable StatusRecoverableError",
+		"Exporter unavailable StatusStopping",
+		"Exporter unavailable StatusStopped",
+	}
+	if !reflect.DeepEqual(r.events, want) {
+		t.Errorf("events = %v, want %v", r.events, want)
+	}
+}
+
+// statusWatcher is an extension, like the health check extension, that
+// watches the status of components.
+type statusWatcher struct {
+	testComponent
+	statusRecorder
+}
+
+func (w *statusWatcher) ComponentStatusChanged(id *componentstatus.InstanceID, ev *componentstatus.Event) {
+	w.record(id, ev)
+}
+
+func TestPipelineStatusWatcher(t *testing.T) {
+	w := new(statusWatcher)
+	watcher := extension.NewFactory(
+		component.MustNewType("watcher"),
+		createEmptyConfig,
+		func(context.Context, extension.Settings, component.Config) (extension.Extension, error) {
+			return w, nil
+		},
+		component.StabilityLevelDevelopment,
+	)
+
+	var recoverable error
+	ctx := context.Background()
+	p, err := collex.NewPipeline().
+		WithSettings(*settings()).
+		WithExtensions(collex.Extension{Factory: watcher}).
+		WithExporter(unavailable(), nil).
+		WithStatusFunc(func(id *componentstatus.InstanceID, ev *componentstatus.Event) {
+			if ev.Status() == componentstatus.StatusRecoverableError {
+				recoverable = ev.Err()
+			}
+		}).
+		Build(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans := tracetest.SpanStubs{{Name: "a", Resource: resource.Empty()}}.Snapshots()
+	_ = p.SpanExporter().ExportSpans(ctx, spans)
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if !errors.Is(recoverable, errUnavailable) {
+		t.Errorf("StatusFunc error = %v, want %v", recoverable, errUnavailable)
+	}
+	want := []string{
+		"Extension watcher StatusStarting",
+		"Extension watcher StatusOK",
+		"Exporter unavailable StatusStarting",
+		"Exporter unavailable StatusOK",
+		"Exporter unavailable StatusRecoverableError",
+		"Exporter unavailable StatusStopping",
+		"Exporter unavailable StatusStopped",
+		"Extension watcher StatusStopping",
+		"Extension watcher StatusStopped",
+	}
+	if !reflect.DeepEqual(w.events, want) {
+		t.Errorf("events = %v, want %v", w.events, want)
+	}
+}
+
+func TestFactoryErrorFunc(t *testing.T) {
+	var errs []error
+	onErr := func(id *componentstatus.InstanceID, err error) {
+		if id.Kind() != component.KindExporter {
+			t.Errorf("error reported by a %s, want an exporter", id.Kind())
+		}
+		errs = append(errs, err)
+	}
+	frag := &fragile{failStart: 1, failFatal: 2}
+	f, err := collex.NewFactory(frag.factory(), settings(), collex.WithErrorFunc(onErr))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first exporter fails to start, a permanent error.
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, nil)
+	if !errors.Is(err, errBroken) {
+		t.Errorf("SpanExporter error = %v, want %v", err, errBroken)
+	}
+	if err := exp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second reports a fatal error when exporting.
+	exp, err = f.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans := tracetest.SpanStubs{{Name: "a", Resource: resource.Empty()}}.Snapshots()
+	_ = exp.ExportSpans(ctx, spans)
+	if err := exp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(errs) != 2 {
+		t.Fatalf("ErrorFunc called %d times, want 2", len(errs))
+	}
+	for _, err := range errs {
+		if !errors.Is(err, errBroken) {
+			t.Errorf("ErrorFunc error = %v, want %v", err, errBroken)
+		}
+	}
+}
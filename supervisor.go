@@ -0,0 +1,463 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/cenkalti/backoff/v4"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/configretry"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pipeline"
+	"go.uber.org/zap"
+)
+
+var (
+	errRestarting = errors.New("collex: exporter restarting, buffer full")
+	errGaveUp     = errors.New("collex: exporter failed, gave up restarting")
+	errStopped    = errors.New("collex: exporter shut down")
+)
+
+// RestartConfig configures how the wrapped exporter is recreated after it
+// reports a fatal error or fails to start.
+type RestartConfig struct {
+	// BackOffConfig is the backoff between attempts to recreate the
+	// exporter. Exporters are not recreated if it is not enabled. Attempts
+	// stop once MaxElapsedTime has passed, if it is not zero.
+	configretry.BackOffConfig
+
+	// MaxBuffered is the maximum number of exports buffered while the
+	// exporter is recreated. Exports are refused once it is reached.
+	MaxBuffered int
+}
+
+// NewRestartConfig returns a RestartConfig with the backoff collector
+// exporters use to retry exports, without the limit on the time it is tried
+// for, and up to 100 buffered exports.
+func NewRestartConfig() RestartConfig {
+	b := configretry.NewDefaultBackOffConfig()
+	b.MaxElapsedTime = 0
+	return RestartConfig{BackOffConfig: b, MaxBuffered: 100}
+}
+
+// buildFunc builds the components of an exporter with the wrapped exporter
+// configured with cfg. It returns their graph and its consumer.
+type buildFunc func(ctx context.Context, cfg component.Config) (*graph, any, error)
+
+// generation is a started graph of components and its consumer.
+type generation struct {
+	g    *graph
+	next any
+	// exports are the exports being sent to next.
+	exports sync.WaitGroup
+}
+
+// wait waits for the exports being sent to the components of gen to be done
+// or ctx to be done, whichever comes first.
+func (gen *generation) wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		gen.exports.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type pendingExport struct {
+	ctx    context.Context
+	export func(context.Context, any) error
+}
+
+// supervisor owns the components of an exporter. The components are
+// replaced when the wrapped exporter is swapped and, if restarts are
+// enabled, recreated when a component reports a fatal error or they fail to
+// start. Exports are buffered while the components are recreated.
+type supervisor struct {
+	log     *zap.Logger
+	restart RestartConfig
+	clock   Clock
+	build   buildFunc
+
+	// swapMu serializes swaps.
+	swapMu sync.Mutex
+
+	// def returns the default configuration of the wrapped exporter.
+	def func() component.Config
+
+	mu sync.Mutex
+	// cfg is the configuration of the wrapped exporter, nil for the default
+	// configuration.
+	cfg component.Config
+	// cur are the current components. They are only exported to if running
+	// is true.
+	cur     *generation
+	running bool
+	// restarting is true while the components are recreated. buf holds the
+	// exports received meanwhile.
+	restarting bool
+	buf        []pendingExport
+	// err is the last error of the components while not running.
+	err     error
+	stopped bool
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	// stopMetrics, if not nil, stops recording the metrics of the
+	// supervisor. It is called when it is shut down.
+	stopMetrics func()
+}
+
+// newSupervisor builds the components with build and starts them. If they
+// fail to start and restarts are enabled, they are recreated in the
+// background and no error is returned.
+func newSupervisor(ctx context.Context, log *zap.Logger, restart RestartConfig, clock Clock, def func() component.Config, cfg component.Config, build buildFunc) (*supervisor, error) {
+	s := &supervisor{log: log, restart: restart, clock: clock, build: build, def: def, cfg: cfg}
+	gen, err := s.create(ctx, cfg)
+	if gen == nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cur = gen
+	if err != nil {
+		if restart.Enabled {
+			s.recreate(err)
+			return s, nil
+		}
+		s.err = err
+		return s, err
+	}
+	s.running = true
+	return s, nil
+}
+
+// create builds and starts the components with the wrapped exporter
+// configured with cfg. If they fail to start, they are returned along with
+// the error. They are nil if they could not be built.
+func (s *supervisor) create(ctx context.Context, cfg component.Config) (*generation, error) {
+	g, next, err := s.build(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if s.restart.Enabled {
+		s.watch(g)
+	}
+	return &generation{g: g, next: next}, g.start(ctx)
+}
+
+// watch has s notified of the fatal errors reported by the components of g.
+func (s *supervisor) watch(g *graph) {
+	g.fatal = func(err error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.running && s.cur.g == g {
+			s.recreate(err)
+		}
+	}
+}
+
+// recreate shuts down the current components and recreates them in the
+// background. It needs to be called with s.mu held.
+func (s *supervisor) recreate(err error) {
+	if s.stopped || s.restarting {
+		return
+	}
+	s.log.Warn("Recreating exporter", zap.Error(err))
+	old := s.cur
+	s.cur = nil
+	s.running, s.restarting = false, true
+	s.err = err
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel, s.done = cancel, make(chan struct{})
+	go s.loop(ctx, old, s.done)
+}
+
+func (s *supervisor) loop(ctx context.Context, old *generation, done chan struct{}) {
+	defer close(done)
+
+	if old != nil {
+		old.exports.Wait()
+		if err := old.g.shutdown(ctx); err != nil {
+			s.log.Warn("Failed to shut down exporter", zap.Error(err))
+		}
+	}
+
+	b := backoff.NewExponentialBackOff(
+		backoff.WithInitialInterval(s.restart.InitialInterval),
+		backoff.WithRandomizationFactor(s.restart.RandomizationFactor),
+		backoff.WithMultiplier(s.restart.Multiplier),
+		backoff.WithMaxInterval(s.restart.MaxInterval),
+		backoff.WithMaxElapsedTime(s.restart.MaxElapsedTime),
+		backoff.WithClockProvider(s.clock),
+	)
+	for {
+		d := b.NextBackOff()
+		if d == backoff.Stop {
+			s.mu.Lock()
+			s.restarting = false
+			s.buf = nil
+			s.err = fmt.Errorf("%w: %w", errGaveUp, s.err)
+			s.log.Error("Gave up recreating exporter", zap.Error(s.err))
+			s.mu.Unlock()
+			return
+		}
+		t := s.clock.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C():
+		}
+
+		s.mu.Lock()
+		cfg := s.cfg
+		s.mu.Unlock()
+		gen, err := s.create(ctx, cfg)
+		if gen != nil && err != nil {
+			err = errors.Join(err, gen.g.shutdown(ctx))
+		}
+		if err != nil {
+			s.log.Warn("Failed to recreate exporter", zap.Error(err))
+			s.mu.Lock()
+			s.err = err
+			s.mu.Unlock()
+			continue
+		}
+		s.resume(ctx, gen)
+		return
+	}
+}
+
+// resume has the exports sent to gen once all the buffered exports are.
+func (s *supervisor) resume(ctx context.Context, gen *generation) {
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		if err := gen.g.shutdown(ctx); err != nil {
+			s.log.Warn("Failed to shut down exporter", zap.Error(err))
+		}
+		return
+	}
+	s.cur = gen
+	s.mu.Unlock()
+
+	for {
+		s.mu.Lock()
+		buf := s.buf
+		s.buf = nil
+		if len(buf) == 0 {
+			s.running, s.restarting = true, false
+			s.err = nil
+			s.mu.Unlock()
+			return
+		}
+		s.mu.Unlock()
+
+		for _, p := range buf {
+			if err := p.export(p.ctx, gen.next); err != nil {
+				s.log.Warn("Failed to export buffered telemetry", zap.Error(err))
+			}
+		}
+	}
+}
+
+// config returns the configuration of the wrapped exporter.
+func (s *supervisor) config() component.Config {
+	s.mu.Lock()
+	cfg := s.cfg
+	s.mu.Unlock()
+	if cfg == nil {
+		return s.def()
+	}
+	return cfg
+}
+
+// swap replaces the components with ones using the wrapped exporter
+// configured with cfg. The new components are started before exports are
+// switched to them. The old ones are shut down, draining their sending
+// queues, once the exports in progress are done. If the new components fail
+// to start, the old ones are kept.
+func (s *supervisor) swap(ctx context.Context, cfg component.Config) error {
+	s.swapMu.Lock()
+	defer s.swapMu.Unlock()
+
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return errStopped
+	}
+	cancel, done := s.cancel, s.done
+	s.mu.Unlock()
+
+	// Stop recreating the components with the previous configuration.
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+
+	gen, err := s.create(ctx, cfg)
+	if err != nil {
+		if gen != nil {
+			err = errors.Join(err, gen.g.shutdown(ctx))
+		}
+		s.mu.Lock()
+		if !s.running && s.restarting {
+			// The previous recreation was interrupted, resume it.
+			s.restarting = false
+			s.recreate(s.err)
+		}
+		s.mu.Unlock()
+		return err
+	}
+
+	s.mu.Lock()
+	s.cfg = cfg
+	if s.stopped {
+		s.mu.Unlock()
+		return errors.Join(errStopped, gen.g.shutdown(ctx))
+	}
+	old := s.cur
+	if !s.running {
+		// The components are being recreated, or failed to start, and
+		// exports are buffered or refused.
+		s.cur = nil
+		s.mu.Unlock()
+		s.resume(ctx, gen)
+		if old == nil {
+			return nil
+		}
+		return old.g.shutdown(ctx)
+	}
+	s.cur = gen
+	s.mu.Unlock()
+
+	old.exports.Wait()
+	return old.g.shutdown(ctx)
+}
+
+// consume calls export with the consumer of the components. If they are
+// being recreated, export is buffered until they are.
+func (s *supervisor) consume(ctx context.Context, export func(context.Context, any) error) error {
+	s.mu.Lock()
+	if s.running {
+		gen := s.cur
+		gen.exports.Add(1)
+		s.mu.Unlock()
+		defer gen.exports.Done()
+		return export(ctx, gen.next)
+	}
+	defer s.mu.Unlock()
+
+	switch {
+	case s.stopped:
+		return errStopped
+	case !s.restarting:
+		return s.err
+	case len(s.buf) >= s.restart.MaxBuffered:
+		return fmt.Errorf("%w: %w", errRestarting, s.err)
+	}
+	s.buf = append(s.buf, pendingExport{ctx: context.WithoutCancel(ctx), export: export})
+	return nil
+}
+
+// buffered returns the number of exports buffered while the components are
+// recreated.
+func (s *supervisor) buffered() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.buf)
+}
+
+// consumer returns the consumer of signal exporters send to.
+func (s *supervisor) consumer(signal pipeline.Signal) any {
+	var (
+		c   any
+		err error
+	)
+	switch signal {
+	case pipeline.SignalTraces:
+		c, err = consumer.NewTraces(func(ctx context.Context, td ptrace.Traces) error {
+			return s.consume(ctx, func(ctx context.Context, next any) error {
+				return next.(consumer.Traces).ConsumeTraces(ctx, td)
+			})
+		})
+	case pipeline.SignalMetrics:
+		c, err = consumer.NewMetrics(func(ctx context.Context, md pmetric.Metrics) error {
+			return s.consume(ctx, func(ctx context.Context, next any) error {
+				return next.(consumer.Metrics).ConsumeMetrics(ctx, md)
+			})
+		})
+	case pipeline.SignalLogs:
+		c, err = consumer.NewLogs(func(ctx context.Context, ld plog.Logs) error {
+			return s.consume(ctx, func(ctx context.Context, next any) error {
+				return next.(consumer.Logs).ConsumeLogs(ctx, ld)
+			})
+		})
+	}
+	if err != nil {
+		// Only returned for a nil function.
+		panic(err)
+	}
+	return c
+}
+
+// shutdown stops recreating the components and shuts them down. Buffered
+// exports are dropped. It is safe to call shutdown multiple times.
+func (s *supervisor) shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.stopped, s.running = true, false
+	cancel, done, stopMetrics := s.cancel, s.done, s.stopMetrics
+	s.stopMetrics = nil
+	s.mu.Unlock()
+
+	if stopMetrics != nil {
+		stopMetrics()
+	}
+
+	if cancel != nil {
+		cancel()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	s.mu.Lock()
+	gen := s.cur
+	s.cur, s.buf = nil, nil
+	s.mu.Unlock()
+	if gen == nil {
+		return nil
+	}
+	// Like swap, let the exports in progress finish before the components
+	// they use are shut down.
+	err := gen.wait(ctx)
+	return errors.Join(err, gen.g.shutdown(ctx))
+}
@@ -0,0 +1,408 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MrAlias/collex"
+	"github.com/MrAlias/collex/collextest"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componentstatus"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var errBroken = errors.New("connection broken")
+
+// fragile is a collector exporter whose first instances fail, either to
+// start or with a fatal error on their first export.
+type fragile struct {
+	mu        sync.Mutex
+	created   int
+	failStart int
+	failFatal int
+	spans     int
+}
+
+func (f *fragile) factory() exporter.Factory {
+	return exporter.NewFactory(
+		component.MustNewType("fragile"),
+		createEmptyConfig,
+		exporter.WithTraces(func(context.Context, exporter.Settings, component.Config) (exporter.Traces, error) {
+			f.mu.Lock()
+			f.created++
+			n := f.created
+			f.mu.Unlock()
+
+			var host component.Host
+			c, err := consumer.NewTraces(func(_ context.Context, td ptrace.Traces) error {
+				if n <= f.failFatal {
+					componentstatus.ReportStatus(host, componentstatus.NewFatalErrorEvent(errBroken))
+					return errBroken
+				}
+				f.mu.Lock()
+				f.spans += td.SpanCount()
+				f.mu.Unlock()
+				return nil
+			})
+			start := testComponent{StartFunc: func(_ context.Context, h component.Host) error {
+				if n <= f.failStart {
+					return errBroken
+				}
+				host = h
+				return nil
+			}}
+			return tracesComponent{testComponent: start, Traces: c}, err
+		}, component.StabilityLevelDevelopment),
+	)
+}
+
+func (f *fragile) exported() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.spans
+}
+
+func restartConfig() collex.RestartConfig {
+	cfg := collex.NewRestartConfig()
+	cfg.InitialInterval = time.Millisecond
+	cfg.MaxInterval = time.Millisecond
+	cfg.RandomizationFactor = 0
+	return cfg
+}
+
+func TestFactoryRestartFatalError(t *testing.T) {
+	frag := &fragile{failFatal: 1}
+	f, err := collex.NewFactory(frag.factory(), settings(), collex.WithRestart(restartConfig()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans := tracetest.SpanStubs{{Name: "a", Resource: resource.Empty()}}.Snapshots()
+	if err := exp.ExportSpans(ctx, spans); !errors.Is(err, errBroken) {
+		t.Errorf("ExportSpans error = %v, want %v", err, errBroken)
+	}
+	// Buffered while the exporter is recreated, if it is not yet.
+	if err := exp.ExportSpans(ctx, spans); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); frag.exported() == 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if err := exp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := frag.exported(); got != 1 {
+		t.Errorf("exported %d spans after the restart, want 1", got)
+	}
+}
+
+func TestFactoryRestartFailedStart(t *testing.T) {
+	frag := &fragile{failStart: 2}
+	f, err := collex.NewFactory(frag.factory(), settings(), collex.WithRestart(restartConfig()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatalf("SpanExporter error = %v, want it to be restarted", err)
+	}
+	spans := tracetest.SpanStubs{{Name: "a", Resource: resource.Empty()}}.Snapshots()
+	if err := exp.ExportSpans(ctx, spans); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); frag.exported() == 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if err := exp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := frag.exported(); got != 1 {
+		t.Errorf("exported %d buffered spans, want 1", got)
+	}
+}
+
+func TestFactoryRestartClock(t *testing.T) {
+	frag := &fragile{failStart: 1}
+	cfg := restartConfig()
+	cfg.InitialInterval, cfg.MaxInterval = time.Hour, time.Hour
+	clock := collextest.NewFakeClock(time.Now())
+	f, err := collex.NewFactory(frag.factory(), settings(), collex.WithRestart(cfg), collex.WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans := tracetest.SpanStubs{{Name: "a", Resource: resource.Empty()}}.Snapshots()
+	if err := exp.ExportSpans(ctx, spans); err != nil {
+		t.Fatal(err)
+	}
+
+	// The exporter is recreated once the backoff passes on the clock, not
+	// an hour later.
+	clock.BlockUntil(1)
+	clock.Advance(time.Hour)
+	for deadline := time.Now().Add(5 * time.Second); frag.exported() == 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if err := exp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := frag.exported(); got != 1 {
+		t.Errorf("exported %d buffered spans, want 1", got)
+	}
+}
+
+func TestFactoryRestartBufferFull(t *testing.T) {
+	frag := &fragile{failStart: 1}
+	cfg := restartConfig()
+	cfg.InitialInterval, cfg.MaxInterval = time.Hour, time.Hour
+	cfg.MaxBuffered = 1
+	f, err := collex.NewFactory(frag.factory(), settings(), collex.WithRestart(cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans := tracetest.SpanStubs{{Name: "a", Resource: resource.Empty()}}.Snapshots()
+	if err := exp.ExportSpans(ctx, spans); err != nil {
+		t.Fatal(err)
+	}
+	if err := exp.ExportSpans(ctx, spans); err == nil {
+		t.Error("expected an error exporting with a full buffer")
+	}
+	if err := exp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type endpointConfig struct {
+	Endpoint string `mapstructure:"endpoint"`
+}
+
+// endpoints is a collector exporter that records the number of spans sent to
+// each endpoint it is configured with. It fails to start with the "down"
+// endpoint.
+type endpoints struct {
+	mu      sync.Mutex
+	spans   map[string]int
+	stopped []string
+}
+
+func (e *endpoints) factory() exporter.Factory {
+	return exporter.NewFactory(
+		component.MustNewType("endpoints"),
+		func() component.Config { return &endpointConfig{Endpoint: "default"} },
+		exporter.WithTraces(func(_ context.Context, _ exporter.Settings, cfg component.Config) (exporter.Traces, error) {
+			endpoint := cfg.(*endpointConfig).Endpoint
+			c, err := consumer.NewTraces(func(_ context.Context, td ptrace.Traces) error {
+				e.mu.Lock()
+				defer e.mu.Unlock()
+				if e.spans == nil {
+					e.spans = make(map[string]int)
+				}
+				e.spans[endpoint] += td.SpanCount()
+				return nil
+			})
+			comp := testComponent{
+				StartFunc: func(context.Context, component.Host) error {
+					if endpoint == "down" {
+						return errBroken
+					}
+					return nil
+				},
+				ShutdownFunc: func(context.Context) error {
+					e.mu.Lock()
+					defer e.mu.Unlock()
+					e.stopped = append(e.stopped, endpoint)
+					return nil
+				},
+			}
+			return tracesComponent{testComponent: comp, Traces: c}, err
+		}, component.StabilityLevelDevelopment),
+	)
+}
+
+func TestSwap(t *testing.T) {
+	var e endpoints
+	f, err := collex.NewFactory(e.factory(), settings())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, &endpointConfig{Endpoint: "old"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans := tracetest.SpanStubs{{Name: "a", Resource: resource.Empty()}}.Snapshots()
+	if err := exp.ExportSpans(ctx, spans); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := collex.Swap(ctx, exp, &endpointConfig{Endpoint: "new"}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(e.stopped, []string{"old"}) {
+		t.Errorf("stopped = %v, want the old exporter stopped", e.stopped)
+	}
+	if err := exp.ExportSpans(ctx, spans); err != nil {
+		t.Fatal(err)
+	}
+
+	// The new exporter is kept if the replacement fails to start.
+	if err := collex.Swap(ctx, exp, &endpointConfig{Endpoint: "down"}); !errors.Is(err, errBroken) {
+		t.Errorf("Swap error = %v, want %v", err, errBroken)
+	}
+	if err := exp.ExportSpans(ctx, spans); err != nil {
+		t.Fatal(err)
+	}
+	if err := exp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]int{"old": 1, "new": 2}
+	if !reflect.DeepEqual(e.spans, want) {
+		t.Errorf("spans = %v, want %v", e.spans, want)
+	}
+}
+
+func TestSwapPipelineExporter(t *testing.T) {
+	ctx := context.Background()
+	p, err := collex.NewPipeline().
+		WithSettings(*settings()).
+		WithExporter(new(sink).factory(), nil).
+		Build(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = p.Shutdown(ctx) }()
+
+	if err := collex.Swap(ctx, p.SpanExporter(), nil); err == nil {
+		t.Error("expected an error swapping an exporter of a Pipeline")
+	}
+}
+
+func TestFactoryShutdownWaitsForExports(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		events  []string
+		entered = make(chan struct{})
+		release = make(chan struct{})
+	)
+	record := func(event string) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	}
+	blocking := exporter.NewFactory(
+		component.MustNewType("blocking"),
+		createEmptyConfig,
+		exporter.WithTraces(func(context.Context, exporter.Settings, component.Config) (exporter.Traces, error) {
+			c, err := consumer.NewTraces(func(context.Context, ptrace.Traces) error {
+				close(entered)
+				<-release
+				record("export")
+				return nil
+			})
+			comp := testComponent{ShutdownFunc: func(context.Context) error {
+				record("shutdown")
+				return nil
+			}}
+			return tracesComponent{testComponent: comp, Traces: c}, err
+		}, component.StabilityLevelDevelopment),
+	)
+	f, err := collex.NewFactory(blocking, settings())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exported := make(chan error)
+	go func() {
+		spans := tracetest.SpanStubs{{Name: "a", Resource: resource.Empty()}}.Snapshots()
+		exported <- exp.ExportSpans(ctx, spans)
+	}()
+	<-entered
+
+	shutdown := make(chan error)
+	go func() { shutdown <- exp.Shutdown(ctx) }()
+	select {
+	case err := <-shutdown:
+		t.Fatalf("Shutdown returned during an export: %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(release)
+	if err := <-exported; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-shutdown; err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"export", "shutdown"}; !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+
+	// Shutdown does not wait past the deadline of its context.
+	exp, err = f.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release = make(chan struct{})
+	entered = make(chan struct{})
+	go func() {
+		spans := tracetest.SpanStubs{{Name: "a", Resource: resource.Empty()}}.Snapshots()
+		exported <- exp.ExportSpans(ctx, spans)
+	}()
+	<-entered
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := exp.Shutdown(timeout); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	close(release)
+	<-exported
+}
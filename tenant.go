@@ -0,0 +1,353 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.uber.org/zap"
+)
+
+var errTenantsShutDown = errors.New("collex: tenant exporter shut down")
+
+// TenantConfig configures a tenant exporter.
+type TenantConfig struct {
+	// Key is the resource attribute identifying the tenant of the
+	// telemetry. Telemetry without it belongs to the tenant "".
+	Key attribute.Key
+	// Config returns the configuration of the exporter of tenant, e.g. a
+	// template with the endpoint or credentials of tenant filled in. If it
+	// returns an error, the exports of tenant fail with it. If it is nil,
+	// the factory default configuration is used for all tenants.
+	Config func(tenant string) (component.Config, error)
+	// IdleTimeout is the time after which the exporter of a tenant that has
+	// not exported is shut down. It is recreated the next time the tenant
+	// exports. If it is not positive, exporters are kept until the tenant
+	// exporter is shut down.
+	IdleTimeout time.Duration
+	// Clock, if not nil, is the Clock IdleTimeout is measured with instead
+	// of the system clock.
+	Clock Clock
+}
+
+// tenant is the exporter of a tenant.
+type tenant[E any] struct {
+	exp E
+	// used is when the tenant last exported.
+	used time.Time
+	// active is the number of exports of the tenant in progress. The
+	// exporter is not evicted while there are any.
+	active int
+}
+
+// tenants are the exporters of the tenants of a tenant exporter, created
+// when a tenant first exports.
+type tenants[E any] struct {
+	cfg      TenantConfig
+	clock    Clock
+	log      *zap.Logger
+	restart  bool
+	create   func(context.Context, component.Config) (E, error)
+	shutdown func(E, context.Context) error
+
+	mu     sync.Mutex
+	m      map[string]*tenant[E]
+	closed bool
+}
+
+func newTenants[E any](f *Factory, cfg TenantConfig, create func(context.Context, component.Config) (E, error), shutdown func(E, context.Context) error) *tenants[E] {
+	return &tenants[E]{
+		cfg:      cfg,
+		clock:    clockOrSystem(cfg.Clock),
+		log:      f.createCfg.Logger,
+		restart:  f.restart.Enabled,
+		create:   create,
+		shutdown: shutdown,
+		m:        make(map[string]*tenant[E]),
+	}
+}
+
+// name returns the tenant of the telemetry of res.
+func (t *tenants[E]) name(res *resource.Resource) string {
+	v, _ := res.Set().Value(t.cfg.Key)
+	return v.Emit()
+}
+
+// export calls fn with the exporter of the tenant name, creating it if there
+// is none.
+func (t *tenants[E]) export(ctx context.Context, name string, fn func(E) error) error {
+	tn, err := t.acquire(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer t.release(tn)
+	return fn(tn.exp)
+}
+
+func (t *tenants[E]) acquire(ctx context.Context, name string) (*tenant[E], error) {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil, errTenantsShutDown
+	}
+	now := t.clock.Now()
+	idle := t.evict(now)
+	defer t.shutdownIdle(ctx, idle)
+	defer t.mu.Unlock()
+
+	tn, ok := t.m[name]
+	if !ok {
+		exp, err := t.new(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("collex: tenant %q: %w", name, err)
+		}
+		tn = &tenant[E]{exp: exp}
+		t.m[name] = tn
+	}
+	tn.used = now
+	tn.active++
+	return tn, nil
+}
+
+func (t *tenants[E]) new(ctx context.Context, name string) (E, error) {
+	var cfg component.Config
+	if t.cfg.Config != nil {
+		var err error
+		if cfg, err = t.cfg.Config(name); err != nil {
+			var zero E
+			return zero, err
+		}
+	}
+	exp, err := t.create(ctx, cfg)
+	if err != nil && any(exp) != nil {
+		if t.restart {
+			// The exporter keeps trying to start its components.
+			t.log.Warn("Failed to start tenant exporter", zap.String("tenant", name), zap.Error(err))
+			return exp, nil
+		}
+		_ = t.shutdown(exp, ctx)
+	}
+	return exp, err
+}
+
+func (t *tenants[E]) release(tn *tenant[E]) {
+	t.mu.Lock()
+	tn.active--
+	t.mu.Unlock()
+}
+
+// evict removes the exporters of the tenants idle since IdleTimeout before
+// now and returns them. It needs to be called with t.mu held.
+func (t *tenants[E]) evict(now time.Time) map[string]E {
+	if t.cfg.IdleTimeout <= 0 {
+		return nil
+	}
+	var idle map[string]E
+	for name, tn := range t.m {
+		if tn.active > 0 || now.Sub(tn.used) < t.cfg.IdleTimeout {
+			continue
+		}
+		if idle == nil {
+			idle = make(map[string]E)
+		}
+		idle[name] = tn.exp
+		delete(t.m, name)
+	}
+	return idle
+}
+
+// shutdownIdle shuts down the exporters of idle tenants. Errors are logged,
+// they do not fail the export evicting the tenants.
+func (t *tenants[E]) shutdownIdle(ctx context.Context, idle map[string]E) {
+	for name, exp := range idle {
+		if err := t.shutdown(exp, ctx); err != nil {
+			t.log.Warn("Failed to shut down idle tenant exporter", zap.String("tenant", name), zap.Error(err))
+		}
+	}
+}
+
+// forEach calls fn with the exporters of all the tenants.
+func (t *tenants[E]) forEach(fn func(E) error) error {
+	t.mu.Lock()
+	exps := make([]E, 0, len(t.m))
+	for _, tn := range t.m {
+		exps = append(exps, tn.exp)
+	}
+	t.mu.Unlock()
+	return callAll(len(exps), func(i int) error { return fn(exps[i]) })
+}
+
+// close shuts down the exporters of all the tenants. No tenant exports
+// afterwards.
+func (t *tenants[E]) close(ctx context.Context) error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	exps := make([]E, 0, len(t.m))
+	for _, tn := range t.m {
+		exps = append(exps, tn.exp)
+	}
+	t.m = nil
+	t.mu.Unlock()
+	return callAll(len(exps), func(i int) error { return t.shutdown(exps[i], ctx) })
+}
+
+// NewTenantSpanExporter returns a SpanExporter exporting the spans of each
+// tenant, identified by the cfg.Key resource attribute, with a separate
+// exporter of f. The exporter of a tenant is created with the configuration
+// cfg.Config returns for it when the tenant first exports, so one process
+// can export to many isolated backends, and shut down once it is idle.
+//
+// Shutting down the returned exporter shuts down the exporters of all the
+// tenants.
+func NewTenantSpanExporter(f *Factory, cfg TenantConfig) trace.SpanExporter {
+	return &tenantSpanExporter{tenants: newTenants(
+		f, cfg, f.SpanExporter,
+		func(exp trace.SpanExporter, ctx context.Context) error { return exp.Shutdown(ctx) },
+	)}
+}
+
+type tenantSpanExporter struct {
+	tenants *tenants[trace.SpanExporter]
+}
+
+func (e *tenantSpanExporter) ExportSpans(ctx context.Context, spans []trace.ReadOnlySpan) error {
+	var (
+		names []string
+		parts = make(map[string][]trace.ReadOnlySpan)
+	)
+	for _, s := range spans {
+		name := e.tenants.name(s.Resource())
+		if _, ok := parts[name]; !ok {
+			names = append(names, name)
+		}
+		parts[name] = append(parts[name], s)
+	}
+	return fanout(FanoutAll, len(names), func(i int) error {
+		return e.tenants.export(ctx, names[i], func(exp trace.SpanExporter) error {
+			return exp.ExportSpans(ctx, parts[names[i]])
+		})
+	})
+}
+
+func (e *tenantSpanExporter) Shutdown(ctx context.Context) error {
+	return e.tenants.close(ctx)
+}
+
+// NewTenantMetricExporter returns a metric Exporter exporting the metrics of
+// each tenant, identified by the cfg.Key resource attribute, with a separate
+// exporter of f created when the tenant first exports. The temporality the
+// Factory was configured with is used.
+//
+// Shutting down the returned exporter shuts down the exporters of all the
+// tenants.
+func NewTenantMetricExporter(f *Factory, cfg TenantConfig) metric.Exporter {
+	return &tenantMetricExporter{
+		temporality: f.temporality,
+		tenants: newTenants(
+			f, cfg, f.MetricExporter,
+			func(exp metric.Exporter, ctx context.Context) error { return exp.Shutdown(ctx) },
+		),
+	}
+}
+
+type tenantMetricExporter struct {
+	temporality metric.TemporalitySelector
+	tenants     *tenants[metric.Exporter]
+}
+
+func (e *tenantMetricExporter) Temporality(k metric.InstrumentKind) metricdata.Temporality {
+	if e.temporality == nil {
+		return metric.DefaultTemporalitySelector(k)
+	}
+	return e.temporality(k)
+}
+
+func (e *tenantMetricExporter) Aggregation(k metric.InstrumentKind) metric.Aggregation {
+	return metric.DefaultAggregationSelector(k)
+}
+
+func (e *tenantMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	return e.tenants.export(ctx, e.tenants.name(rm.Resource), func(exp metric.Exporter) error {
+		return exp.Export(ctx, rm)
+	})
+}
+
+func (e *tenantMetricExporter) ForceFlush(ctx context.Context) error {
+	return e.tenants.forEach(func(exp metric.Exporter) error { return exp.ForceFlush(ctx) })
+}
+
+func (e *tenantMetricExporter) Shutdown(ctx context.Context) error {
+	return e.tenants.close(ctx)
+}
+
+// NewTenantLogExporter returns a log Exporter exporting the log records of
+// each tenant, identified by the cfg.Key resource attribute, with a separate
+// exporter of f created when the tenant first exports.
+//
+// Shutting down the returned exporter shuts down the exporters of all the
+// tenants.
+func NewTenantLogExporter(f *Factory, cfg TenantConfig) log.Exporter {
+	return &tenantLogExporter{tenants: newTenants(
+		f, cfg, f.LogExporter,
+		func(exp log.Exporter, ctx context.Context) error { return exp.Shutdown(ctx) },
+	)}
+}
+
+type tenantLogExporter struct {
+	tenants *tenants[log.Exporter]
+}
+
+func (e *tenantLogExporter) Export(ctx context.Context, records []log.Record) error {
+	var (
+		names []string
+		parts = make(map[string][]log.Record)
+	)
+	for _, r := range records {
+		res := r.Resource()
+		name := e.tenants.name(&res)
+		if _, ok := parts[name]; !ok {
+			names = append(names, name)
+		}
+		parts[name] = append(parts[name], r)
+	}
+	return fanout(FanoutAll, len(names), func(i int) error {
+		return e.tenants.export(ctx, names[i], func(exp log.Exporter) error {
+			return exp.Export(ctx, parts[names[i]])
+		})
+	})
+}
+
+func (e *tenantLogExporter) ForceFlush(ctx context.Context) error {
+	return e.tenants.forEach(func(exp log.Exporter) error { return exp.ForceFlush(ctx) })
+}
+
+func (e *tenantLogExporter) Shutdown(ctx context.Context) error {
+	return e.tenants.close(ctx)
+}
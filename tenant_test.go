@@ -0,0 +1,137 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MrAlias/collex"
+	"github.com/MrAlias/collex/collextest"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type tenantConfig struct{ Tenant string }
+
+// tenantBackends are the backends of the tenants, counting the spans each
+// receives and the times its exporter is created and shut down.
+type tenantBackends struct {
+	mu                      sync.Mutex
+	spans, created, stopped map[string]int
+}
+
+func newTenantBackends() *tenantBackends {
+	return &tenantBackends{spans: map[string]int{}, created: map[string]int{}, stopped: map[string]int{}}
+}
+
+func (b *tenantBackends) factory() exporter.Factory {
+	return exporter.NewFactory(
+		component.MustNewType("tenant"),
+		func() component.Config { return &tenantConfig{} },
+		exporter.WithTraces(func(_ context.Context, _ exporter.Settings, cfg component.Config) (exporter.Traces, error) {
+			tenant := cfg.(*tenantConfig).Tenant
+			b.created[tenant]++
+			c, err := consumer.NewTraces(func(_ context.Context, td ptrace.Traces) error {
+				b.mu.Lock()
+				b.spans[tenant] += td.SpanCount()
+				b.mu.Unlock()
+				return nil
+			})
+			stop := testComponent{ShutdownFunc: func(context.Context) error {
+				b.stopped[tenant]++
+				return nil
+			}}
+			return tracesComponent{testComponent: stop, Traces: c}, err
+		}, component.StabilityLevelDevelopment),
+	)
+}
+
+func tenantSpans(tenants ...string) tracetest.SpanStubs {
+	stubs := make(tracetest.SpanStubs, len(tenants))
+	for i, tenant := range tenants {
+		stubs[i].Name = "span"
+		stubs[i].Resource = resource.NewSchemaless(attribute.String("tenant.id", tenant))
+	}
+	return stubs
+}
+
+func TestTenantSpanExporter(t *testing.T) {
+	b := newTenantBackends()
+	f, err := collex.NewFactory(b.factory(), settings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := collextest.NewFakeClock(time.Now())
+	exp := collex.NewTenantSpanExporter(f, collex.TenantConfig{
+		Key: "tenant.id",
+		Config: func(tenant string) (component.Config, error) {
+			if tenant == "banned" {
+				return nil, errBroken
+			}
+			return &tenantConfig{Tenant: tenant}, nil
+		},
+		IdleTimeout: time.Minute,
+		Clock:       clock,
+	})
+	ctx := context.Background()
+
+	if err := exp.ExportSpans(ctx, tenantSpans("a", "b", "a").Snapshots()); err != nil {
+		t.Fatal(err)
+	}
+	if b.spans["a"] != 2 || b.spans["b"] != 1 {
+		t.Errorf("got spans %v, want 2 for a and 1 for b", b.spans)
+	}
+	if err := exp.ExportSpans(ctx, tenantSpans("banned").Snapshots()); !errors.Is(err, errBroken) {
+		t.Errorf("export of banned tenant returned %v, want %v", err, errBroken)
+	}
+
+	clock.Advance(30 * time.Second)
+	if err := exp.ExportSpans(ctx, tenantSpans("a").Snapshots()); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(45 * time.Second)
+	if err := exp.ExportSpans(ctx, tenantSpans("a").Snapshots()); err != nil {
+		t.Fatal(err)
+	}
+	if b.stopped["b"] != 1 || b.stopped["a"] != 0 {
+		t.Errorf("got stopped %v, want only the idle tenant b stopped", b.stopped)
+	}
+
+	if err := exp.ExportSpans(ctx, tenantSpans("b").Snapshots()); err != nil {
+		t.Fatal(err)
+	}
+	if b.created["a"] != 1 || b.created["b"] != 2 {
+		t.Errorf("got created %v, want a created once and b recreated", b.created)
+	}
+
+	if err := exp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if b.stopped["a"] != 1 || b.stopped["b"] != 2 {
+		t.Errorf("got stopped %v, want all tenants stopped", b.stopped)
+	}
+	if err := exp.ExportSpans(ctx, tenantSpans("a").Snapshots()); err == nil {
+		t.Error("export after shutdown succeeded")
+	}
+}
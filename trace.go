@@ -19,20 +19,69 @@ package collex
 
 import (
 	"context"
+	"errors"
 
 	"github.com/MrAlias/collex/transmute"
-	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pipeline"
 	"go.opentelemetry.io/otel/sdk/trace"
 )
 
+var errNoTraces = errors.New("collex: no traces pipelines")
+
 type spanExporter struct {
-	cexp exporter.Traces
+	// next is the consumer the exported spans are sent to.
+	next consumer.Traces
+	// export sends the exports to next through the middleware.
+	export Export
+	// sup owns the components the exporter sends to. It is nil if the
+	// components are owned by a Pipeline.
+	sup *supervisor
+	// metadata is the client.Info metadata of the exports with none.
+	metadata map[string][]string
+	// tracer traces the exports. It is nil if they are not traced.
+	tracer *exportTracer
+	// errs reports the failed exports. It is nil if they are not reported.
+	errs *exportErrors
+	// shed sheds exports under memory pressure. It is nil if none are.
+	shed *shedder
+	// panics recovers the panics of the exports.
+	panics *panicGuard
 }
 
 func (e *spanExporter) ExportSpans(ctx context.Context, spans []trace.ReadOnlySpan) error {
-	return e.cexp.ConsumeTraces(ctx, transmute.Spans(spans))
+	if e.next == nil {
+		return errNoTraces
+	}
+	if kept := e.shed.spans(ctx, spans); len(kept) < len(spans) {
+		if len(kept) == 0 {
+			return nil
+		}
+		spans = kept
+	}
+	tracer := e.tracer
+	if ownSpans(spans) {
+		tracer = nil
+	}
+	ctx, end := tracer.start(ctx, len(spans))
+	err := e.panics.do(ctx, func() error {
+		b := Batch{Signal: pipeline.SignalTraces, Traces: transmute.Spans(spans)}
+		return e.export(withDefaultMetadata(ctx, e.metadata), b)
+	})
+	end(err)
+	e.errs.report(err, len(spans))
+	return err
+}
+
+func (e *spanExporter) consume(ctx context.Context, b Batch) error {
+	return e.next.ConsumeTraces(ctx, b.Traces)
 }
 
+// Shutdown shuts down the components owned by the exporter. It is safe to
+// call Shutdown multiple times.
 func (e *spanExporter) Shutdown(ctx context.Context) error {
-	return e.cexp.Shutdown(ctx)
+	if e.sup == nil {
+		return nil
+	}
+	return e.sup.shutdown(ctx)
 }
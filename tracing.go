@@ -0,0 +1,78 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"context"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/pipeline"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace"
+	api "go.opentelemetry.io/otel/trace"
+)
+
+// exportTracer traces the exports of an exporter of a Factory. A nil
+// exportTracer traces nothing.
+type exportTracer struct {
+	tracer api.Tracer
+	name   string
+	attrs  []attribute.KeyValue
+}
+
+// newExportTracer returns an exportTracer tracing the exports of signal to
+// the exporter with id with tp. It returns nil if tp is nil.
+func newExportTracer(tp api.TracerProvider, id component.ID, signal pipeline.Signal) *exportTracer {
+	if tp == nil {
+		return nil
+	}
+	return &exportTracer{
+		tracer: tp.Tracer(scopeName),
+		name:   "collex/export/" + signal.String(),
+		attrs: []attribute.KeyValue{
+			attribute.String("exporter", id.String()),
+			attribute.String("signal", signal.String()),
+		},
+	}
+}
+
+// start starts the span of an export of n items. The returned function ends
+// it with the error of the export.
+func (t *exportTracer) start(ctx context.Context, n int) (context.Context, func(error)) {
+	if t == nil {
+		return ctx, func(error) {}
+	}
+	ctx, span := t.tracer.Start(ctx, t.name, api.WithAttributes(t.attrs...), api.WithAttributes(attribute.Int("items", n)))
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+// ownSpans returns whether all spans were created by collex tracing exports.
+// Exporting them is not traced, otherwise a TracerProvider exporting with the
+// exporter it traces would trace the exports of its own spans endlessly.
+func ownSpans(spans []trace.ReadOnlySpan) bool {
+	for _, s := range spans {
+		if s.InstrumentationScope().Name != scopeName {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,147 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/MrAlias/collex"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestWithTracing(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	f, err := collex.NewFactory(flaky(), settings(), collex.WithTracing(tp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = exp.Shutdown(ctx) }()
+
+	spans := tracetest.SpanStubs{{Name: "a"}, {Name: "b"}}.Snapshots()
+	if err := exp.ExportSpans(ctx, spans); err == nil {
+		t.Fatal("first export succeeded")
+	}
+	if err := exp.ExportSpans(ctx, spans); err != nil {
+		t.Fatal(err)
+	}
+
+	ended := rec.Ended()
+	if len(ended) != 2 {
+		t.Fatalf("%d export spans, want 2", len(ended))
+	}
+	for i, s := range ended {
+		if s.Name() != "collex/export/traces" {
+			t.Errorf("span %d name %q", i, s.Name())
+		}
+		want := attribute.NewSet(
+			attribute.String("exporter", "flaky"),
+			attribute.String("signal", "traces"),
+			attribute.Int("items", 2),
+		)
+		if got := attribute.NewSet(s.Attributes()...); !got.Equals(&want) {
+			t.Errorf("span %d attributes: %v", i, s.Attributes())
+		}
+	}
+	if ended[0].Status().Code != codes.Error || ended[1].Status().Code != codes.Unset {
+		t.Errorf("span statuses: %v, %v", ended[0].Status(), ended[1].Status())
+	}
+}
+
+func TestWithTracingMetrics(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	var s sink
+	f, err := collex.NewFactory(s.factory(), settings(), collex.WithTracing(tp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	exp, err := f.MetricExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = exp.Shutdown(ctx) }()
+
+	rm := &metricdata.ResourceMetrics{
+		Resource: resource.Empty(),
+		ScopeMetrics: []metricdata.ScopeMetrics{{Metrics: []metricdata.Metrics{{
+			Name: "requests",
+			Data: metricdata.Gauge[int64]{DataPoints: []metricdata.DataPoint[int64]{{Value: 1}, {Value: 2}, {Value: 3}}},
+		}}}},
+	}
+	if err := exp.Export(ctx, rm); err != nil {
+		t.Fatal(err)
+	}
+	ended := rec.Ended()
+	if len(ended) != 1 || ended[0].Name() != "collex/export/metrics" {
+		t.Fatalf("export spans: %v", ended)
+	}
+	set := attribute.NewSet(ended[0].Attributes()...)
+	if v, ok := set.Value("items"); !ok || v.AsInt64() != 3 {
+		t.Errorf("items attribute %v, want 3", v.Emit())
+	}
+}
+
+func TestWithTracingRecursion(t *testing.T) {
+	// The exporter is registered with the TracerProvider tracing it.
+	tp := sdktrace.NewTracerProvider()
+	var s sink
+	f, err := collex.NewFactory(s.factory(), settings(), collex.WithTracing(tp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp.RegisterSpanProcessor(sdktrace.NewBatchSpanProcessor(exp))
+
+	_, span := tp.Tracer("app").Start(ctx, "request")
+	span.End()
+	// Export the span of the application, then the span of its export. The
+	// export of the latter is not traced.
+	for range 3 {
+		if err := tp.ForceFlush(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, td := range s.traces {
+		spans := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans()
+		for i := range spans.Len() {
+			names = append(names, spans.At(i).Name())
+		}
+	}
+	if len(names) != 2 || names[0] != "request" || names[1] != "collex/export/traces" {
+		t.Errorf("exported spans %q, want the request and its export", names)
+	}
+}
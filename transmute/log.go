@@ -0,0 +1,205 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transmute
+
+import (
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	api "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/log/logtest"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Logs converts r to pdata Logs.
+func Logs(r []log.Record) plog.Logs {
+	l := plog.NewLogs()
+	rMap := mapRecords(r)
+
+	rl := l.ResourceLogs()
+	rl.EnsureCapacity(len(rMap))
+	for res, sMap := range rMap {
+		pr := rl.AppendEmpty()
+		pr.SetSchemaUrl(res.SchemaURL())
+		setAttrMapIter(pr.Resource().Attributes(), res.Iter())
+		setScopeLogs(pr.ScopeLogs(), sMap)
+	}
+	return l
+}
+
+type recordScopeMap map[instrumentation.Scope][]*log.Record
+
+type recordResMap map[resource.Resource]recordScopeMap
+
+func mapRecords(records []log.Record) recordResMap {
+	if len(records) == 0 {
+		return nil
+	}
+
+	rMap := make(recordResMap)
+	for i := range records {
+		r := &records[i]
+		res := r.Resource()
+		sMap := rMap[res]
+		if sMap == nil {
+			sMap = make(recordScopeMap)
+			rMap[res] = sMap
+		}
+		scope := r.InstrumentationScope()
+		sMap[scope] = append(sMap[scope], r)
+	}
+	return rMap
+}
+
+func setScopeLogs(p plog.ScopeLogsSlice, o recordScopeMap) {
+	p.EnsureCapacity(len(o))
+	for scope, records := range o {
+		scopeLogs := p.AppendEmpty()
+		scopeLogs.SetSchemaUrl(scope.SchemaURL)
+		setScope(scopeLogs.Scope(), scope)
+		setLogRecords(scopeLogs.LogRecords(), records)
+	}
+}
+
+func setLogRecords(p plog.LogRecordSlice, o []*log.Record) {
+	p.EnsureCapacity(len(o))
+	for _, r := range o {
+		setLogRecord(p.AppendEmpty(), r)
+	}
+}
+
+func setLogRecord(p plog.LogRecord, o *log.Record) {
+	p.SetTimestamp(pcommon.NewTimestampFromTime(o.Timestamp()))
+	p.SetObservedTimestamp(pcommon.NewTimestampFromTime(o.ObservedTimestamp()))
+	p.SetSeverityNumber(plog.SeverityNumber(o.Severity()))
+	p.SetSeverityText(o.SeverityText())
+	setValue(p.Body(), o.Body())
+
+	attrs := p.Attributes()
+	attrs.EnsureCapacity(o.AttributesLen())
+	o.WalkAttributes(func(kv api.KeyValue) bool {
+		setValue(attrs.PutEmpty(kv.Key), kv.Value)
+		return true
+	})
+	p.SetDroppedAttributesCount(uint32(o.DroppedAttributes()))
+
+	p.SetTraceID(pcommon.TraceID(o.TraceID()))
+	p.SetSpanID(pcommon.SpanID(o.SpanID()))
+	p.SetFlags(plog.DefaultLogRecordFlags.WithIsSampled(o.TraceFlags().IsSampled()))
+}
+
+func setValue(p pcommon.Value, o api.Value) {
+	switch o.Kind() {
+	case api.KindBool:
+		p.SetBool(o.AsBool())
+	case api.KindFloat64:
+		p.SetDouble(o.AsFloat64())
+	case api.KindInt64:
+		p.SetInt(o.AsInt64())
+	case api.KindString:
+		p.SetStr(o.AsString())
+	case api.KindBytes:
+		p.SetEmptyBytes().FromRaw(o.AsBytes())
+	case api.KindSlice:
+		vSlice := o.AsSlice()
+		s := p.SetEmptySlice()
+		s.EnsureCapacity(len(vSlice))
+		for _, v := range vSlice {
+			setValue(s.AppendEmpty(), v)
+		}
+	case api.KindMap:
+		kvs := o.AsMap()
+		m := p.SetEmptyMap()
+		m.EnsureCapacity(len(kvs))
+		for _, kv := range kvs {
+			setValue(m.PutEmpty(kv.Key), kv.Value)
+		}
+	default:
+		// leave empty.
+	}
+}
+
+// Records converts ld to log records of the OpenTelemetry Go SDK. It is the
+// inverse of Logs.
+func Records(ld plog.Logs) []log.Record {
+	var out []log.Record
+	for i := range ld.ResourceLogs().Len() {
+		rl := ld.ResourceLogs().At(i)
+		res := resource.NewWithAttributes(rl.SchemaUrl(), attributes(rl.Resource().Attributes())...)
+		for j := range rl.ScopeLogs().Len() {
+			sl := rl.ScopeLogs().At(j)
+			scope := instrumentationScope(sl.Scope(), sl.SchemaUrl())
+			for k := range sl.LogRecords().Len() {
+				out = append(out, record(sl.LogRecords().At(k), res, &scope))
+			}
+		}
+	}
+	return out
+}
+
+func record(p plog.LogRecord, res *resource.Resource, scope *instrumentation.Scope) log.Record {
+	f := logtest.RecordFactory{
+		Timestamp:            p.Timestamp().AsTime(),
+		ObservedTimestamp:    p.ObservedTimestamp().AsTime(),
+		Severity:             api.Severity(p.SeverityNumber()),
+		SeverityText:         p.SeverityText(),
+		Body:                 value(p.Body()),
+		TraceID:              trace.TraceID(p.TraceID()),
+		SpanID:               trace.SpanID(p.SpanID()),
+		Resource:             res,
+		InstrumentationScope: scope,
+		DroppedAttributes:    int(p.DroppedAttributesCount()),
+	}
+	if p.Flags().IsSampled() {
+		f.TraceFlags = trace.FlagsSampled
+	}
+	p.Attributes().Range(func(k string, v pcommon.Value) bool {
+		f.Attributes = append(f.Attributes, api.KeyValue{Key: k, Value: value(v)})
+		return true
+	})
+	return f.NewRecord()
+}
+
+func value(p pcommon.Value) api.Value {
+	switch p.Type() {
+	case pcommon.ValueTypeBool:
+		return api.BoolValue(p.Bool())
+	case pcommon.ValueTypeDouble:
+		return api.Float64Value(p.Double())
+	case pcommon.ValueTypeInt:
+		return api.Int64Value(p.Int())
+	case pcommon.ValueTypeStr:
+		return api.StringValue(p.Str())
+	case pcommon.ValueTypeBytes:
+		return api.BytesValue(p.Bytes().AsRaw())
+	case pcommon.ValueTypeSlice:
+		s := p.Slice()
+		vals := make([]api.Value, s.Len())
+		for i := range s.Len() {
+			vals[i] = value(s.At(i))
+		}
+		return api.SliceValue(vals...)
+	case pcommon.ValueTypeMap:
+		kvs := make([]api.KeyValue, 0, p.Map().Len())
+		p.Map().Range(func(k string, v pcommon.Value) bool {
+			kvs = append(kvs, api.KeyValue{Key: k, Value: value(v)})
+			return true
+		})
+		return api.MapValue(kvs...)
+	}
+	return api.Value{}
+}
@@ -0,0 +1,417 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transmute
+
+import (
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// Metrics converts rm to pdata Metrics.
+func Metrics(rm *metricdata.ResourceMetrics) pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	if rm == nil || len(rm.ScopeMetrics) == 0 {
+		return md
+	}
+
+	r := md.ResourceMetrics().AppendEmpty()
+	if rm.Resource != nil {
+		r.SetSchemaUrl(rm.Resource.SchemaURL())
+		setAttrMapIter(r.Resource().Attributes(), rm.Resource.Iter())
+	}
+	sms := r.ScopeMetrics()
+	sms.EnsureCapacity(len(rm.ScopeMetrics))
+	for _, sm := range rm.ScopeMetrics {
+		s := sms.AppendEmpty()
+		s.SetSchemaUrl(sm.Scope.SchemaURL)
+		setScope(s.Scope(), sm.Scope)
+		setMetrics(s.Metrics(), sm.Metrics)
+	}
+	return md
+}
+
+func setMetrics(p pmetric.MetricSlice, o []metricdata.Metrics) {
+	p.EnsureCapacity(len(o))
+	for _, om := range o {
+		pm := p.AppendEmpty()
+		pm.SetName(om.Name)
+		pm.SetDescription(om.Description)
+		pm.SetUnit(om.Unit)
+		switch a := om.Data.(type) {
+		case metricdata.Gauge[int64]:
+			setDataPoints(pm.SetEmptyGauge().DataPoints(), a.DataPoints)
+		case metricdata.Gauge[float64]:
+			setDataPoints(pm.SetEmptyGauge().DataPoints(), a.DataPoints)
+		case metricdata.Sum[int64]:
+			setSum(pm.SetEmptySum(), a)
+		case metricdata.Sum[float64]:
+			setSum(pm.SetEmptySum(), a)
+		case metricdata.Histogram[int64]:
+			setHistogram(pm.SetEmptyHistogram(), a)
+		case metricdata.Histogram[float64]:
+			setHistogram(pm.SetEmptyHistogram(), a)
+		case metricdata.ExponentialHistogram[int64]:
+			setExponentialHistogram(pm.SetEmptyExponentialHistogram(), a)
+		case metricdata.ExponentialHistogram[float64]:
+			setExponentialHistogram(pm.SetEmptyExponentialHistogram(), a)
+		case metricdata.Summary:
+			setSummary(pm.SetEmptySummary(), a)
+		default:
+			// drop unknown.
+		}
+	}
+}
+
+func temporality(o metricdata.Temporality) pmetric.AggregationTemporality {
+	switch o {
+	case metricdata.CumulativeTemporality:
+		return pmetric.AggregationTemporalityCumulative
+	case metricdata.DeltaTemporality:
+		return pmetric.AggregationTemporalityDelta
+	}
+	return pmetric.AggregationTemporalityUnspecified
+}
+
+func setSum[N int64 | float64](p pmetric.Sum, o metricdata.Sum[N]) {
+	p.SetAggregationTemporality(temporality(o.Temporality))
+	p.SetIsMonotonic(o.IsMonotonic)
+	setDataPoints(p.DataPoints(), o.DataPoints)
+}
+
+func setDataPoints[N int64 | float64](p pmetric.NumberDataPointSlice, o []metricdata.DataPoint[N]) {
+	p.EnsureCapacity(len(o))
+	for _, odp := range o {
+		pdp := p.AppendEmpty()
+		setAttrMapIter(pdp.Attributes(), odp.Attributes.Iter())
+		pdp.SetStartTimestamp(pcommon.NewTimestampFromTime(odp.StartTime))
+		pdp.SetTimestamp(pcommon.NewTimestampFromTime(odp.Time))
+		switch v := any(odp.Value).(type) {
+		case int64:
+			pdp.SetIntValue(v)
+		case float64:
+			pdp.SetDoubleValue(v)
+		}
+		setExemplars(pdp.Exemplars(), odp.Exemplars)
+	}
+}
+
+func setHistogram[N int64 | float64](p pmetric.Histogram, o metricdata.Histogram[N]) {
+	p.SetAggregationTemporality(temporality(o.Temporality))
+	dps := p.DataPoints()
+	dps.EnsureCapacity(len(o.DataPoints))
+	for _, odp := range o.DataPoints {
+		pdp := dps.AppendEmpty()
+		setAttrMapIter(pdp.Attributes(), odp.Attributes.Iter())
+		pdp.SetStartTimestamp(pcommon.NewTimestampFromTime(odp.StartTime))
+		pdp.SetTimestamp(pcommon.NewTimestampFromTime(odp.Time))
+		pdp.SetCount(odp.Count)
+		pdp.SetSum(float64(odp.Sum))
+		if v, ok := odp.Min.Value(); ok {
+			pdp.SetMin(float64(v))
+		}
+		if v, ok := odp.Max.Value(); ok {
+			pdp.SetMax(float64(v))
+		}
+		pdp.ExplicitBounds().FromRaw(odp.Bounds)
+		pdp.BucketCounts().FromRaw(odp.BucketCounts)
+		setExemplars(pdp.Exemplars(), odp.Exemplars)
+	}
+}
+
+func setExponentialHistogram[N int64 | float64](p pmetric.ExponentialHistogram, o metricdata.ExponentialHistogram[N]) {
+	p.SetAggregationTemporality(temporality(o.Temporality))
+	dps := p.DataPoints()
+	dps.EnsureCapacity(len(o.DataPoints))
+	for _, odp := range o.DataPoints {
+		pdp := dps.AppendEmpty()
+		setAttrMapIter(pdp.Attributes(), odp.Attributes.Iter())
+		pdp.SetStartTimestamp(pcommon.NewTimestampFromTime(odp.StartTime))
+		pdp.SetTimestamp(pcommon.NewTimestampFromTime(odp.Time))
+		pdp.SetCount(odp.Count)
+		pdp.SetSum(float64(odp.Sum))
+		if v, ok := odp.Min.Value(); ok {
+			pdp.SetMin(float64(v))
+		}
+		if v, ok := odp.Max.Value(); ok {
+			pdp.SetMax(float64(v))
+		}
+		pdp.SetScale(odp.Scale)
+		pdp.SetZeroCount(odp.ZeroCount)
+		pdp.SetZeroThreshold(odp.ZeroThreshold)
+		pdp.Positive().SetOffset(odp.PositiveBucket.Offset)
+		pdp.Positive().BucketCounts().FromRaw(odp.PositiveBucket.Counts)
+		pdp.Negative().SetOffset(odp.NegativeBucket.Offset)
+		pdp.Negative().BucketCounts().FromRaw(odp.NegativeBucket.Counts)
+		setExemplars(pdp.Exemplars(), odp.Exemplars)
+	}
+}
+
+func setSummary(p pmetric.Summary, o metricdata.Summary) {
+	dps := p.DataPoints()
+	dps.EnsureCapacity(len(o.DataPoints))
+	for _, odp := range o.DataPoints {
+		pdp := dps.AppendEmpty()
+		setAttrMapIter(pdp.Attributes(), odp.Attributes.Iter())
+		pdp.SetStartTimestamp(pcommon.NewTimestampFromTime(odp.StartTime))
+		pdp.SetTimestamp(pcommon.NewTimestampFromTime(odp.Time))
+		pdp.SetCount(odp.Count)
+		pdp.SetSum(odp.Sum)
+		qvs := pdp.QuantileValues()
+		qvs.EnsureCapacity(len(odp.QuantileValues))
+		for _, oqv := range odp.QuantileValues {
+			pqv := qvs.AppendEmpty()
+			pqv.SetQuantile(oqv.Quantile)
+			pqv.SetValue(oqv.Value)
+		}
+	}
+}
+
+func setExemplars[N int64 | float64](p pmetric.ExemplarSlice, o []metricdata.Exemplar[N]) {
+	p.EnsureCapacity(len(o))
+	for _, oe := range o {
+		pe := p.AppendEmpty()
+		setAttrMapSlice(pe.FilteredAttributes(), oe.FilteredAttributes)
+		pe.SetTimestamp(pcommon.NewTimestampFromTime(oe.Time))
+		switch v := any(oe.Value).(type) {
+		case int64:
+			pe.SetIntValue(v)
+		case float64:
+			pe.SetDoubleValue(v)
+		}
+		var traceID pcommon.TraceID
+		copy(traceID[:], oe.TraceID)
+		pe.SetTraceID(traceID)
+		var spanID pcommon.SpanID
+		copy(spanID[:], oe.SpanID)
+		pe.SetSpanID(spanID)
+	}
+}
+
+// ResourceMetrics converts md to metrics of the OpenTelemetry Go SDK, one for
+// each resource of md. It is the inverse of Metrics.
+//
+// The values of gauges and sums are int64 when all their data points hold
+// integers, and float64 otherwise. Histograms are always float64 given pdata
+// stores their sums as such.
+func ResourceMetrics(md pmetric.Metrics) []*metricdata.ResourceMetrics {
+	var out []*metricdata.ResourceMetrics
+	for i := range md.ResourceMetrics().Len() {
+		rm := md.ResourceMetrics().At(i)
+		o := &metricdata.ResourceMetrics{
+			Resource: resource.NewWithAttributes(rm.SchemaUrl(), attributes(rm.Resource().Attributes())...),
+		}
+		for j := range rm.ScopeMetrics().Len() {
+			sm := rm.ScopeMetrics().At(j)
+			s := metricdata.ScopeMetrics{Scope: instrumentationScope(sm.Scope(), sm.SchemaUrl())}
+			for k := range sm.Metrics().Len() {
+				s.Metrics = append(s.Metrics, metrics(sm.Metrics().At(k)))
+			}
+			o.ScopeMetrics = append(o.ScopeMetrics, s)
+		}
+		out = append(out, o)
+	}
+	return out
+}
+
+func metrics(p pmetric.Metric) metricdata.Metrics {
+	o := metricdata.Metrics{
+		Name:        p.Name(),
+		Description: p.Description(),
+		Unit:        p.Unit(),
+	}
+	switch p.Type() {
+	case pmetric.MetricTypeGauge:
+		if isInt(p.Gauge().DataPoints()) {
+			o.Data = metricdata.Gauge[int64]{DataPoints: dataPoints[int64](p.Gauge().DataPoints())}
+		} else {
+			o.Data = metricdata.Gauge[float64]{DataPoints: dataPoints[float64](p.Gauge().DataPoints())}
+		}
+	case pmetric.MetricTypeSum:
+		if isInt(p.Sum().DataPoints()) {
+			o.Data = sum[int64](p.Sum())
+		} else {
+			o.Data = sum[float64](p.Sum())
+		}
+	case pmetric.MetricTypeHistogram:
+		o.Data = histogram(p.Histogram())
+	case pmetric.MetricTypeExponentialHistogram:
+		o.Data = exponentialHistogram(p.ExponentialHistogram())
+	case pmetric.MetricTypeSummary:
+		o.Data = summary(p.Summary())
+	}
+	return o
+}
+
+// isInt returns whether p has data points and all of them hold integers.
+func isInt(p pmetric.NumberDataPointSlice) bool {
+	if p.Len() == 0 {
+		return false
+	}
+	for i := range p.Len() {
+		if p.At(i).ValueType() != pmetric.NumberDataPointValueTypeInt {
+			return false
+		}
+	}
+	return true
+}
+
+func sdkTemporality(p pmetric.AggregationTemporality) metricdata.Temporality {
+	switch p {
+	case pmetric.AggregationTemporalityCumulative:
+		return metricdata.CumulativeTemporality
+	case pmetric.AggregationTemporalityDelta:
+		return metricdata.DeltaTemporality
+	}
+	return 0
+}
+
+func sum[N int64 | float64](p pmetric.Sum) metricdata.Sum[N] {
+	return metricdata.Sum[N]{
+		DataPoints:  dataPoints[N](p.DataPoints()),
+		Temporality: sdkTemporality(p.AggregationTemporality()),
+		IsMonotonic: p.IsMonotonic(),
+	}
+}
+
+func dataPoints[N int64 | float64](p pmetric.NumberDataPointSlice) []metricdata.DataPoint[N] {
+	var out []metricdata.DataPoint[N]
+	for i := range p.Len() {
+		pdp := p.At(i)
+		var v N
+		switch pdp.ValueType() {
+		case pmetric.NumberDataPointValueTypeInt:
+			v = N(pdp.IntValue())
+		case pmetric.NumberDataPointValueTypeDouble:
+			v = N(pdp.DoubleValue())
+		}
+		out = append(out, metricdata.DataPoint[N]{
+			Attributes: attribute.NewSet(attributes(pdp.Attributes())...),
+			StartTime:  pdp.StartTimestamp().AsTime(),
+			Time:       pdp.Timestamp().AsTime(),
+			Value:      v,
+			Exemplars:  exemplars[N](pdp.Exemplars()),
+		})
+	}
+	return out
+}
+
+func histogram(p pmetric.Histogram) metricdata.Histogram[float64] {
+	o := metricdata.Histogram[float64]{Temporality: sdkTemporality(p.AggregationTemporality())}
+	for i := range p.DataPoints().Len() {
+		pdp := p.DataPoints().At(i)
+		o.DataPoints = append(o.DataPoints, metricdata.HistogramDataPoint[float64]{
+			Attributes:   attribute.NewSet(attributes(pdp.Attributes())...),
+			StartTime:    pdp.StartTimestamp().AsTime(),
+			Time:         pdp.Timestamp().AsTime(),
+			Count:        pdp.Count(),
+			Bounds:       pdp.ExplicitBounds().AsRaw(),
+			BucketCounts: pdp.BucketCounts().AsRaw(),
+			Min:          extrema(pdp.HasMin(), pdp.Min()),
+			Max:          extrema(pdp.HasMax(), pdp.Max()),
+			Sum:          pdp.Sum(),
+			Exemplars:    exemplars[float64](pdp.Exemplars()),
+		})
+	}
+	return o
+}
+
+func exponentialHistogram(p pmetric.ExponentialHistogram) metricdata.ExponentialHistogram[float64] {
+	o := metricdata.ExponentialHistogram[float64]{Temporality: sdkTemporality(p.AggregationTemporality())}
+	for i := range p.DataPoints().Len() {
+		pdp := p.DataPoints().At(i)
+		o.DataPoints = append(o.DataPoints, metricdata.ExponentialHistogramDataPoint[float64]{
+			Attributes: attribute.NewSet(attributes(pdp.Attributes())...),
+			StartTime:  pdp.StartTimestamp().AsTime(),
+			Time:       pdp.Timestamp().AsTime(),
+			Count:      pdp.Count(),
+			Min:        extrema(pdp.HasMin(), pdp.Min()),
+			Max:        extrema(pdp.HasMax(), pdp.Max()),
+			Sum:        pdp.Sum(),
+			Scale:      pdp.Scale(),
+			ZeroCount:  pdp.ZeroCount(),
+			PositiveBucket: metricdata.ExponentialBucket{
+				Offset: pdp.Positive().Offset(),
+				Counts: pdp.Positive().BucketCounts().AsRaw(),
+			},
+			NegativeBucket: metricdata.ExponentialBucket{
+				Offset: pdp.Negative().Offset(),
+				Counts: pdp.Negative().BucketCounts().AsRaw(),
+			},
+			ZeroThreshold: pdp.ZeroThreshold(),
+			Exemplars:     exemplars[float64](pdp.Exemplars()),
+		})
+	}
+	return o
+}
+
+func extrema(ok bool, v float64) metricdata.Extrema[float64] {
+	if !ok {
+		return metricdata.Extrema[float64]{}
+	}
+	return metricdata.NewExtrema(v)
+}
+
+func summary(p pmetric.Summary) metricdata.Summary {
+	var o metricdata.Summary
+	for i := range p.DataPoints().Len() {
+		pdp := p.DataPoints().At(i)
+		odp := metricdata.SummaryDataPoint{
+			Attributes: attribute.NewSet(attributes(pdp.Attributes())...),
+			StartTime:  pdp.StartTimestamp().AsTime(),
+			Time:       pdp.Timestamp().AsTime(),
+			Count:      pdp.Count(),
+			Sum:        pdp.Sum(),
+		}
+		for j := range pdp.QuantileValues().Len() {
+			qv := pdp.QuantileValues().At(j)
+			odp.QuantileValues = append(odp.QuantileValues, metricdata.QuantileValue{
+				Quantile: qv.Quantile(),
+				Value:    qv.Value(),
+			})
+		}
+		o.DataPoints = append(o.DataPoints, odp)
+	}
+	return o
+}
+
+func exemplars[N int64 | float64](p pmetric.ExemplarSlice) []metricdata.Exemplar[N] {
+	var out []metricdata.Exemplar[N]
+	for i := range p.Len() {
+		pe := p.At(i)
+		var v N
+		switch pe.ValueType() {
+		case pmetric.ExemplarValueTypeInt:
+			v = N(pe.IntValue())
+		case pmetric.ExemplarValueTypeDouble:
+			v = N(pe.DoubleValue())
+		}
+		oe := metricdata.Exemplar[N]{
+			FilteredAttributes: attributes(pe.FilteredAttributes()),
+			Time:               pe.Timestamp().AsTime(),
+			Value:              v,
+		}
+		if id := pe.SpanID(); !id.IsEmpty() {
+			oe.SpanID = id[:]
+		}
+		if id := pe.TraceID(); !id.IsEmpty() {
+			oe.TraceID = id[:]
+		}
+		out = append(out, oe)
+	}
+	return out
+}
@@ -22,6 +22,7 @@ import (
 	"go.opentelemetry.io/otel/sdk/instrumentation"
 	"go.opentelemetry.io/otel/sdk/resource"
 	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	api "go.opentelemetry.io/otel/trace"
 )
 
@@ -41,6 +42,13 @@ func Spans(s []trace.ReadOnlySpan) ptrace.Traces {
 	return t
 }
 
+// SpanStubs converts s to pdata Traces. This lets the fixtures of test suites
+// based on the tracetest package, and the spans recorded by its SpanRecorder,
+// be compared with pdata. Stubs without a resource have an empty one.
+func SpanStubs(s tracetest.SpanStubs) ptrace.Traces {
+	return Spans(s.Snapshots())
+}
+
 type scopeMap map[instrumentation.Scope][]trace.ReadOnlySpan
 
 type resMap map[resource.Resource]scopeMap
@@ -52,14 +60,18 @@ func mapSpans(spans []trace.ReadOnlySpan) resMap {
 
 	rMap := make(resMap)
 	for _, s := range spans {
-		sMap := rMap[*s.Resource()]
+		res := s.Resource()
+		if res == nil {
+			res = resource.Empty()
+		}
+		sMap := rMap[*res]
 		if sMap == nil {
 			sMap = make(scopeMap)
 		}
 		roSpans := sMap[s.InstrumentationScope()]
 		roSpans = append(roSpans, s)
 		sMap[s.InstrumentationScope()] = roSpans
-		rMap[*s.Resource()] = sMap
+		rMap[*res] = sMap
 	}
 	return rMap
 }
@@ -126,6 +138,7 @@ func setScopeSpans(p ptrace.ScopeSpansSlice, o scopeMap) {
 	p.EnsureCapacity(len(o))
 	for scope, spans := range o {
 		scopeSpans := p.AppendEmpty()
+		scopeSpans.SetSchemaUrl(scope.SchemaURL)
 		setScope(scopeSpans.Scope(), scope)
 		setSpans(scopeSpans.Spans(), spans)
 	}
@@ -134,7 +147,7 @@ func setScopeSpans(p ptrace.ScopeSpansSlice, o scopeMap) {
 func setScope(p pcommon.InstrumentationScope, o instrumentation.Scope) {
 	p.SetName(o.Name)
 	p.SetVersion(o.Version)
-	// TODO: support scope attributes when added to o.
+	setAttrMapIter(p.Attributes(), o.Attributes.Iter())
 }
 
 func setSpans(p ptrace.SpanSlice, o []trace.ReadOnlySpan) {
@@ -212,3 +225,174 @@ func setStatus(p ptrace.Status, o trace.Status) {
 		p.SetCode(ptrace.StatusCodeUnset)
 	}
 }
+
+// ReadOnlySpans converts td to spans of the OpenTelemetry Go SDK. It is the
+// inverse of Spans.
+//
+// Attribute values without an equivalent in the attribute package, e.g. maps,
+// bytes, and slices of mixed types, are converted to their string
+// representation.
+func ReadOnlySpans(td ptrace.Traces) []trace.ReadOnlySpan {
+	var out []trace.ReadOnlySpan
+	for i := range td.ResourceSpans().Len() {
+		rs := td.ResourceSpans().At(i)
+		res := resource.NewWithAttributes(rs.SchemaUrl(), attributes(rs.Resource().Attributes())...)
+		for j := range rs.ScopeSpans().Len() {
+			ss := rs.ScopeSpans().At(j)
+			scope := instrumentationScope(ss.Scope(), ss.SchemaUrl())
+			for k := range ss.Spans().Len() {
+				out = append(out, readOnlySpan(ss.Spans().At(k), res, scope))
+			}
+		}
+	}
+	return out
+}
+
+func instrumentationScope(p pcommon.InstrumentationScope, schemaURL string) instrumentation.Scope {
+	return instrumentation.Scope{
+		Name:       p.Name(),
+		Version:    p.Version(),
+		SchemaURL:  schemaURL,
+		Attributes: attribute.NewSet(attributes(p.Attributes())...),
+	}
+}
+
+func readOnlySpan(p ptrace.Span, res *resource.Resource, scope instrumentation.Scope) trace.ReadOnlySpan {
+	stub := tracetest.SpanStub{
+		Name:                 p.Name(),
+		SpanContext:          spanContext(p.TraceID(), p.SpanID(), p.TraceState()),
+		SpanKind:             sdkSpanKind(p.Kind()),
+		StartTime:            p.StartTimestamp().AsTime(),
+		EndTime:              p.EndTimestamp().AsTime(),
+		Attributes:           attributes(p.Attributes()),
+		Status:               status(p.Status()),
+		DroppedAttributes:    int(p.DroppedAttributesCount()),
+		DroppedEvents:        int(p.DroppedEventsCount()),
+		DroppedLinks:         int(p.DroppedLinksCount()),
+		Resource:             res,
+		InstrumentationScope: scope,
+	}
+	if !p.ParentSpanID().IsEmpty() {
+		stub.Parent = spanContext(p.TraceID(), p.ParentSpanID(), pcommon.NewTraceState())
+	}
+	for i := range p.Events().Len() {
+		e := p.Events().At(i)
+		stub.Events = append(stub.Events, trace.Event{
+			Name:                  e.Name(),
+			Attributes:            attributes(e.Attributes()),
+			DroppedAttributeCount: int(e.DroppedAttributesCount()),
+			Time:                  e.Timestamp().AsTime(),
+		})
+	}
+	for i := range p.Links().Len() {
+		l := p.Links().At(i)
+		stub.Links = append(stub.Links, trace.Link{
+			SpanContext:           spanContext(l.TraceID(), l.SpanID(), l.TraceState()),
+			Attributes:            attributes(l.Attributes()),
+			DroppedAttributeCount: int(l.DroppedAttributesCount()),
+		})
+	}
+	return stub.Snapshot()
+}
+
+func spanContext(traceID pcommon.TraceID, spanID pcommon.SpanID, ts pcommon.TraceState) api.SpanContext {
+	// An invalid trace state cannot be represented and is dropped.
+	state, _ := api.ParseTraceState(ts.AsRaw())
+	return api.NewSpanContext(api.SpanContextConfig{
+		TraceID:    api.TraceID(traceID),
+		SpanID:     api.SpanID(spanID),
+		TraceState: state,
+	})
+}
+
+func sdkSpanKind(p ptrace.SpanKind) api.SpanKind {
+	switch p {
+	case ptrace.SpanKindInternal:
+		return api.SpanKindInternal
+	case ptrace.SpanKindServer:
+		return api.SpanKindServer
+	case ptrace.SpanKindClient:
+		return api.SpanKindClient
+	case ptrace.SpanKindProducer:
+		return api.SpanKindProducer
+	case ptrace.SpanKindConsumer:
+		return api.SpanKindConsumer
+	}
+	return api.SpanKindUnspecified
+}
+
+func status(p ptrace.Status) trace.Status {
+	switch p.Code() {
+	case ptrace.StatusCodeOk:
+		return trace.Status{Code: codes.Ok, Description: p.Message()}
+	case ptrace.StatusCodeError:
+		return trace.Status{Code: codes.Error, Description: p.Message()}
+	}
+	return trace.Status{Code: codes.Unset, Description: p.Message()}
+}
+
+// attributes returns the attributes of p, nil if there are none.
+func attributes(p pcommon.Map) []attribute.KeyValue {
+	if p.Len() == 0 {
+		return nil
+	}
+	out := make([]attribute.KeyValue, 0, p.Len())
+	p.Range(func(k string, v pcommon.Value) bool {
+		out = append(out, attributeKeyValue(k, v))
+		return true
+	})
+	return out
+}
+
+func attributeKeyValue(k string, v pcommon.Value) attribute.KeyValue {
+	switch v.Type() {
+	case pcommon.ValueTypeBool:
+		return attribute.Bool(k, v.Bool())
+	case pcommon.ValueTypeInt:
+		return attribute.Int64(k, v.Int())
+	case pcommon.ValueTypeDouble:
+		return attribute.Float64(k, v.Double())
+	case pcommon.ValueTypeStr:
+		return attribute.String(k, v.Str())
+	case pcommon.ValueTypeSlice:
+		if kv, ok := attributeSlice(k, v.Slice()); ok {
+			return kv
+		}
+	}
+	return attribute.String(k, v.AsString())
+}
+
+// attributeSlice returns the slice attribute of s. It returns false if the
+// values of s are not all of one type an attribute slice can hold.
+func attributeSlice(k string, s pcommon.Slice) (attribute.KeyValue, bool) {
+	if s.Len() == 0 {
+		return attribute.StringSlice(k, []string{}), true
+	}
+	switch s.At(0).Type() {
+	case pcommon.ValueTypeBool:
+		v, ok := sliceOf(s, pcommon.ValueTypeBool, pcommon.Value.Bool)
+		return attribute.BoolSlice(k, v), ok
+	case pcommon.ValueTypeInt:
+		v, ok := sliceOf(s, pcommon.ValueTypeInt, pcommon.Value.Int)
+		return attribute.Int64Slice(k, v), ok
+	case pcommon.ValueTypeDouble:
+		v, ok := sliceOf(s, pcommon.ValueTypeDouble, pcommon.Value.Double)
+		return attribute.Float64Slice(k, v), ok
+	case pcommon.ValueTypeStr:
+		v, ok := sliceOf(s, pcommon.ValueTypeStr, pcommon.Value.Str)
+		return attribute.StringSlice(k, v), ok
+	}
+	return attribute.KeyValue{}, false
+}
+
+func sliceOf[T any](s pcommon.Slice, typ pcommon.ValueType, get func(pcommon.Value) T) ([]T, bool) {
+	out := make([]T, s.Len())
+	for i := range s.Len() {
+		v := s.At(i)
+		if v.Type() != typ {
+			return nil, false
+		}
+		out[i] = get(v)
+	}
+	return out, true
+}
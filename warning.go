@@ -0,0 +1,159 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"go.opentelemetry.io/collector/component"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Warning is a warning about the configuration of a component, e.g. the use
+// of a deprecated setting.
+type Warning struct {
+	// Kind is the kind of the component.
+	Kind component.Kind
+	// ID is the ID of the component.
+	ID component.ID
+	// Path is the path of the setting the warning is about, if known. See
+	// Field.
+	Path string
+	// Message describes the warning.
+	Message string
+}
+
+func (w Warning) String() string {
+	s := fmt.Sprintf("%s %s: ", strings.ToLower(w.Kind.String()), w.ID)
+	if w.Path != "" {
+		s += w.Path + ": "
+	}
+	return s + w.Message
+}
+
+// Warnings returns the warnings about the configuration of the components of
+// exp, an exporter returned by a Factory. It returns nil for any other
+// exporter.
+//
+// Settings are deprecated in two ways. A setting changed from its default
+// with a deprecated struct tag is warned about with the tag as message, e.g.
+// `deprecated:"use endpoint instead"`. Warnings a processor, exporter, or
+// connector logs about deprecated settings while it is created, like contrib
+// components do, are returned as well.
+func Warnings(exp any) []Warning {
+	sup := supervisorOf(exp)
+	if sup == nil {
+		return nil
+	}
+	sup.mu.Lock()
+	gen := sup.cur
+	sup.mu.Unlock()
+	if gen == nil {
+		return nil
+	}
+	return gen.g.warnings()
+}
+
+// configWarnings returns the warnings about the deprecated settings of cfg
+// changed from the default configuration def.
+func configWarnings(kind component.Kind, id component.ID, def, cfg component.Config) []Warning {
+	var warns []Warning
+	for _, c := range diff(def, cfg) {
+		if msg, ok := c.Tag.Lookup("deprecated"); ok {
+			if msg == "" {
+				msg = "deprecated"
+			}
+			warns = append(warns, Warning{Kind: kind, ID: id, Path: c.Path, Message: msg})
+		}
+	}
+	return warns
+}
+
+// warn records w unless it already is.
+func (g *graph) warn(w Warning) {
+	g.warnMu.Lock()
+	defer g.warnMu.Unlock()
+	for _, seen := range g.warns {
+		if seen == w {
+			return
+		}
+	}
+	g.warns = append(g.warns, w)
+}
+
+func (g *graph) warnings() []Warning {
+	g.warnMu.Lock()
+	defer g.warnMu.Unlock()
+	return append([]Warning(nil), g.warns...)
+}
+
+// telemetry returns the telemetry settings of the component with id while it
+// is created. Warnings about deprecated settings it logs are recorded until
+// the returned function is called.
+func (g *graph) telemetry(kind component.Kind, id component.ID) (component.TelemetrySettings, func()) {
+	set := g.set.TelemetrySettings
+	if set.Logger == nil {
+		return set, func() {}
+	}
+	rec := &warningCore{g: g, kind: kind, id: id, done: new(atomic.Bool)}
+	set.Logger = set.Logger.WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return zapcore.NewTee(c, rec)
+	}))
+	return set, func() { rec.done.Store(true) }
+}
+
+// warningCore records the warnings about deprecated settings logged by a
+// component until done.
+type warningCore struct {
+	g    *graph
+	kind component.Kind
+	id   component.ID
+	done *atomic.Bool
+}
+
+func (c *warningCore) Enabled(l zapcore.Level) bool {
+	return l >= zapcore.WarnLevel && !c.done.Load()
+}
+
+func (c *warningCore) With([]zapcore.Field) zapcore.Core {
+	return c
+}
+
+func (c *warningCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *warningCore) Write(ent zapcore.Entry, _ []zapcore.Field) error {
+	if strings.Contains(strings.ToLower(ent.Message), "deprecat") {
+		c.g.warn(Warning{Kind: c.kind, ID: c.id, Message: ent.Message})
+	}
+	return nil
+}
+
+func (*warningCore) Sync() error {
+	return nil
+}
+
+// Warnings returns the warnings about the configuration of the components of
+// the pipeline. See Warnings for how they are found.
+func (p *Pipeline) Warnings() []Warning {
+	return p.g.warnings()
+}
@@ -0,0 +1,104 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/MrAlias/collex"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type legacyConfig struct {
+	Endpoint string `mapstructure:"endpoint"`
+	URL      string `mapstructure:"url" deprecated:"use endpoint instead"`
+	Insecure bool   `mapstructure:"insecure"`
+}
+
+// legacy is a collector exporter that, like some contrib exporters, logs a
+// warning when a deprecated setting is used.
+func legacy() exporter.Factory {
+	return exporter.NewFactory(
+		component.MustNewType("legacy"),
+		func() component.Config { return &legacyConfig{} },
+		exporter.WithTraces(func(_ context.Context, set exporter.Settings, cfg component.Config) (exporter.Traces, error) {
+			if cfg.(*legacyConfig).Insecure {
+				set.Logger.Warn("insecure is deprecated and will be removed, use tls::insecure")
+			}
+			set.Logger.Warn("sending without compression")
+			c, err := consumer.NewTraces(func(context.Context, ptrace.Traces) error {
+				set.Logger.Warn("deprecated endpoint in use")
+				return nil
+			})
+			return tracesComponent{Traces: c}, err
+		}, component.StabilityLevelDevelopment),
+	)
+}
+
+func TestFactoryWarnings(t *testing.T) {
+	f, err := collex.NewFactory(legacy(), settings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, &legacyConfig{URL: "collector:4317", Insecure: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Warnings logged after the exporter is created are not recorded.
+	spans := tracetest.SpanStubs{{Name: "a", Resource: resource.Empty()}}.Snapshots()
+	if err := exp.ExportSpans(ctx, spans); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, w := range collex.Warnings(exp) {
+		got = append(got, w.String())
+	}
+	want := []string{
+		"exporter legacy: url: use endpoint instead",
+		"exporter legacy: insecure is deprecated and will be removed, use tls::insecure",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("warnings = %q, want %q", got, want)
+	}
+	if err := exp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPipelineWarnings(t *testing.T) {
+	ctx := context.Background()
+	p, err := collex.NewPipeline().
+		WithExporter(legacy(), &legacyConfig{Endpoint: "collector:4317"}).
+		WithSettings(*settings()).
+		Build(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Warnings(); len(got) != 0 {
+		t.Errorf("unexpected warnings: %v", got)
+	}
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
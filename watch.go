@@ -0,0 +1,123 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/confmap"
+)
+
+const defaultWatchInterval = 30 * time.Second
+
+// ConfigWatcher reloads the configuration of exporters returned by a Factory
+// when the configuration at a URI changes. This allows the exporters of an
+// application, e.g. their endpoints or batch sizes, to be tuned without
+// redeploying it.
+type ConfigWatcher struct {
+	// Factory is the ExporterFactory passed to NewFactory. The
+	// configuration at URI is loaded with it.
+	Factory component.Factory
+	// URI is the location of the configuration. It is loaded like
+	// ConfigFromURI does.
+	URI string
+	// Providers are additional confmap providers used to load the
+	// configuration, see ConfigFromURI.
+	Providers []confmap.ProviderFactory
+	// Interval is how often the configuration at URI is checked for changes.
+	// If it is not positive, it is checked every 30 seconds.
+	Interval time.Duration
+	// Clock, if not nil, is the Clock the interval is measured with instead
+	// of the system clock.
+	Clock Clock
+	// ErrorFunc, if not nil, is called with the errors loading or applying
+	// a configuration. The exporters keep their previous configuration when
+	// that happens.
+	ErrorFunc func(error)
+}
+
+// Watch checks the configuration at URI for changes until ctx is done. When
+// it differs from the configuration of one of exps, exporters returned by a
+// Factory, the new configuration is validated and the exporter is swapped to
+// it with Swap. The configuration is first checked when Watch is called.
+//
+// An error is returned if the configuration cannot be loaded by that first
+// check or if exps were not returned by a Factory. Otherwise, the error of ctx
+// is returned once it is done.
+//
+//	w := collex.ConfigWatcher{Factory: otlpexporter.NewFactory(), URI: "file:/etc/otel/exporter.yaml"}
+//	go func() { _ = w.Watch(ctx, spanExporter, logExporter) }()
+func (w ConfigWatcher) Watch(ctx context.Context, exps ...any) error {
+	sups, err := supervisorsOf(exps)
+	if err != nil {
+		return err
+	}
+	cfg, err := ConfigFromURI(ctx, w.Factory, w.URI, w.Providers...)
+	if err != nil {
+		return err
+	}
+
+	interval := w.Interval
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	clock := clockOrSystem(w.Clock)
+	for {
+		if err == nil {
+			err = applyConfig(ctx, cfg, sups)
+		}
+		if err != nil && w.ErrorFunc != nil && ctx.Err() == nil {
+			w.ErrorFunc(err)
+		}
+
+		t := clock.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C():
+		}
+		cfg, err = ConfigFromURI(ctx, w.Factory, w.URI, w.Providers...)
+	}
+}
+
+// supervisorsOf returns the supervisors of exps, exporters returned by a
+// Factory.
+func supervisorsOf(exps []any) ([]*supervisor, error) {
+	sups := make([]*supervisor, len(exps))
+	for i, exp := range exps {
+		if sups[i] = supervisorOf(exp); sups[i] == nil {
+			return nil, errNotSwappable
+		}
+	}
+	return sups, nil
+}
+
+// applyConfig swaps the exporters of sups that are not configured with cfg to
+// it. Exporters that fail to be swapped keep their configuration.
+func applyConfig(ctx context.Context, cfg component.Config, sups []*supervisor) error {
+	var errs []error
+	for _, sup := range sups {
+		if reflect.DeepEqual(cfg, sup.config()) {
+			continue
+		}
+		errs = append(errs, sup.swap(ctx, cfg))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,170 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/MrAlias/collex"
+	"github.com/MrAlias/collex/collextest"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// writeFile replaces the file at path with data in one step, so it is never
+// read partially written.
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfigWatcher(t *testing.T) {
+	var e endpoints
+	f, err := collex.NewFactory(e.factory(), settings())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "exporter.yaml")
+	writeFile(t, path, "endpoint: old")
+	ctx := context.Background()
+	cfg, err := collex.ConfigFromURI(ctx, e.factory(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp, err := f.SpanExporter(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errCh := make(chan error, 1)
+	w := collex.ConfigWatcher{
+		Factory:  e.factory(),
+		URI:      "file:" + path,
+		Interval: time.Millisecond,
+		ErrorFunc: func(err error) {
+			select {
+			case errCh <- err:
+			default:
+			}
+		},
+	}
+	watchCtx, cancel := context.WithCancel(ctx)
+	done := make(chan error)
+	go func() { done <- w.Watch(watchCtx, exp) }()
+
+	// A configuration the exporter fails to start with is reported and
+	// not applied.
+	writeFile(t, path, "endpoint: down")
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, errBroken) {
+			t.Errorf("reload error = %v, want %v", err, errBroken)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("reload error not reported")
+	}
+
+	writeFile(t, path, "endpoint: new")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		e.mu.Lock()
+		reloaded := slices.Contains(e.stopped, "old")
+		e.mu.Unlock()
+		if reloaded {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("exporter not reloaded")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Watch error = %v, want %v", err, context.Canceled)
+	}
+
+	spans := tracetest.SpanStubs{{Name: "a", Resource: resource.Empty()}}.Snapshots()
+	if err := exp.ExportSpans(ctx, spans); err != nil {
+		t.Fatal(err)
+	}
+	if err := exp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := e.spans["new"]; got != 1 {
+		t.Errorf("new endpoint exported %d spans, want 1", got)
+	}
+}
+
+func TestConfigWatcherClock(t *testing.T) {
+	var e endpoints
+	f, err := collex.NewFactory(e.factory(), settings())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "exporter.yaml")
+	writeFile(t, path, "endpoint: old")
+	ctx := context.Background()
+	exp, err := f.SpanExporter(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = exp.Shutdown(ctx) }()
+
+	clock := collextest.NewFakeClock(time.Now())
+	w := collex.ConfigWatcher{Factory: e.factory(), URI: "file:" + path, Interval: time.Hour, Clock: clock}
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() { _ = w.Watch(watchCtx, exp) }()
+
+	// The first check is done once Watch waits for the interval to pass.
+	clock.BlockUntil(1)
+	writeFile(t, path, "endpoint: new")
+	e.mu.Lock()
+	stopped := slices.Clone(e.stopped)
+	e.mu.Unlock()
+	if !slices.Equal(stopped, []string{"default"}) {
+		t.Fatalf("stopped endpoints %v before the interval passed, want [default]", stopped)
+	}
+
+	clock.Advance(time.Hour)
+	clock.BlockUntil(1)
+	e.mu.Lock()
+	stopped = slices.Clone(e.stopped)
+	e.mu.Unlock()
+	if !slices.Equal(stopped, []string{"default", "old"}) {
+		t.Errorf("stopped endpoints %v after the interval passed, want [default old]", stopped)
+	}
+}
+
+func TestConfigWatcherNotSwappable(t *testing.T) {
+	w := collex.ConfigWatcher{Factory: new(endpoints).factory(), URI: "yaml:endpoint: a"}
+	if err := w.Watch(context.Background(), struct{}{}); err == nil {
+		t.Error("expected an error watching an exporter not returned by a Factory")
+	}
+}
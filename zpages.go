@@ -0,0 +1,75 @@
+// Copyright 2022 Tyler Yahn (MrAlias)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collex
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"go.opentelemetry.io/collector/component"
+)
+
+// RegisterZPages registers the pipelinez and extensionz pages of the host
+// with mux. It is called by the zpages extension when it is started so the
+// components run in-process are able to be inspected the same way as in a
+// collector.
+func (h *host) RegisterZPages(mux *http.ServeMux, pathPrefix string) {
+	mux.HandleFunc(path.Join(pathPrefix, "pipelinez"), h.pipelinez)
+	mux.HandleFunc(path.Join(pathPrefix, "extensionz"), h.extensionz)
+}
+
+func (h *host) pipelinez(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	h.mu.Lock()
+	pipes := make([]*pipeNode, len(h.pipes))
+	copy(pipes, h.pipes)
+	h.mu.Unlock()
+	sort.Slice(pipes, func(i, j int) bool { return pipes[i].id.String() < pipes[j].id.String() })
+	for _, p := range pipes {
+		var procs, exps []component.ID
+		for _, n := range p.procs {
+			procs = append(procs, n.id)
+		}
+		for _, n := range p.exps {
+			exps = append(exps, n.id)
+		}
+		for _, n := range p.conns {
+			exps = append(exps, n.id)
+		}
+		fmt.Fprintf(w, "%s\n  processors: %s\n  exporters: %s\n", p.id, joinIDs(procs), joinIDs(exps))
+	}
+}
+
+func (h *host) extensionz(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	h.mu.Lock()
+	ids := make([]component.ID, len(h.ids))
+	copy(ids, h.ids)
+	h.mu.Unlock()
+	for _, id := range ids {
+		fmt.Fprintln(w, id)
+	}
+}
+
+func joinIDs(ids []component.ID) string {
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = id.String()
+	}
+	return "[" + strings.Join(s, ", ") + "]"
+}